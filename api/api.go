@@ -0,0 +1,445 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pointer2null/weather/calibration"
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+	logger "github.com/sirupsen/logrus"
+)
+
+// read only http api over the observation archive
+
+const (
+	defaultPeriod = time.Hour * 24 * 7
+	defaultLimit  = 1000
+	maxLimit      = 10000
+)
+
+// resolutions accepted by /api/v1/observations, passed to date_trunc
+var resolutions = map[string]bool{
+	"minute": true,
+	"hour":   true,
+	"day":    true,
+	"week":   true,
+	"month":  true,
+}
+
+type Server struct {
+	db       postgres.Querier
+	station  string
+	location *time.Location
+	noaa     *climate.NOAAReporter
+	records  *climate.RecordTracker
+	// history, when set, answers the observations endpoint in place of db
+	history    atomic.Pointer[postgres.Querier]
+	wind       WindExporter
+	cal        calibration.Set
+	rain       *climate.RainEvents
+	stream     http.Handler
+	chartCache chartCache
+}
+
+func New(db postgres.Querier, station string, location *time.Location, records *climate.RecordTracker) *Server {
+	return &Server{
+		db:       db,
+		station:  station,
+		location: location,
+		noaa:     climate.NewNOAAReporter(db, station, location),
+		records:  records,
+	}
+}
+
+// SetHistory sends observation queries to q, e.g. a database with years of
+// history or rollups. It may be called while the server is running.
+func (s *Server) SetHistory(q postgres.Querier) {
+	s.history.Store(&q)
+}
+
+func (s *Server) historyDB() postgres.Querier {
+	if q := s.history.Load(); q != nil {
+		return *q
+	}
+	return s.db
+}
+
+// SetWindArchive enables the raw wind export. Call it before Register.
+func (s *Server) SetWindArchive(w WindExporter) {
+	s.wind = w
+}
+
+// SetCalibration sets the calibration for wind speeds from the raw samples.
+// Call it before Register.
+func (s *Server) SetCalibration(set calibration.Set) {
+	s.cal = set
+}
+
+// SetRainEvents enables the current rain event. Call it before Register.
+func (s *Server) SetRainEvents(re *climate.RainEvents) {
+	s.rain = re
+}
+
+// SetStream enables the live stream. Call it before Register.
+func (s *Server) SetStream(h http.Handler) {
+	s.stream = h
+}
+
+// Register adds the api endpoints to mux
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/observations", s.observations)
+	mux.HandleFunc("/api/v1/daily", s.daily)
+	mux.HandleFunc("/api/v1/extremes", s.extremes)
+	mux.HandleFunc("/reports/noaa/", s.noaaReport)
+	mux.HandleFunc("/api/v1/records", s.stationRecords)
+	mux.HandleFunc("/api/v1/export", s.exportData)
+	mux.HandleFunc("/charts/", s.chart)
+	mux.HandleFunc("/api/v1/windrose", s.windRoseStats)
+	mux.HandleFunc("/api/v1/rain/events", s.rainEvents)
+	if s.rain != nil {
+		mux.HandleFunc("/api/v1/rain/current", s.currentRain)
+	}
+	if s.wind != nil {
+		mux.HandleFunc("/api/v1/wind/raw", s.windRaw)
+	}
+	if s.stream != nil {
+		mux.Handle("/api/v1/stream", s.stream)
+	}
+}
+
+type page struct {
+	Limit      int32  `json:"limit"`
+	Offset     int32  `json:"offset"`
+	NextOffset *int32 `json:"next_offset,omitempty"`
+}
+
+type observation struct {
+	Time           time.Time `json:"time"`
+	Samples        int64     `json:"samples"`
+	Temperature    *float64  `json:"temperature_C"`
+	TemperatureMin *float64  `json:"temperature_min_C"`
+	TemperatureMax *float64  `json:"temperature_max_C"`
+	Pressure       *float64  `json:"pressure_hPa"`
+	Mslp           *float64  `json:"mslp_hPa"`
+	Humidity       *float64  `json:"humidity_RH"`
+	DewPoint       *float64  `json:"dew_point_C"`
+	Rain           float64   `json:"rain_mm"`
+	RainRateMax    *float64  `json:"rain_rate_max_mm_hr"`
+	WindSpeed      *float64  `json:"wind_speed_mph"`
+	WindGust       *float64  `json:"wind_gust_mph"`
+	WindDirection  *float64  `json:"wind_dir"`
+}
+
+type observationsResponse struct {
+	Station      string        `json:"station"`
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"`
+	Resolution   string        `json:"resolution"`
+	Page         page          `json:"page"`
+	Observations []observation `json:"observations"`
+}
+
+func (s *Server) observations(rw http.ResponseWriter, r *http.Request) {
+	q, err := s.parseQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resolution := r.URL.Query().Get("resolution")
+	if resolution == "" {
+		resolution = "hour"
+	}
+	if !resolutions[resolution] {
+		http.Error(rw, fmt.Sprintf("invalid resolution [%v]", resolution), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := s.historyDB().GetObservations(r.Context(), postgres.GetObservationsParams{
+		Resolution: resolution,
+		StationID:  q.station,
+		FromTime:   q.from,
+		ToTime:     q.to,
+		RowLimit:   q.limit,
+		RowOffset:  q.offset,
+	})
+	if err != nil {
+		serverError(rw, err)
+		return
+	}
+
+	resp := observationsResponse{
+		Station:      q.station,
+		From:         q.from,
+		To:           q.to,
+		Resolution:   resolution,
+		Page:         q.page(len(rows)),
+		Observations: make([]observation, 0, len(rows)),
+	}
+	for _, row := range rows {
+		resp.Observations = append(resp.Observations, observation{
+			Time:           row.Bucket.UTC(),
+			Samples:        row.Samples,
+			Temperature:    nullable(row.Temperature),
+			TemperatureMin: nullable(row.TemperatureMin),
+			TemperatureMax: nullable(row.TemperatureMax),
+			Pressure:       nullable(row.Pressure),
+			Mslp:           nullable(row.Mslp),
+			Humidity:       nullable(row.Humidity),
+			DewPoint:       nullable(row.DewPoint),
+			Rain:           row.RainMm,
+			RainRateMax:    nullable(row.RainRateMax),
+			WindSpeed:      nullable(row.WindSpeed),
+			WindGust:       nullable(row.WindGust),
+			WindDirection:  nullable(row.WindDirection),
+		})
+	}
+	writeJSON(rw, resp)
+}
+
+type daySummary struct {
+	Day             string   `json:"day"`
+	Samples         int64    `json:"samples"`
+	TemperatureMin  *float64 `json:"temperature_min_C"`
+	TemperatureMax  *float64 `json:"temperature_max_C"`
+	TemperatureMean *float64 `json:"temperature_mean_C"`
+	Rain            float64  `json:"rain_mm"`
+	WindGustMax     *float64 `json:"wind_gust_max_mph"`
+	WindSpeedMean   *float64 `json:"wind_speed_mean_mph"`
+	PressureMin     *float64 `json:"pressure_min_hPa"`
+	PressureMax     *float64 `json:"pressure_max_hPa"`
+}
+
+type dailyResponse struct {
+	Station string       `json:"station"`
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	Page    page         `json:"page"`
+	Days    []daySummary `json:"days"`
+}
+
+func (s *Server) daily(rw http.ResponseWriter, r *http.Request) {
+	q, err := s.parseQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := s.db.GetDailySummaries(r.Context(), postgres.GetDailySummariesParams{
+		TimeZone:  s.location.String(),
+		StationID: q.station,
+		FromTime:  q.from,
+		ToTime:    q.to,
+		RowLimit:  q.limit,
+		RowOffset: q.offset,
+	})
+	if err != nil {
+		serverError(rw, err)
+		return
+	}
+
+	resp := dailyResponse{
+		Station: q.station,
+		From:    q.from,
+		To:      q.to,
+		Page:    q.page(len(rows)),
+		Days:    make([]daySummary, 0, len(rows)),
+	}
+	for _, row := range rows {
+		resp.Days = append(resp.Days, daySummary{
+			Day:             row.Day.Format("2006-01-02"),
+			Samples:         row.Samples,
+			TemperatureMin:  nullable(row.TemperatureMin),
+			TemperatureMax:  nullable(row.TemperatureMax),
+			TemperatureMean: nullable(row.TemperatureMean),
+			Rain:            row.RainMm,
+			WindGustMax:     nullable(row.WindGustMax),
+			WindSpeedMean:   nullable(row.WindSpeedMean),
+			PressureMin:     nullable(row.PressureMin),
+			PressureMax:     nullable(row.PressureMax),
+		})
+	}
+	writeJSON(rw, resp)
+}
+
+type extreme struct {
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+type extremesResponse struct {
+	Station        string    `json:"station"`
+	Period         string    `json:"period"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Samples        int64     `json:"samples"`
+	TemperatureMax *extreme  `json:"temperature_max_C"`
+	TemperatureMin *extreme  `json:"temperature_min_C"`
+	WindGustMax    *extreme  `json:"wind_gust_max_mph"`
+	RainRateMax    *extreme  `json:"rain_rate_max_mm_hr"`
+	PressureMax    *extreme  `json:"pressure_max_hPa"`
+	PressureMin    *extreme  `json:"pressure_min_hPa"`
+	MslpMax        *extreme  `json:"mslp_max_hPa"`
+	MslpMin        *extreme  `json:"mslp_min_hPa"`
+	RainTotal      float64   `json:"rain_total_mm"`
+}
+
+func (s *Server) extremes(rw http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "day"
+	}
+	to := time.Now().In(s.location)
+	from, err := periodStart(period, to)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	station := s.stationParam(r)
+
+	row, err := s.db.GetExtremes(r.Context(), postgres.GetExtremesParams{
+		StationID: station,
+		FromTime:  from,
+		ToTime:    to,
+	})
+	if err != nil {
+		serverError(rw, err)
+		return
+	}
+
+	writeJSON(rw, extremesResponse{
+		Station:        station,
+		Period:         period,
+		From:           from.UTC(),
+		To:             to.UTC(),
+		Samples:        row.Samples,
+		TemperatureMax: toExtreme(row.TemperatureMax, row.TemperatureMaxAt),
+		TemperatureMin: toExtreme(row.TemperatureMin, row.TemperatureMinAt),
+		WindGustMax:    toExtreme(row.WindGustMax, row.WindGustMaxAt),
+		RainRateMax:    toExtreme(row.RainRateMax, row.RainRateMaxAt),
+		PressureMax:    toExtreme(row.PressureMax, row.PressureMaxAt),
+		PressureMin:    toExtreme(row.PressureMin, row.PressureMinAt),
+		MslpMax:        toExtreme(row.MslpMax, row.MslpMaxAt),
+		MslpMin:        toExtreme(row.MslpMin, row.MslpMinAt),
+		RainTotal:      row.RainTotal,
+	})
+}
+
+// periodStart returns the start of the calendar period containing now. "all"
+// reaches back to the unix epoch.
+func periodStart(period string, now time.Time) (time.Time, error) {
+	y, m, d := now.Date()
+	switch period {
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "week":
+		// weeks start on monday
+		offset := (int(now.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, now.Location()), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, now.Location()), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, now.Location()), nil
+	case "all":
+		return time.Unix(0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid period [%v]", period)
+}
+
+type query struct {
+	station string
+	from    time.Time
+	to      time.Time
+	limit   int32
+	offset  int32
+}
+
+func (s *Server) parseQuery(r *http.Request) (query, error) {
+	v := r.URL.Query()
+	q := query{
+		station: s.stationParam(r),
+		to:      time.Now().UTC(),
+		limit:   defaultLimit,
+	}
+	var err error
+	if to := v.Get("to"); to != "" {
+		if q.to, err = climate.ParseTime(to, s.location); err != nil {
+			return q, fmt.Errorf("invalid to [%v]", to)
+		}
+	}
+	q.from = q.to.Add(-defaultPeriod)
+	if from := v.Get("from"); from != "" {
+		if q.from, err = climate.ParseTime(from, s.location); err != nil {
+			return q, fmt.Errorf("invalid from [%v]", from)
+		}
+	}
+	if !q.from.Before(q.to) {
+		return q, fmt.Errorf("from must be before to")
+	}
+	if limit := v.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > maxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		q.limit = int32(l)
+	}
+	if offset := v.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil || o < 0 {
+			return q, fmt.Errorf("invalid offset [%v]", offset)
+		}
+		q.offset = int32(o)
+	}
+	return q, nil
+}
+
+// page reports where the next page starts when this one came back full
+func (q query) page(rows int) page {
+	p := page{Limit: q.limit, Offset: q.offset}
+	if int32(rows) == q.limit {
+		next := q.offset + q.limit
+		p.NextOffset = &next
+	}
+	return p
+}
+
+func (s *Server) stationParam(r *http.Request) string {
+	if station := r.URL.Query().Get("station"); station != "" {
+		return station
+	}
+	return s.station
+}
+
+func nullable(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func toExtreme(v sql.NullFloat64, at sql.NullTime) *extreme {
+	if !v.Valid || !at.Valid {
+		return nil
+	}
+	return &extreme{Value: v.Float64, Time: at.Time.UTC()}
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		serverError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = rw.Write(js) // not much we can do if this fails
+}
+
+func serverError(rw http.ResponseWriter, err error) {
+	logger.Errorf("API error [%v]", err)
+	http.Error(rw, "internal error", http.StatusInternalServerError)
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/windarchive"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeQuerier struct {
+	postgres.Querier
+	obsArgs postgres.GetObservationsParams
+	obsRows []postgres.GetObservationsRow
+	events  []postgres.RainEvent
+}
+
+func (f *fakeQuerier) GetRainEvents(ctx context.Context, arg postgres.GetRainEventsParams) ([]postgres.RainEvent, error) {
+	return f.events, nil
+}
+
+func (f *fakeQuerier) UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error {
+	return nil
+}
+
+func (f *fakeQuerier) GetObservations(ctx context.Context, arg postgres.GetObservationsParams) ([]postgres.GetObservationsRow, error) {
+	f.obsArgs = arg
+	return f.obsRows, nil
+}
+
+func valid(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: true}
+}
+
+func TestObservations(t *testing.T) {
+	f := &fakeQuerier{obsRows: []postgres.GetObservationsRow{
+		{Bucket: time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC), Samples: 6, Temperature: valid(12.5)},
+		{Bucket: time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC), Samples: 6, Temperature: valid(13.5)},
+	}}
+	mux := http.NewServeMux()
+	New(f, "home", time.UTC, nil).Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/observations?from=2026-10-01&to=2026-10-02&resolution=hour&limit=2", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.Equal(t, "hour", f.obsArgs.Resolution)
+	require.Equal(t, "home", f.obsArgs.StationID)
+	require.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), f.obsArgs.FromTime)
+	require.Equal(t, int32(2), f.obsArgs.RowLimit)
+
+	var resp observationsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Observations, 2)
+	require.Equal(t, 13.5, *resp.Observations[1].Temperature)
+	require.Nil(t, resp.Observations[0].Humidity)
+	// a full page means there may be more
+	require.NotNil(t, resp.Page.NextOffset)
+	require.Equal(t, int32(2), *resp.Page.NextOffset)
+}
+
+func TestObservationsRejectsBadParams(t *testing.T) {
+	mux := http.NewServeMux()
+	New(&fakeQuerier{}, "home", time.UTC, nil).Register(mux)
+
+	for _, q := range []string{
+		"resolution=second",
+		"from=yesterday",
+		"from=2026-10-02&to=2026-10-01",
+		"limit=0",
+		"offset=-1",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/observations?"+q, nil))
+		require.Equal(t, http.StatusBadRequest, rec.Code, q)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	// a thursday
+	now := time.Date(2026, 10, 15, 14, 30, 0, 0, time.UTC)
+
+	for period, want := range map[string]time.Time{
+		"day":   time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+		"week":  time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		"month": time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		"year":  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		got, err := periodStart(period, now)
+		require.NoError(t, err)
+		require.Equal(t, want, got, period)
+	}
+
+	_, err := periodStart("decade", now)
+	require.Error(t, err)
+}
+
+type fakeWind struct {
+	from, to time.Time
+}
+
+func (f *fakeWind) Export(ctx context.Context, from time.Time, to time.Time, fn func(windarchive.Sample) error) error {
+	f.from, f.to = from, to
+	return fn(windarchive.Sample{Time: from, Pulses: 3, Direction: 112.5})
+}
+
+func TestWindRaw(t *testing.T) {
+	wind := &fakeWind{}
+	s := New(&fakeQuerier{}, "home", time.UTC, nil)
+	s.SetWindArchive(wind)
+	mux := http.NewServeMux()
+	s.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/wind/raw?to=2026-10-18T12:00:00Z", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC), wind.from)
+	require.Equal(t, "time,pulses,direction\n2026-10-18T11:00:00Z,3,112.5\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/wind/raw?from=2026-10-01&to=2026-10-18", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestExport(t *testing.T) {
+	f := &fakeQuerier{obsRows: []postgres.GetObservationsRow{
+		{Bucket: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Samples: 144, Temperature: valid(10)},
+	}}
+	mux := http.NewServeMux()
+	New(f, "home", time.UTC, nil).Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/export?from=2026-10-01&to=2026-10-02&resolution=day&format=jsonl&temp=F", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	require.Equal(t, "day", f.obsArgs.Resolution)
+	require.Contains(t, rec.Body.String(), `"temperature_F":50,`)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/export?format=xlsx", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCharts(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	f := &fakeQuerier{obsRows: []postgres.GetObservationsRow{
+		{Bucket: at.Add(-20 * time.Minute), Samples: 1, Temperature: valid(10), WindSpeed: valid(6), WindDirection: valid(90)},
+		{Bucket: at.Add(-10 * time.Minute), Samples: 1, Temperature: valid(11), WindSpeed: valid(0.5), WindDirection: valid(180)},
+	}}
+	mux := http.NewServeMux()
+	New(f, "home", time.UTC, nil).Register(mux)
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		if len(header) == 2 {
+			r.Header.Set(header[0], header[1])
+		}
+		mux.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := get("/charts/temperature.svg?period=24h&to=2026-10-18T12:00:00Z&temp=F")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/svg+xml", rec.Header().Get("Content-Type"))
+	require.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+	require.Equal(t, "minute", f.obsArgs.Resolution)
+	require.Equal(t, at.Add(-24*time.Hour), f.obsArgs.FromTime)
+	require.Contains(t, rec.Body.String(), ">°F</text>")
+	etag := rec.Header().Get("ETag")
+
+	// the second request comes from the cache
+	f.obsArgs = postgres.GetObservationsParams{}
+	rec = get("/charts/temperature.svg?to=2026-10-18T12:00:00Z&temp=F&period=24h")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, f.obsArgs.Resolution)
+	require.Equal(t, http.StatusNotModified, get("/charts/temperature.svg?to=2026-10-18T12:00:00Z&temp=F&period=24h", "If-None-Match", etag).Code)
+
+	rec = get("/charts/windrose.png?period=60d&wind=kmh")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	require.Equal(t, "hour", f.obsArgs.Resolution)
+
+	require.Equal(t, http.StatusNotFound, get("/charts/sunshine.svg").Code)
+	for _, bad := range []string{"/charts/rain.gif", "/charts/rain.svg?period=10m", "/charts/rain.svg?period=7x", "/charts/rain.svg?width=10", "/charts/rain.svg?rain=furlongs"} {
+		require.Equal(t, http.StatusBadRequest, get(bad).Code, bad)
+	}
+}
+
+func TestWindRose(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	f := &fakeQuerier{obsRows: []postgres.GetObservationsRow{
+		{Bucket: at.Add(-30 * time.Minute), Samples: 2, WindSpeed: valid(6), WindDirection: valid(92)},
+		{Bucket: at.Add(-20 * time.Minute), Samples: 1, WindSpeed: valid(30), WindDirection: valid(355)},
+		{Bucket: at.Add(-10 * time.Minute), Samples: 1, WindSpeed: valid(0.4), WindDirection: valid(180)},
+	}}
+	mux := http.NewServeMux()
+	New(f, "home", time.UTC, nil).Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/windrose?period=1d&to=2026-10-18T12:00:00Z", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "minute", f.obsArgs.Resolution)
+	var resp windRoseResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "observations", resp.Source)
+	require.Equal(t, 0.67, resp.Hours)
+	require.Equal(t, 25.0, resp.CalmPercent)
+	require.Equal(t, "E", resp.Prevailing.Compass)
+	require.Equal(t, 50.0, resp.Prevailing.Percent)
+	require.Equal(t, []float64{0, 50, 0, 0, 0, 0}, resp.Sectors[4].Bands)
+	require.Equal(t, []float64{0, 0, 0, 0, 0, 25}, resp.Sectors[0].Bands)
+	require.Equal(t, "1-4 mph", resp.Bands[0].Name)
+	require.Nil(t, resp.Bands[5].To)
+
+	// the raw samples are used when they are archived
+	s := New(f, "home", time.UTC, nil)
+	s.SetWindArchive(&fakeWind{})
+	mux = http.NewServeMux()
+	s.Register(mux)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/windrose?period=1h&wind=knots", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "raw", resp.Source)
+	require.Equal(t, "kn", resp.Unit)
+	require.Equal(t, "ESE", resp.Prevailing.Compass)
+}
+
+func TestRainEvents(t *testing.T) {
+	start := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	f := &fakeQuerier{events: []postgres.RainEvent{
+		{StartAt: start, EndAt: start.Add(time.Hour), TotalMm: 5.3, Peak5min: 20.1},
+	}}
+	events := climate.NewRainEvents(f, "home", 30*time.Minute)
+	server := New(f, "home", time.UTC, nil)
+	server.SetRainEvents(events)
+	mux := http.NewServeMux()
+	server.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/rain/events?from=2026-10-01&to=2026-10-02", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Events []struct {
+			Start     time.Time `json:"start"`
+			Total     float64   `json:"total_mm"`
+			Duration  float64   `json:"duration_s"`
+			DryBefore *float64  `json:"dry_before_s"`
+		} `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Events, 1)
+	require.Equal(t, start, resp.Events[0].Start)
+	require.Equal(t, 3600.0, resp.Events[0].Duration)
+	require.Nil(t, resp.Events[0].DryBefore)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/rain/current", nil))
+	require.JSONEq(t, `{"station": "home", "raining": false, "event": null}`, rec.Body.String())
+
+	events.Tip(time.Now(), 0.2794)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/rain/current", nil))
+	var current currentRainResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &current))
+	require.True(t, current.Raining)
+	require.Equal(t, 0.2794, current.Event.Total)
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pointer2null/weather/charts"
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/export"
+	"github.com/pointer2null/weather/windrose"
+)
+
+const (
+	maxChartPeriod = time.Hour * 24 * 366 * 5
+	// rendered charts kept, see chartCache
+	chartCacheEntries = 64
+)
+
+var unitLabels = map[string]string{
+	"C": "°C", "F": "°F",
+	"hPa": "hPa", "inHg": "inHg",
+	"mph": "mph", "kmh": "km/h", "ms": "m/s", "knots": "kn",
+	"mm": "mm", "in": "in",
+}
+
+// chartQuery is a parsed chart request
+type chartQuery struct {
+	name   string
+	format string
+	period string
+	from   time.Time
+	to     time.Time
+	units  export.Units
+	opts   charts.Options
+}
+
+// chart serves /charts/<name>.<svg|png> where name is temperature,
+// humidity, pressure, wind, rain or windrose, over the period (e.g. 24h, 7d,
+// 1y) ending at to, which defaults to now. Units are chosen as for export
+// and the size with width and height; theme=dark suits dark pages.
+func (s *Server) chart(rw http.ResponseWriter, r *http.Request) {
+	q, err := s.parseChartQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := chartData[q.name]; !ok && q.name != "windrose" {
+		http.NotFound(rw, r)
+		return
+	}
+
+	s.cached(rw, r, charts.Formats[q.format], chartTTL(q.to.Sub(q.from)), func(buf *bytes.Buffer) error {
+		return s.renderChart(r, q, buf)
+	})
+}
+
+// cached serves the response render writes from the cache, rendering it
+// again once it is older than ttl
+func (s *Server) cached(rw http.ResponseWriter, r *http.Request, contentType string, ttl time.Duration, render func(*bytes.Buffer) error) {
+	key := r.URL.Path + "?" + r.URL.Query().Encode()
+	c, ok := s.chartCache.get(key)
+	if !ok {
+		var buf bytes.Buffer
+		if err := render(&buf); err != nil {
+			serverError(rw, err)
+			return
+		}
+		c = s.chartCache.put(key, buf.Bytes(), ttl)
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(math.Round(time.Until(c.expires).Seconds()))))
+	rw.Header().Set("ETag", c.etag)
+	if r.Header.Get("If-None-Match") == c.etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	_, _ = rw.Write(c.body) // not much we can do if this fails
+}
+
+func (s *Server) parseChartQuery(r *http.Request) (chartQuery, error) {
+	name := strings.TrimPrefix(r.URL.Path, "/charts/")
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 0 {
+		return chartQuery{}, fmt.Errorf("chart must be named like temperature.svg")
+	}
+	v := r.URL.Query()
+	param := func(name string, def string) string {
+		if p := v.Get(name); p != "" {
+			return p
+		}
+		return def
+	}
+
+	q := chartQuery{
+		name:   name[:dot],
+		format: name[dot+1:],
+		period: param("period", "24h"),
+		to:     time.Now().UTC(),
+		units: export.Units{
+			Temperature: param("temp", export.DefaultUnits.Temperature),
+			Pressure:    param("pressure", export.DefaultUnits.Pressure),
+			Wind:        param("wind", export.DefaultUnits.Wind),
+			Rain:        param("rain", export.DefaultUnits.Rain),
+		},
+		opts: charts.Options{Width: 800, Height: 300, Dark: v.Get("theme") == "dark"},
+	}
+	if q.name == "windrose" {
+		q.period = param("period", "7d")
+		q.opts.Width, q.opts.Height = 520, 400
+	}
+	if _, ok := charts.Formats[q.format]; !ok {
+		return q, fmt.Errorf("format must be svg or png, not [%v]", q.format)
+	}
+	period, err := parsePeriod(q.period)
+	if err != nil {
+		return q, err
+	}
+	if to := v.Get("to"); to != "" {
+		if q.to, err = climate.ParseTime(to, s.location); err != nil {
+			return q, fmt.Errorf("invalid to [%v]", to)
+		}
+	}
+	q.from = q.to.Add(-period)
+	if err := q.units.Validate(); err != nil {
+		return q, err
+	}
+	for _, dim := range []struct {
+		name string
+		v    *int
+	}{{"width", &q.opts.Width}, {"height", &q.opts.Height}} {
+		if p := v.Get(dim.name); p != "" {
+			if *dim.v, err = strconv.Atoi(p); err != nil {
+				return q, fmt.Errorf("invalid %v [%v]", dim.name, p)
+			}
+		}
+	}
+	return q, q.opts.Validate()
+}
+
+// parsePeriod reads a duration, also allowing days, weeks and years as d, w
+// and y, e.g. 7d
+func parsePeriod(v string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': time.Hour * 24, 'w': time.Hour * 24 * 7, 'y': time.Hour * 24 * 365}
+	if v == "" {
+		return 0, fmt.Errorf("period is empty")
+	}
+	var d time.Duration
+	var err error
+	if u, ok := units[v[len(v)-1]]; ok {
+		var n int
+		n, err = strconv.Atoi(v[:len(v)-1])
+		d = time.Duration(n) * u
+	} else {
+		d, err = time.ParseDuration(v)
+	}
+	if err != nil || d < time.Hour || d > maxChartPeriod {
+		return 0, fmt.Errorf("period must be between 1h and 5y, not [%v]", v)
+	}
+	return d, nil
+}
+
+// chartResolution picks a bucket size giving a few hundred points at most
+func chartResolution(period time.Duration) (string, time.Duration) {
+	switch {
+	case period <= time.Hour*24*3:
+		// the station reports every few minutes, so minutes are the raw data
+		return "minute", time.Minute * env.ReportFreqMin
+	case period <= time.Hour*24*31:
+		return "hour", time.Hour
+	case period <= time.Hour*24*366*2:
+		return "day", time.Hour * 24
+	}
+	return "week", time.Hour * 24 * 7
+}
+
+// chartTTL is how long a chart is served from the cache: about one
+// observation's worth of the chart, between a minute and an hour
+func chartTTL(period time.Duration) time.Duration {
+	ttl := period / 288
+	if ttl < time.Minute {
+		return time.Minute
+	}
+	if ttl > time.Hour {
+		return time.Hour
+	}
+	return ttl.Round(time.Minute)
+}
+
+type chartSeries struct {
+	name  string
+	kind  charts.Kind
+	value func(postgres.GetObservationsRow) (float64, bool)
+}
+
+type chartSpec struct {
+	title string
+	// unit picks the unit and conversion for the chart from the chosen units
+	unit   func(u export.Units) (string, func(float64) float64)
+	zero   bool
+	series []chartSeries
+}
+
+func always(f func(postgres.GetObservationsRow) float64) func(postgres.GetObservationsRow) (float64, bool) {
+	return func(o postgres.GetObservationsRow) (float64, bool) { return f(o), true }
+}
+
+var (
+	temperatureUnit = func(u export.Units) (string, func(float64) float64) {
+		conv, _, _, _ := u.Converters()
+		return unitLabels[u.Temperature], conv
+	}
+	percentUnit = func(u export.Units) (string, func(float64) float64) {
+		return "%", func(v float64) float64 { return v }
+	}
+	pressureUnit = func(u export.Units) (string, func(float64) float64) {
+		_, conv, _, _ := u.Converters()
+		return unitLabels[u.Pressure], conv
+	}
+	windUnit = func(u export.Units) (string, func(float64) float64) {
+		_, _, conv, _ := u.Converters()
+		return unitLabels[u.Wind], conv
+	}
+	rainUnit = func(u export.Units) (string, func(float64) float64) {
+		_, _, _, conv := u.Converters()
+		return unitLabels[u.Rain], conv
+	}
+)
+
+// the time series charts
+var chartData = map[string]chartSpec{
+	"temperature": {
+		title: "Temperature",
+		unit:  temperatureUnit,
+		series: []chartSeries{
+			{name: "temperature", value: func(o postgres.GetObservationsRow) (float64, bool) { return o.Temperature.Float64, o.Temperature.Valid }},
+			{name: "dew point", value: func(o postgres.GetObservationsRow) (float64, bool) { return o.DewPoint.Float64, o.DewPoint.Valid }},
+		},
+	},
+	"humidity": {
+		title: "Humidity",
+		unit:  percentUnit,
+		series: []chartSeries{
+			{name: "humidity", value: func(o postgres.GetObservationsRow) (float64, bool) { return o.Humidity.Float64, o.Humidity.Valid }},
+		},
+	},
+	"pressure": {
+		title: "Pressure",
+		unit:  pressureUnit,
+		series: []chartSeries{
+			{name: "sea level", value: func(o postgres.GetObservationsRow) (float64, bool) {
+				if o.Mslp.Valid {
+					return o.Mslp.Float64, true
+				}
+				// older rows only have the station pressure
+				return o.Pressure.Float64, o.Pressure.Valid
+			}},
+		},
+	},
+	"wind": {
+		title: "Wind",
+		unit:  windUnit,
+		zero:  true,
+		series: []chartSeries{
+			{name: "average", value: func(o postgres.GetObservationsRow) (float64, bool) { return o.WindSpeed.Float64, o.WindSpeed.Valid }},
+			{name: "gust", value: func(o postgres.GetObservationsRow) (float64, bool) { return o.WindGust.Float64, o.WindGust.Valid }},
+		},
+	},
+	"rain": {
+		title: "Rain",
+		unit:  rainUnit,
+		zero:  true,
+		series: []chartSeries{
+			{name: "rain", kind: charts.Bars, value: always(func(o postgres.GetObservationsRow) float64 { return o.RainMm })},
+		},
+	},
+}
+
+func (s *Server) renderChart(r *http.Request, q chartQuery, buf *bytes.Buffer) error {
+	title := func(name string) string {
+		return fmt.Sprintf("%s, %s to %s", name, q.period, q.to.In(s.location).Format("2 Jan 2006 15:04"))
+	}
+
+	if q.name == "windrose" {
+		rose, _, err := s.windRose(r.Context(), s.stationParam(r), q.from, q.to)
+		if err != nil {
+			return err
+		}
+		_, _, conv, _ := q.units.Converters()
+		img := charts.Rose{Title: title("Wind rose"), Calm: rose.CalmPercent()}
+		if p, ok := rose.Prevailing(); ok {
+			img.Title += ", prevailing " + windrose.Points[p]
+		}
+		for b := range windrose.Bands {
+			img.Bands = append(img.Bands, windrose.BandName(b, conv, unitLabels[q.units.Wind]))
+		}
+		for sector := range img.Percent {
+			for b := range windrose.Bands {
+				img.Percent[sector] = append(img.Percent[sector], rose.Percent(sector, b))
+			}
+		}
+		return img.Render(buf, q.format, q.opts)
+	}
+
+	resolution, step := chartResolution(q.to.Sub(q.from))
+	rows, err := s.historyDB().GetObservations(r.Context(), postgres.GetObservationsParams{
+		Resolution: resolution,
+		StationID:  s.stationParam(r),
+		FromTime:   q.from,
+		ToTime:     q.to,
+		RowLimit:   maxLimit,
+	})
+	if err != nil {
+		return err
+	}
+	spec := chartData[q.name]
+	unit, conv := spec.unit(q.units)
+	c := charts.Chart{
+		Title:    title(spec.title),
+		Unit:     unit,
+		From:     q.from,
+		To:       q.to,
+		Step:     step,
+		Zero:     spec.zero,
+		Location: s.location,
+	}
+	for _, cs := range spec.series {
+		series := charts.Series{Name: cs.name, Kind: cs.kind}
+		for _, row := range rows {
+			if v, ok := cs.value(row); ok {
+				series.Points = append(series.Points, charts.Point{Time: row.Bucket, Value: conv(v)})
+			}
+		}
+		c.Series = append(c.Series, series)
+	}
+	return c.Render(buf, q.format, q.opts)
+}
+
+type cachedChart struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// chartCache holds rendered charts and wind roses so a page of them, or a
+// mailing list fetching the same few, doesn't render each one every time
+type chartCache struct {
+	lock    sync.Mutex
+	entries map[string]cachedChart
+}
+
+func (c *chartCache) get(key string) (cachedChart, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return cachedChart{}, false
+	}
+	return e, true
+}
+
+// put stores a chart, making room by dropping expired charts, or failing
+// that the one closest to expiring
+func (c *chartCache) put(key string, body []byte, ttl time.Duration) cachedChart {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	e := cachedChart{body: body, etag: fmt.Sprintf("\"%x\"", h.Sum64()), expires: time.Now().Add(ttl)}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedChart)
+	}
+	if len(c.entries) >= chartCacheEntries {
+		now := time.Now()
+		var soonest string
+		for k, v := range c.entries {
+			if now.After(v.expires) {
+				delete(c.entries, k)
+			} else if soonest == "" || v.expires.Before(c.entries[soonest].expires) {
+				soonest = k
+			}
+		}
+		if len(c.entries) >= chartCacheEntries {
+			delete(c.entries, soonest)
+		}
+	}
+	c.entries[key] = e
+	return e
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pointer2null/weather/export"
+	logger "github.com/sirupsen/logrus"
+)
+
+// exportData streams observations as a download, with the same options as
+// the export command: format, resolution and the temp, pressure, wind and
+// rain units.
+func (s *Server) exportData(rw http.ResponseWriter, r *http.Request) {
+	base, err := s.parseQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v := r.URL.Query()
+	param := func(name string, def string) string {
+		if p := v.Get(name); p != "" {
+			return p
+		}
+		return def
+	}
+	q := export.Query{
+		Station:    base.station,
+		From:       base.from,
+		To:         base.to,
+		Resolution: param("resolution", "raw"),
+		Format:     param("format", "csv"),
+		Units: export.Units{
+			Temperature: param("temp", export.DefaultUnits.Temperature),
+			Pressure:    param("pressure", export.DefaultUnits.Pressure),
+			Wind:        param("wind", export.DefaultUnits.Wind),
+			Rain:        param("rain", export.DefaultUnits.Rain),
+		},
+	}
+	if err := q.Validate(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rw.Header().Set("Content-Type", export.Formats[q.Format])
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s-%s.%s\"",
+		q.Station, q.From.In(s.location).Format("20060102"), q.To.In(s.location).Format("20060102"), q.Format))
+	if err := export.Write(r.Context(), s.historyDB(), q, rw); err != nil {
+		// the download has started, so all we can do is stop
+		logger.Errorf("Export failed [%v]", err)
+	}
+}
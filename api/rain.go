@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+)
+
+type rainEventsResponse struct {
+	Station string              `json:"station"`
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	Events  []climate.RainEvent `json:"events"`
+}
+
+// rainEvents serves /api/v1/rain/events, the events that started between
+// from and to
+func (s *Server) rainEvents(rw http.ResponseWriter, r *http.Request) {
+	q, err := s.parseQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := s.db.GetRainEvents(r.Context(), postgres.GetRainEventsParams{
+		StationID: q.station,
+		FromTime:  q.from,
+		ToTime:    q.to,
+	})
+	if err != nil {
+		serverError(rw, err)
+		return
+	}
+	resp := rainEventsResponse{
+		Station: q.station,
+		From:    q.from,
+		To:      q.to,
+		Events:  make([]climate.RainEvent, 0, len(rows)),
+	}
+	for _, row := range rows {
+		resp.Events = append(resp.Events, climate.RainEventFrom(row))
+	}
+	writeJSON(rw, resp)
+}
+
+type currentRainResponse struct {
+	Station string             `json:"station"`
+	Raining bool               `json:"raining"`
+	Event   *climate.RainEvent `json:"event"`
+}
+
+// currentRain serves /api/v1/rain/current, the event under way if it is
+// raining
+func (s *Server) currentRain(rw http.ResponseWriter, r *http.Request) {
+	resp := currentRainResponse{Station: s.station}
+	if e, ok := s.rain.Current(); ok {
+		resp.Raining, resp.Event = true, &e
+	}
+	writeJSON(rw, resp)
+}
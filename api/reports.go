@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pointer2null/weather/climate"
+)
+
+// noaaReport serves /reports/noaa/{year} and /reports/noaa/{year}/{month} as
+// fixed width text, or as JSON with ?format=json or an Accept header asking
+// for it.
+func (s *Server) noaaReport(rw http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/reports/noaa/"), "/"), "/")
+	if len(parts) < 1 || len(parts) > 2 {
+		http.NotFound(rw, r)
+		return
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil || year < 1900 || year > 9999 {
+		http.Error(rw, fmt.Sprintf("invalid year [%v]", parts[0]), http.StatusBadRequest)
+		return
+	}
+
+	var report interface {
+		Text() string
+	}
+	if len(parts) == 2 {
+		month, err := strconv.Atoi(parts[1])
+		if err != nil || month < 1 || month > 12 {
+			http.Error(rw, fmt.Sprintf("invalid month [%v]", parts[1]), http.StatusBadRequest)
+			return
+		}
+		m, err := s.noaa.Month(r.Context(), year, time.Month(month))
+		if err != nil {
+			serverError(rw, err)
+			return
+		}
+		report = m
+	} else {
+		y, err := s.noaa.Year(r.Context(), year)
+		if err != nil {
+			serverError(rw, err)
+			return
+		}
+		report = y
+	}
+
+	if wantsJSON(r) {
+		writeJSON(rw, report)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = rw.Write([]byte(report.Text()))
+}
+
+func wantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "json"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+type recordsResponse struct {
+	Station string           `json:"station"`
+	Records []climate.Record `json:"records"`
+}
+
+// stationRecords serves /api/v1/records, optionally filtered with
+// ?scope=all|year|month and ?period=YYYY or MM
+func (s *Server) stationRecords(rw http.ResponseWriter, r *http.Request) {
+	if s.records == nil {
+		http.Error(rw, "records are not available", http.StatusServiceUnavailable)
+		return
+	}
+	scope := r.URL.Query().Get("scope")
+	switch scope {
+	case "", climate.ScopeAll, climate.ScopeYear, climate.ScopeMonth:
+	default:
+		http.Error(rw, fmt.Sprintf("invalid scope [%v]", scope), http.StatusBadRequest)
+		return
+	}
+	records := s.records.Records(scope, r.URL.Query().Get("period"))
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		}
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		return a.Metric < b.Metric
+	})
+	if records == nil {
+		records = []climate.Record{}
+	}
+	writeJSON(rw, recordsResponse{Station: s.station, Records: records})
+}
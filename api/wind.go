@@ -0,0 +1,218 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/export"
+	"github.com/pointer2null/weather/windarchive"
+	"github.com/pointer2null/weather/windrose"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWindPeriod = time.Hour
+	// a day is about 350k samples
+	maxWindPeriod = time.Hour * 24
+)
+
+type WindExporter interface {
+	Export(ctx context.Context, from time.Time, to time.Time, f func(windarchive.Sample) error) error
+}
+
+// windRaw streams the raw wind samples in a time range as csv. The range
+// defaults to the last hour and is limited to a day.
+func (s *Server) windRaw(rw http.ResponseWriter, r *http.Request) {
+	q, err := s.parseQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("from") == "" {
+		q.from = q.to.Add(-defaultWindPeriod)
+	}
+	if q.to.Sub(q.from) > maxWindPeriod {
+		http.Error(rw, fmt.Sprintf("range is limited to %v", maxWindPeriod), http.StatusBadRequest)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/csv")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"wind-%s.csv\"", q.from.Format("20060102T150405Z")))
+	w := bufio.NewWriter(rw)
+	fmt.Fprintln(w, "time,pulses,direction")
+	err = s.wind.Export(r.Context(), q.from, q.to, func(sample windarchive.Sample) error {
+		_, err := fmt.Fprintf(w, "%s,%d,%g\n", sample.Time.Format(time.RFC3339Nano), sample.Pulses, sample.Direction)
+		return err
+	})
+	if err != nil {
+		// the header has gone, so all we can do is stop
+		logger.Errorf("Wind export failed [%v]", err)
+		return
+	}
+	_ = w.Flush()
+}
+
+// the raw samples are used for wind roses up to this long, beyond it the
+// observations are quicker
+const maxRawRosePeriod = time.Hour * 24 * 7
+
+// windRose counts the wind between from and to, from the raw samples when
+// they are archived, otherwise from the observations. It returns which it
+// used, "raw" or "observations".
+func (s *Server) windRose(ctx context.Context, station string, from time.Time, to time.Time) (*windrose.Rose, string, error) {
+	rose := windrose.New(from, to)
+	if s.wind != nil && station == s.station && to.Sub(from) <= maxRawRosePeriod {
+		avg := windrose.NewAverager(func(r windrose.Reading) {
+			rose.Add(r.Direction, s.cal.WindSpeed(r.Time, r.Speed), r.Seconds/60)
+		})
+		err := s.wind.Export(ctx, from, to, func(sample windarchive.Sample) error {
+			avg.Add(sample.Time, sample.Pulses, sample.Direction)
+			return nil
+		})
+		avg.Flush()
+		// an archive that was turned on recently won't cover the range
+		if err != nil || rose.Total > 0 {
+			return rose, "raw", err
+		}
+	}
+
+	resolution := "minute"
+	if to.Sub(from) > time.Hour*24*31 {
+		resolution = "hour"
+	}
+	for offset := int32(0); ; offset += maxLimit {
+		rows, err := s.historyDB().GetObservations(ctx, postgres.GetObservationsParams{
+			Resolution: resolution,
+			StationID:  station,
+			FromTime:   from,
+			ToTime:     to,
+			RowLimit:   maxLimit,
+			RowOffset:  offset,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, row := range rows {
+			if !row.WindDirection.Valid || !row.WindSpeed.Valid {
+				continue
+			}
+			// each observation is the mean of the minutes since the last
+			rose.Add(row.WindDirection.Float64, row.WindSpeed.Float64, float64(row.Samples)*env.ReportFreqMin)
+		}
+		if len(rows) < maxLimit {
+			return rose, "observations", nil
+		}
+	}
+}
+
+type roseBand struct {
+	Name string   `json:"name"`
+	From float64  `json:"from"`
+	To   *float64 `json:"to,omitempty"`
+}
+
+type roseSector struct {
+	Direction float64   `json:"direction"`
+	Compass   string    `json:"compass"`
+	Percent   float64   `json:"percent"`
+	Bands     []float64 `json:"bands_percent,omitempty"`
+}
+
+type windRoseResponse struct {
+	Station     string       `json:"station"`
+	Source      string       `json:"source"`
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	Hours       float64      `json:"hours"`
+	Unit        string       `json:"unit"`
+	Bands       []roseBand   `json:"bands"`
+	CalmPercent float64      `json:"calm_percent"`
+	Prevailing  *roseSector  `json:"prevailing"`
+	Sectors     []roseSector `json:"sectors"`
+}
+
+// round keeps percentages readable
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// windRoseStats serves the wind rose as JSON: for each compass point the
+// percentage of the time the wind blew from it, in total and by speed band,
+// with the calm percentage and prevailing direction. It takes period, to,
+// station and the wind unit as the windrose chart does.
+func (s *Server) windRoseStats(rw http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	period := v.Get("period")
+	if period == "" {
+		period = "7d"
+	}
+	d, err := parsePeriod(period)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := time.Now().UTC()
+	if p := v.Get("to"); p != "" {
+		if to, err = climate.ParseTime(p, s.location); err != nil {
+			http.Error(rw, fmt.Sprintf("invalid to [%v]", p), http.StatusBadRequest)
+			return
+		}
+	}
+	units := export.DefaultUnits
+	if p := v.Get("wind"); p != "" {
+		units.Wind = p
+	}
+	if err := units.Validate(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	station := s.stationParam(r)
+
+	s.cached(rw, r, "application/json", chartTTL(d), func(buf *bytes.Buffer) error {
+		rose, source, err := s.windRose(r.Context(), station, to.Add(-d), to)
+		if err != nil {
+			return err
+		}
+		_, _, conv, _ := units.Converters()
+		unit := unitLabels[units.Wind]
+		resp := windRoseResponse{
+			Station:     station,
+			Source:      source,
+			From:        rose.From,
+			To:          rose.To,
+			Hours:       round(rose.Total / 60),
+			Unit:        unit,
+			CalmPercent: round(rose.CalmPercent()),
+		}
+		for b, lo := range windrose.Bands {
+			band := roseBand{Name: windrose.BandName(b, conv, unit), From: round(conv(lo))}
+			if b < len(windrose.Bands)-1 {
+				hi := round(conv(windrose.Bands[b+1]))
+				band.To = &hi
+			}
+			resp.Bands = append(resp.Bands, band)
+		}
+		for sector, name := range windrose.Points {
+			rs := roseSector{Direction: float64(sector) * 360 / windrose.Sectors, Compass: name, Percent: round(rose.SectorPercent(sector))}
+			for b := range windrose.Bands {
+				rs.Bands = append(rs.Bands, round(rose.Percent(sector, b)))
+			}
+			resp.Sectors = append(resp.Sectors, rs)
+		}
+		if p, ok := rose.Prevailing(); ok {
+			prevailing := resp.Sectors[p]
+			prevailing.Bands = nil
+			resp.Prevailing = &prevailing
+		}
+		return json.NewEncoder(buf).Encode(resp)
+	})
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pointer2null/weather/calibration"
+	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/sensors"
+	logger "github.com/sirupsen/logrus"
+)
+
+// calibrateCommand runs a sensor calibration with the station stopped, as it
+// needs the sensors to itself
+func calibrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("calibrate what? vane, wind or rain")
+	}
+	switch args[0] {
+	case "vane":
+		return calibrateVane(args[1:])
+	case "wind":
+		return calibrateWind(args[1:])
+	case "rain":
+		return calibrateRain(args[1:])
+	default:
+		return fmt.Errorf("can't calibrate [%v]", args[0])
+	}
+}
+
+func calibrateVane(args []string) error {
+	fs := flag.NewFlagSet("calibrate vane", flag.ExitOnError)
+	bus := fs.String("bus", "1", "I²C bus (/dev/i2c-1)")
+	north := fs.Float64("north", 0, "true bearing in degrees of the vane's N, for a mast that isn't aligned to north")
+	samples := fs.Int("samples", 20, "voltage readings averaged at each point")
+	output := fs.String("o", "", "file to write the table to, for -vane (defaults to stdout)")
+	_ = fs.Parse(args)
+
+	adc, err := sensors.OpenVaneADC(*bus)
+	if err != nil {
+		return err
+	}
+	defer adc.Close()
+
+	points, err := calibration.WalkVane(os.Stdin, os.Stderr, adc.Volts, *samples, 50*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	vane, err := calibration.NewVane(points, *north)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(vane, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if *output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	if err := os.WriteFile(*output, b, 0o644); err != nil {
+		return err
+	}
+	logger.Infof("Wrote the vane table to %v, run the station with -vane %v", *output, *output)
+	return nil
+}
+
+func calibrateWind(args []string) error {
+	fs := flag.NewFlagSet("calibrate wind", flag.ExitOnError)
+	bus := fs.String("bus", "1", "I²C bus (/dev/i2c-1)")
+	reference := fs.String("reference", "", "where the reference speeds come from: blank to type them in, a serial device such as /dev/ttyUSB0, or http")
+	listen := fs.String("listen", ":8090", "address for -reference http, which takes /reference?speed=")
+	unit := fs.String("unit", "mph", "reference unit, mph, kmh, ms or knots")
+	window := fs.Duration("window", 10*time.Second, "time the pulse rate is averaged over before each reference speed")
+	fit := fs.String("fit", "linear", "linear or piecewise")
+	segments := fs.Int("segments", 3, "lines in a piecewise fit")
+	logPath := fs.String("log", "", "csv file to log the readings to")
+	input := fs.String("input", "", "fit the -log of an earlier run instead of measuring")
+	file := fs.String("calibration", "", "the calibration the new version follows, if there is one")
+	_ = fs.Parse(args)
+
+	if _, err := calibration.ParseReference("0", *unit); err != nil {
+		return err
+	}
+	if *fit != "linear" && *fit != "piecewise" {
+		return fmt.Errorf("-fit must be linear or piecewise")
+	}
+	var set calibration.Set
+	if *file != "" {
+		var err error
+		if set, err = calibration.Load(*file); err != nil {
+			return err
+		}
+	}
+
+	var points []calibration.WindPoint
+	var err error
+	if *input != "" {
+		points, err = readWindLog(*input)
+	} else {
+		points, err = measureWind(*bus, *reference, *listen, *unit, *window, *logPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	var curve calibration.Curve
+	if *fit == "linear" {
+		curve, err = calibration.FitLinear(points)
+	} else {
+		curve, err = calibration.FitPiecewise(points, *segments)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infof("Starting threshold %.1f mph, rms error %.2f mph over %d readings",
+		curve.StartingThreshold(), curve.RMSError(points), len(points))
+
+	v := set.Next(time.Now().UTC().Truncate(time.Second), calibration.WindSpeed, curve)
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Add this version to the -calibration list:")
+	_, err = fmt.Println(string(b))
+	return err
+}
+
+// calibrateRain counts the tips while a known volume of water drips through
+// the gauge, and works out the rain in a tip from the funnel's area
+func calibrateRain(args []string) error {
+	fs := flag.NewFlagSet("calibrate rain", flag.ExitOnError)
+	volume := fs.Float64("volume", 0, "water poured in, ml")
+	diameter := fs.Float64("diameter", 0, "funnel diameter, mm")
+	area := fs.Float64("area", 0, "funnel area, cm², in place of -diameter")
+	file := fs.String("calibration", "", "the calibration the new version follows, if there is one")
+	write := fs.Bool("write", false, "add the new version to the -calibration file rather than printing it")
+	denoise := fs.Duration("denoise", env.RainDenoise, "time the rain pin must stay low for a tip, as -rainDenoise")
+	debounce := fs.Duration("debounce", env.RainDebounce, "shortest time between tips, as -rainDebounce")
+	_ = fs.Parse(args)
+
+	mm2 := *area * 100
+	if *diameter > 0 {
+		mm2 = calibration.GaugeArea(*diameter)
+	}
+	if *volume <= 0 || mm2 <= 0 {
+		return fmt.Errorf("-volume and -diameter or -area are required")
+	}
+	if *write && *file == "" {
+		return fmt.Errorf("-write needs -calibration")
+	}
+	var set calibration.Set
+	if *file != "" {
+		var err error
+		if set, err = calibration.Load(*file); err != nil {
+			return err
+		}
+	}
+
+	counter, err := sensors.OpenTipCounter(*denoise, *debounce, func(tips int) {
+		fmt.Fprintf(os.Stderr, "tip %d\n", tips)
+	})
+	if err != nil {
+		return err
+	}
+	defer counter.Close()
+	fmt.Fprintf(os.Stderr, "Pour %.0f ml in slowly, then press Enter once it has stopped dripping\n", *volume)
+	if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+		return err
+	}
+
+	tips := counter.Tips()
+	if rejected := counter.Rejected(); rejected > 0 {
+		logger.Warnf("%d edges on the rain pin weren't tips, check the -denoise and -debounce times", rejected)
+	}
+	perTip, err := calibration.RainPerTip(*volume, mm2, tips)
+	if err != nil {
+		return err
+	}
+	logger.Infof("%.0f ml is %.2f mm of rain in %d tips, %.4f mm a tip against a nominal %v",
+		*volume, *volume*1000/mm2, tips, perTip, env.MMPerBucketTip)
+
+	v := set.Next(time.Now().UTC().Truncate(time.Second), calibration.Rain, calibration.Curve{Gain: perTip / env.MMPerBucketTip})
+	if *write {
+		if err := append(set, v).Save(*file); err != nil {
+			return err
+		}
+		logger.Infof("Added version %d to %v", v.Version, *file)
+		return nil
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Add this version to the -calibration list:")
+	_, err = fmt.Println(string(b))
+	return err
+}
+
+// measureWind counts the anemometer's pulses and pairs each reference speed
+// with the rate over the window before it, until the reference runs out or
+// Enter is pressed
+func measureWind(bus string, reference string, listen string, unit string, window time.Duration, logPath string) ([]calibration.WindPoint, error) {
+	masthead, err := sensors.OpenMasthead(bus)
+	if err != nil {
+		return nil, err
+	}
+	defer masthead.Close()
+
+	var log *csv.Writer
+	if logPath != "" {
+		f, err := os.Create(logPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		log = csv.NewWriter(f)
+		defer log.Flush()
+		_ = log.Write([]string{"time", "nominal_mph", "reference_mph"})
+	}
+
+	pulses := &pulseWindow{length: window}
+	ticker := time.NewTicker(time.Second / env.WindSamplesPerSecond)
+	defer ticker.Stop()
+	go func() {
+		for t := range ticker.C {
+			n, err := masthead.Pulses()
+			if err != nil {
+				logger.Warnf("Masthead read failed [%v]", err)
+				continue
+			}
+			pulses.add(t, n)
+		}
+	}()
+
+	refs := make(chan string)
+	stop := make(chan struct{})
+	finish := sync.OnceFunc(func() { close(stop) })
+	stdin := bufio.NewScanner(os.Stdin)
+	switch reference {
+	case "":
+		go func() {
+			for {
+				fmt.Fprintf(os.Stderr, "Reference speed in %v, blank to finish: ", unit)
+				if !stdin.Scan() || strings.TrimSpace(stdin.Text()) == "" {
+					finish()
+					return
+				}
+				refs <- stdin.Text()
+			}
+		}()
+	case "http":
+		mux := http.NewServeMux()
+		mux.HandleFunc("/reference", func(rw http.ResponseWriter, r *http.Request) {
+			refs <- r.FormValue("speed")
+			fmt.Fprintln(rw, "ok")
+		})
+		go func() {
+			logger.Error(http.ListenAndServe(listen, mux))
+		}()
+	default:
+		f, err := os.Open(reference)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		go func() {
+			lines := bufio.NewScanner(f)
+			for lines.Scan() {
+				refs <- lines.Text()
+			}
+			logger.Infof("Reference stopped [%v]", lines.Err())
+			finish()
+		}()
+	}
+	if reference != "" {
+		logger.Infof("Reading reference speeds from %v, press Enter to finish", reference)
+		go func() {
+			stdin.Scan()
+			finish()
+		}()
+	}
+
+	logger.Infof("Averaging the pulses over %v before each reference speed", window)
+	var points []calibration.WindPoint
+	for {
+		select {
+		case <-stop:
+			return points, nil
+		case line := <-refs:
+			ref, err := calibration.ParseReference(line, unit)
+			if err != nil {
+				logger.Warnf("Ignoring reference [%v]", err)
+				continue
+			}
+			now := time.Now()
+			rate, ok := pulses.rate(now)
+			if !ok {
+				logger.Warnf("Ignoring reference, the pulses don't cover %v yet", window)
+				continue
+			}
+			p := calibration.WindPoint{Time: now, Nominal: rate * env.MphPerTick, Reference: ref}
+			points = append(points, p)
+			logger.Infof("Reference %.1f mph, %.2f pulses/s, nominal %.1f mph", p.Reference, rate, p.Nominal)
+			if log != nil {
+				_ = log.Write([]string{p.Time.UTC().Format(time.RFC3339), fmt.Sprint(p.Nominal), fmt.Sprint(p.Reference)})
+				log.Flush()
+			}
+		}
+	}
+}
+
+// readWindLog reads the readings measureWind logged
+func readWindLog(path string) ([]calibration.WindPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var points []calibration.WindPoint
+	for i, row := range rows {
+		if i == 0 || len(row) < 3 {
+			continue
+		}
+		var p calibration.WindPoint
+		if p.Time, err = time.Parse(time.RFC3339, row[0]); err != nil {
+			return nil, fmt.Errorf("%v line %d: %w", path, i+1, err)
+		}
+		if p.Nominal, err = strconv.ParseFloat(row[1], 64); err != nil {
+			return nil, fmt.Errorf("%v line %d: %w", path, i+1, err)
+		}
+		if p.Reference, err = strconv.ParseFloat(row[2], 64); err != nil {
+			return nil, fmt.Errorf("%v line %d: %w", path, i+1, err)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// pulseWindow holds the masthead's pulse counts over the last length
+type pulseWindow struct {
+	lock    sync.Mutex
+	length  time.Duration
+	samples []pulseSample
+}
+
+type pulseSample struct {
+	t      time.Time
+	pulses uint32
+}
+
+func (w *pulseWindow) add(t time.Time, pulses uint32) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.samples = append(w.samples, pulseSample{t, pulses})
+	for len(w.samples) > 0 && t.Sub(w.samples[0].t) > w.length {
+		w.samples = w.samples[1:]
+	}
+}
+
+// rate is the pulses per second, false until the samples cover the window
+func (w *pulseWindow) rate(now time.Time) (float64, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.samples) == 0 || now.Sub(w.samples[0].t) < w.length*9/10 {
+		return 0, false
+	}
+	var sum uint32
+	for _, s := range w.samples {
+		sum += s.pulses
+	}
+	seconds := float64(len(w.samples)) / env.WindSamplesPerSecond
+	return float64(sum) / seconds, true
+}
@@ -0,0 +1,349 @@
+// Package calibration corrects raw sensor readings with an offset, a gain or
+// a polynomial per quantity. Calibrations are versioned and take effect from
+// a date, and every version is recorded in the database along with the
+// version each observation used, so history can be reprocessed when a sensor
+// is recalibrated.
+package calibration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+)
+
+// Quantity names a calibrated reading
+type Quantity string
+
+const (
+	// Temperature is the MCP9808's and TemperatureSecondary the BME280's, C
+	Temperature          Quantity = "temperature"
+	TemperatureSecondary Quantity = "temperature_secondary"
+	// Humidity is %, Pressure station pressure in hPa
+	Humidity Quantity = "humidity"
+	Pressure Quantity = "pressure"
+	// WindSpeed is mph, applied to the mean speeds and gusts
+	WindSpeed Quantity = "wind_speed"
+	// Rain is mm, from the tips at env.MMPerBucketTip. It only takes a
+	// gain, the ratio of the measured to the nominal volume of a tip.
+	Rain Quantity = "rain"
+	// RainIntensity corrects for the rain a bucket misses while it tips,
+	// which grows with the intensity. It maps the measured mm/h to the true.
+	RainIntensity Quantity = "rain_intensity"
+)
+
+var Quantities = []Quantity{Temperature, TemperatureSecondary, Humidity, Pressure, WindSpeed, Rain, RainIntensity}
+
+// Curve maps a raw reading x to a calibrated one: Gain*x + Offset, or with
+// Poly c0 + c1*x + c2*x^2 ... in place of both, or with Knots straight lines
+// between [raw, calibrated] points, extended past the ends. A Gain of 0
+// means 1.
+type Curve struct {
+	Offset float64      `json:"offset,omitempty"`
+	Gain   float64      `json:"gain,omitempty"`
+	Poly   []float64    `json:"poly,omitempty"`
+	Knots  [][2]float64 `json:"knots,omitempty"`
+}
+
+func (c Curve) Apply(x float64) float64 {
+	if len(c.Knots) > 0 {
+		k := c.segment(x)
+		x0, y0, x1, y1 := c.Knots[k][0], c.Knots[k][1], c.Knots[k+1][0], c.Knots[k+1][1]
+		return y0 + (x-x0)*(y1-y0)/(x1-x0)
+	}
+	if len(c.Poly) > 0 {
+		// Horner's method
+		y := 0.0
+		for i := len(c.Poly) - 1; i >= 0; i-- {
+			y = y*x + c.Poly[i]
+		}
+		return y
+	}
+	gain := c.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	return gain*x + c.Offset
+}
+
+// segment is the index of the knot starting the line x is on
+func (c Curve) segment(x float64) int {
+	k := 0
+	for k < len(c.Knots)-2 && x >= c.Knots[k+1][0] {
+		k++
+	}
+	return k
+}
+
+// slope is the curve's derivative at x
+func (c Curve) slope(x float64) float64 {
+	if len(c.Knots) > 0 {
+		k := c.segment(x)
+		return (c.Knots[k+1][1] - c.Knots[k][1]) / (c.Knots[k+1][0] - c.Knots[k][0])
+	}
+	if len(c.Poly) == 0 {
+		if c.Gain == 0 {
+			return 1
+		}
+		return c.Gain
+	}
+	d := 0.0
+	for i := len(c.Poly) - 1; i >= 1; i-- {
+		d = d*x + float64(i)*c.Poly[i]
+	}
+	return d
+}
+
+// Invert finds the raw reading that calibrates to y, false if the curve
+// can't be inverted there
+func (c Curve) Invert(y float64) (float64, bool) {
+	// Newton's method, from y as calibrations are close to 1:1
+	x := y
+	for i := 0; i < 50; i++ {
+		d := c.slope(x)
+		if d == 0 {
+			return 0, false
+		}
+		step := (c.Apply(x) - y) / d
+		x -= step
+		if math.Abs(step) < 1e-9*math.Max(1, math.Abs(x)) {
+			return x, true
+		}
+	}
+	return 0, false
+}
+
+// Version is a set of curves that applies from a date until the next
+// version's. A quantity without a curve is left as read.
+type Version struct {
+	Version int                `json:"version"`
+	From    time.Time          `json:"from"`
+	Curves  map[Quantity]Curve `json:"curves"`
+}
+
+// Number is the version number, 0 for no calibration
+func (v *Version) Number() int {
+	if v == nil {
+		return 0
+	}
+	return v.Version
+}
+
+// Apply calibrates a raw reading, a nil version leaves it alone
+func (v *Version) Apply(q Quantity, x float64) float64 {
+	if v == nil {
+		return x
+	}
+	c, ok := v.Curves[q]
+	if !ok {
+		return x
+	}
+	return c.Apply(x)
+}
+
+// Invert undoes Apply
+func (v *Version) Invert(q Quantity, y float64) (float64, bool) {
+	if v == nil {
+		return y, true
+	}
+	c, ok := v.Curves[q]
+	if !ok {
+		return y, true
+	}
+	return c.Invert(y)
+}
+
+// curves is the JSON the version's curves are recorded as
+func (v *Version) curves() string {
+	b, _ := json.Marshal(v.Curves) // can't fail, and map keys are sorted
+	return string(b)
+}
+
+func (v *Version) validate() error {
+	if v.Version < 1 {
+		return fmt.Errorf("version %d: versions start at 1", v.Version)
+	}
+	if v.From.IsZero() {
+		return fmt.Errorf("version %d: no from date", v.Version)
+	}
+	known := make(map[Quantity]bool)
+	for _, q := range Quantities {
+		known[q] = true
+	}
+	for q, c := range v.Curves {
+		if !known[q] {
+			return fmt.Errorf("version %d: unknown quantity [%v]", v.Version, q)
+		}
+		if q == Rain && (c.Offset != 0 || len(c.Poly) > 0 || len(c.Knots) > 0) {
+			return fmt.Errorf("version %d: rain only takes a gain", v.Version)
+		}
+		if len(c.Poly) > 0 && (c.Offset != 0 || c.Gain != 0 || len(c.Knots) > 0) {
+			return fmt.Errorf("version %d: %v has a polynomial and an offset, gain or knots", v.Version, q)
+		}
+		if len(c.Knots) > 0 && (c.Offset != 0 || c.Gain != 0) {
+			return fmt.Errorf("version %d: %v has knots and an offset or gain", v.Version, q)
+		}
+		if len(c.Knots) == 1 {
+			return fmt.Errorf("version %d: %v needs at least 2 knots", v.Version, q)
+		}
+		for i := 1; i < len(c.Knots); i++ {
+			if c.Knots[i][0] <= c.Knots[i-1][0] {
+				return fmt.Errorf("version %d: %v knots must increase", v.Version, q)
+			}
+		}
+		if c.Gain < 0 {
+			return fmt.Errorf("version %d: %v has a negative gain", v.Version, q)
+		}
+	}
+	return nil
+}
+
+// Set is every version, oldest first
+type Set []Version
+
+// Load reads a JSON list of versions, e.g.
+//
+//	[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"temperature_secondary": {"offset": -0.5}}}]
+func Load(path string) (Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Set
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].From.Before(s[j].From) })
+	for i := range s {
+		if err := s[i].validate(); err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		if i > 0 && s[i].Version <= s[i-1].Version {
+			return nil, fmt.Errorf("%v: version %d takes effect after version %d", path, s[i-1].Version, s[i].Version)
+		}
+	}
+	return s, nil
+}
+
+// Save writes the set to path in the form Load reads
+func (s Set) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// At is the version in effect at t, nil if there is none
+func (s Set) At(t time.Time) *Version {
+	for i := len(s) - 1; i >= 0; i-- {
+		if !s[i].From.After(t) {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// WindSpeed calibrates a wind speed at t. Calm stays calm, whatever the
+// curve's offset, as no pulses means the cups aren't turning.
+func (s Set) WindSpeed(t time.Time, mph float64) float64 {
+	if mph == 0 {
+		return 0
+	}
+	return s.At(t).Apply(WindSpeed, mph)
+}
+
+// RainFactor is the correction for rain falling at intensity mm/h, to
+// multiply the tips' volume by
+func (v *Version) RainFactor(intensity float64) float64 {
+	if intensity <= 0 {
+		return 1
+	}
+	corrected := v.Apply(RainIntensity, intensity)
+	if corrected <= 0 {
+		return 1
+	}
+	return corrected / intensity
+}
+
+// Find returns a version by number, nil for 0. False if there is no such
+// version.
+func (s Set) Find(version int) (*Version, bool) {
+	if version == 0 {
+		return nil, true
+	}
+	for i := range s {
+		if s[i].Version == version {
+			return &s[i], true
+		}
+	}
+	return nil, false
+}
+
+// Store is where the versions are recorded
+type Store interface {
+	GetCalibrations(ctx context.Context, stationID string) ([]postgres.Calibration, error)
+	InsertCalibration(ctx context.Context, arg postgres.InsertCalibrationParams) error
+}
+
+// ErrChanged is returned by Record for a version that was recorded with
+// different curves or date. Observations already name it, so changing it
+// would lose what they were calibrated with; add a new version instead.
+var ErrChanged = errors.New("calibration version changed since it was recorded")
+
+// Record stores any versions not yet recorded for the station
+func Record(ctx context.Context, db Store, station string, s Set) error {
+	recorded, err := Recorded(ctx, db, station)
+	if err != nil {
+		return err
+	}
+	for i := range s {
+		v := &s[i]
+		if r, ok := recorded.Find(v.Version); ok {
+			if !r.From.Equal(v.From) || r.curves() != v.curves() {
+				return fmt.Errorf("version %d: %w", v.Version, ErrChanged)
+			}
+			continue
+		}
+		err := db.InsertCalibration(ctx, postgres.InsertCalibrationParams{
+			StationID:     station,
+			Version:       int32(v.Version),
+			EffectiveFrom: v.From,
+			Curves:        v.curves(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recorded reads back the versions recorded for the station
+func Recorded(ctx context.Context, db Store, station string) (Set, error) {
+	rows, err := db.GetCalibrations(ctx, station)
+	if err != nil {
+		return nil, err
+	}
+	var s Set
+	for _, r := range rows {
+		v := Version{Version: int(r.Version), From: r.EffectiveFrom}
+		if err := json.Unmarshal([]byte(r.Curves), &v.Curves); err != nil {
+			return nil, fmt.Errorf("version %d: %w", r.Version, err)
+		}
+		s = append(s, v)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].From.Before(s[j].From) })
+	return s, nil
+}
+
+// NullVersion is the version to record against an observation
+func NullVersion(v *Version) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(v.Number()), Valid: v != nil}
+}
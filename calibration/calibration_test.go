@@ -0,0 +1,226 @@
+package calibration
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func TestCurve(t *testing.T) {
+	linear := Curve{Offset: -0.5, Gain: 1.02}
+	require.InDelta(t, 9.7, linear.Apply(10), 1e-9)
+	x, ok := linear.Invert(9.7)
+	require.True(t, ok)
+	require.InDelta(t, 10, x, 1e-9)
+
+	// a gain of 0 is no gain
+	require.Equal(t, 11.0, Curve{Offset: 1}.Apply(10))
+
+	poly := Curve{Poly: []float64{0.1, 0.98, 0.001}}
+	require.InDelta(t, 0.1+9.8+0.1, poly.Apply(10), 1e-9)
+	x, ok = poly.Invert(poly.Apply(23.4))
+	require.True(t, ok)
+	require.InDelta(t, 23.4, x, 1e-6)
+
+	_, ok = Curve{Poly: []float64{5}}.Invert(5)
+	require.False(t, ok)
+
+	knots := Curve{Knots: [][2]float64{{2, 3}, {10, 11}, {20, 23}}}
+	require.InDelta(t, 7.0, knots.Apply(6), 1e-9)
+	require.InDelta(t, 17.0, knots.Apply(15), 1e-9)
+	// extended past the ends
+	require.InDelta(t, 1.0, knots.Apply(0), 1e-9)
+	require.InDelta(t, 35.0, knots.Apply(30), 1e-9)
+	x, ok = knots.Invert(17)
+	require.True(t, ok)
+	require.InDelta(t, 15, x, 1e-9)
+}
+
+func writeSet(t *testing.T, json string) string {
+	path := filepath.Join(t.TempDir(), "calibration.json")
+	require.NoError(t, os.WriteFile(path, []byte(json), 0o644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	s, err := Load(writeSet(t, `[
+		{"version": 2, "from": "2026-06-01T00:00:00Z", "curves": {"rain": {"gain": 1.05}}},
+		{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"temperature_secondary": {"offset": -0.5}}}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, s, 2)
+	require.Equal(t, 1, s[0].Version)
+
+	require.Nil(t, s.At(t0.Add(-time.Hour)))
+	require.Equal(t, 1, s.At(t0).Number())
+	require.Equal(t, 2, s.At(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)).Number())
+	// a quantity without a curve is left as read
+	require.Equal(t, 12.0, s.At(t0).Apply(Temperature, 12))
+	require.Equal(t, 11.5, s.At(t0).Apply(TemperatureSecondary, 12))
+
+	v, ok := s.Find(2)
+	require.True(t, ok)
+	require.InDelta(t, 1.05, v.Apply(Rain, 1), 1e-9)
+	_, ok = s.Find(3)
+	require.False(t, ok)
+
+	for name, json := range map[string]string{
+		"unknown quantity": `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"visibility": {"offset": 1}}}]`,
+		"rain offset":      `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"rain": {"offset": 1}}}]`,
+		"poly and gain":    `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"pressure": {"gain": 1, "poly": [0, 1]}}}]`,
+		"knots and offset": `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"wind_speed": {"offset": 1, "knots": [[0, 1], [10, 11]]}}}]`,
+		"one knot":         `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"wind_speed": {"knots": [[0, 1]]}}}]`,
+		"knots decrease":   `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"wind_speed": {"knots": [[10, 1], [5, 11]]}}}]`,
+		"negative gain":    `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"humidity": {"gain": -1}}}]`,
+		"no date":          `[{"version": 1, "curves": {}}]`,
+		"version 0":        `[{"version": 0, "from": "2026-03-01T00:00:00Z", "curves": {}}]`,
+		"versions out of order": `[{"version": 2, "from": "2026-03-01T00:00:00Z", "curves": {}},
+			{"version": 1, "from": "2026-06-01T00:00:00Z", "curves": {}}]`,
+	} {
+		_, err := Load(writeSet(t, json))
+		require.Error(t, err, name)
+	}
+}
+
+func TestWindSpeed(t *testing.T) {
+	s := Set{{Version: 1, From: t0, Curves: map[Quantity]Curve{WindSpeed: {Offset: 0.8, Gain: 1.1}}}}
+	require.Equal(t, 0.0, s.WindSpeed(t0, 0))
+	require.InDelta(t, 11.8, s.WindSpeed(t0, 10), 1e-9)
+	require.Equal(t, 10.0, s.WindSpeed(t0.Add(-time.Hour), 10))
+}
+
+type fakeStore struct {
+	rows []postgres.Calibration
+}
+
+func (f *fakeStore) GetCalibrations(ctx context.Context, stationID string) ([]postgres.Calibration, error) {
+	return f.rows, nil
+}
+
+func (f *fakeStore) InsertCalibration(ctx context.Context, arg postgres.InsertCalibrationParams) error {
+	f.rows = append(f.rows, postgres.Calibration{
+		StationID:     arg.StationID,
+		Version:       arg.Version,
+		EffectiveFrom: arg.EffectiveFrom,
+		Curves:        arg.Curves,
+	})
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeStore{}
+	s := Set{{Version: 1, From: t0, Curves: map[Quantity]Curve{Pressure: {Offset: 1.2}}}}
+	require.NoError(t, Record(ctx, db, "home", s))
+	require.Len(t, db.rows, 1)
+
+	// recording again changes nothing, a new version is added
+	s = append(s, Version{Version: 2, From: t0.AddDate(0, 1, 0), Curves: map[Quantity]Curve{Pressure: {Offset: 0.9}}})
+	require.NoError(t, Record(ctx, db, "home", s))
+	require.Len(t, db.rows, 2)
+
+	recorded, err := Recorded(ctx, db, "home")
+	require.NoError(t, err)
+	require.Equal(t, s, recorded)
+
+	s[0].Curves = map[Quantity]Curve{Pressure: {Offset: 1.5}}
+	err = Record(ctx, db, "home", s)
+	require.True(t, errors.Is(err, ErrChanged))
+	require.Len(t, db.rows, 2)
+}
+
+func TestRainFactor(t *testing.T) {
+	v := &Version{Version: 1, From: t0, Curves: map[Quantity]Curve{RainIntensity: {Poly: []float64{0, 1, 0.001}}}}
+	require.Equal(t, 1.0, v.RainFactor(0))
+	require.InDelta(t, 1.01, v.RainFactor(10), 1e-9)
+	require.InDelta(t, 1.1, v.RainFactor(100), 1e-9)
+	var none *Version
+	require.Equal(t, 1.0, none.RainFactor(50))
+}
+
+func TestRecalibrateRain(t *testing.T) {
+	// only the intensity curve changes
+	v1 := &Version{Version: 1, From: t0, Curves: map[Quantity]Curve{Rain: {Gain: 1.1}}}
+	v2 := &Version{Version: 2, From: t0, Curves: map[Quantity]Curve{Rain: {Gain: 1.1}, RainIntensity: {Poly: []float64{0, 1, 0.001}}}}
+	start := t0.Add(9 * time.Hour)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	obs := []RainObservation{
+		// the end of the previous rain day only counts toward the intensity
+		{Time: at(0), Raw: 1, Rain: 1, Day: 5},
+		{Time: at(1), Raw: 1, Rain: 1, Rate: 60, Day: 1},
+		{Time: at(2), Raw: 1, Rain: 1, Rate: 60, Day: 2},
+		{Time: at(30), Raw: 0.2, Rain: 0.2, Day: 2.2},
+	}
+	for i := range obs {
+		obs[i].Was, obs[i].Version = v1, v2
+	}
+	RecalibrateRain(obs, start)
+
+	require.InDelta(t, 1.006, obs[0].Rain, 1e-9)
+	require.Equal(t, 5.0, obs[0].Day)
+	// 2 mm in the 10 minutes is 12 mm/h
+	require.InDelta(t, 1.012, obs[1].Rain, 1e-9)
+	require.InDelta(t, 1.012, obs[1].Day, 1e-9)
+	require.InDelta(t, 63.6, obs[1].Rate, 1e-9)
+	require.InDelta(t, 1.018, obs[2].Rain, 1e-9)
+	require.InDelta(t, 2.03, obs[2].Day, 1e-9)
+	require.InDelta(t, 0.20024, obs[3].Rain, 1e-9)
+	require.InDelta(t, 2.23024, obs[3].Day, 1e-9)
+	require.Equal(t, 0.0, obs[3].Rate)
+	// the tip volume didn't change
+	require.InDelta(t, 0.2, obs[3].Raw, 1e-9)
+
+	// an observation already on its version is kept, but carries the
+	// change in the day's rain before it
+	later := RainObservation{Time: at(30), Raw: 0.2, Rain: 0.2, Day: 2.2, Was: v2, Version: v2}
+	part := append(append([]RainObservation{}, obs[:3]...), later)
+	for i := range part[:3] {
+		part[i].Raw, part[i].Rain, part[i].Rate, part[i].Day = 1, 1, 60, float64(i)
+	}
+	RecalibrateRain(part, start)
+	require.Equal(t, 0.2, part[3].Rain)
+	require.InDelta(t, 2.23, part[3].Day, 1e-9)
+
+	// and back again
+	for i := range obs {
+		obs[i].Was, obs[i].Version = v2, v1
+	}
+	RecalibrateRain(obs, start)
+	require.InDelta(t, 1, obs[2].Rain, 1e-9)
+	require.InDelta(t, 2, obs[2].Day, 1e-9)
+	require.InDelta(t, 60, obs[2].Rate, 1e-9)
+	require.InDelta(t, 2.2, obs[3].Day, 1e-9)
+}
+
+func TestRainPerTip(t *testing.T) {
+	// 100 ml through a 16.6 cm funnel is 4.62 mm, which took 17 tips
+	area := GaugeArea(166)
+	perTip, err := RainPerTip(100, area, 17)
+	require.NoError(t, err)
+	require.InDelta(t, 0.2718, perTip, 0.0001)
+
+	_, err = RainPerTip(100, area, 0)
+	require.Error(t, err)
+	_, err = RainPerTip(0, area, 10)
+	require.Error(t, err)
+
+	// a new version saved to the file loads back
+	path := writeSet(t, `[{"version": 1, "from": "2026-03-01T00:00:00Z", "curves": {"temperature": {"offset": 0.2}}}]`)
+	s, err := Load(path)
+	require.NoError(t, err)
+	s = append(s, s.Next(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), Rain, Curve{Gain: perTip / 0.2794}))
+	require.NoError(t, s.Save(path))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, s, loaded)
+	require.InDelta(t, perTip, loaded.At(time.Now()).Apply(Rain, 0.2794), 1e-9)
+	require.Equal(t, 0.2, loaded.At(time.Now()).Curves[Temperature].Offset)
+}
@@ -0,0 +1,102 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// GaugeArea is the collecting area of a round funnel, mm²
+func GaugeArea(diameter float64) float64 {
+	return math.Pi * diameter * diameter / 4
+}
+
+// RainPerTip is the mm of rain a tip measures, from a volume in ml poured
+// into a funnel of area mm² that tipped the bucket tips times
+func RainPerTip(volume float64, area float64, tips int) (float64, error) {
+	if volume <= 0 || area <= 0 {
+		return 0, fmt.Errorf("need a volume and an area above 0")
+	}
+	if tips < 1 {
+		return 0, fmt.Errorf("the bucket didn't tip")
+	}
+	// 1 ml is 1000 mm³
+	return volume * 1000 / area / float64(tips), nil
+}
+
+// RainIntensityWindow is the time the intensity a tip fell at is measured
+// over
+const RainIntensityWindow = 10 * time.Minute
+
+// RainObservation is an observation's rain, recorded with Was and to be
+// calibrated with Version
+type RainObservation struct {
+	Time time.Time
+	// Raw is the rain since the previous observation at the tips'
+	// calibrated volume, Rain the same corrected for intensity, Rate the
+	// corrected rate, mm/h, and Day the corrected rain this rain day
+	Raw  float64
+	Rain float64
+	Rate float64
+	Day  float64
+
+	Was     *Version
+	Version *Version
+}
+
+// RecalibrateRain calibrates the observations, in time order, with their
+// new versions. The tip volume is changed on Raw, and Rain is rebuilt from
+// it with the intensity correction, taking the intensity from the raw rain
+// over the RainIntensityWindow up to each observation. An observation
+// already on its version is left as recorded. The observations up to from
+// only count toward the intensity; Day takes up the change in Rain after
+// from, so from should be the start of a rain day.
+func RecalibrateRain(obs []RainObservation, from time.Time) {
+	dayChange := 0.0
+	for i := range obs {
+		o := &obs[i]
+		if o.Was.Number() == o.Version.Number() {
+			if o.Time.After(from) {
+				o.Day += dayChange
+			}
+			continue
+		}
+		o.Raw = o.regain(o.Raw)
+		intensity := 0.0
+		for j := i; j >= 0 && o.Time.Sub(obs[j].Time) < RainIntensityWindow; j-- {
+			intensity += obs[j].Raw
+		}
+		intensity *= float64(time.Hour) / float64(RainIntensityWindow)
+		rain := o.Raw * o.Version.RainFactor(intensity)
+
+		rate := o.regain(o.Was.uncorrectRate(o.Rate))
+		o.Rate = rate * o.Version.RainFactor(rate)
+		if o.Time.After(from) {
+			dayChange += rain - o.Rain
+			o.Day += dayChange
+		}
+		o.Rain = rain
+	}
+}
+
+// regain changes rain measured at Was's tip volume to Version's
+func (o *RainObservation) regain(mm float64) float64 {
+	tips, ok := o.Was.Invert(Rain, mm)
+	if !ok {
+		return mm
+	}
+	return o.Version.Apply(Rain, tips)
+}
+
+// uncorrectRate undoes the intensity correction of a rate, the inverse of
+// rate * RainFactor(rate)
+func (v *Version) uncorrectRate(rate float64) float64 {
+	if rate <= 0 {
+		return rate
+	}
+	raw, ok := v.Invert(RainIntensity, rate)
+	if !ok || raw <= 0 {
+		return rate
+	}
+	return raw
+}
@@ -0,0 +1,173 @@
+package calibration
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pointer2null/weather/windrose"
+)
+
+// Threshold is one entry of the vane lookup table: voltages from the entry
+// before up to Below read as Degrees. The last entry takes everything above.
+type Threshold struct {
+	Below   float64 `json:"volts_below,omitempty"`
+	Degrees float64 `json:"degrees"`
+}
+
+// Vane turns the vane's ADS1115 voltage into a direction. The resistor
+// network gives one voltage per point, so the table is the midpoints between
+// the voltages measured with the vane held at each. NorthOffset is added to
+// every direction, for a mast that isn't aligned to true north.
+type Vane struct {
+	NorthOffset float64     `json:"north_offset"`
+	Thresholds  []Threshold `json:"thresholds"`
+}
+
+// DefaultVane was measured for the station's own vane, see doc/direction.xlsx
+var DefaultVane = Vane{Thresholds: []Threshold{
+	{0.376, 112.5},
+	{0.441, 67.5},
+	{0.548, 90},
+	{0.775, 157.5},
+	{1.069, 135},
+	{1.324, 202.5},
+	{1.726, 180},
+	{2.161, 22.5},
+	{2.64, 45},
+	{3.055, 247.5},
+	{3.315, 225},
+	{3.705, 337.5},
+	{4.013, 0},
+	{4.258, 292.5},
+	{4.550, 315},
+	{0, 270},
+}}
+
+// LoadVane reads a vane table written by "calibrate vane"
+func LoadVane(path string) (Vane, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Vane{}, err
+	}
+	var v Vane
+	if err := json.Unmarshal(b, &v); err != nil {
+		return Vane{}, fmt.Errorf("%v: %w", path, err)
+	}
+	if err := v.validate(); err != nil {
+		return Vane{}, fmt.Errorf("%v: %w", path, err)
+	}
+	return v, nil
+}
+
+func (v Vane) validate() error {
+	if len(v.Thresholds) < 2 {
+		return fmt.Errorf("the vane table needs at least 2 points")
+	}
+	for i, t := range v.Thresholds[:len(v.Thresholds)-1] {
+		if i > 0 && t.Below <= v.Thresholds[i-1].Below {
+			return fmt.Errorf("vane thresholds must increase, %v follows %v", t.Below, v.Thresholds[i-1].Below)
+		}
+	}
+	for _, t := range v.Thresholds {
+		if t.Degrees < 0 || t.Degrees >= 360 {
+			return fmt.Errorf("vane direction %v is not in [0, 360)", t.Degrees)
+		}
+	}
+	return nil
+}
+
+// Direction is the direction in degrees and its compass point for a voltage
+func (v Vane) Direction(volts float64) (float64, string) {
+	deg := v.Thresholds[len(v.Thresholds)-1].Degrees
+	for _, t := range v.Thresholds[:len(v.Thresholds)-1] {
+		if volts < t.Below {
+			deg = t.Degrees
+			break
+		}
+	}
+	deg = math.Mod(deg+v.NorthOffset, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg, windrose.Points[windrose.Sector(deg)]
+}
+
+// VanePoint is the voltage measured with the vane held at a direction
+type VanePoint struct {
+	Degrees float64
+	Volts   float64
+}
+
+// NewVane builds the table from the voltage measured at each point, with
+// each threshold midway between neighbouring voltages
+func NewVane(points []VanePoint, northOffset float64) (Vane, error) {
+	sorted := append([]VanePoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Volts < sorted[j].Volts })
+	v := Vane{NorthOffset: northOffset}
+	for i, p := range sorted {
+		t := Threshold{Degrees: p.Degrees}
+		if i < len(sorted)-1 {
+			next := sorted[i+1]
+			if next.Volts == p.Volts {
+				return Vane{}, fmt.Errorf("%v and %v both read %.3f V", p.Degrees, next.Degrees, p.Volts)
+			}
+			t.Below = math.Round((p.Volts+next.Volts)/2*1000) / 1000
+		}
+		v.Thresholds = append(v.Thresholds, t)
+	}
+	return v, v.validate()
+}
+
+// WalkVane asks for the vane to be held at each compass point in turn and
+// averages samples reads of its voltage there. A point can be skipped, for a
+// vane that can't reach it.
+func WalkVane(in io.Reader, out io.Writer, read func() (float64, error), samples int, interval time.Duration) ([]VanePoint, error) {
+	lines := bufio.NewScanner(in)
+	var points []VanePoint
+	for sector, name := range windrose.Points {
+		deg := float64(sector) * 360 / windrose.Sectors
+		for {
+			fmt.Fprintf(out, "Hold the vane at %v (%v°) and press Enter, s to skip: ", name, deg)
+			if !lines.Scan() {
+				if err := lines.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.ErrUnexpectedEOF
+			}
+			if strings.TrimSpace(lines.Text()) == "s" {
+				break
+			}
+			sum, lo, hi := 0.0, math.Inf(1), math.Inf(-1)
+			for i := 0; i < samples; i++ {
+				if i > 0 {
+					time.Sleep(interval)
+				}
+				v, err := read()
+				if err != nil {
+					return nil, err
+				}
+				sum += v
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+			if hi-lo > vaneSteady {
+				fmt.Fprintf(out, "  the vane moved (%.3f - %.3f V), again\n", lo, hi)
+				continue
+			}
+			p := VanePoint{Degrees: deg, Volts: sum / float64(samples)}
+			fmt.Fprintf(out, "  %.3f V\n", p.Volts)
+			points = append(points, p)
+			break
+		}
+	}
+	return points, nil
+}
+
+// vaneSteady is the most the voltage can wander while the vane is held still
+const vaneSteady = 0.05
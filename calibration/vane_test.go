@@ -0,0 +1,80 @@
+package calibration
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaneDirection(t *testing.T) {
+	deg, compass := DefaultVane.Direction(0.2)
+	require.Equal(t, 112.5, deg)
+	require.Equal(t, "ESE", compass)
+	deg, compass = DefaultVane.Direction(3.9)
+	require.Equal(t, 0.0, deg)
+	require.Equal(t, "N", compass)
+	deg, compass = DefaultVane.Direction(4.9)
+	require.Equal(t, 270.0, deg)
+	require.Equal(t, "W", compass)
+
+	// a mast turned 22.5° east of north
+	turned := DefaultVane
+	turned.NorthOffset = 22.5
+	deg, compass = turned.Direction(4.0)
+	require.Equal(t, 22.5, deg)
+	require.Equal(t, "NNE", compass)
+	deg, _ = turned.Direction(3.5) // NNW
+	require.Equal(t, 0.0, deg)
+	turned.NorthOffset = -45
+	deg, compass = turned.Direction(4.0)
+	require.Equal(t, 315.0, deg)
+	require.Equal(t, "NW", compass)
+}
+
+func TestNewVane(t *testing.T) {
+	v, err := NewVane([]VanePoint{{0, 3.84}, {90, 0.45}, {180, 1.98}, {270, 4.62}}, 5)
+	require.NoError(t, err)
+	require.Equal(t, Vane{NorthOffset: 5, Thresholds: []Threshold{{1.215, 90}, {2.91, 180}, {4.23, 0}, {0, 270}}}, v)
+
+	_, err = NewVane([]VanePoint{{0, 3.84}, {90, 3.84}}, 0)
+	require.Error(t, err)
+	_, err = NewVane([]VanePoint{{0, 3.84}}, 0)
+	require.Error(t, err)
+
+	// it round trips through the file -vane reads
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	loaded, err := LoadVane(writeSet(t, string(b)))
+	require.NoError(t, err)
+	require.Equal(t, v, loaded)
+
+	_, err = LoadVane(writeSet(t, `{"thresholds": [{"volts_below": 2, "degrees": 0}, {"volts_below": 1, "degrees": 90}, {"degrees": 180}]}`))
+	require.Error(t, err)
+}
+
+func TestWalkVane(t *testing.T) {
+	// the second reading at N wanders, so N is measured again
+	volts := []float64{3.84, 3.95, 3.84, 3.84}
+	read := func() (float64, error) {
+		v := 2.0
+		if len(volts) > 0 {
+			v, volts = volts[0], volts[1:]
+		}
+		return v, nil
+	}
+	// N twice, NNE skipped, then the rest
+	in := "\n\ns\n" + strings.Repeat("\n", 14)
+	var out bytes.Buffer
+	points, err := WalkVane(strings.NewReader(in), &out, read, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, points, 15)
+	require.Equal(t, VanePoint{0, 3.84}, points[0])
+	require.Equal(t, 45.0, points[1].Degrees)
+	require.Contains(t, out.String(), "the vane moved")
+
+	_, err = WalkVane(strings.NewReader("\n"), &out, read, 1, 0)
+	require.Error(t, err)
+}
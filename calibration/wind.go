@@ -0,0 +1,143 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+)
+
+// WindPoint pairs the anemometer's speed at the nominal env.MphPerTick with a
+// reference instrument's over the same time, both mph
+type WindPoint struct {
+	Time      time.Time
+	Nominal   float64
+	Reference float64
+}
+
+// turning drops the points where the cups weren't turning, which say
+// nothing about the curve other than that the wind was below the threshold
+func turning(points []WindPoint) []WindPoint {
+	var out []WindPoint
+	for _, p := range points {
+		if p.Nominal > 0 {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Nominal < out[j].Nominal })
+	return out
+}
+
+// FitLinear fits Gain*nominal + Offset by least squares. The offset is the
+// starting threshold, the speed at which the cups begin to turn.
+func FitLinear(points []WindPoint) (Curve, error) {
+	points = turning(points)
+	n := float64(len(points))
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		sx += p.Nominal
+		sy += p.Reference
+		sxx += p.Nominal * p.Nominal
+		sxy += p.Nominal * p.Reference
+	}
+	d := n*sxx - sx*sx
+	if len(points) < 2 || d == 0 {
+		return Curve{}, fmt.Errorf("need readings at 2 or more different speeds, have %d", len(points))
+	}
+	gain := (n*sxy - sx*sy) / d
+	if gain <= 0 {
+		return Curve{}, fmt.Errorf("the reference doesn't rise with the anemometer, gain %.3f", gain)
+	}
+	return Curve{Gain: gain, Offset: (sy - gain*sx) / n}, nil
+}
+
+// FitPiecewise splits the points into segments bands of speed and joins the
+// mean of each with a straight line, for an anemometer that isn't linear.
+// The first line extended to a nominal 0 is the starting threshold.
+func FitPiecewise(points []WindPoint, segments int) (Curve, error) {
+	points = turning(points)
+	if segments < 1 || len(points) < segments+1 {
+		return Curve{}, fmt.Errorf("need at least %d readings for %d segments, have %d", segments+1, segments, len(points))
+	}
+	var c Curve
+	for k := 0; k <= segments; k++ {
+		band := points[k*len(points)/(segments+1) : (k+1)*len(points)/(segments+1)]
+		var x, y float64
+		for _, p := range band {
+			x += p.Nominal
+			y += p.Reference
+		}
+		x, y = x/float64(len(band)), y/float64(len(band))
+		if len(c.Knots) > 0 && x <= c.Knots[len(c.Knots)-1][0] {
+			return Curve{}, fmt.Errorf("too few different speeds for %d segments", segments)
+		}
+		c.Knots = append(c.Knots, [2]float64{x, y})
+	}
+	for k := 1; k < len(c.Knots); k++ {
+		if c.Knots[k][1] <= c.Knots[k-1][1] {
+			return Curve{}, fmt.Errorf("the reference doesn't rise with the anemometer between %.1f and %.1f mph", c.Knots[k-1][0], c.Knots[k][0])
+		}
+	}
+	return c, nil
+}
+
+// StartingThreshold is the speed at which the cups start to turn, mph
+func (c Curve) StartingThreshold() float64 {
+	return math.Max(0, c.Apply(0))
+}
+
+// RMSError is how far the curve is from the reference, mph
+func (c Curve) RMSError(points []WindPoint) float64 {
+	points = turning(points)
+	if len(points) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, p := range points {
+		e := c.Apply(p.Nominal) - p.Reference
+		sum += e * e
+	}
+	return math.Sqrt(sum / float64(len(points)))
+}
+
+var number = regexp.MustCompile(`-?[0-9]+(\.[0-9]+)?`)
+
+// ParseReference reads a speed from a line a handheld anemometer sends or
+// someone types, the first number in it, and converts it to mph
+func ParseReference(line string, unit string) (float64, error) {
+	perMph, err := env.WindUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+	m := number.FindString(line)
+	if m == "" {
+		return 0, fmt.Errorf("no speed in [%v]", line)
+	}
+	v, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("negative speed [%v]", v)
+	}
+	return v / perMph, nil
+}
+
+// Next is the version that follows the set's latest, from the given time,
+// with q's curve replaced and every other curve kept
+func (s Set) Next(from time.Time, q Quantity, c Curve) Version {
+	v := Version{Version: 1, From: from, Curves: map[Quantity]Curve{}}
+	if len(s) > 0 {
+		last := s[len(s)-1]
+		v.Version = last.Version + 1
+		for k, lc := range last.Curves {
+			v.Curves[k] = lc
+		}
+	}
+	v.Curves[q] = c
+	return v
+}
@@ -0,0 +1,96 @@
+package calibration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func windPoints(f func(nominal float64) float64, nominal ...float64) []WindPoint {
+	var points []WindPoint
+	for _, n := range nominal {
+		points = append(points, WindPoint{Time: t0, Nominal: n, Reference: f(n)})
+	}
+	return points
+}
+
+func TestFitLinear(t *testing.T) {
+	points := windPoints(func(n float64) float64 { return 1.1*n + 0.8 }, 2, 5, 9, 14, 20)
+	// below the threshold the cups don't turn
+	points = append(points, WindPoint{Time: t0, Nominal: 0, Reference: 0.5})
+	c, err := FitLinear(points)
+	require.NoError(t, err)
+	require.InDelta(t, 1.1, c.Gain, 1e-9)
+	require.InDelta(t, 0.8, c.Offset, 1e-9)
+	require.InDelta(t, 0.8, c.StartingThreshold(), 1e-9)
+	require.InDelta(t, 0, c.RMSError(points), 1e-9)
+
+	_, err = FitLinear(windPoints(func(n float64) float64 { return n }, 0, 5, 5))
+	require.Error(t, err)
+	_, err = FitLinear(windPoints(func(n float64) float64 { return 20 - n }, 2, 5, 9))
+	require.Error(t, err)
+}
+
+func TestFitPiecewise(t *testing.T) {
+	// linear to 10 mph, then reading low
+	truth := func(n float64) float64 {
+		if n < 10 {
+			return n + 1
+		}
+		return 11 + (n-10)*1.5
+	}
+	points := windPoints(truth, 2, 3, 4, 9, 10, 11, 18, 20, 22)
+	c, err := FitPiecewise(points, 2)
+	require.NoError(t, err)
+	// a knot is the mean of its band, so the one on the kink is a little high
+	require.Equal(t, [][2]float64{{3, 4}, {10, 11 + 1.0/6}, {20, 26}}, c.Knots)
+	require.InDelta(t, 1.0, c.StartingThreshold(), 0.1)
+	linear, err := FitLinear(points)
+	require.NoError(t, err)
+	require.Less(t, c.RMSError(points), linear.RMSError(points))
+
+	_, err = FitPiecewise(points[:2], 2)
+	require.Error(t, err)
+	_, err = FitPiecewise(windPoints(truth, 5, 5, 5, 5), 1)
+	require.Error(t, err)
+}
+
+func TestParseReference(t *testing.T) {
+	for line, want := range map[string]float64{
+		"12.5":         12.5,
+		"WS: 4.47 m/s": 4.47 / 0.44704,
+		"  7 \r":       7,
+		"speed=3.0;ok": 3,
+	} {
+		unit := "mph"
+		if line == "WS: 4.47 m/s" {
+			unit = "ms"
+		}
+		v, err := ParseReference(line, unit)
+		require.NoError(t, err, line)
+		require.InDelta(t, want, v, 1e-9, line)
+	}
+	_, err := ParseReference("calm", "mph")
+	require.Error(t, err)
+	_, err = ParseReference("-2", "mph")
+	require.Error(t, err)
+	_, err = ParseReference("2", "furlongs")
+	require.Error(t, err)
+}
+
+func TestNext(t *testing.T) {
+	wind := Curve{Gain: 1.05, Offset: 0.7}
+	v := Set(nil).Next(t0, WindSpeed, wind)
+	require.Equal(t, Version{Version: 1, From: t0, Curves: map[Quantity]Curve{WindSpeed: wind}}, v)
+
+	s := Set{{Version: 3, From: t0, Curves: map[Quantity]Curve{Pressure: {Offset: 1.2}, WindSpeed: {Gain: 1.2}}}}
+	later := t0.Add(24 * time.Hour)
+	v = s.Next(later, WindSpeed, wind)
+	require.Equal(t, 4, v.Version)
+	require.Equal(t, later, v.From)
+	require.Equal(t, map[Quantity]Curve{Pressure: {Offset: 1.2}, WindSpeed: wind}, v.Curves)
+	// the set it came from is unchanged
+	require.Equal(t, 1.2, s[0].Curves[WindSpeed].Gain)
+	require.NoError(t, v.validate())
+}
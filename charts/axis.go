@@ -0,0 +1,121 @@
+package charts
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// niceTicks covers [lo, hi] with about n round steps of 1, 2 or 5 times a
+// power of ten, and returns the widened range with the ticks in it
+func niceTicks(lo, hi float64, n int) (float64, float64, []float64) {
+	if hi <= lo {
+		// a flat line still wants an axis around it
+		lo, hi = lo-1, hi+1
+	}
+	raw := (hi - lo) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag * 10
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*mag*(1+1e-9) {
+			step = m * mag
+			break
+		}
+	}
+	lo = math.Floor(lo/step) * step
+	hi = math.Ceil(hi/step) * step
+	var ticks []float64
+	for v := lo; v <= hi+step/2; v += step {
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+	return lo, hi, ticks
+}
+
+// label formats a tick with no more decimals than the step needs
+func label(v float64, step float64) string {
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step) - 1e-9))
+	}
+	if v == 0 {
+		v = 0 // no -0
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+type timeStep struct {
+	step   time.Duration
+	months int
+	format string
+}
+
+// time axis steps, the first that gives few enough ticks is used
+var timeSteps = []timeStep{
+	{step: time.Hour, format: "15:04"},
+	{step: time.Hour * 2, format: "15:04"},
+	{step: time.Hour * 3, format: "15:04"},
+	{step: time.Hour * 6, format: "15:04"},
+	{step: time.Hour * 12, format: "Jan 2 15:04"},
+	{step: time.Hour * 24, format: "Jan 2"},
+	{step: time.Hour * 24 * 2, format: "Jan 2"},
+	{step: time.Hour * 24 * 7, format: "Jan 2"},
+	{step: time.Hour * 24 * 14, format: "Jan 2"},
+	{months: 1, format: "Jan"},
+	{months: 3, format: "Jan 2006"},
+	{months: 6, format: "Jan 2006"},
+	{months: 12, format: "2006"},
+}
+
+type timeTick struct {
+	t     time.Time
+	label string
+}
+
+// timeTicks puts at most n ticks on round local times between from and to
+func timeTicks(from, to time.Time, loc *time.Location, n int) []timeTick {
+	span := to.Sub(from)
+	ts := timeSteps[len(timeSteps)-1]
+	for _, s := range timeSteps {
+		d := s.step
+		if s.months > 0 {
+			d = time.Hour * 24 * 30 * time.Duration(s.months)
+		}
+		if span/d <= time.Duration(n) {
+			ts = s
+			break
+		}
+	}
+
+	from = from.In(loc)
+	y, m, d := from.Date()
+	var t time.Time
+	switch {
+	case ts.months > 0:
+		t = time.Date(y, time.Month((int(m)-1)/ts.months*ts.months+1), 1, 0, 0, 0, 0, loc)
+	case ts.step >= time.Hour*24:
+		t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	default:
+		hours := int(ts.step / time.Hour)
+		t = time.Date(y, m, d, from.Hour()/hours*hours, 0, 0, 0, loc)
+	}
+
+	var ticks []timeTick
+	for ; !t.After(to); t = next(t, ts) {
+		if t.Before(from) {
+			continue
+		}
+		ticks = append(ticks, timeTick{t: t, label: t.Format(ts.format)})
+	}
+	return ticks
+}
+
+func next(t time.Time, ts timeStep) time.Time {
+	switch {
+	case ts.months > 0:
+		return t.AddDate(0, ts.months, 0)
+	case ts.step >= time.Hour*24:
+		// by date, so a clock change doesn't shift the ticks off midnight
+		return t.AddDate(0, 0, int(ts.step/(time.Hour*24)))
+	}
+	return t.Add(ts.step)
+}
@@ -0,0 +1,114 @@
+// Package charts draws the archive as static images, time series and wind
+// roses, in SVG or PNG, for pages and e-mails that can't run Grafana. It is
+// pure Go so it renders on the Pi.
+package charts
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+)
+
+const (
+	SVG = "svg"
+	PNG = "png"
+)
+
+// Formats maps each image format to its content type
+var Formats = map[string]string{
+	SVG: "image/svg+xml",
+	PNG: "image/png",
+}
+
+// Options are the size and look of an image
+type Options struct {
+	Width  int
+	Height int
+	Dark   bool
+}
+
+const (
+	MinSize = 200
+	MaxSize = 2000
+)
+
+func (o Options) Validate() error {
+	if o.Width < MinSize || o.Width > MaxSize || o.Height < MinSize || o.Height > MaxSize {
+		return fmt.Errorf("width and height must be between %d and %d", MinSize, MaxSize)
+	}
+	return nil
+}
+
+type theme struct {
+	background color.RGBA
+	text       color.RGBA
+	muted      color.RGBA
+	grid       color.RGBA
+	series     []color.RGBA
+	bands      []color.RGBA
+}
+
+var (
+	light = theme{
+		background: rgb(0xffffff),
+		text:       rgb(0x1d2733),
+		muted:      rgb(0x6b7785),
+		grid:       rgb(0xe3e7eb),
+		series:     []color.RGBA{rgb(0x1f6feb), rgb(0xe8833a), rgb(0x2da44e), rgb(0x8250df)},
+		bands:      []color.RGBA{rgb(0xb6d7f2), rgb(0x6fb1e4), rgb(0x2f86c9), rgb(0xf2b84b), rgb(0xe8743a), rgb(0xc8312e)},
+	}
+	dark = theme{
+		background: rgb(0x1c2229),
+		text:       rgb(0xe6ebf0),
+		muted:      rgb(0x8b97a4),
+		grid:       rgb(0x2e3742),
+		series:     []color.RGBA{rgb(0x58a6ff), rgb(0xf0a35e), rgb(0x56d364), rgb(0xbc8cff)},
+		bands:      []color.RGBA{rgb(0x274b6d), rgb(0x2f6fa8), rgb(0x58a6ff), rgb(0xd29922), rgb(0xe8743a), rgb(0xf85149)},
+	}
+)
+
+func (o Options) theme() theme {
+	if o.Dark {
+		return dark
+	}
+	return light
+}
+
+func rgb(v uint32) color.RGBA {
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+type point struct {
+	x, y float64
+}
+
+type anchor int
+
+const (
+	start anchor = iota
+	middle
+	end
+)
+
+// canvas is what the charts draw on; text is placed by its baseline
+type canvas interface {
+	rect(x, y, w, h float64, c color.RGBA)
+	polyline(pts []point, width float64, c color.RGBA)
+	polygon(pts []point, c color.RGBA)
+	text(x, y float64, s string, a anchor, c color.RGBA)
+	encode(w io.Writer) error
+}
+
+func newCanvas(format string, o Options) (canvas, error) {
+	var c canvas
+	switch format {
+	case SVG:
+		c = newSVG(o.Width, o.Height)
+	case PNG:
+		c = newPNG(o.Width, o.Height)
+	default:
+		return nil, fmt.Errorf("format must be svg or png, not [%v]", format)
+	}
+	c.rect(0, 0, float64(o.Width), float64(o.Height), o.theme().background)
+	return c, nil
+}
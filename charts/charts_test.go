@@ -0,0 +1,107 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNiceTicks(t *testing.T) {
+	lo, hi, ticks := niceTicks(3.2, 17.9, 5)
+	require.Equal(t, 0.0, lo)
+	require.Equal(t, 20.0, hi)
+	require.Equal(t, []float64{0, 5, 10, 15, 20}, ticks)
+
+	lo, hi, ticks = niceTicks(1012.3, 1013.1, 4)
+	require.InDelta(t, 1012.2, lo, 1e-9)
+	require.InDelta(t, 1013.2, hi, 1e-9)
+	require.Len(t, ticks, 6)
+	require.Equal(t, "1012.4", label(ticks[1], ticks[1]-ticks[0]))
+
+	// flat data still gets a range
+	lo, hi, _ = niceTicks(5, 5, 4)
+	require.Less(t, lo, 5.0)
+	require.Greater(t, hi, 5.0)
+}
+
+func TestTimeTicks(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	to := time.Date(2026, 10, 18, 12, 30, 0, 0, loc)
+
+	ticks := timeTicks(to.Add(-24*time.Hour), to, loc, 8)
+	require.Len(t, ticks, 8)
+	require.Equal(t, "15:00", ticks[0].label)
+	require.Equal(t, "12:00", ticks[len(ticks)-1].label)
+
+	// days stay on midnight across the clocks going back
+	ticks = timeTicks(to.Add(-14*24*time.Hour), to.AddDate(0, 0, 14), loc, 10)
+	for _, tt := range ticks {
+		require.Zero(t, tt.t.Hour(), tt.label)
+	}
+}
+
+func chart() Chart {
+	from := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	c := Chart{
+		Title: "Temperature",
+		Unit:  "°C",
+		From:  from,
+		To:    from.Add(24 * time.Hour),
+		Step:  time.Hour,
+	}
+	var temp, dew []Point
+	for h := 0; h < 24; h++ {
+		if h == 10 || h == 11 || h == 12 || h == 13 {
+			continue // a gap
+		}
+		temp = append(temp, Point{Time: from.Add(time.Duration(h) * time.Hour), Value: 10 + float64(h%12)})
+		dew = append(dew, Point{Time: from.Add(time.Duration(h) * time.Hour), Value: 6})
+	}
+	c.Series = []Series{{Name: "temperature", Points: temp}, {Name: "dew point", Points: dew}}
+	return c
+}
+
+func TestChartSVG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, chart().Render(&buf, SVG, Options{Width: 600, Height: 300}))
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), new(interface{})), "well formed")
+	require.Contains(t, buf.String(), `width="600" height="300"`)
+	require.Contains(t, buf.String(), "<text x=\"56\" y=\"20\" text-anchor=\"start\" fill=\"#1d2733\">Temperature</text>")
+	// the gap splits the temperature into two lines, plus the dew point's two
+	require.Equal(t, 4, bytes.Count(buf.Bytes(), []byte("<polyline")))
+}
+
+func TestChartPNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, chart().Render(&buf, PNG, Options{Width: 600, Height: 300, Dark: true}))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	require.Equal(t, 600, img.Bounds().Dx())
+	r, g, b, _ := img.At(1, 1).RGBA()
+	require.Equal(t, dark.background, rgb(uint32(r>>8)<<16|uint32(g>>8)<<8|uint32(b>>8)))
+}
+
+func TestRose(t *testing.T) {
+	r := Rose{Title: "Wind rose", Bands: []string{"1-5 mph", "5-10 mph"}, Calm: 10}
+	r.Percent[4] = []float64{30, 20}
+	r.Percent[12] = []float64{40, 0}
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Render(&buf, SVG, Options{Width: 500, Height: 400}))
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), new(interface{})))
+	require.Contains(t, buf.String(), ">calm 10.0%</text>")
+	// the two bands from the east and one from the west, then the hub
+	require.Equal(t, 4, bytes.Count(buf.Bytes(), []byte("<polygon")))
+
+	buf.Reset()
+	require.NoError(t, r.Render(&buf, PNG, Options{Width: 500, Height: 400}))
+	_, err := png.Decode(&buf)
+	require.NoError(t, err)
+
+	require.Error(t, r.Render(&buf, "gif", Options{Width: 500, Height: 400}))
+}
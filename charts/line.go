@@ -0,0 +1,146 @@
+package charts
+
+import (
+	"io"
+	"math"
+	"time"
+)
+
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+type Kind int
+
+const (
+	Line Kind = iota
+	// Bars draws each point as a bar Chart.Step wide, for totals like rain
+	Bars
+)
+
+type Series struct {
+	Name   string
+	Kind   Kind
+	Points []Point
+}
+
+// Chart is a time series chart of one quantity, with one or more series
+type Chart struct {
+	Title  string
+	Unit   string
+	From   time.Time
+	To     time.Time
+	Series []Series
+	// Step is the time each point covers. A line breaks where points are
+	// more than 3 steps apart, rather than bridging missing data.
+	Step time.Duration
+	// Zero keeps zero on the value axis, for totals and speeds
+	Zero bool
+	// Location is the zone the time axis is labelled in
+	Location *time.Location
+}
+
+const (
+	marginLeft   = 56
+	marginRight  = 16
+	marginTop    = 40
+	marginBottom = 28
+	lineWidth    = 1.5
+)
+
+// Render draws the chart as format, SVG or PNG, to w
+func (c Chart) Render(w io.Writer, format string, o Options) error {
+	cv, err := newCanvas(format, o)
+	if err != nil {
+		return err
+	}
+	th := o.theme()
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	left, top := float64(marginLeft), float64(marginTop)
+	right, bottom := float64(o.Width-marginRight), float64(o.Height-marginBottom)
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		cv.text((left+right)/2, (top+bottom)/2, "no data", middle, th.muted)
+		lo, hi = 0, 1
+	}
+	if c.Zero {
+		lo, hi = math.Min(lo, 0), math.Max(hi, 0)
+	}
+	lo, hi, ticks := niceTicks(lo, hi, int((bottom-top)/50)+1)
+
+	span := c.To.Sub(c.From).Seconds()
+	x := func(t time.Time) float64 {
+		return left + t.Sub(c.From).Seconds()/span*(right-left)
+	}
+	y := func(v float64) float64 {
+		return bottom - (v-lo)/(hi-lo)*(bottom-top)
+	}
+
+	// grid and axes
+	for _, v := range ticks {
+		cv.rect(left, y(v), right-left, 1, th.grid)
+		cv.text(left-6, y(v)+4, label(v, ticks[1]-ticks[0]), end, th.muted)
+	}
+	for _, tt := range timeTicks(c.From, c.To, loc, int((right-left)/90)) {
+		cv.rect(x(tt.t), top, 1, bottom-top, th.grid)
+		cv.text(x(tt.t), bottom+16, tt.label, middle, th.muted)
+	}
+	cv.text(left, 20, c.Title, start, th.text)
+	cv.text(left-6, top-12, c.Unit, end, th.muted)
+
+	barWidth := math.Max(1, c.Step.Seconds()/span*(right-left)-1)
+	for i, s := range c.Series {
+		col := th.series[i%len(th.series)]
+		switch s.Kind {
+		case Bars:
+			for _, p := range s.Points {
+				high, base := y(math.Max(p.Value, 0)), y(math.Min(p.Value, 0))
+				cv.rect(x(p.Time), high, barWidth, base-high, col)
+			}
+		default:
+			for _, run := range c.runs(s.Points) {
+				pts := make([]point, len(run))
+				for j, p := range run {
+					pts[j] = point{x(p.Time), y(p.Value)}
+				}
+				cv.polyline(pts, lineWidth, col)
+			}
+		}
+	}
+
+	// legend, right aligned along the top
+	if len(c.Series) > 1 {
+		lx := right
+		for i := len(c.Series) - 1; i >= 0; i-- {
+			cv.text(lx, 20, c.Series[i].Name, end, th.text)
+			lx -= float64(len(c.Series[i].Name))*7 + 16
+			cv.rect(lx, 12, 10, 10, th.series[i%len(th.series)])
+			lx -= 12
+		}
+	}
+	return cv.encode(w)
+}
+
+// runs splits points where there is a gap in the data
+func (c Chart) runs(points []Point) [][]Point {
+	var runs [][]Point
+	begin := 0
+	for i := 1; i <= len(points); i++ {
+		if i == len(points) || (c.Step > 0 && points[i].Time.Sub(points[i-1].Time) > 3*c.Step) {
+			runs = append(runs, points[begin:i])
+			begin = i
+		}
+	}
+	return runs
+}
@@ -0,0 +1,110 @@
+package charts
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+type pngCanvas struct {
+	img *image.RGBA
+	r   *vector.Rasterizer
+}
+
+func newPNG(width, height int) *pngCanvas {
+	return &pngCanvas{
+		img: image.NewRGBA(image.Rect(0, 0, width, height)),
+		r:   vector.NewRasterizer(width, height),
+	}
+}
+
+// fill draws whatever has been added to the rasterizer
+func (p *pngCanvas) fill(c color.RGBA) {
+	p.r.Draw(p.img, p.img.Bounds(), image.NewUniform(c), image.Point{})
+	b := p.img.Bounds()
+	p.r.Reset(b.Dx(), b.Dy())
+}
+
+func (p *pngCanvas) path(pts []point) {
+	p.r.MoveTo(float32(pts[0].x), float32(pts[0].y))
+	for _, pt := range pts[1:] {
+		p.r.LineTo(float32(pt.x), float32(pt.y))
+	}
+	p.r.ClosePath()
+}
+
+func (p *pngCanvas) rect(x, y, w, h float64, c color.RGBA) {
+	p.path([]point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}})
+	p.fill(c)
+}
+
+// polyline strokes each segment as a quad, all wound the same way so
+// overlaps don't cancel out, with a square at each joint to close the gaps
+func (p *pngCanvas) polyline(pts []point, width float64, c color.RGBA) {
+	if len(pts) == 0 {
+		return
+	}
+	h := width / 2
+	for i := 1; i < len(pts); i++ {
+		a, b := pts[i-1], pts[i]
+		dx, dy := b.x-a.x, b.y-a.y
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy/l*h, dx/l*h
+		p.path([]point{{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny}})
+	}
+	for _, pt := range pts {
+		p.path([]point{{pt.x - h, pt.y + h}, {pt.x + h, pt.y + h}, {pt.x + h, pt.y - h}, {pt.x - h, pt.y - h}})
+	}
+	p.fill(c)
+}
+
+func (p *pngCanvas) polygon(pts []point, c color.RGBA) {
+	if len(pts) < 3 {
+		return
+	}
+	p.path(pts)
+	p.fill(c)
+}
+
+// text uses the 7x13 bitmap font, which is ascii only, so a degree sign is
+// drawn as a small ring
+func (p *pngCanvas) text(x, y float64, s string, a anchor, c color.RGBA) {
+	const advance = 7
+	w := float64(utf8.RuneCountInString(s) * advance)
+	switch a {
+	case middle:
+		x -= w / 2
+	case end:
+		x -= w
+	}
+	d := font.Drawer{Dst: p.img, Src: image.NewUniform(c), Face: basicfont.Face7x13}
+	for _, r := range s {
+		if r == '°' {
+			var ring []point
+			for deg := 0.0; deg <= 360; deg += 30 {
+				a := deg * math.Pi / 180
+				ring = append(ring, point{x + 3.5 + 2*math.Sin(a), y - 8 + 2*math.Cos(a)})
+			}
+			p.polyline(ring, 1, c)
+		} else {
+			d.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+			d.DrawString(string(r))
+		}
+		x += advance
+	}
+}
+
+func (p *pngCanvas) encode(w io.Writer) error {
+	return png.Encode(w, p.img)
+}
@@ -0,0 +1,114 @@
+package charts
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// Sectors is the number of compass points a rose is divided into
+const Sectors = 16
+
+var compassPoints = [Sectors]string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// Rose is a wind rose: for each sector, clockwise from north, the percentage
+// of the time the wind blew from it in each speed band.
+type Rose struct {
+	Title string
+	// Bands names the speed bands, slowest first, e.g. "1-5 mph"
+	Bands []string
+	// Percent[sector][band]
+	Percent [Sectors][]float64
+	// Calm is the percentage of the time too calm to have a direction
+	Calm float64
+}
+
+const legendWidth = 110
+
+// Render draws the rose as format, SVG or PNG, to w. The legend takes the
+// right hand side, the rose is fitted to what's left.
+func (r Rose) Render(w io.Writer, format string, o Options) error {
+	cv, err := newCanvas(format, o)
+	if err != nil {
+		return err
+	}
+	th := o.theme()
+
+	cx := float64(o.Width-legendWidth) / 2
+	cy := float64(o.Height+marginTop) / 2
+	radius := math.Min(cx, (float64(o.Height)-marginTop)/2) - 24
+
+	outer := 0.0
+	for _, bands := range r.Percent {
+		total := 0.0
+		for _, p := range bands {
+			total += p
+		}
+		outer = math.Max(outer, total)
+	}
+	_, outer, rings := niceTicks(0, math.Max(outer, 1), 4)
+
+	polar := func(deg, rad float64) point {
+		a := deg * math.Pi / 180
+		return point{cx + rad*math.Sin(a), cy - rad*math.Cos(a)}
+	}
+	circle := func(rad float64) []point {
+		var pts []point
+		for deg := 0.0; deg <= 360; deg += 5 {
+			pts = append(pts, polar(deg, rad))
+		}
+		return pts
+	}
+
+	// the calm circle is a fixed size in the middle, the sectors start at
+	// its edge
+	hub := radius * 0.12
+	scale := func(p float64) float64 {
+		return hub + p/outer*(radius-hub)
+	}
+	for _, v := range rings[1:] {
+		cv.polyline(circle(scale(v)), 1, th.grid)
+		p := polar(22.5/2, scale(v))
+		cv.text(p.x+2, p.y, fmt.Sprintf("%g%%", v), start, th.muted)
+	}
+	for i, name := range compassPoints {
+		if i%2 == 0 {
+			p := polar(float64(i)*22.5, radius+14)
+			cv.text(p.x, p.y+4, name, middle, th.muted)
+		}
+	}
+
+	// stacked wedges, 80% of a sector wide
+	const half = 22.5 * 0.4
+	for s, bands := range r.Percent {
+		mid := float64(s) * 22.5
+		inner := 0.0
+		for b, p := range bands {
+			if p <= 0 {
+				continue
+			}
+			var pts []point
+			for deg := mid - half; deg <= mid+half+0.01; deg += half / 4 {
+				pts = append(pts, polar(deg, scale(inner+p)))
+			}
+			for deg := mid + half; deg >= mid-half-0.01; deg -= half / 4 {
+				pts = append(pts, polar(deg, scale(inner)))
+			}
+			cv.polygon(pts, th.bands[b%len(th.bands)])
+			inner += p
+		}
+	}
+	cv.polygon(circle(hub), th.background)
+	cv.polyline(circle(hub), 1, th.grid)
+	cv.text(cx, cy+4, fmt.Sprintf("%.0f%%", r.Calm), middle, th.muted)
+
+	cv.text(16, 20, r.Title, start, th.text)
+	lx, ly := float64(o.Width-legendWidth), float64(marginTop+16)
+	cv.text(lx, ly, fmt.Sprintf("calm %.1f%%", r.Calm), start, th.muted)
+	for b := len(r.Bands) - 1; b >= 0; b-- {
+		ly += 18
+		cv.rect(lx, ly-10, 12, 12, th.bands[b%len(th.bands)])
+		cv.text(lx+18, ly, r.Bands[b], start, th.text)
+	}
+	return cv.encode(w)
+}
@@ -0,0 +1,67 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+type svgCanvas struct {
+	width, height int
+	buf           bytes.Buffer
+}
+
+func newSVG(width, height int) *svgCanvas {
+	return &svgCanvas{width: width, height: height}
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// num keeps coordinates short, a day of points is a lot of text
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
+
+func (s *svgCanvas) points(pts []point) string {
+	var b bytes.Buffer
+	for i, p := range pts {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(num(p.x))
+		b.WriteByte(',')
+		b.WriteString(num(p.y))
+	}
+	return b.String()
+}
+
+func (s *svgCanvas) rect(x, y, w, h float64, c color.RGBA) {
+	fmt.Fprintf(&s.buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n", num(x), num(y), num(w), num(h), hex(c))
+}
+
+func (s *svgCanvas) polyline(pts []point, width float64, c color.RGBA) {
+	fmt.Fprintf(&s.buf, "<polyline points=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%s\" stroke-linejoin=\"round\"/>\n", s.points(pts), hex(c), num(width))
+}
+
+func (s *svgCanvas) polygon(pts []point, c color.RGBA) {
+	fmt.Fprintf(&s.buf, "<polygon points=\"%s\" fill=\"%s\"/>\n", s.points(pts), hex(c))
+}
+
+var anchors = map[anchor]string{start: "start", middle: "middle", end: "end"}
+
+func (s *svgCanvas) text(x, y float64, v string, a anchor, c color.RGBA) {
+	fmt.Fprintf(&s.buf, "<text x=\"%s\" y=\"%s\" text-anchor=\"%s\" fill=\"%s\">", num(x), num(y), anchors[a], hex(c))
+	_ = xml.EscapeText(&s.buf, []byte(v)) // writes to a buffer, which can't fail
+	s.buf.WriteString("</text>\n")
+}
+
+func (s *svgCanvas) encode(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"sans-serif\" font-size=\"12\">\n%s</svg>\n",
+		s.width, s.height, s.width, s.height, s.buf.Bytes())
+	return err
+}
@@ -0,0 +1,46 @@
+package climate
+
+import (
+	"time"
+
+	"github.com/pointer2null/weather/env"
+)
+
+// A climatological day runs from 09:00 to 09:00 station time and takes the
+// date on which it starts. An observation covers the time up to it, so the
+// one at 09:00 ends the day rather than starting the next: a day is
+// (start, end]. Days are represented as midnight UTC on that date, which is
+// how a postgres DATE scans.
+
+// DayOf returns the climatological day that t falls in.
+func DayOf(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	y, m, d := local.Date()
+	if !local.After(time.Date(y, m, d, env.ClimateDayStartHour, 0, 0, 0, loc)) {
+		d--
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DayBounds returns the start and end of the climatological day.
+func DayBounds(day time.Time, loc *time.Location) (time.Time, time.Time) {
+	y, m, d := day.Date()
+	start := time.Date(y, m, d, env.ClimateDayStartHour, 0, 0, 0, loc)
+	end := time.Date(y, m, d+1, env.ClimateDayStartHour, 0, 0, 0, loc)
+	return start, end
+}
+
+// NextDay returns the day after day.
+func NextDay(day time.Time) time.Time {
+	return day.AddDate(0, 0, 1)
+}
+
+// ParseTime accepts RFC3339 timestamps or plain dates, which start at
+// midnight in loc.
+func ParseTime(v string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, loc)
+	return t.UTC(), err
+}
@@ -0,0 +1,487 @@
+package climate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+)
+
+// NOAA style monthly and yearly climatological summaries, laid out like the
+// NOAAMO/NOAAYR reports produced by WeeWX and Cumulus. Units are metric: C,
+// mm and the station's native mph for wind.
+
+const (
+	// degree day base, 65F
+	DegreeDayBase = 18.3
+
+	HotDayC      = 30.0
+	FreezingC    = 0.0
+	SevereFrostC = -18.0
+
+	RainDayMM    = 0.2
+	WetDayMM     = 2.0
+	VeryWetDayMM = 20.0
+)
+
+const noaaRuleLength = 87
+
+type NOAAHeader struct {
+	Name      string  `json:"name"`
+	Elevation float64 `json:"elevation_m"`
+}
+
+// NOAADay is a line of the monthly report. Values the station didn't read,
+// such as the temperature on a day the sensor was out, are nil and shown as
+// "---".
+type NOAADay struct {
+	Day              int      `json:"day"`
+	MeanTemp         *float64 `json:"mean_temp_C"`
+	HighTemp         *float64 `json:"high_temp_C"`
+	HighTime         string   `json:"high_time,omitempty"`
+	LowTemp          *float64 `json:"low_temp_C"`
+	LowTime          string   `json:"low_time,omitempty"`
+	HeatingDegreeDay *float64 `json:"heat_deg_days"`
+	CoolingDegreeDay *float64 `json:"cool_deg_days"`
+	Rain             float64  `json:"rain_mm"`
+	AvgWindSpeed     *float64 `json:"avg_wind_mph"`
+	HighWind         *float64 `json:"high_wind_mph"`
+	HighWindTime     string   `json:"high_wind_time,omitempty"`
+	DominantDir      *float64 `json:"dom_dir"`
+}
+
+// NOAACounts are the threshold day counts shown under both reports.
+type NOAACounts struct {
+	MaxAtOrAboveHot  int `json:"max_ge_30"`
+	MaxAtOrBelowZero int `json:"max_le_0"`
+	MinAtOrBelowZero int `json:"min_le_0"`
+	MinAtOrBelowM18  int `json:"min_le_minus_18"`
+	RainDays         int `json:"rain_ge_0_2"`
+	WetDays          int `json:"rain_ge_2"`
+	VeryWetDays      int `json:"rain_ge_20"`
+}
+
+type NOAAMonth struct {
+	NOAAHeader
+	Year  int       `json:"year"`
+	Month int       `json:"month"`
+	Days  []NOAADay `json:"days"`
+
+	MeanTemp         *float64   `json:"mean_temp_C"`
+	HighTemp         *float64   `json:"high_temp_C"`
+	HighTempDay      int        `json:"high_temp_day"`
+	LowTemp          *float64   `json:"low_temp_C"`
+	LowTempDay       int        `json:"low_temp_day"`
+	HeatingDegreeDay float64    `json:"heat_deg_days"`
+	CoolingDegreeDay float64    `json:"cool_deg_days"`
+	Rain             float64    `json:"rain_mm"`
+	MaxDayRain       float64    `json:"max_day_rain_mm"`
+	MaxDayRainDay    int        `json:"max_day_rain_day"`
+	AvgWindSpeed     *float64   `json:"avg_wind_mph"`
+	HighWind         *float64   `json:"high_wind_mph"`
+	HighWindDay      int        `json:"high_wind_day"`
+	DominantDir      *float64   `json:"dom_dir"`
+	Counts           NOAACounts `json:"counts"`
+}
+
+type NOAAYearMonth struct {
+	Month            int        `json:"month"`
+	Days             int        `json:"days"`
+	MeanMax          *float64   `json:"mean_max_C"`
+	MeanMin          *float64   `json:"mean_min_C"`
+	MeanTemp         *float64   `json:"mean_temp_C"`
+	HeatingDegreeDay float64    `json:"heat_deg_days"`
+	CoolingDegreeDay float64    `json:"cool_deg_days"`
+	HighTemp         *float64   `json:"high_temp_C"`
+	HighTempDay      int        `json:"high_temp_day"`
+	LowTemp          *float64   `json:"low_temp_C"`
+	LowTempDay       int        `json:"low_temp_day"`
+	Rain             float64    `json:"rain_mm"`
+	MaxDayRain       float64    `json:"max_day_rain_mm"`
+	MaxDayRainDay    int        `json:"max_day_rain_day"`
+	AvgWindSpeed     *float64   `json:"avg_wind_mph"`
+	HighWind         *float64   `json:"high_wind_mph"`
+	HighWindDay      int        `json:"high_wind_day"`
+	DominantDir      *float64   `json:"dom_dir"`
+	Counts           NOAACounts `json:"counts"`
+}
+
+type NOAAYear struct {
+	NOAAHeader
+	Year   int             `json:"year"`
+	Months []NOAAYearMonth `json:"months"`
+	Total  NOAAYearMonth   `json:"total"`
+}
+
+type NOAAReporter struct {
+	db       postgres.Querier
+	station  string
+	location *time.Location
+}
+
+func NewNOAAReporter(db postgres.Querier, station string, location *time.Location) *NOAAReporter {
+	return &NOAAReporter{
+		db:       db,
+		station:  station,
+		location: location,
+	}
+}
+
+func (n *NOAAReporter) header() NOAAHeader {
+	return NOAAHeader{Name: n.station, Elevation: env.StationElevation}
+}
+
+// Month loads the daily summaries for a month and builds its report.
+func (n *NOAAReporter) Month(ctx context.Context, year int, month time.Month) (NOAAMonth, error) {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	days, err := n.db.GetDailySummariesBetween(ctx, postgres.GetDailySummariesBetweenParams{
+		StationID: n.station,
+		FromDay:   first,
+		ToDay:     first.AddDate(0, 1, -1),
+	})
+	if err != nil {
+		return NOAAMonth{}, err
+	}
+	return BuildNOAAMonth(n.header(), year, month, days, n.location), nil
+}
+
+// Year loads the daily summaries for a year and builds its report.
+func (n *NOAAReporter) Year(ctx context.Context, year int) (NOAAYear, error) {
+	days, err := n.db.GetDailySummariesBetween(ctx, postgres.GetDailySummariesBetweenParams{
+		StationID: n.station,
+		FromDay:   time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+		ToDay:     time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		return NOAAYear{}, err
+	}
+	return BuildNOAAYear(n.header(), year, days), nil
+}
+
+// BuildNOAAMonth builds the monthly report from that month's daily summaries.
+// Times are shown in loc.
+func BuildNOAAMonth(header NOAAHeader, year int, month time.Month, days []postgres.DailySummary, loc *time.Location) NOAAMonth {
+	r := NOAAMonth{NOAAHeader: header, Year: year, Month: int(month)}
+	var wind windVector
+	var temp, windSpeed mean
+	var high, low, gust sql.NullFloat64
+	for i, d := range days {
+		day := NOAADay{
+			Day:          d.Day.Day(),
+			MeanTemp:     ptr(d.TemperatureMean),
+			HighTemp:     ptr(d.TemperatureMax),
+			HighTime:     clock(d.TemperatureMaxAt, loc),
+			LowTemp:      ptr(d.TemperatureMin),
+			LowTime:      clock(d.TemperatureMinAt, loc),
+			Rain:         d.RainTotal,
+			AvgWindSpeed: ptr(d.WindSpeedMean),
+			HighWind:     ptr(d.WindGustMax),
+			HighWindTime: clock(d.WindGustAt, loc),
+		}
+		if heat, cool, ok := degreeDays(d); ok {
+			day.HeatingDegreeDay, day.CoolingDegreeDay = &heat, &cool
+			r.HeatingDegreeDay += heat
+			r.CoolingDegreeDay += cool
+		}
+		if d.WindDirDominant.Valid && d.WindSpeedMean.Valid {
+			dir := d.WindDirDominant.Float64
+			day.DominantDir = &dir
+			wind.add(d.WindSpeedMean.Float64, dir)
+		}
+		r.Days = append(r.Days, day)
+
+		temp.add(d.TemperatureMean)
+		windSpeed.add(d.WindSpeedMean)
+		r.Rain += day.Rain
+		if greater(d.TemperatureMax, high) {
+			high, r.HighTempDay = d.TemperatureMax, day.Day
+		}
+		if less(d.TemperatureMin, low) {
+			low, r.LowTempDay = d.TemperatureMin, day.Day
+		}
+		if i == 0 || day.Rain > r.MaxDayRain {
+			r.MaxDayRain, r.MaxDayRainDay = day.Rain, day.Day
+		}
+		if greater(d.WindGustMax, gust) {
+			gust, r.HighWindDay = d.WindGustMax, day.Day
+		}
+		r.Counts.add(d)
+	}
+	r.HighTemp, r.LowTemp, r.HighWind = ptr(high), ptr(low), ptr(gust)
+	r.MeanTemp, r.AvgWindSpeed = temp.value(), windSpeed.value()
+	if dir, ok := wind.direction(); ok {
+		r.DominantDir = &dir
+	}
+	return r
+}
+
+// BuildNOAAYear builds the yearly report from the year's daily summaries.
+func BuildNOAAYear(header NOAAHeader, year int, days []postgres.DailySummary) NOAAYear {
+	r := NOAAYear{NOAAHeader: header, Year: year}
+	byMonth := make(map[time.Month][]postgres.DailySummary)
+	for _, d := range days {
+		byMonth[d.Day.Month()] = append(byMonth[d.Day.Month()], d)
+	}
+	for m := time.January; m <= time.December; m++ {
+		if len(byMonth[m]) == 0 {
+			continue
+		}
+		r.Months = append(r.Months, summariseYearMonth(int(m), byMonth[m]))
+	}
+	r.Total = summariseYearMonth(0, days)
+	return r
+}
+
+// summariseYearMonth summarises a month (or, for the total line, a whole
+// year). In the total line the *Day fields hold the month instead.
+func summariseYearMonth(month int, days []postgres.DailySummary) NOAAYearMonth {
+	r := NOAAYearMonth{Month: month, Days: len(days)}
+	var maxMean, minMean, temp, windSpeed mean
+	var high, low, gust sql.NullFloat64
+	var wind windVector
+	for i, d := range days {
+		maxMean.add(d.TemperatureMax)
+		minMean.add(d.TemperatureMin)
+		temp.add(d.TemperatureMean)
+		windSpeed.add(d.WindSpeedMean)
+		if heat, cool, ok := degreeDays(d); ok {
+			r.HeatingDegreeDay += heat
+			r.CoolingDegreeDay += cool
+		}
+		r.Rain += d.RainTotal
+
+		when := d.Day.Day()
+		if month == 0 {
+			when = int(d.Day.Month())
+		}
+		if greater(d.TemperatureMax, high) {
+			high, r.HighTempDay = d.TemperatureMax, when
+		}
+		if less(d.TemperatureMin, low) {
+			low, r.LowTempDay = d.TemperatureMin, when
+		}
+		if i == 0 || d.RainTotal > r.MaxDayRain {
+			r.MaxDayRain, r.MaxDayRainDay = d.RainTotal, when
+		}
+		if greater(d.WindGustMax, gust) {
+			gust, r.HighWindDay = d.WindGustMax, when
+		}
+		if d.WindDirDominant.Valid && d.WindSpeedMean.Valid {
+			wind.add(d.WindSpeedMean.Float64, d.WindDirDominant.Float64)
+		}
+		r.Counts.add(d)
+	}
+	r.HighTemp, r.LowTemp, r.HighWind = ptr(high), ptr(low), ptr(gust)
+	r.MeanMax, r.MeanMin, r.MeanTemp = maxMean.value(), minMean.value(), temp.value()
+	r.AvgWindSpeed = windSpeed.value()
+	if dir, ok := wind.direction(); ok {
+		r.DominantDir = &dir
+	}
+	return r
+}
+
+// degreeDays are the day's heating and cooling degree days, if it has a
+// mean temperature
+func degreeDays(d postgres.DailySummary) (float64, float64, bool) {
+	if !d.TemperatureMean.Valid {
+		return 0, 0, false
+	}
+	t := d.TemperatureMean.Float64
+	return math.Max(0, DegreeDayBase-t), math.Max(0, t-DegreeDayBase), true
+}
+
+// mean averages the values that are there
+type mean struct {
+	sum float64
+	n   int
+}
+
+func (m *mean) add(v sql.NullFloat64) {
+	if v.Valid {
+		m.sum += v.Float64
+		m.n++
+	}
+}
+
+func (m mean) value() *float64 {
+	if m.n == 0 {
+		return nil
+	}
+	v := m.sum / float64(m.n)
+	return &v
+}
+
+func ptr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// clock is the time of day t falls at in loc, or empty if there's no t
+func clock(t sql.NullTime, loc *time.Location) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.In(loc).Format("15:04")
+}
+
+func (c *NOAACounts) add(d postgres.DailySummary) {
+	if d.TemperatureMax.Valid && d.TemperatureMax.Float64 >= HotDayC {
+		c.MaxAtOrAboveHot++
+	}
+	if d.TemperatureMax.Valid && d.TemperatureMax.Float64 <= FreezingC {
+		c.MaxAtOrBelowZero++
+	}
+	if d.TemperatureMin.Valid && d.TemperatureMin.Float64 <= FreezingC {
+		c.MinAtOrBelowZero++
+	}
+	if d.TemperatureMin.Valid && d.TemperatureMin.Float64 <= SevereFrostC {
+		c.MinAtOrBelowM18++
+	}
+	if d.RainTotal >= RainDayMM {
+		c.RainDays++
+	}
+	if d.RainTotal >= WetDayMM {
+		c.WetDays++
+	}
+	if d.RainTotal >= VeryWetDayMM {
+		c.VeryWetDays++
+	}
+}
+
+// Text renders the report in the fixed width NOAAMO layout.
+func (r NOAAMonth) Text() string {
+	b := &strings.Builder{}
+	title := fmt.Sprintf("MONTHLY CLIMATOLOGICAL SUMMARY for %s %d", time.Month(r.Month).String()[:3], r.Year)
+	writeHeader(b, title, r.NOAAHeader)
+
+	fmt.Fprintf(b, "                   TEMPERATURE (C), RAIN (mm), WIND SPEED (mph)\n\n")
+	fmt.Fprintf(b, "                                      HEAT   COOL         AVG\n")
+	fmt.Fprintf(b, "      MEAN                            DEG    DEG          WIND                    DOM\n")
+	fmt.Fprintf(b, "DAY   TEMP   HIGH   TIME    LOW   TIME   DAYS   DAYS   RAIN  SPEED   HIGH   TIME   DIR\n")
+	rule(b)
+	for _, d := range r.Days {
+		fmt.Fprintf(b, " %02d %6s %6s  %5s %6s  %5s %6s %6s %6.1f %6s %6s  %5s  %4s\n",
+			d.Day, numText(d.MeanTemp), numText(d.HighTemp), timeText(d.HighTime), numText(d.LowTemp), timeText(d.LowTime),
+			numText(d.HeatingDegreeDay), numText(d.CoolingDegreeDay), d.Rain, numText(d.AvgWindSpeed), numText(d.HighWind),
+			timeText(d.HighWindTime), dirText(d.DominantDir))
+	}
+	rule(b)
+	fmt.Fprintf(b, "    %6s %6s  %5s %6s  %5s %6.1f %6.1f %6.1f %6s %6s  %5s  %4s\n\n",
+		numText(r.MeanTemp), numText(r.HighTemp), dayText(r.HighTempDay), numText(r.LowTemp), dayText(r.LowTempDay),
+		r.HeatingDegreeDay, r.CoolingDegreeDay, r.Rain, numText(r.AvgWindSpeed), numText(r.HighWind), dayText(r.HighWindDay),
+		dirText(r.DominantDir))
+
+	writeCounts(b, r.Counts)
+	fmt.Fprintf(b, "Max rain: %.1f on day %02d\n", r.MaxDayRain, r.MaxDayRainDay)
+	return b.String()
+}
+
+// Text renders the report in the fixed width NOAAYR layout.
+func (r NOAAYear) Text() string {
+	b := &strings.Builder{}
+	writeHeader(b, fmt.Sprintf("ANNUAL CLIMATOLOGICAL SUMMARY for %d", r.Year), r.NOAAHeader)
+
+	fmt.Fprintf(b, "                          TEMPERATURE (C)\n\n")
+	fmt.Fprintf(b, "                             HEAT   COOL\n")
+	fmt.Fprintf(b, "      MEAN   MEAN            DEG    DEG                          MAX    MAX    MIN    MIN\n")
+	fmt.Fprintf(b, " MO    MAX    MIN   MEAN    DAYS   DAYS    HI  DAY    LOW  DAY  >=30   <=0    <=0  <=-18\n")
+	rule(b)
+	for _, m := range r.Months {
+		fmt.Fprintf(b, " %02d %6s %6s %6s %7.1f %6.1f %6s  %3s %6s  %3s %5d %5d %6d %6d\n",
+			m.Month, numText(m.MeanMax), numText(m.MeanMin), numText(m.MeanTemp), m.HeatingDegreeDay, m.CoolingDegreeDay,
+			numText(m.HighTemp), dayText(m.HighTempDay), numText(m.LowTemp), dayText(m.LowTempDay),
+			m.Counts.MaxAtOrAboveHot, m.Counts.MaxAtOrBelowZero, m.Counts.MinAtOrBelowZero, m.Counts.MinAtOrBelowM18)
+	}
+	rule(b)
+	t := r.Total
+	fmt.Fprintf(b, "    %6s %6s %6s %7.1f %6.1f %6s  %3s %6s  %3s %5d %5d %6d %6d\n\n",
+		numText(t.MeanMax), numText(t.MeanMin), numText(t.MeanTemp), t.HeatingDegreeDay, t.CoolingDegreeDay,
+		numText(t.HighTemp), monthText(t.HighTempDay), numText(t.LowTemp), monthText(t.LowTempDay),
+		t.Counts.MaxAtOrAboveHot, t.Counts.MaxAtOrBelowZero, t.Counts.MinAtOrBelowZero, t.Counts.MinAtOrBelowM18)
+
+	fmt.Fprintf(b, "                          PRECIPITATION (mm)\n\n")
+	fmt.Fprintf(b, "                 MAX          ---NO. OF DAYS---\n")
+	fmt.Fprintf(b, " MO   TOTAL      OBS. DAY    >=0.2   >=2   >=20\n")
+	rule(b)
+	for _, m := range r.Months {
+		fmt.Fprintf(b, " %02d %7.1f %9.1f  %3d %7d %5d %6d\n",
+			m.Month, m.Rain, m.MaxDayRain, m.MaxDayRainDay, m.Counts.RainDays, m.Counts.WetDays, m.Counts.VeryWetDays)
+	}
+	rule(b)
+	fmt.Fprintf(b, "    %7.1f %9.1f  %3s %7d %5d %6d\n\n",
+		t.Rain, t.MaxDayRain, monthText(t.MaxDayRainDay), t.Counts.RainDays, t.Counts.WetDays, t.Counts.VeryWetDays)
+
+	fmt.Fprintf(b, "                          WIND SPEED (mph)\n\n")
+	fmt.Fprintf(b, "                                  DOM\n")
+	fmt.Fprintf(b, " MO    AVG     HI   DATE          DIR\n")
+	rule(b)
+	for _, m := range r.Months {
+		fmt.Fprintf(b, " %02d %6s %6s    %3s         %4s\n", m.Month, numText(m.AvgWindSpeed), numText(m.HighWind), dayText(m.HighWindDay), dirText(m.DominantDir))
+	}
+	rule(b)
+	fmt.Fprintf(b, "    %6s %6s    %3s         %4s\n", numText(t.AvgWindSpeed), numText(t.HighWind), monthText(t.HighWindDay), dirText(t.DominantDir))
+	return b.String()
+}
+
+func writeHeader(b *strings.Builder, title string, h NOAAHeader) {
+	fmt.Fprintf(b, "                   %s\n\n\n", title)
+	fmt.Fprintf(b, "NAME: %s\n", h.Name)
+	fmt.Fprintf(b, "ELEV: %.0f meters\n\n\n", h.Elevation)
+}
+
+func writeCounts(b *strings.Builder, c NOAACounts) {
+	fmt.Fprintf(b, "Max >=  %5.1f: %3d\n", HotDayC, c.MaxAtOrAboveHot)
+	fmt.Fprintf(b, "Max <=  %5.1f: %3d\n", FreezingC, c.MaxAtOrBelowZero)
+	fmt.Fprintf(b, "Min <=  %5.1f: %3d\n", FreezingC, c.MinAtOrBelowZero)
+	fmt.Fprintf(b, "Min <=  %5.1f: %3d\n", SevereFrostC, c.MinAtOrBelowM18)
+	fmt.Fprintf(b, "Rain >= %5.1f: %3d\n", RainDayMM, c.RainDays)
+	fmt.Fprintf(b, "Rain >= %5.1f: %3d\n", WetDayMM, c.WetDays)
+	fmt.Fprintf(b, "Rain >= %5.1f: %3d\n", VeryWetDayMM, c.VeryWetDays)
+}
+
+func rule(b *strings.Builder) {
+	b.WriteString(strings.Repeat("-", noaaRuleLength))
+	b.WriteString("\n")
+}
+
+func numText(v *float64) string {
+	if v == nil {
+		return "---"
+	}
+	return fmt.Sprintf("%.1f", *v)
+}
+
+func timeText(t string) string {
+	if t == "" {
+		return "---"
+	}
+	return t
+}
+
+func dayText(d int) string {
+	if d == 0 {
+		return "---"
+	}
+	return fmt.Sprintf("%d", d)
+}
+
+func dirText(dir *float64) string {
+	if dir == nil {
+		return "---"
+	}
+	return fmt.Sprintf("%.0f", math.Mod(math.Round(*dir), 360))
+}
+
+func monthText(m int) string {
+	if m < 1 || m > 12 {
+		return "---"
+	}
+	return strings.ToUpper(time.Month(m).String()[:3])
+}
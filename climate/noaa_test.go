@@ -0,0 +1,121 @@
+package climate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+func summaryFor(month time.Month, day int, min, max, mean, rain, gust float64, dir float64) postgres.DailySummary {
+	d := time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	return postgres.DailySummary{
+		Day:              d,
+		TemperatureMax:   valid(max),
+		TemperatureMaxAt: sql.NullTime{Time: d.Add(14 * time.Hour), Valid: true},
+		TemperatureMin:   valid(min),
+		TemperatureMinAt: sql.NullTime{Time: d.Add(30 * time.Hour), Valid: true},
+		TemperatureMean:  valid(mean),
+		RainTotal:        rain,
+		WindGustMax:      valid(gust),
+		WindGustAt:       sql.NullTime{Time: d.Add(16 * time.Hour), Valid: true},
+		WindSpeedMean:    valid(5),
+		WindDirDominant:  sql.NullFloat64{Float64: dir, Valid: true},
+	}
+}
+
+func TestNOAAMonth(t *testing.T) {
+	days := []postgres.DailySummary{
+		summaryFor(time.January, 1, -2, 3, 0.3, 0, 20, 350),
+		summaryFor(time.January, 2, 1, 8, 4.3, 2.4, 31, 10),
+		summaryFor(time.January, 3, -19, -1, -10, 25, 12, 0),
+	}
+	r := BuildNOAAMonth(NOAAHeader{Name: "home", Elevation: 25}, 2026, time.January, days, time.UTC)
+
+	require.Len(t, r.Days, 3)
+	require.Equal(t, "14:00", r.Days[0].HighTime)
+	require.Equal(t, "06:00", r.Days[0].LowTime)
+	require.InDelta(t, 18.0, *r.Days[0].HeatingDegreeDay, 1e-9)
+	require.Equal(t, 0.0, *r.Days[0].CoolingDegreeDay)
+
+	require.Equal(t, 8.0, *r.HighTemp)
+	require.Equal(t, 2, r.HighTempDay)
+	require.Equal(t, -19.0, *r.LowTemp)
+	require.Equal(t, 3, r.LowTempDay)
+	require.InDelta(t, 27.4, r.Rain, 1e-9)
+	require.Equal(t, 3, r.MaxDayRainDay)
+	require.Equal(t, 31.0, *r.HighWind)
+	require.InDelta(t, 18.0+14.0+28.3, r.HeatingDegreeDay, 1e-9)
+
+	// northerly either side of 0 must not average to south
+	require.NotNil(t, r.DominantDir)
+	require.True(t, *r.DominantDir < 1 || *r.DominantDir > 359)
+
+	require.Equal(t, NOAACounts{
+		MaxAtOrBelowZero: 1,
+		MinAtOrBelowZero: 2,
+		MinAtOrBelowM18:  1,
+		RainDays:         2,
+		WetDays:          2,
+		VeryWetDays:      1,
+	}, r.Counts)
+
+	text := r.Text()
+	require.Contains(t, text, "MONTHLY CLIMATOLOGICAL SUMMARY for Jan 2026")
+	require.Contains(t, text, "NAME: home")
+	require.Equal(t, 3, strings.Count(text, "  14:00 "))
+}
+
+func TestNOAAYear(t *testing.T) {
+	days := []postgres.DailySummary{
+		summaryFor(time.January, 1, -2, 3, 0.3, 1, 20, 270),
+		summaryFor(time.July, 20, 15, 31, 22.5, 0, 18, 270),
+		summaryFor(time.July, 21, 16, 28, 21.3, 12, 40, 270),
+	}
+	r := BuildNOAAYear(NOAAHeader{Name: "home"}, 2026, days)
+
+	require.Len(t, r.Months, 2)
+	require.Equal(t, 1, r.Months[0].Month)
+	require.Equal(t, 7, r.Months[1].Month)
+	require.Equal(t, 2, r.Months[1].Days)
+	require.InDelta(t, 4.2+3.0, r.Months[1].CoolingDegreeDay, 1e-9)
+	require.Equal(t, 1, r.Months[1].Counts.MaxAtOrAboveHot)
+
+	// in the total line the day fields hold the month
+	require.Equal(t, 31.0, *r.Total.HighTemp)
+	require.Equal(t, 7, r.Total.HighTempDay)
+	require.Equal(t, 1, r.Total.LowTempDay)
+	require.InDelta(t, 13.0, r.Total.Rain, 1e-9)
+
+	text := r.Text()
+	require.Contains(t, text, "ANNUAL CLIMATOLOGICAL SUMMARY for 2026")
+	require.Contains(t, text, "JUL")
+}
+
+func TestNOAAMonthWithoutReadings(t *testing.T) {
+	// the temperature sensor was out all of the 2nd
+	out := summaryFor(time.January, 2, 0, 0, 0, 4, 31, 10)
+	out.TemperatureMax, out.TemperatureMaxAt = sql.NullFloat64{}, sql.NullTime{}
+	out.TemperatureMin, out.TemperatureMinAt = sql.NullFloat64{}, sql.NullTime{}
+	out.TemperatureMean = sql.NullFloat64{}
+	days := []postgres.DailySummary{
+		summaryFor(time.January, 1, 2, 3, 2.5, 0, 20, 350),
+		out,
+	}
+	r := BuildNOAAMonth(NOAAHeader{Name: "home"}, 2026, time.January, days, time.UTC)
+
+	require.Nil(t, r.Days[1].HighTemp)
+	require.Nil(t, r.Days[1].HeatingDegreeDay)
+	require.Equal(t, 2.0, *r.LowTemp)
+	require.Equal(t, 1, r.LowTempDay)
+	require.Equal(t, 2.5, *r.MeanTemp)
+	require.InDelta(t, 15.8, r.HeatingDegreeDay, 1e-9)
+	require.Equal(t, NOAACounts{RainDays: 1, WetDays: 1}, r.Counts)
+	require.Contains(t, r.Text(), " 02    ---    ---    ---    ---    ---    ---    ---    4.0")
+
+	y := BuildNOAAYear(NOAAHeader{Name: "home"}, 2026, days)
+	require.Equal(t, 2.0, *y.Total.MeanMin)
+}
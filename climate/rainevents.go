@@ -0,0 +1,279 @@
+package climate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	logger "github.com/sirupsen/logrus"
+)
+
+// A rain event starts at the first tip after a dry spell and ends once it
+// has been dry as long again. Its end is the last tip.
+
+// rainPeaks are the windows the peak intensities are taken over
+var rainPeaks = []time.Duration{5 * time.Minute, 10 * time.Minute, time.Hour}
+
+type RainEvent struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Ongoing bool      `json:"ongoing"`
+	Total   float64   `json:"total_mm"`
+	// peak intensities, mm/h
+	Peak5  float64 `json:"peak_5min_mm_hr"`
+	Peak10 float64 `json:"peak_10min_mm_hr"`
+	Peak60 float64 `json:"peak_60min_mm_hr"`
+	// DryBefore is the time since the previous event's last tip, nil if
+	// there was none
+	DryBefore *time.Duration `json:"-"`
+}
+
+func (e RainEvent) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// MarshalJSON gives the durations in seconds
+func (e RainEvent) MarshalJSON() ([]byte, error) {
+	type event RainEvent
+	out := struct {
+		event
+		Duration  float64  `json:"duration_s"`
+		DryBefore *float64 `json:"dry_before_s"`
+	}{event: event(e), Duration: e.Duration().Seconds()}
+	if e.DryBefore != nil {
+		d := e.DryBefore.Seconds()
+		out.DryBefore = &d
+	}
+	return json.Marshal(out)
+}
+
+func (e RainEvent) params(station string) postgres.UpsertRainEventParams {
+	p := postgres.UpsertRainEventParams{
+		StationID: station,
+		StartAt:   e.Start,
+		EndAt:     e.End,
+		Ongoing:   e.Ongoing,
+		TotalMm:   e.Total,
+		DurationS: int64(e.Duration().Seconds()),
+		Peak5min:  e.Peak5,
+		Peak10min: e.Peak10,
+		Peak60min: e.Peak60,
+	}
+	if e.DryBefore != nil {
+		p.DryBeforeS = sql.NullInt64{Int64: int64(e.DryBefore.Seconds()), Valid: true}
+	}
+	return p
+}
+
+// RainEventFrom converts a stored event
+func RainEventFrom(r postgres.RainEvent) RainEvent {
+	e := RainEvent{
+		Start:   r.StartAt,
+		End:     r.EndAt,
+		Ongoing: r.Ongoing,
+		Total:   r.TotalMm,
+		Peak5:   r.Peak5min,
+		Peak10:  r.Peak10min,
+		Peak60:  r.Peak60min,
+	}
+	if r.DryBeforeS.Valid {
+		d := time.Duration(r.DryBeforeS.Int64) * time.Second
+		e.DryBefore = &d
+	}
+	return e
+}
+
+type rainTip struct {
+	t      time.Time
+	amount float64
+}
+
+// rainTipQueue is how many tips can wait for Run before more are dropped
+const rainTipQueue = 64
+
+// RainEvents follows the tips into events and stores each as it changes
+type RainEvents struct {
+	db      postgres.Querier
+	station string
+	dry     time.Duration
+	queue   chan rainTip
+
+	lock    sync.Mutex
+	current *RainEvent
+	// the current event's tips within the longest peak window
+	tips []rainTip
+	// the last tip of the previous event
+	lastRain  time.Time
+	listeners []func(RainEvent)
+}
+
+func NewRainEvents(db postgres.Querier, station string, dry time.Duration) *RainEvents {
+	return &RainEvents{db: db, station: station, dry: dry, queue: make(chan rainTip, rainTipQueue)}
+}
+
+// OnChange registers a function to call when an event starts or ends,
+// which Ongoing says
+func (re *RainEvents) OnChange(f func(RainEvent)) {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+	re.listeners = append(re.listeners, f)
+}
+
+// Load picks up from the latest stored event, carrying it on if the
+// station was stopped for less than the dry time. The tips before the stop
+// are gone, so its peaks only grow from what was stored.
+func (re *RainEvents) Load(ctx context.Context, now time.Time) error {
+	row, err := re.db.GetLatestRainEvent(ctx, re.station)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e := RainEventFrom(row)
+	re.lock.Lock()
+	if e.Ongoing && now.Sub(e.End) < re.dry {
+		re.current = &e
+		re.lock.Unlock()
+		return nil
+	}
+	re.lastRain = e.End
+	re.lock.Unlock()
+	if e.Ongoing {
+		e.Ongoing = false
+		re.save(e)
+	}
+	return nil
+}
+
+// Add queues a tip of amount mm at t for Run, without blocking; if Run has
+// fallen that far behind the tip is dropped.
+func (re *RainEvents) Add(t time.Time, amount float64) {
+	select {
+	case re.queue <- rainTip{t, amount}:
+	default:
+		logger.Errorf("Rain event queue full, dropping tip at [%v]", t)
+	}
+}
+
+// Tip adds amount mm of rain at t. It writes to the database, so the gpio
+// loop should Add rather than call it.
+func (re *RainEvents) Tip(t time.Time, amount float64) {
+	var changes []RainEvent
+	re.lock.Lock()
+	ended, ok := re.end(t)
+	if ok {
+		changes = append(changes, ended)
+	}
+	if re.current == nil {
+		e := RainEvent{Start: t, Ongoing: true}
+		if !re.lastRain.IsZero() {
+			d := t.Sub(re.lastRain)
+			e.DryBefore = &d
+		}
+		re.current = &e
+		re.tips = nil
+	}
+	e := re.current
+	re.tips = append(re.tips, rainTip{t, amount})
+	for len(re.tips) > 0 && t.Sub(re.tips[0].t) >= rainPeaks[len(rainPeaks)-1] {
+		re.tips = re.tips[1:]
+	}
+	e.End = t
+	e.Total += amount
+	e.Peak5 = max(e.Peak5, re.intensity(t, rainPeaks[0]))
+	e.Peak10 = max(e.Peak10, re.intensity(t, rainPeaks[1]))
+	e.Peak60 = max(e.Peak60, re.intensity(t, rainPeaks[2]))
+	current := *e
+	if e.Start.Equal(t) {
+		changes = append(changes, current)
+	}
+	listeners := re.listeners
+	re.lock.Unlock()
+
+	// saved outside the lock so Current doesn't wait on the database
+	if ok {
+		re.save(ended)
+	}
+	re.save(current)
+	for _, e := range changes {
+		for _, f := range listeners {
+			f(e)
+		}
+	}
+}
+
+// intensity is the rain over the window ending at t, mm/h
+func (re *RainEvents) intensity(t time.Time, window time.Duration) float64 {
+	sum := 0.0
+	for _, tip := range re.tips {
+		if t.Sub(tip.t) < window {
+			sum += tip.amount
+		}
+	}
+	return sum * float64(time.Hour) / float64(window)
+}
+
+// Check ends the current event if it has been dry long enough by now
+func (re *RainEvents) Check(now time.Time) {
+	re.lock.Lock()
+	ended, ok := re.end(now)
+	listeners := re.listeners
+	re.lock.Unlock()
+	if !ok {
+		return
+	}
+	re.save(ended)
+	for _, f := range listeners {
+		f(ended)
+	}
+}
+
+// end closes the current event if it has been dry since its last tip. The
+// caller saves it, once the lock is released.
+func (re *RainEvents) end(now time.Time) (RainEvent, bool) {
+	if re.current == nil || now.Sub(re.current.End) < re.dry {
+		return RainEvent{}, false
+	}
+	e := *re.current
+	e.Ongoing = false
+	logger.Infof("Rain event over, %.1f mm in %v", e.Total, e.Duration().Round(time.Minute))
+	re.current = nil
+	re.tips = nil
+	re.lastRain = e.End
+	return e, true
+}
+
+func (re *RainEvents) save(e RainEvent) {
+	if err := re.db.UpsertRainEvent(context.Background(), e.params(re.station)); err != nil {
+		logger.Errorf("Failed to save rain event [%v] [%v]", e.Start, err)
+	}
+}
+
+// Run adds the queued tips and ends events as the dry time passes, forever.
+// Only Run saves tips, so they reach the database in order.
+func (re *RainEvents) Run() {
+	check := time.NewTicker(time.Minute)
+	for {
+		select {
+		case tip := <-re.queue:
+			re.Tip(tip.t, tip.amount)
+		case now := <-check.C:
+			re.Check(now)
+		}
+	}
+}
+
+// Current is the event under way, false when it is dry
+func (re *RainEvents) Current() (RainEvent, bool) {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+	if re.current == nil {
+		return RainEvent{}, false
+	}
+	return *re.current, true
+}
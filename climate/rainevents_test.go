@@ -0,0 +1,138 @@
+package climate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+type rainEventStore struct {
+	postgres.Querier
+	events map[time.Time]postgres.UpsertRainEventParams
+}
+
+func (r *rainEventStore) UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error {
+	r.events[arg.StartAt] = arg
+	return nil
+}
+
+func (r *rainEventStore) GetLatestRainEvent(ctx context.Context, stationID string) (postgres.RainEvent, error) {
+	var latest *postgres.RainEvent
+	for _, e := range r.events {
+		if latest == nil || e.StartAt.After(latest.StartAt) {
+			latest = &postgres.RainEvent{
+				StationID: e.StationID, StartAt: e.StartAt, EndAt: e.EndAt, Ongoing: e.Ongoing, TotalMm: e.TotalMm,
+				DurationS: e.DurationS, Peak5min: e.Peak5min, Peak10min: e.Peak10min, Peak60min: e.Peak60min, DryBeforeS: e.DryBeforeS,
+			}
+		}
+	}
+	if latest == nil {
+		return postgres.RainEvent{}, sql.ErrNoRows
+	}
+	return *latest, nil
+}
+
+func TestRainEvents(t *testing.T) {
+	t0 := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := &rainEventStore{events: map[time.Time]postgres.UpsertRainEventParams{}}
+	re := NewRainEvents(store, "home", 30*time.Minute)
+	var changes []RainEvent
+	re.OnChange(func(e RainEvent) { changes = append(changes, e) })
+	require.NoError(t, re.Load(context.Background(), t0))
+	at := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+
+	// 5 tips in the first 4 minutes, then one every 10 minutes
+	for _, m := range []int{0, 1, 2, 3, 4, 14, 24} {
+		re.Tip(at(m), 0.2)
+	}
+	require.Len(t, changes, 1)
+	require.True(t, changes[0].Ongoing)
+	require.Equal(t, 0.2, changes[0].Total)
+	require.Nil(t, changes[0].DryBefore)
+	e, ok := re.Current()
+	require.True(t, ok)
+	require.InDelta(t, 1.4, e.Total, 1e-9)
+	require.Equal(t, 24*time.Minute, e.Duration())
+	require.InDelta(t, 12, e.Peak5, 1e-9) // 1 mm in 5 minutes
+	require.InDelta(t, 6, e.Peak10, 1e-9) // the same 1 mm over 10 minutes
+	require.InDelta(t, 1.4, e.Peak60, 1e-9)
+
+	// still raining 29 minutes after the last tip
+	re.Check(at(53))
+	require.Len(t, changes, 1)
+	re.Check(at(54))
+	require.Len(t, changes, 2)
+	require.False(t, changes[1].Ongoing)
+	_, ok = re.Current()
+	require.False(t, ok)
+	stored := store.events[at(0)]
+	require.False(t, stored.Ongoing)
+	require.Equal(t, int64(24*60), stored.DurationS)
+
+	// the next event knows how long it was dry, after a restart
+	re = NewRainEvents(store, "home", 30*time.Minute)
+	require.NoError(t, re.Load(context.Background(), at(100)))
+	re.Tip(at(124), 0.2)
+	e, ok = re.Current()
+	require.True(t, ok)
+	require.Equal(t, 100*time.Minute, *e.DryBefore)
+	require.Equal(t, sql.NullInt64{Int64: 6000, Valid: true}, store.events[at(124)].DryBeforeS)
+
+	// a tip after the dry time ends the event without a check
+	re.OnChange(func(e RainEvent) { changes = append(changes, e) })
+	re.Tip(at(200), 0.2)
+	require.Len(t, changes, 4)
+	require.False(t, changes[2].Ongoing)
+	require.Equal(t, at(124), changes[2].Start)
+	require.Equal(t, at(200), changes[3].Start)
+
+	// an event under way when the station stopped carries on
+	re = NewRainEvents(store, "home", 30*time.Minute)
+	require.NoError(t, re.Load(context.Background(), at(210)))
+	re.Tip(at(215), 0.2)
+	e, ok = re.Current()
+	require.True(t, ok)
+	require.Equal(t, at(200), e.Start)
+	require.InDelta(t, 0.4, e.Total, 1e-9)
+
+	b, err := json.Marshal(e)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"duration_s":900`)
+	require.Contains(t, string(b), `"dry_before_s":4560`)
+}
+
+// blockingStore holds every save until released
+type blockingStore struct {
+	rainEventStore
+	release chan struct{}
+}
+
+func (b *blockingStore) UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error {
+	<-b.release
+	return nil
+}
+
+func TestRainEventsAddDoesNotWaitForTheDatabase(t *testing.T) {
+	store := &blockingStore{release: make(chan struct{})}
+	re := NewRainEvents(store, "home", 30*time.Minute)
+	go re.Run()
+
+	now := time.Now()
+	re.Add(now, 0.2)
+	re.Add(now.Add(time.Second), 0.2)
+	// the first save is held, but the tip is counted and can be read
+	require.Eventually(t, func() bool {
+		e, ok := re.Current()
+		return ok && e.Total == 0.2
+	}, time.Second, time.Millisecond)
+	close(store.release)
+	require.Eventually(t, func() bool {
+		e, _ := re.Current()
+		return e.Total == 0.4
+	}, time.Second, time.Millisecond)
+}
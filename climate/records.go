@@ -0,0 +1,375 @@
+package climate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Station records are kept for every metric at three scopes: all time, each
+// year, and each calendar month across all years (e.g. warmest October).
+
+type Metric string
+
+const (
+	TemperatureHigh Metric = "temperature_high"
+	TemperatureLow  Metric = "temperature_low"
+	WindGust        Metric = "wind_gust"
+	WettestDay      Metric = "wettest_day"
+	WettestHour     Metric = "wettest_hour"
+	RainRate        Metric = "rain_rate"
+	PressureHigh    Metric = "pressure_high"
+	PressureLow     Metric = "pressure_low"
+)
+
+// Metrics lists every tracked metric
+var Metrics = []Metric{TemperatureHigh, TemperatureLow, WindGust, WettestDay, WettestHour, RainRate, PressureHigh, PressureLow}
+
+// lower reports whether a smaller value beats the record
+func (m Metric) lower() bool {
+	return m == TemperatureLow || m == PressureLow
+}
+
+func (m Metric) beats(value float64, record float64) bool {
+	if m.lower() {
+		return value < record
+	}
+	return value > record
+}
+
+const (
+	ScopeAll   = "all"
+	ScopeYear  = "year"
+	ScopeMonth = "month"
+)
+
+type RecordKey struct {
+	Scope  string `json:"scope"`
+	Period string `json:"period"`
+	Metric Metric `json:"metric"`
+}
+
+type Record struct {
+	RecordKey
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// Event is raised when a record that already existed is broken.
+type Event struct {
+	Station  string  `json:"station"`
+	Record   Record  `json:"record"`
+	Previous *Record `json:"previous"`
+}
+
+// keysFor returns the record keys that an observation at t counts towards.
+func keysFor(m Metric, t time.Time, loc *time.Location) []RecordKey {
+	local := t.In(loc)
+	return []RecordKey{
+		{Scope: ScopeAll, Period: "", Metric: m},
+		{Scope: ScopeYear, Period: fmt.Sprintf("%04d", local.Year()), Metric: m},
+		{Scope: ScopeMonth, Period: fmt.Sprintf("%02d", int(local.Month())), Metric: m},
+	}
+}
+
+// recordSet is a plain map of records with the rule for replacing them
+type recordSet map[RecordKey]Record
+
+// offer applies a value to every scope and returns the records it broke,
+// paired with what they replaced (nil if there was no record yet).
+func (rs recordSet) offer(m Metric, value float64, t time.Time, loc *time.Location) ([]Record, []*Record) {
+	var broken []Record
+	var previous []*Record
+	for _, k := range keysFor(m, t, loc) {
+		old, ok := rs[k]
+		if ok && !m.beats(value, old.Value) {
+			continue
+		}
+		r := Record{RecordKey: k, Value: value, Time: t}
+		rs[k] = r
+		broken = append(broken, r)
+		if ok {
+			prev := old
+			previous = append(previous, &prev)
+		} else {
+			previous = append(previous, nil)
+		}
+	}
+	return broken, previous
+}
+
+// ComputeRecords builds every record from raw observations, skipping values
+// quality control flagged. A record's rain belongs to the period that ends at
+// its timestamp. The wettest hour is the most rain in the 60 minutes up to
+// any observation, as it is tracked live, and the wettest day the most in a
+// climatological day.
+func ComputeRecords(records []postgres.Weather, loc *time.Location) map[RecordKey]Record {
+	rs := recordSet{}
+	type rain struct {
+		at time.Time
+		mm float64
+	}
+	var rains []rain
+	days := make(map[time.Time]float64)
+	for _, r := range records {
+		r = r.Checked()
+		t := r.ObservedAt
+		if r.Temperature.Valid {
+			rs.offer(TemperatureHigh, r.Temperature.Float64, t, loc)
+			rs.offer(TemperatureLow, r.Temperature.Float64, t, loc)
+		}
+		if r.WindGust.Valid {
+			rs.offer(WindGust, r.WindGust.Float64, t, loc)
+		}
+		if r.Pressure.Valid {
+			rs.offer(PressureHigh, r.Pressure.Float64, t, loc)
+			rs.offer(PressureLow, r.Pressure.Float64, t, loc)
+		}
+		if r.RainRate.Valid {
+			rs.offer(RainRate, r.RainRate.Float64, t, loc)
+		}
+		rains = append(rains, rain{at: t, mm: r.RainMm})
+		days[DayOf(t, loc)] += r.RainMm
+	}
+	sort.Slice(rains, func(i, j int) bool { return rains[i].at.Before(rains[j].at) })
+	var hour float64
+	first := 0
+	for _, r := range rains {
+		hour += r.mm
+		for !rains[first].at.After(r.at.Add(-time.Hour)) {
+			hour -= rains[first].mm
+			first++
+		}
+		if hour > 0 {
+			rs.offer(WettestHour, hour, r.at, loc)
+		}
+	}
+	for day, total := range days {
+		if total > 0 {
+			start, _ := DayBounds(day, loc)
+			rs.offer(WettestDay, total, start, loc)
+		}
+	}
+	return rs
+}
+
+type RecordTracker struct {
+	db       postgres.Querier
+	station  string
+	location *time.Location
+
+	lock      sync.Mutex
+	records   recordSet
+	lastEvent map[RecordKey]time.Time
+	listeners []func(Event)
+}
+
+func NewRecordTracker(db postgres.Querier, station string, location *time.Location) *RecordTracker {
+	return &RecordTracker{
+		db:        db,
+		station:   station,
+		location:  location,
+		records:   recordSet{},
+		lastEvent: make(map[RecordKey]time.Time),
+	}
+}
+
+// OnRecord registers a function to call whenever a record is broken
+func (rt *RecordTracker) OnRecord(f func(Event)) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.listeners = append(rt.listeners, f)
+}
+
+// transactor is a store that can run queries in one transaction
+type transactor interface {
+	InTx(ctx context.Context, f func(postgres.Querier) error) error
+}
+
+// Recompute rebuilds every record from the archive, a year at a time, and
+// replaces the stored set. Records broken live while it runs would be lost,
+// so it should finish before the first Observe.
+func (rt *RecordTracker) Recompute(ctx context.Context) error {
+	all := recordSet{}
+	first, err := rt.db.GetFirstObservation(ctx, rt.station)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		// step on climatological day boundaries so no day or hour is split
+		start, _ := DayBounds(DayOf(first, rt.location), rt.location)
+		for from := start; from.Before(time.Now()); from = from.AddDate(1, 0, 0) {
+			rows, err := rt.db.GetRecordsBetween(ctx, postgres.GetRecordsBetweenParams{
+				StationID: rt.station,
+				FromTime:  from,
+				ToTime:    from.AddDate(1, 0, 0),
+			})
+			if err != nil {
+				return err
+			}
+			for _, r := range ComputeRecords(rows, rt.location) {
+				all.offer(r.Metric, r.Value, r.Time, rt.location)
+			}
+		}
+	}
+
+	// replaced in one transaction, so a failure keeps the old set
+	replace := func(db postgres.Querier) error {
+		if err := db.DeleteStationRecords(ctx, rt.station); err != nil {
+			return err
+		}
+		for _, r := range all {
+			if err := saveRecord(ctx, db, rt.station, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if tx, ok := rt.db.(transactor); ok {
+		err = tx.InTx(ctx, replace)
+	} else {
+		err = replace(rt.db)
+	}
+	if err != nil {
+		return err
+	}
+	rt.lock.Lock()
+	rt.records = all
+	rt.lock.Unlock()
+	logger.Infof("Recomputed %d station records", len(all))
+	return nil
+}
+
+// Load reads the stored records without recomputing them
+func (rt *RecordTracker) Load(ctx context.Context) error {
+	rows, err := rt.db.GetStationRecords(ctx, rt.station)
+	if err != nil {
+		return err
+	}
+	rs := recordSet{}
+	for _, r := range rows {
+		k := RecordKey{Scope: r.Scope, Period: r.Period, Metric: Metric(r.Metric)}
+		rs[k] = Record{RecordKey: k, Value: r.Value, Time: r.ObservedAt}
+	}
+	rt.lock.Lock()
+	rt.records = rs
+	rt.lock.Unlock()
+	return nil
+}
+
+// Observe checks live values against the records. Only metrics present in
+// values are considered, so disabled sensors can simply be left out.
+func (rt *RecordTracker) Observe(t time.Time, values map[Metric]float64) {
+	rt.lock.Lock()
+	var saves []Record
+	var events []Event
+	for _, m := range Metrics {
+		v, ok := values[m]
+		if !ok {
+			continue
+		}
+		broken, previous := rt.records.offer(m, v, t, rt.location)
+		for i, r := range broken {
+			saves = append(saves, r)
+			if previous[i] == nil {
+				// first value for this scope is not news
+				continue
+			}
+			// a rising temperature can beat the record every minute, so only
+			// announce each record once per hold off period
+			if last, ok := rt.lastEvent[r.RecordKey]; ok && t.Sub(last) < env.RecordEventHoldOff {
+				continue
+			}
+			rt.lastEvent[r.RecordKey] = t
+			events = append(events, Event{Station: rt.station, Record: r, Previous: previous[i]})
+		}
+	}
+	listeners := rt.listeners
+	rt.lock.Unlock()
+
+	for _, r := range saves {
+		if err := rt.save(context.Background(), r); err != nil {
+			logger.Errorf("Failed to save record [%v] [%v]", r.RecordKey, err)
+		}
+	}
+	for _, e := range events {
+		for _, f := range listeners {
+			f(e)
+		}
+	}
+}
+
+// Records returns the current records, optionally limited to one scope and
+// period.
+func (rt *RecordTracker) Records(scope string, period string) []Record {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	var out []Record
+	for _, r := range rt.records {
+		if scope != "" && r.Scope != scope {
+			continue
+		}
+		if period != "" && r.Period != period {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func (rt *RecordTracker) save(ctx context.Context, r Record) error {
+	return saveRecord(ctx, rt.db, rt.station, r)
+}
+
+func saveRecord(ctx context.Context, db postgres.Querier, station string, r Record) error {
+	return db.UpsertStationRecord(ctx, postgres.UpsertStationRecordParams{
+		StationID:  station,
+		Scope:      r.Scope,
+		Period:     r.Period,
+		Metric:     string(r.Metric),
+		Value:      r.Value,
+		ObservedAt: r.Time.UTC(),
+	})
+}
+
+// LogRecord is an OnRecord listener that logs the new record
+func LogRecord(e Event) {
+	logger.Infof("New %v record [%v %v]: %.2f (was %.2f on %v)",
+		e.Record.Scope, e.Record.Metric, e.Record.Period, e.Record.Value, e.Previous.Value, e.Previous.Time.Format(time.RFC822))
+}
+
+// WebhookNotifier returns an OnRecord listener that POSTs each event as JSON
+// to url.
+func WebhookNotifier(url string) func(Event) {
+	client := http.Client{Timeout: time.Second * 10}
+	return func(e Event) {
+		js, err := json.Marshal(e)
+		if err != nil {
+			logger.Errorf("Failed to encode record event [%v]", err)
+			return
+		}
+		// don't hold up the reporting loop on a slow receiver
+		go func() {
+			resp, err := client.Post(url, "application/json", bytes.NewReader(js))
+			if err != nil {
+				logger.Errorf("Failed to send record webhook [%v]", err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				logger.Errorf("Record webhook failed HTTP [%v]", resp.Status)
+			}
+		}()
+	}
+}
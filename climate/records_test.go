@@ -0,0 +1,99 @@
+package climate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+type recordStore struct {
+	postgres.Querier
+	saved []postgres.UpsertStationRecordParams
+}
+
+func (r *recordStore) UpsertStationRecord(ctx context.Context, arg postgres.UpsertStationRecordParams) error {
+	r.saved = append(r.saved, arg)
+	return nil
+}
+
+func TestComputeRecords(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2026, 3, d, h, m, 0, 0, time.UTC) }
+	rs := ComputeRecords([]postgres.Weather{
+		{ObservedAt: at(1, 10, 10), Temperature: valid(5), Pressure: valid(1000), WindGust: valid(10), RainMm: 1},
+		{ObservedAt: at(1, 11, 0), Temperature: valid(12), Pressure: valid(990), WindGust: valid(30), RainMm: 2, RainRate: sql.NullFloat64{Float64: 9, Valid: true}},
+		{ObservedAt: at(1, 11, 10), Temperature: valid(8), Pressure: valid(1020), WindGust: valid(12), RainMm: 0.5},
+		// after 09:00 the next day, so a new climate day
+		{ObservedAt: at(2, 9, 10), Temperature: valid(-3), Pressure: valid(1005), WindGust: valid(5), RainMm: 2.8},
+		// flagged values don't count
+		{ObservedAt: at(2, 9, 20), Temperature: valid(4), Pressure: valid(1005), WindGust: valid(250), QcFlags: sql.NullString{String: `{"wind_gust":"failed"}`, Valid: true}},
+	}, time.UTC)
+
+	get := func(scope, period string, m Metric) Record {
+		r, ok := rs[RecordKey{Scope: scope, Period: period, Metric: m}]
+		require.True(t, ok, "%v %v %v", scope, period, m)
+		return r
+	}
+	require.Equal(t, 12.0, get(ScopeAll, "", TemperatureHigh).Value)
+	require.Equal(t, at(1, 11, 0), get(ScopeAll, "", TemperatureHigh).Time)
+	require.Equal(t, -3.0, get(ScopeYear, "2026", TemperatureLow).Value)
+	require.Equal(t, 30.0, get(ScopeMonth, "03", WindGust).Value)
+	require.Equal(t, 990.0, get(ScopeAll, "", PressureLow).Value)
+	require.Equal(t, 1020.0, get(ScopeAll, "", PressureHigh).Value)
+	require.Equal(t, 9.0, get(ScopeAll, "", RainRate).Value)
+
+	// the hour ending 11:00 has both, whichever clock hours they fall in
+	require.Equal(t, 3.0, get(ScopeAll, "", WettestHour).Value)
+	require.Equal(t, at(1, 11, 0), get(ScopeAll, "", WettestHour).Time)
+	require.Equal(t, 3.5, get(ScopeAll, "", WettestDay).Value)
+	require.Equal(t, at(1, 9, 0), get(ScopeAll, "", WettestDay).Time)
+}
+
+func TestWettestHourSpansClockHours(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 1, h, m, 0, 0, time.UTC) }
+	rs := ComputeRecords([]postgres.Weather{
+		{ObservedAt: at(10, 40), RainMm: 1.5},
+		{ObservedAt: at(11, 10), RainMm: 1.5},
+		{ObservedAt: at(12, 0), RainMm: 0.5},
+		// a flagged gauge adds nothing
+		{ObservedAt: at(12, 10), RainMm: 20, QcFlags: sql.NullString{String: `{"rain_day":"failed"}`, Valid: true}},
+	}, time.UTC)
+
+	r := rs[RecordKey{Scope: ScopeAll, Metric: WettestHour}]
+	require.Equal(t, 3.0, r.Value)
+	require.Equal(t, at(11, 10), r.Time)
+}
+
+func TestObserveRaisesEvents(t *testing.T) {
+	store := &recordStore{}
+	rt := NewRecordTracker(store, "home", time.UTC)
+	var events []Event
+	rt.OnRecord(func(e Event) { events = append(events, e) })
+
+	t0 := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	// first values set the records quietly
+	rt.Observe(t0, map[Metric]float64{TemperatureHigh: 20})
+	require.Empty(t, events)
+	require.Len(t, store.saved, 3)
+
+	// not a record
+	rt.Observe(t0.Add(time.Minute), map[Metric]float64{TemperatureHigh: 19})
+	require.Empty(t, events)
+
+	// breaks all three scopes
+	rt.Observe(t0.Add(2*time.Minute), map[Metric]float64{TemperatureHigh: 21})
+	require.Len(t, events, 3)
+	require.Equal(t, 20.0, events[0].Previous.Value)
+
+	// still climbing, stored but not announced again within the hold off
+	rt.Observe(t0.Add(3*time.Minute), map[Metric]float64{TemperatureHigh: 22})
+	require.Len(t, events, 3)
+	require.Len(t, rt.Records(ScopeAll, ""), 1)
+	require.Equal(t, 22.0, rt.Records(ScopeAll, "")[0].Value)
+
+	rt.Observe(t0.Add(2*time.Hour), map[Metric]float64{TemperatureHigh: 23})
+	require.Len(t, events, 6)
+}
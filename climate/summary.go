@@ -0,0 +1,180 @@
+package climate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Summarise builds the summary for a climatological day from the records
+// that fall within it. It returns false if there were no records. Values
+// that weren't read or that quality control flagged are left out, and one
+// that no record has is NULL.
+func Summarise(station string, day time.Time, loc *time.Location, records []postgres.Weather) (postgres.UpsertDailySummaryParams, bool) {
+	start, end := DayBounds(day, loc)
+	s := postgres.UpsertDailySummaryParams{
+		StationID:   station,
+		Day:         day,
+		PeriodStart: start.UTC(),
+		PeriodEnd:   end.UTC(),
+	}
+	if len(records) == 0 {
+		return s, false
+	}
+
+	var tempSum, windSum float64
+	var tempN, windN int
+	var dominant windVector
+	for _, r := range records {
+		r = r.Checked()
+		at := sql.NullTime{Time: r.ObservedAt, Valid: true}
+		s.RainTotal += r.RainMm
+
+		if r.Temperature.Valid {
+			tempSum += r.Temperature.Float64
+			tempN++
+		}
+		if greater(r.Temperature, s.TemperatureMax) {
+			s.TemperatureMax, s.TemperatureMaxAt = r.Temperature, at
+		}
+		if less(r.Temperature, s.TemperatureMin) {
+			s.TemperatureMin, s.TemperatureMinAt = r.Temperature, at
+		}
+		if r.WindSpeed.Valid {
+			windSum += r.WindSpeed.Float64
+			windN++
+			if r.WindDirection.Valid {
+				dominant.add(r.WindSpeed.Float64, r.WindDirection.Float64)
+			}
+		}
+		if greater(r.WindGust, s.WindGustMax) {
+			s.WindGustMax, s.WindGustDir, s.WindGustAt = r.WindGust, r.WindDirection, at
+		}
+		if greater(r.Pressure, s.PressureMax) {
+			s.PressureMax = r.Pressure
+		}
+		if less(r.Pressure, s.PressureMin) {
+			s.PressureMin = r.Pressure
+		}
+		if greater(r.RainRate, s.RainRateMax) {
+			s.RainRateMax = r.RainRate
+		}
+	}
+	s.Samples = int32(len(records))
+	if tempN > 0 {
+		s.TemperatureMean = sql.NullFloat64{Float64: tempSum / float64(tempN), Valid: true}
+	}
+	if windN > 0 {
+		s.WindSpeedMean = sql.NullFloat64{Float64: windSum / float64(windN), Valid: true}
+	}
+	if dir, ok := dominant.direction(); ok {
+		s.WindDirDominant = sql.NullFloat64{Float64: dir, Valid: true}
+	}
+	return s, true
+}
+
+// greater is true if v has a value above than's, or than has none
+func greater(v, than sql.NullFloat64) bool {
+	return v.Valid && (!than.Valid || v.Float64 > than.Float64)
+}
+
+// less is true if v has a value below than's, or than has none
+func less(v, than sql.NullFloat64) bool {
+	return v.Valid && (!than.Valid || v.Float64 < than.Float64)
+}
+
+type Summariser struct {
+	db       postgres.Querier
+	station  string
+	location *time.Location
+}
+
+func NewSummariser(db postgres.Querier, station string, location *time.Location) *Summariser {
+	return &Summariser{
+		db:       db,
+		station:  station,
+		location: location,
+	}
+}
+
+// SummariseDay computes and stores the summary for one climatological day.
+func (s *Summariser) SummariseDay(ctx context.Context, day time.Time) error {
+	start, end := DayBounds(day, s.location)
+	records, err := s.db.GetRecordsBetween(ctx, postgres.GetRecordsBetweenParams{
+		StationID: s.station,
+		FromTime:  start,
+		ToTime:    end,
+	})
+	if err != nil {
+		return err
+	}
+	summary, ok := Summarise(s.station, day, s.location, records)
+	if !ok {
+		logger.Infof("No records for climate day %v", day.Format("2006-01-02"))
+		return nil
+	}
+	return s.db.UpsertDailySummary(ctx, summary)
+}
+
+// Backfill summarises every completed day since the first observation that
+// has no summary yet.
+func (s *Summariser) Backfill(ctx context.Context) error {
+	first, err := s.db.GetFirstObservation(ctx, s.station)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	firstDay := DayOf(first, s.location)
+
+	days, err := s.db.GetDailySummaryDays(ctx, postgres.GetDailySummaryDaysParams{
+		StationID: s.station,
+		Day:       firstDay,
+	})
+	if err != nil {
+		return err
+	}
+	done := make(map[time.Time]bool, len(days))
+	for _, d := range days {
+		done[d.UTC()] = true
+	}
+
+	// today's day is still in progress
+	today := DayOf(time.Now(), s.location)
+	for day := firstDay; day.Before(today); day = NextDay(day) {
+		if done[day] {
+			continue
+		}
+		logger.Infof("Backfilling climate day %v", day.Format("2006-01-02"))
+		if err := s.SummariseDay(ctx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run called as a go routine: backfills any missing days then summarises
+// each day shortly after it ends.
+func (s *Summariser) Run() {
+	if err := s.Backfill(context.Background()); err != nil {
+		logger.Errorf("Failed to backfill daily summaries [%v]", err)
+	}
+	for {
+		now := time.Now()
+		_, end := DayBounds(DayOf(now, s.location), s.location)
+		time.Sleep(end.Add(env.SummaryDelay).Sub(now))
+
+		// the day that has just finished
+		day := DayOf(end.Add(-time.Minute), s.location)
+		logger.Infof("Summarising climate day %v", day.Format("2006-01-02"))
+		if err := s.SummariseDay(context.Background(), day); err != nil {
+			logger.Errorf("Failed to write daily summary [%v]", err)
+		}
+	}
+}
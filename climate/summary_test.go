@@ -0,0 +1,143 @@
+package climate
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+func london(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	return loc
+}
+
+func valid(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: true}
+}
+
+func TestDayOf(t *testing.T) {
+	loc := london(t)
+
+	// 08:59 BST still belongs to the previous day
+	require.Equal(t, time.Date(2026, 7, 9, 0, 0, 0, 0, time.UTC), DayOf(time.Date(2026, 7, 10, 7, 59, 0, 0, time.UTC), loc))
+	// as does 09:00 BST, which ends it
+	require.Equal(t, time.Date(2026, 7, 9, 0, 0, 0, 0, time.UTC), DayOf(time.Date(2026, 7, 10, 8, 0, 0, 0, time.UTC), loc))
+	// and anything after starts a new one
+	require.Equal(t, time.Date(2026, 7, 10, 0, 0, 0, 0, time.UTC), DayOf(time.Date(2026, 7, 10, 8, 0, 1, 0, time.UTC), loc))
+	// winter, 09:01 GMT
+	require.Equal(t, time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), DayOf(time.Date(2026, 1, 10, 9, 1, 0, 0, time.UTC), loc))
+
+	// DayBounds agrees
+	for _, at := range []time.Time{
+		time.Date(2026, 7, 10, 8, 0, 0, 0, time.UTC),
+		time.Date(2026, 7, 10, 8, 0, 1, 0, time.UTC),
+		time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC),
+	} {
+		start, end := DayBounds(DayOf(at, loc), loc)
+		require.True(t, at.After(start) && !at.After(end), "%v not in (%v, %v]", at, start, end)
+	}
+}
+
+func TestDayBoundsAcrossClockChange(t *testing.T) {
+	loc := london(t)
+
+	// clocks go back at 02:00 on 25 October 2026, so the day is 25 hours long
+	start, end := DayBounds(time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC), loc)
+	require.Equal(t, time.Date(2026, 10, 24, 8, 0, 0, 0, time.UTC), start.UTC())
+	require.Equal(t, time.Date(2026, 10, 25, 9, 0, 0, 0, time.UTC), end.UTC())
+	require.Equal(t, 25*time.Hour, end.Sub(start))
+}
+
+func TestParseTime(t *testing.T) {
+	loc := london(t)
+
+	// a date starts at midnight station time, BST in July
+	at, err := ParseTime("2026-07-10", loc)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 7, 9, 23, 0, 0, 0, time.UTC), at)
+
+	at, err = ParseTime("2026-07-10T12:00:00+02:00", loc)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 7, 10, 10, 0, 0, 0, time.UTC), at)
+
+	_, err = ParseTime("10/07/2026", loc)
+	require.Error(t, err)
+}
+
+func TestSummarise(t *testing.T) {
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return time.Date(2026, 1, 10, h, 0, 0, 0, time.UTC) }
+
+	_, ok := Summarise("home", day, time.UTC, nil)
+	require.False(t, ok)
+
+	s, ok := Summarise("home", day, time.UTC, []postgres.Weather{
+		{ObservedAt: at(10), Temperature: valid(4), Pressure: valid(1010), RainMm: 0.2, WindSpeed: valid(3), WindGust: valid(10), WindDirection: valid(90)},
+		{ObservedAt: at(14), Temperature: valid(9), Pressure: valid(1004), RainMm: 1.4, WindSpeed: valid(8), WindGust: valid(25), WindDirection: valid(225), RainRate: sql.NullFloat64{Float64: 6.1, Valid: true}},
+		{ObservedAt: at(22), Temperature: valid(-1), Pressure: valid(1012), RainMm: 0, WindSpeed: valid(1), WindGust: valid(4), WindDirection: valid(0)},
+	})
+	require.True(t, ok)
+	require.Equal(t, int32(3), s.Samples)
+	require.Equal(t, time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC), s.PeriodStart)
+	require.Equal(t, valid(9), s.TemperatureMax)
+	require.Equal(t, at(14), s.TemperatureMaxAt.Time)
+	require.Equal(t, valid(-1), s.TemperatureMin)
+	require.Equal(t, at(22), s.TemperatureMinAt.Time)
+	require.Equal(t, valid(4), s.TemperatureMean)
+	require.InDelta(t, 1.6, s.RainTotal, 1e-9)
+	require.Equal(t, 6.1, s.RainRateMax.Float64)
+	require.Equal(t, valid(25), s.WindGustMax)
+	require.Equal(t, valid(225), s.WindGustDir)
+	require.Equal(t, valid(4), s.WindSpeedMean)
+	require.Equal(t, valid(1004), s.PressureMin)
+	require.Equal(t, valid(1012), s.PressureMax)
+}
+
+func TestSummariseLeavesOutUnreadValues(t *testing.T) {
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return time.Date(2026, 1, 10, h, 0, 0, 0, time.UTC) }
+
+	// the temperature sensor was out for the last reading, and the
+	// anemometer all day
+	s, ok := Summarise("home", day, time.UTC, []postgres.Weather{
+		{ObservedAt: at(10), Temperature: valid(4), Pressure: valid(1010), RainMm: 0.2},
+		{ObservedAt: at(14), Temperature: valid(9), Pressure: valid(1004), RainMm: 1.4},
+		{ObservedAt: at(22), RainMm: 0.4},
+	})
+	require.True(t, ok)
+	require.Equal(t, int32(3), s.Samples)
+	require.Equal(t, valid(4), s.TemperatureMin)
+	require.Equal(t, at(10), s.TemperatureMinAt.Time)
+	require.Equal(t, valid(6.5), s.TemperatureMean)
+	require.Equal(t, valid(1004), s.PressureMin)
+	require.InDelta(t, 2.0, s.RainTotal, 1e-9)
+	require.False(t, s.WindGustMax.Valid)
+	require.False(t, s.WindGustAt.Valid)
+	require.False(t, s.WindSpeedMean.Valid)
+	require.False(t, s.WindDirDominant.Valid)
+}
+
+func TestSummariseLeavesOutFlaggedValues(t *testing.T) {
+	day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return time.Date(2026, 1, 10, h, 0, 0, 0, time.UTC) }
+
+	s, ok := Summarise("home", day, time.UTC, []postgres.Weather{
+		{ObservedAt: at(10), Temperature: valid(4), Pressure: valid(1010), RainMm: 0.2, WindSpeed: valid(3), WindGust: valid(10), WindDirection: valid(90)},
+		{
+			ObservedAt: at(14), Temperature: valid(45), Pressure: valid(1004), RainMm: 30, WindSpeed: valid(150), WindGust: valid(300), WindDirection: valid(90),
+			QcFlags: sql.NullString{String: `{"temperature":"failed","wind_speed":"failed","wind_gust":"failed","rain_day":"suspect"}`, Valid: true},
+		},
+	})
+	require.True(t, ok)
+	require.Equal(t, int32(2), s.Samples)
+	require.Equal(t, valid(4), s.TemperatureMax)
+	require.Equal(t, valid(4), s.TemperatureMean)
+	require.Equal(t, valid(10), s.WindGustMax)
+	require.Equal(t, valid(3), s.WindSpeedMean)
+	require.InDelta(t, 0.2, s.RainTotal, 1e-9)
+	require.Equal(t, valid(1004), s.PressureMin)
+}
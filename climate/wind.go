@@ -0,0 +1,30 @@
+package climate
+
+import "math"
+
+// windVector accumulates speed weighted wind directions so a dominant
+// direction can be found. A plain average of bearings is wrong either side
+// of north (350 and 10 would average to 180).
+type windVector struct {
+	x float64
+	y float64
+}
+
+func (v *windVector) add(speed float64, dir float64) {
+	rad := dir * math.Pi / 180
+	v.x += speed * math.Sin(rad)
+	v.y += speed * math.Cos(rad)
+}
+
+// direction returns the resultant bearing in degrees, or false if the
+// vectors cancel out or there was no wind.
+func (v *windVector) direction() (float64, bool) {
+	if math.Hypot(v.x, v.y) < 1e-9 {
+		return 0, false
+	}
+	deg := math.Atan2(v.x, v.y) * 180 / math.Pi
+	if deg < 0 {
+		deg += 360
+	}
+	return deg, true
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/db/sqlite"
+	"github.com/pointer2null/weather/export"
+	"github.com/pointer2null/weather/importer"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Commands run in place of the station when named as the first argument, e.g.
+//
+//	weatherServer.exe noaa -from 2026-01 -to 2026-10
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"noaa": {
+		usage: "noaa -from YYYY-MM [-to YYYY-MM] [-dir DIR] - regenerate NOAA monthly and yearly reports",
+		run:   noaaCommand,
+	},
+	"export": {
+		usage: "export -from DATE [-to DATE] [-format csv|jsonl|parquet] [-resolution raw|minute|hour|day|week|month] [-o FILE] - export observations",
+		run:   exportCommand,
+	},
+	"import": {
+		usage: "import -format cumulus|weewx|wow [-dry-run] [-temp C|F] [-pressure hPa|mb|inHg] [-wind mph|kmh|ms|knots] [-rain mm|in] FILE... - import history from other weather software",
+		run:   importCommand,
+	},
+	"calibrate": {
+		usage: "calibrate vane [-north DEG] [-o FILE] | wind [-reference DEVICE|http] [-unit mph|kmh|ms|knots] [-fit linear|piecewise] [-log FILE] | rain -volume ML -diameter MM [-calibration FILE -write] - measure the vane's lookup table, fit the anemometer to a reference, or measure the rain in a bucket tip",
+		run:   calibrateCommand,
+	},
+	"recalibrate": {
+		usage: "recalibrate -calibration FILE -from DATE [-to DATE] [-dry-run] - reprocess observations with the calibration in force at their time",
+		run:   recalibrateCommand,
+	},
+}
+
+func runCommand(name string, args []string) {
+	c, ok := commands[name]
+	if !ok {
+		logger.Errorf("Unknown command [%v]", name)
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintf(os.Stderr, "  %v\n", commands[n].usage)
+		}
+		logger.Exit(2)
+	}
+	if err := c.run(args); err != nil {
+		logger.Errorf("%v failed [%v]", name, err)
+		logger.Exit(1)
+	}
+}
+
+// stationFlags adds the flags shared by commands that read the archive
+func stationFlags(fs *flag.FlagSet) (station *string, tz *string) {
+	station = fs.String("station", "default", "station id")
+	tz = fs.String("tz", "Europe/London", "station time zone")
+	return station, tz
+}
+
+func noaaCommand(args []string) error {
+	fs := flag.NewFlagSet("noaa", flag.ExitOnError)
+	station, tz := stationFlags(fs)
+	path := fs.String("db", localDB, "local sqlite database")
+	from := fs.String("from", "", "first month, YYYY-MM")
+	to := fs.String("to", "", "last month, YYYY-MM (defaults to -from)")
+	dir := fs.String("dir", ".", "directory to write the reports to")
+	resummarise := fs.Bool("summarise", true, "rebuild the daily summaries from the raw records first")
+	_ = fs.Parse(args)
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	first, err := time.Parse("2006-01", *from)
+	if err != nil {
+		return fmt.Errorf("invalid -from [%v]", *from)
+	}
+	last := first
+	if *to != "" {
+		if last, err = time.Parse("2006-01", *to); err != nil {
+			return fmt.Errorf("invalid -to [%v]", *to)
+		}
+	}
+	if last.Before(first) {
+		return fmt.Errorf("-to is before -from")
+	}
+
+	db, err := openLocal(*path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	q := sqlite.New(db)
+	ctx := context.Background()
+
+	if *resummarise {
+		s := climate.NewSummariser(q, *station, location)
+		today := climate.DayOf(time.Now(), location)
+		for day := first; day.Before(last.AddDate(0, 1, 0)) && day.Before(today); day = climate.NextDay(day) {
+			if err := s.SummariseDay(ctx, day); err != nil {
+				return err
+			}
+		}
+	}
+
+	reporter := climate.NewNOAAReporter(q, *station, location)
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		report, err := reporter.Month(ctx, m.Year(), m.Month())
+		if err != nil {
+			return err
+		}
+		if err := writeReport(*dir, fmt.Sprintf("NOAA-%s.txt", m.Format("2006-01")), report.Text()); err != nil {
+			return err
+		}
+	}
+	for y := first.Year(); y <= last.Year(); y++ {
+		report, err := reporter.Year(ctx, y)
+		if err != nil {
+			return err
+		}
+		if err := writeReport(*dir, fmt.Sprintf("NOAA-%d.txt", y), report.Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	station, tz := stationFlags(fs)
+	path := fs.String("db", localDB, "local sqlite database")
+	source := fs.String("source", "sqlite", "read from the local sqlite database or from pg")
+	from := fs.String("from", "", "start, YYYY-MM-DD or RFC3339")
+	to := fs.String("to", "", "end, YYYY-MM-DD or RFC3339 (defaults to now)")
+	q := export.Query{Units: export.DefaultUnits}
+	fs.StringVar(&q.Format, "format", "csv", "csv, jsonl or parquet")
+	fs.StringVar(&q.Resolution, "resolution", "raw", "raw, minute, hour, day, week or month")
+	fs.StringVar(&q.Units.Temperature, "temp", q.Units.Temperature, "temperature unit, C or F")
+	fs.StringVar(&q.Units.Pressure, "pressure", q.Units.Pressure, "pressure unit, hPa or inHg")
+	fs.StringVar(&q.Units.Wind, "wind", q.Units.Wind, "wind unit, mph, kmh, ms or knots")
+	fs.StringVar(&q.Units.Rain, "rain", q.Units.Rain, "rain unit, mm or in")
+	output := fs.String("o", "", "file to write (defaults to stdout)")
+	_ = fs.Parse(args)
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	q.Station = *station
+	if q.From, err = climate.ParseTime(*from, location); err != nil {
+		return fmt.Errorf("invalid -from [%v]", *from)
+	}
+	q.To = time.Now().UTC()
+	if *to != "" {
+		if q.To, err = climate.ParseTime(*to, location); err != nil {
+			return fmt.Errorf("invalid -to [%v]", *to)
+		}
+	}
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	var db postgres.Querier
+	switch *source {
+	case "sqlite":
+		local, err := openLocal(*path)
+		if err != nil {
+			return err
+		}
+		defer local.Close()
+		db = sqlite.New(local)
+	case "pg":
+		pg, err := openPostgres()
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+		db = postgres.New(pg)
+	default:
+		return fmt.Errorf("-source must be sqlite or pg")
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+	}
+	if err := export.Write(context.Background(), db, q, out); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	station, tz := stationFlags(fs)
+	path := fs.String("db", localDB, "local sqlite database")
+	target := fs.String("target", "sqlite", "write to the local sqlite database, which syncs to postgres, or straight to pg")
+	format := fs.String("format", "", "cumulus (monthly logs), weewx (archive database) or wow (csv download)")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without writing anything")
+	resummarise := fs.Bool("summarise", true, "rebuild the daily summaries and station records afterwards")
+	var units importer.Units
+	fs.StringVar(&units.Temperature, "temp", "", "temperature unit of the files, C or F")
+	fs.StringVar(&units.Pressure, "pressure", "", "pressure unit of the files, hPa, mb or inHg")
+	fs.StringVar(&units.Wind, "wind", "", "wind unit of the files, mph, kmh, ms or knots")
+	fs.StringVar(&units.Rain, "rain", "", "rain unit of the files, mm or in")
+	_ = fs.Parse(args)
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		return fmt.Errorf("no files to import")
+	}
+
+	var db postgres.Querier
+	switch *target {
+	case "sqlite":
+		local, err := openLocal(*path)
+		if err != nil {
+			return err
+		}
+		defer local.Close()
+		db = sqlite.New(local)
+	case "pg":
+		pg, err := openPostgres()
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+		db = postgres.New(pg)
+	default:
+		return fmt.Errorf("-target must be sqlite or pg")
+	}
+
+	ctx := context.Background()
+	im := importer.New(db, *station, *dryRun)
+	switch *format {
+	case "cumulus":
+		c := &importer.Cumulus{Units: withDefaults(units, importer.CumulusUnits), Location: location}
+		// monthly logs are named by month, which doesn't sort by name
+		sort.SliceStable(files, func(i, j int) bool {
+			a, aok := importer.CumulusMonth(files[i])
+			b, bok := importer.CumulusMonth(files[j])
+			if !aok || !bok {
+				return files[i] < files[j]
+			}
+			return a.Before(b)
+		})
+		err = importFiles(ctx, files, im, c.Read)
+	case "wow":
+		w := &importer.WOW{Units: withDefaults(units, importer.WOWUnits)}
+		err = importFiles(ctx, files, im, w.Read)
+	case "weewx":
+		for _, f := range files {
+			logger.Infof("Importing %v", f)
+			if err = importer.WeeWX(ctx, f, im); err != nil {
+				break
+			}
+		}
+	default:
+		return fmt.Errorf("-format must be cumulus, weewx or wow")
+	}
+	report := im.Report()
+	fmt.Print(report.String())
+	if err != nil {
+		return err
+	}
+	if *dryRun || report.New == 0 || !*resummarise {
+		return nil
+	}
+
+	s := climate.NewSummariser(db, *station, location)
+	today := climate.DayOf(time.Now(), location)
+	for day := climate.DayOf(report.First, location); !day.After(climate.DayOf(report.Last, location)) && day.Before(today); day = climate.NextDay(day) {
+		if err := s.SummariseDay(ctx, day); err != nil {
+			return err
+		}
+	}
+	// a running station keeps the records it loaded until it restarts
+	return climate.NewRecordTracker(db, *station, location).Recompute(ctx)
+}
+
+// withDefaults fills any unit not given on the command line from the
+// format's defaults
+func withDefaults(u importer.Units, defaults importer.Units) importer.Units {
+	if u.Temperature == "" {
+		u.Temperature = defaults.Temperature
+	}
+	if u.Pressure == "" {
+		u.Pressure = defaults.Pressure
+	}
+	if u.Wind == "" {
+		u.Wind = defaults.Wind
+	}
+	if u.Rain == "" {
+		u.Rain = defaults.Rain
+	}
+	return u
+}
+
+func importFiles(ctx context.Context, files []string, im *importer.Importer, read func(context.Context, string, io.Reader, *importer.Importer) error) error {
+	for _, name := range files {
+		logger.Infof("Importing %v", name)
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = read(ctx, filepath.Base(name), f, im)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReport(dir string, name string, text string) error {
+	path := filepath.Join(dir, name)
+	logger.Infof("Writing %v", path)
+	return os.WriteFile(path, []byte(text), 0644)
+}
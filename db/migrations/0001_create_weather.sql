@@ -1,6 +1,6 @@
 -- +migrate up
 
-CREATE TABLE weather (
+CREATE TABLE IF NOT EXISTS weather (
     record_date TIMESTAMP without time zone PRIMARY KEY,
     temperature FLOAT NOT NULL,
     pressure FLOAT NOT NULL,
@@ -8,4 +8,4 @@ CREATE TABLE weather (
     wind_speed FLOAT NOT NULL,
     wind_gust FLOAT NOT NULL,
     wind_direction FLOAT NOT NULL
-);
\ No newline at end of file
+);
@@ -0,0 +1,19 @@
+-- +migrate up
+
+ALTER TABLE weather
+    ADD COLUMN station_id TEXT NOT NULL DEFAULT 'default',
+    ADD COLUMN observed_at TIMESTAMPTZ,
+    ADD COLUMN humidity FLOAT,
+    ADD COLUMN dew_point FLOAT,
+    ADD COLUMN mslp FLOAT,
+    ADD COLUMN rain_rate FLOAT,
+    ADD COLUMN rain_day FLOAT;
+
+-- record_date was filled from now() into a column without a zone, so it holds
+-- the server's local time. Read it back in that zone to get the real instant.
+UPDATE weather SET observed_at = record_date AT TIME ZONE current_setting('TimeZone');
+
+ALTER TABLE weather DROP CONSTRAINT weather_pkey;
+ALTER TABLE weather DROP COLUMN record_date;
+ALTER TABLE weather ALTER COLUMN observed_at SET NOT NULL;
+ALTER TABLE weather ADD PRIMARY KEY (station_id, observed_at);
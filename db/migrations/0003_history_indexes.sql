@@ -0,0 +1,10 @@
+-- +migrate up
+
+-- The primary key already covers (station_id, observed_at) range scans. Rows
+-- arrive in time order so a BRIN index keeps cross-station time queries cheap
+-- without the size of a btree.
+CREATE INDEX IF NOT EXISTS weather_observed_at_brin ON weather USING BRIN (observed_at);
+
+-- +migrate down
+
+DROP INDEX IF EXISTS weather_observed_at_brin;
@@ -0,0 +1,30 @@
+-- +migrate up
+
+-- One row per climatological day. The day runs 09:00 to 09:00 station time
+-- and is named after the date on which it starts, as the Met Office does.
+CREATE TABLE IF NOT EXISTS daily_summary (
+    station_id TEXT NOT NULL,
+    day DATE NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    samples INTEGER NOT NULL,
+    temperature_max FLOAT NOT NULL,
+    temperature_max_at TIMESTAMPTZ NOT NULL,
+    temperature_min FLOAT NOT NULL,
+    temperature_min_at TIMESTAMPTZ NOT NULL,
+    temperature_mean FLOAT NOT NULL,
+    rain_total FLOAT NOT NULL,
+    rain_rate_max FLOAT,
+    wind_gust_max FLOAT NOT NULL,
+    wind_gust_dir FLOAT NOT NULL,
+    wind_gust_at TIMESTAMPTZ NOT NULL,
+    wind_speed_mean FLOAT NOT NULL,
+    pressure_min FLOAT NOT NULL,
+    pressure_max FLOAT NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (station_id, day)
+);
+
+-- +migrate down
+
+DROP TABLE IF EXISTS daily_summary;
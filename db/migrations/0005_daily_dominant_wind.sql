@@ -0,0 +1,8 @@
+-- +migrate up
+
+-- speed weighted vector mean of the day's wind direction, NULL when calm
+ALTER TABLE daily_summary ADD COLUMN wind_dir_dominant FLOAT;
+
+-- +migrate down
+
+ALTER TABLE daily_summary DROP COLUMN wind_dir_dominant;
@@ -0,0 +1,18 @@
+-- +migrate up
+
+-- scope is all, year or month. period is empty for all, YYYY for a year and
+-- MM for a calendar month across every year.
+CREATE TABLE IF NOT EXISTS station_records (
+    station_id TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    period TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    value FLOAT NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (station_id, scope, period, metric)
+);
+
+-- +migrate down
+
+DROP TABLE IF EXISTS station_records;
@@ -0,0 +1,17 @@
+-- +migrate up
+
+-- Every raw anemometer sample (4 per second) when the wind archive writes to
+-- postgres. Rows are only ever appended in time order by COPY, so there is
+-- no primary key to maintain.
+CREATE TABLE IF NOT EXISTS wind_raw (
+    station_id TEXT NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL,
+    pulses SMALLINT NOT NULL,
+    direction REAL NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS wind_raw_station_time ON wind_raw (station_id, observed_at);
+
+-- +migrate down
+
+DROP TABLE IF EXISTS wind_raw;
@@ -0,0 +1,9 @@
+-- +migrate up
+
+-- The quality control flags of an observation's values, as JSON such as
+-- {"temperature":"suspect"}. NULL when every value passed.
+ALTER TABLE weather ADD COLUMN IF NOT EXISTS qc_flags TEXT;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN IF EXISTS qc_flags;
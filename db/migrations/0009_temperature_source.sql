@@ -0,0 +1,9 @@
+-- +migrate up
+
+-- The sensor the temperature came from: mcp9808, or bme280 when the
+-- MCP9808 failed. NULL for observations from before there was a choice.
+ALTER TABLE weather ADD COLUMN IF NOT EXISTS temperature_source TEXT;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN IF EXISTS temperature_source;
@@ -0,0 +1,21 @@
+-- +migrate up
+
+-- Every calibration version a station has used. Versions are never changed
+-- once recorded, so an observation can always be reprocessed from the raw
+-- reading its version implies.
+CREATE TABLE IF NOT EXISTS calibrations (
+    station_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    effective_from TIMESTAMPTZ NOT NULL,
+    curves TEXT NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (station_id, version)
+);
+
+-- The calibration version an observation was corrected with, NULL for none
+ALTER TABLE weather ADD COLUMN IF NOT EXISTS calibration_version INTEGER;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN IF EXISTS calibration_version;
+DROP TABLE IF EXISTS calibrations;
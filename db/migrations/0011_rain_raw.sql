@@ -0,0 +1,9 @@
+-- +migrate up
+
+-- Rain before the intensity correction, from the tips at the calibrated
+-- volume. rain_mm holds the corrected total. NULL before it was kept.
+ALTER TABLE weather ADD COLUMN IF NOT EXISTS rain_raw_mm FLOAT;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN IF EXISTS rain_raw_mm;
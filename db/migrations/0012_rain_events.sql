@@ -0,0 +1,25 @@
+-- +migrate up
+
+-- One row per rain event: from the first tip after a dry spell to the last
+-- tip before the next. end_at is the last tip so far while ongoing is set.
+-- The peaks are the highest rain over 5, 10 and 60 minutes as mm/h, and
+-- dry_before_s the time since the previous event's last tip, NULL for the
+-- first event.
+CREATE TABLE IF NOT EXISTS rain_events (
+    station_id TEXT NOT NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ NOT NULL,
+    ongoing BOOLEAN NOT NULL,
+    total_mm FLOAT NOT NULL,
+    duration_s BIGINT NOT NULL,
+    peak_5min FLOAT NOT NULL,
+    peak_10min FLOAT NOT NULL,
+    peak_60min FLOAT NOT NULL,
+    dry_before_s BIGINT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (station_id, start_at)
+);
+
+-- +migrate down
+
+DROP TABLE IF EXISTS rain_events;
@@ -0,0 +1,46 @@
+-- +migrate up
+
+-- A reading from a sensor that is off or couldn't be read is NULL rather
+-- than 0, so it can't pass for a real 0 C or 0 hPa. A day without any
+-- reading of a value leaves that part of its summary NULL in the same way.
+ALTER TABLE weather
+    ALTER COLUMN temperature DROP NOT NULL,
+    ALTER COLUMN pressure DROP NOT NULL,
+    ALTER COLUMN wind_speed DROP NOT NULL,
+    ALTER COLUMN wind_gust DROP NOT NULL,
+    ALTER COLUMN wind_direction DROP NOT NULL;
+
+ALTER TABLE daily_summary
+    ALTER COLUMN temperature_max DROP NOT NULL,
+    ALTER COLUMN temperature_max_at DROP NOT NULL,
+    ALTER COLUMN temperature_min DROP NOT NULL,
+    ALTER COLUMN temperature_min_at DROP NOT NULL,
+    ALTER COLUMN temperature_mean DROP NOT NULL,
+    ALTER COLUMN wind_gust_max DROP NOT NULL,
+    ALTER COLUMN wind_gust_dir DROP NOT NULL,
+    ALTER COLUMN wind_gust_at DROP NOT NULL,
+    ALTER COLUMN wind_speed_mean DROP NOT NULL,
+    ALTER COLUMN pressure_min DROP NOT NULL,
+    ALTER COLUMN pressure_max DROP NOT NULL;
+
+-- +migrate down
+
+ALTER TABLE daily_summary
+    ALTER COLUMN temperature_max SET NOT NULL,
+    ALTER COLUMN temperature_max_at SET NOT NULL,
+    ALTER COLUMN temperature_min SET NOT NULL,
+    ALTER COLUMN temperature_min_at SET NOT NULL,
+    ALTER COLUMN temperature_mean SET NOT NULL,
+    ALTER COLUMN wind_gust_max SET NOT NULL,
+    ALTER COLUMN wind_gust_dir SET NOT NULL,
+    ALTER COLUMN wind_gust_at SET NOT NULL,
+    ALTER COLUMN wind_speed_mean SET NOT NULL,
+    ALTER COLUMN pressure_min SET NOT NULL,
+    ALTER COLUMN pressure_max SET NOT NULL;
+
+ALTER TABLE weather
+    ALTER COLUMN temperature SET NOT NULL,
+    ALTER COLUMN pressure SET NOT NULL,
+    ALTER COLUMN wind_speed SET NOT NULL,
+    ALTER COLUMN wind_gust SET NOT NULL,
+    ALTER COLUMN wind_direction SET NOT NULL;
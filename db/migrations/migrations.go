@@ -0,0 +1,172 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// The migrations use the sql-migrate layout that sqlc already understands:
+// each file is named NNNN_description.sql and holds an "up" section and an
+// optional "down" section. Only the up section is ever run here.
+
+//go:embed *.sql
+var files embed.FS
+
+const (
+	upMarker   = "-- +migrate up"
+	downMarker = "-- +migrate down"
+)
+
+type migration struct {
+	version int
+	name    string
+	up      string
+}
+
+// Dialect selects the placeholder style used for the bookkeeping queries.
+type Dialect int
+
+const (
+	Postgres Dialect = iota
+	SQLite
+)
+
+// Run applies the embedded postgres migrations.
+func Run(ctx context.Context, db *sql.DB) error {
+	return Apply(ctx, db, files, Postgres)
+}
+
+// Apply runs, in version order, every migration in fsys that has not yet
+// been recorded in the schema_migrations table. Each migration runs in its
+// own transaction together with its version record.
+func Apply(ctx context.Context, db *sql.DB, fsys fs.FS, dialect Dialect) error {
+	all, err := load(fsys)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		if applied[m.version] {
+			continue
+		}
+		logger.Infof("Applying migration %04d [%v]", m.version, m.name)
+		if err := apply(ctx, db, m, dialect); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, m migration, dialect Dialect) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, m.up); err != nil {
+		return fmt.Errorf("migration %04d [%v] failed: %w", m.version, m.name, err)
+	}
+	insert := "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+	if dialect == SQLite {
+		insert = "INSERT INTO schema_migrations (version, name) VALUES (?, ?)"
+	}
+	if _, err := tx.ExecContext(ctx, insert, m.version, m.name); err != nil {
+		return fmt.Errorf("failed to record migration %04d: %w", m.version, err)
+	}
+	return tx.Commit()
+}
+
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+func load(fsys fs.FS) ([]migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var all []migration
+	seen := make(map[int]string)
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration [%v] is not named NNNN_description.sql", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration [%v] has an invalid version: %w", name, err)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations [%v] and [%v] share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		up, err := upSection(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("migration [%v]: %w", name, err)
+		}
+		all = append(all, migration{version: version, name: name, up: up})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].version < all[j].version })
+	return all, nil
+}
+
+// upSection returns the statements between the up marker and the down
+// marker (or the end of the file).
+func upSection(content string) (string, error) {
+	lower := strings.ToLower(content)
+	start := strings.Index(lower, upMarker)
+	if start < 0 {
+		return "", fmt.Errorf("missing %q marker", upMarker)
+	}
+	start += len(upMarker)
+	end := len(content)
+	if i := strings.Index(lower[start:], downMarker); i >= 0 {
+		end = start + i
+	}
+	up := strings.TrimSpace(content[start:end])
+	if up == "" {
+		return "", fmt.Errorf("empty up section")
+	}
+	return up, nil
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrdersAndStripsDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.sql": {Data: []byte("-- +migrate Up\nALTER TABLE a ADD COLUMN b INT;\n-- +migrate Down\nALTER TABLE a DROP COLUMN b;\n")},
+		"0001_first.sql":  {Data: []byte("-- +migrate up\n\nCREATE TABLE a (id INT);\n")},
+	}
+
+	all, err := load(fsys)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Equal(t, 1, all[0].version)
+	require.Equal(t, "CREATE TABLE a (id INT);", all[0].up)
+	require.Equal(t, 2, all[1].version)
+	require.Equal(t, "ALTER TABLE a ADD COLUMN b INT;", all[1].up)
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	_, err := load(fstest.MapFS{"first.sql": {Data: []byte("-- +migrate up\nSELECT 1;")}})
+	require.Error(t, err)
+
+	_, err = load(fstest.MapFS{"0001_a.sql": {Data: []byte("SELECT 1;")}})
+	require.Error(t, err)
+
+	_, err = load(fstest.MapFS{
+		"0001_a.sql": {Data: []byte("-- +migrate up\nSELECT 1;")},
+		"0001_b.sql": {Data: []byte("-- +migrate up\nSELECT 2;")},
+	})
+	require.Error(t, err)
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	all, err := load(files)
+	require.NoError(t, err)
+	for i, m := range all {
+		require.Equal(t, i+1, m.version, "migration versions must be contiguous")
+	}
+}
+
+func TestTimescaleMigrationsLoad(t *testing.T) {
+	files, err := fs.Sub(timescaleFiles, "timescale")
+	require.NoError(t, err)
+	all, err := load(files)
+	require.NoError(t, err)
+	for i, m := range all {
+		require.Equal(t, 1001+i, m.version, "timescale migration versions must be contiguous from 1001")
+	}
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// TimescaleDB support is optional. When the extension can be loaded the
+// weather table becomes a hypertable with compression and hourly and daily
+// continuous aggregates. These migrations share schema_migrations with the
+// plain ones and are numbered from 1001 to stay clear of them.
+
+//go:embed timescale/*.sql
+var timescaleFiles embed.FS
+
+// Aggregates lists the continuous aggregates, finest first
+var Aggregates = []string{"weather_hourly", "weather_daily"}
+
+// aggregatesVersion is the latest migration that creates Aggregates
+const aggregatesVersion = 1004
+
+// Timescale enables the extension if it is available and applies the
+// timescale migrations. It reports whether timescale is in use; a server
+// without the extension is not an error.
+func Timescale(ctx context.Context, db *sql.DB) (bool, error) {
+	var installed, available bool
+	err := db.QueryRowContext(ctx, `SELECT
+    EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'),
+    EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb')`).Scan(&installed, &available)
+	if err != nil {
+		return false, err
+	}
+	if !available {
+		return false, nil
+	}
+	if !installed {
+		// needs the library in shared_preload_libraries and enough privilege
+		if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS timescaledb"); err != nil {
+			logger.Warnf("TimescaleDB is available but could not be enabled [%v]", err)
+			return false, nil
+		}
+	}
+
+	files, err := fs.Sub(timescaleFiles, "timescale")
+	if err != nil {
+		return false, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	if err := Apply(ctx, db, files, Postgres); err != nil {
+		return false, err
+	}
+
+	// the aggregates are created empty and can't be refreshed inside the
+	// migration's transaction, so build the whole history once here
+	if !applied[aggregatesVersion] {
+		for _, view := range Aggregates {
+			logger.Infof("Building continuous aggregate %v", view)
+			if _, err := db.ExecContext(ctx, "CALL refresh_continuous_aggregate($1, NULL::timestamptz, NULL::timestamptz)", view); err != nil {
+				return false, fmt.Errorf("failed to refresh %v: %w", view, err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// RefreshAggregates rebuilds the aggregates over [from, to), for rows that
+// arrived after the refresh policy had moved past them.
+func RefreshAggregates(ctx context.Context, db *sql.DB, from time.Time, to time.Time) error {
+	for _, view := range Aggregates {
+		if _, err := db.ExecContext(ctx, "CALL refresh_continuous_aggregate($1, $2::timestamptz, $3::timestamptz)", view, from, to); err != nil {
+			return fmt.Errorf("failed to refresh %v: %w", view, err)
+		}
+	}
+	return nil
+}
+
+// SetRetention drops raw chunks of table older than keep. Zero removes the
+// policy so everything is kept. The aggregates are unaffected.
+func SetRetention(ctx context.Context, db *sql.DB, table string, keep time.Duration) error {
+	if _, err := db.ExecContext(ctx, "SELECT remove_retention_policy($1, if_exists => true)", table); err != nil {
+		return err
+	}
+	if keep <= 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, "SELECT add_retention_policy($1, make_interval(secs => $2))", table, keep.Seconds())
+	return err
+}
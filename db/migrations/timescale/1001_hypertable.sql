@@ -0,0 +1,18 @@
+-- +migrate up
+
+-- Applied only when the timescaledb extension is available. The primary key
+-- already includes observed_at, which a hypertable requires. Chunks older
+-- than 90 days are compressed, segmented by station so a station's history
+-- stays together.
+SELECT create_hypertable('weather', 'observed_at',
+    chunk_time_interval => INTERVAL '30 days',
+    migrate_data => true,
+    if_not_exists => true);
+
+ALTER TABLE weather SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'station_id',
+    timescaledb.compress_orderby = 'observed_at DESC'
+);
+
+SELECT add_compression_policy('weather', INTERVAL '90 days', if_not_exists => true);
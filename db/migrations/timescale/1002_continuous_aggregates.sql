@@ -0,0 +1,72 @@
+-- +migrate up
+
+-- Hourly and daily rollups. They hold sums and counts rather than means so
+-- that coarser buckets can be built from them exactly; nullable columns
+-- carry their own count. Wind direction is kept as summed unit vectors.
+-- Real time aggregation covers the gap since the last refresh.
+CREATE MATERIALIZED VIEW IF NOT EXISTS weather_hourly
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    station_id,
+    time_bucket(INTERVAL '1 hour', observed_at) AS bucket,
+    count(*) AS samples,
+    sum(temperature) AS temperature_sum,
+    min(temperature) AS temperature_min,
+    max(temperature) AS temperature_max,
+    sum(pressure) AS pressure_sum,
+    sum(humidity) AS humidity_sum,
+    count(humidity) AS humidity_n,
+    sum(dew_point) AS dew_point_sum,
+    count(dew_point) AS dew_point_n,
+    sum(mslp) AS mslp_sum,
+    count(mslp) AS mslp_n,
+    sum(rain_mm) AS rain_mm,
+    max(rain_rate) AS rain_rate_max,
+    sum(wind_speed) AS wind_speed_sum,
+    max(wind_gust) AS wind_gust,
+    sum(sin(radians(wind_direction))) AS wind_dir_sin,
+    sum(cos(radians(wind_direction))) AS wind_dir_cos
+FROM weather
+GROUP BY station_id, bucket
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS weather_daily
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    station_id,
+    time_bucket(INTERVAL '1 day', observed_at) AS bucket,
+    count(*) AS samples,
+    sum(temperature) AS temperature_sum,
+    min(temperature) AS temperature_min,
+    max(temperature) AS temperature_max,
+    sum(pressure) AS pressure_sum,
+    sum(humidity) AS humidity_sum,
+    count(humidity) AS humidity_n,
+    sum(dew_point) AS dew_point_sum,
+    count(dew_point) AS dew_point_n,
+    sum(mslp) AS mslp_sum,
+    count(mslp) AS mslp_n,
+    sum(rain_mm) AS rain_mm,
+    max(rain_rate) AS rain_rate_max,
+    sum(wind_speed) AS wind_speed_sum,
+    max(wind_gust) AS wind_gust,
+    sum(sin(radians(wind_direction))) AS wind_dir_sin,
+    sum(cos(radians(wind_direction))) AS wind_dir_cos
+FROM weather
+GROUP BY station_id, bucket
+WITH NO DATA;
+
+-- Only the recent past is refreshed on a schedule, so raw chunks dropped by
+-- a retention policy do not empty the rollups. Late rows synced after an
+-- outage are refreshed explicitly.
+SELECT add_continuous_aggregate_policy('weather_hourly',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '30 minutes',
+    if_not_exists => true);
+
+SELECT add_continuous_aggregate_policy('weather_daily',
+    start_offset => INTERVAL '7 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => true);
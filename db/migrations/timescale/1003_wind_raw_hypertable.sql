@@ -0,0 +1,16 @@
+-- +migrate up
+
+-- About 350k rows a day, so daily chunks, compressed once they are a week
+-- old. The archive's own retention drops the old ones.
+SELECT create_hypertable('wind_raw', 'observed_at',
+    chunk_time_interval => INTERVAL '1 day',
+    migrate_data => true,
+    if_not_exists => true);
+
+ALTER TABLE wind_raw SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'station_id',
+    timescaledb.compress_orderby = 'observed_at'
+);
+
+SELECT add_compression_policy('wind_raw', INTERVAL '7 days', if_not_exists => true);
@@ -0,0 +1,79 @@
+-- +migrate up
+
+-- Readings can now be NULL, and values quality control flagged are left
+-- out, so every mean needs its own count rather than the bucket's samples.
+-- The rollups are rebuilt from the raw table once the migration has run;
+-- buckets whose raw chunks a retention policy has already dropped can't be
+-- rebuilt and are lost.
+DROP MATERIALIZED VIEW IF EXISTS weather_daily;
+DROP MATERIALIZED VIEW IF EXISTS weather_hourly;
+
+CREATE MATERIALIZED VIEW weather_hourly
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    station_id,
+    time_bucket(INTERVAL '1 hour', observed_at) AS bucket,
+    count(*) AS samples,
+    sum(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_sum,
+    count(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_n,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_min,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_max,
+    sum(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_sum,
+    count(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_n,
+    sum(humidity) FILTER (WHERE (qc_flags::jsonb -> 'humidity') IS NULL) AS humidity_sum,
+    count(humidity) FILTER (WHERE (qc_flags::jsonb -> 'humidity') IS NULL) AS humidity_n,
+    sum(dew_point) FILTER (WHERE (qc_flags::jsonb -> 'dew_point') IS NULL) AS dew_point_sum,
+    count(dew_point) FILTER (WHERE (qc_flags::jsonb -> 'dew_point') IS NULL) AS dew_point_n,
+    sum(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_sum,
+    count(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_n,
+    sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL) AS rain_mm,
+    max(rain_rate) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL) AS rain_rate_max,
+    sum(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL) AS wind_speed_sum,
+    count(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL) AS wind_speed_n,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL) AS wind_gust,
+    sum(sin(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL) AS wind_dir_sin,
+    sum(cos(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL) AS wind_dir_cos
+FROM weather
+GROUP BY station_id, bucket
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW weather_daily
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    station_id,
+    time_bucket(INTERVAL '1 day', observed_at) AS bucket,
+    count(*) AS samples,
+    sum(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_sum,
+    count(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_n,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_min,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_max,
+    sum(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_sum,
+    count(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_n,
+    sum(humidity) FILTER (WHERE (qc_flags::jsonb -> 'humidity') IS NULL) AS humidity_sum,
+    count(humidity) FILTER (WHERE (qc_flags::jsonb -> 'humidity') IS NULL) AS humidity_n,
+    sum(dew_point) FILTER (WHERE (qc_flags::jsonb -> 'dew_point') IS NULL) AS dew_point_sum,
+    count(dew_point) FILTER (WHERE (qc_flags::jsonb -> 'dew_point') IS NULL) AS dew_point_n,
+    sum(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_sum,
+    count(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_n,
+    sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL) AS rain_mm,
+    max(rain_rate) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL) AS rain_rate_max,
+    sum(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL) AS wind_speed_sum,
+    count(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL) AS wind_speed_n,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL) AS wind_gust,
+    sum(sin(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL) AS wind_dir_sin,
+    sum(cos(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL) AS wind_dir_cos
+FROM weather
+GROUP BY station_id, bucket
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('weather_hourly',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '30 minutes',
+    if_not_exists => true);
+
+SELECT add_continuous_aggregate_policy('weather_daily',
+    start_offset => INTERVAL '7 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour',
+    if_not_exists => true);
@@ -0,0 +1,41 @@
+package postgres
+
+// Not generated: the queries filter on qc_flags in SQL, and this does the
+// same for the rows read into Go.
+
+import (
+	"database/sql"
+
+	"github.com/pointer2null/weather/qc"
+)
+
+// Checked is w with the values quality control flagged set to NULL, as the
+// summarising queries leave them out. Sea level pressure goes with the
+// pressure and the rain with the day's total, so a flagged rain gauge adds
+// no rain. Unreadable flags are treated as none, as before there were flags.
+func (w Weather) Checked() Weather {
+	flags, _ := qc.ParseFlags(w.QcFlags.String)
+	if len(flags) == 0 {
+		return w
+	}
+	blank := func(v qc.Variable, values ...*sql.NullFloat64) {
+		if flags.Flagged(v) {
+			for _, value := range values {
+				*value = sql.NullFloat64{}
+			}
+		}
+	}
+	blank(qc.Temperature, &w.Temperature)
+	blank(qc.Humidity, &w.Humidity)
+	blank(qc.Pressure, &w.Pressure, &w.Mslp)
+	blank(qc.DewPoint, &w.DewPoint)
+	blank(qc.WindSpeed, &w.WindSpeed)
+	blank(qc.WindGust, &w.WindGust)
+	blank(qc.WindDirection, &w.WindDirection)
+	blank(qc.RainRate, &w.RainRate)
+	blank(qc.RainDay, &w.RainDay)
+	if flags.Flagged(qc.RainDay) {
+		w.RainMm = 0
+	}
+	return w
+}
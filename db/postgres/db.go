@@ -24,9 +24,63 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.deleteStationRecordsStmt, err = db.PrepareContext(ctx, deleteStationRecords); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteStationRecords: %w", err)
+	}
 	if q.getAllRecordsStmt, err = db.PrepareContext(ctx, getAllRecords); err != nil {
 		return nil, fmt.Errorf("error preparing query GetAllRecords: %w", err)
 	}
+	if q.getCalibrationsStmt, err = db.PrepareContext(ctx, getCalibrations); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCalibrations: %w", err)
+	}
+	if q.getDailySummariesStmt, err = db.PrepareContext(ctx, getDailySummaries); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDailySummaries: %w", err)
+	}
+	if q.getDailySummariesBetweenStmt, err = db.PrepareContext(ctx, getDailySummariesBetween); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDailySummariesBetween: %w", err)
+	}
+	if q.getDailySummaryDaysStmt, err = db.PrepareContext(ctx, getDailySummaryDays); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDailySummaryDays: %w", err)
+	}
+	if q.getExtremesStmt, err = db.PrepareContext(ctx, getExtremes); err != nil {
+		return nil, fmt.Errorf("error preparing query GetExtremes: %w", err)
+	}
+	if q.getFirstObservationStmt, err = db.PrepareContext(ctx, getFirstObservation); err != nil {
+		return nil, fmt.Errorf("error preparing query GetFirstObservation: %w", err)
+	}
+	if q.getLatestRainEventStmt, err = db.PrepareContext(ctx, getLatestRainEvent); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLatestRainEvent: %w", err)
+	}
+	if q.getObservationsStmt, err = db.PrepareContext(ctx, getObservations); err != nil {
+		return nil, fmt.Errorf("error preparing query GetObservations: %w", err)
+	}
+	if q.getRainEventsStmt, err = db.PrepareContext(ctx, getRainEvents); err != nil {
+		return nil, fmt.Errorf("error preparing query GetRainEvents: %w", err)
+	}
+	if q.getRecordsBetweenStmt, err = db.PrepareContext(ctx, getRecordsBetween); err != nil {
+		return nil, fmt.Errorf("error preparing query GetRecordsBetween: %w", err)
+	}
+	if q.getStationRecordsStmt, err = db.PrepareContext(ctx, getStationRecords); err != nil {
+		return nil, fmt.Errorf("error preparing query GetStationRecords: %w", err)
+	}
+	if q.insertCalibrationStmt, err = db.PrepareContext(ctx, insertCalibration); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertCalibration: %w", err)
+	}
+	if q.updateCalibratedRecordStmt, err = db.PrepareContext(ctx, updateCalibratedRecord); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateCalibratedRecord: %w", err)
+	}
+	if q.upsertDailySummaryStmt, err = db.PrepareContext(ctx, upsertDailySummary); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertDailySummary: %w", err)
+	}
+	if q.upsertRainEventStmt, err = db.PrepareContext(ctx, upsertRainEvent); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertRainEvent: %w", err)
+	}
+	if q.upsertRecordStmt, err = db.PrepareContext(ctx, upsertRecord); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertRecord: %w", err)
+	}
+	if q.upsertStationRecordStmt, err = db.PrepareContext(ctx, upsertStationRecord); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertStationRecord: %w", err)
+	}
 	if q.writeRecordStmt, err = db.PrepareContext(ctx, writeRecord); err != nil {
 		return nil, fmt.Errorf("error preparing query WriteRecord: %w", err)
 	}
@@ -35,11 +89,101 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 
 func (q *Queries) Close() error {
 	var err error
+	if q.deleteStationRecordsStmt != nil {
+		if cerr := q.deleteStationRecordsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteStationRecordsStmt: %w", cerr)
+		}
+	}
 	if q.getAllRecordsStmt != nil {
 		if cerr := q.getAllRecordsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getAllRecordsStmt: %w", cerr)
 		}
 	}
+	if q.getCalibrationsStmt != nil {
+		if cerr := q.getCalibrationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCalibrationsStmt: %w", cerr)
+		}
+	}
+	if q.getDailySummariesStmt != nil {
+		if cerr := q.getDailySummariesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDailySummariesStmt: %w", cerr)
+		}
+	}
+	if q.getDailySummariesBetweenStmt != nil {
+		if cerr := q.getDailySummariesBetweenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDailySummariesBetweenStmt: %w", cerr)
+		}
+	}
+	if q.getDailySummaryDaysStmt != nil {
+		if cerr := q.getDailySummaryDaysStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDailySummaryDaysStmt: %w", cerr)
+		}
+	}
+	if q.getExtremesStmt != nil {
+		if cerr := q.getExtremesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getExtremesStmt: %w", cerr)
+		}
+	}
+	if q.getFirstObservationStmt != nil {
+		if cerr := q.getFirstObservationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getFirstObservationStmt: %w", cerr)
+		}
+	}
+	if q.getLatestRainEventStmt != nil {
+		if cerr := q.getLatestRainEventStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLatestRainEventStmt: %w", cerr)
+		}
+	}
+	if q.getObservationsStmt != nil {
+		if cerr := q.getObservationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getObservationsStmt: %w", cerr)
+		}
+	}
+	if q.getRainEventsStmt != nil {
+		if cerr := q.getRainEventsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getRainEventsStmt: %w", cerr)
+		}
+	}
+	if q.getRecordsBetweenStmt != nil {
+		if cerr := q.getRecordsBetweenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getRecordsBetweenStmt: %w", cerr)
+		}
+	}
+	if q.getStationRecordsStmt != nil {
+		if cerr := q.getStationRecordsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getStationRecordsStmt: %w", cerr)
+		}
+	}
+	if q.insertCalibrationStmt != nil {
+		if cerr := q.insertCalibrationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertCalibrationStmt: %w", cerr)
+		}
+	}
+	if q.updateCalibratedRecordStmt != nil {
+		if cerr := q.updateCalibratedRecordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateCalibratedRecordStmt: %w", cerr)
+		}
+	}
+	if q.upsertDailySummaryStmt != nil {
+		if cerr := q.upsertDailySummaryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertDailySummaryStmt: %w", cerr)
+		}
+	}
+	if q.upsertRainEventStmt != nil {
+		if cerr := q.upsertRainEventStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertRainEventStmt: %w", cerr)
+		}
+	}
+	if q.upsertRecordStmt != nil {
+		if cerr := q.upsertRecordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertRecordStmt: %w", cerr)
+		}
+	}
+	if q.upsertStationRecordStmt != nil {
+		if cerr := q.upsertStationRecordStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertStationRecordStmt: %w", cerr)
+		}
+	}
 	if q.writeRecordStmt != nil {
 		if cerr := q.writeRecordStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing writeRecordStmt: %w", cerr)
@@ -82,17 +226,53 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                DBTX
-	tx                *sql.Tx
-	getAllRecordsStmt *sql.Stmt
-	writeRecordStmt   *sql.Stmt
+	db                           DBTX
+	tx                           *sql.Tx
+	deleteStationRecordsStmt     *sql.Stmt
+	getAllRecordsStmt            *sql.Stmt
+	getCalibrationsStmt          *sql.Stmt
+	getDailySummariesStmt        *sql.Stmt
+	getDailySummariesBetweenStmt *sql.Stmt
+	getDailySummaryDaysStmt      *sql.Stmt
+	getExtremesStmt              *sql.Stmt
+	getFirstObservationStmt      *sql.Stmt
+	getLatestRainEventStmt       *sql.Stmt
+	getObservationsStmt          *sql.Stmt
+	getRainEventsStmt            *sql.Stmt
+	getRecordsBetweenStmt        *sql.Stmt
+	getStationRecordsStmt        *sql.Stmt
+	insertCalibrationStmt        *sql.Stmt
+	updateCalibratedRecordStmt   *sql.Stmt
+	upsertDailySummaryStmt       *sql.Stmt
+	upsertRainEventStmt          *sql.Stmt
+	upsertRecordStmt             *sql.Stmt
+	upsertStationRecordStmt      *sql.Stmt
+	writeRecordStmt              *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                tx,
-		tx:                tx,
-		getAllRecordsStmt: q.getAllRecordsStmt,
-		writeRecordStmt:   q.writeRecordStmt,
+		db:                           tx,
+		tx:                           tx,
+		deleteStationRecordsStmt:     q.deleteStationRecordsStmt,
+		getAllRecordsStmt:            q.getAllRecordsStmt,
+		getCalibrationsStmt:          q.getCalibrationsStmt,
+		getDailySummariesStmt:        q.getDailySummariesStmt,
+		getDailySummariesBetweenStmt: q.getDailySummariesBetweenStmt,
+		getDailySummaryDaysStmt:      q.getDailySummaryDaysStmt,
+		getExtremesStmt:              q.getExtremesStmt,
+		getFirstObservationStmt:      q.getFirstObservationStmt,
+		getLatestRainEventStmt:       q.getLatestRainEventStmt,
+		getObservationsStmt:          q.getObservationsStmt,
+		getRainEventsStmt:            q.getRainEventsStmt,
+		getRecordsBetweenStmt:        q.getRecordsBetweenStmt,
+		getStationRecordsStmt:        q.getStationRecordsStmt,
+		insertCalibrationStmt:        q.insertCalibrationStmt,
+		updateCalibratedRecordStmt:   q.updateCalibratedRecordStmt,
+		upsertDailySummaryStmt:       q.upsertDailySummaryStmt,
+		upsertRainEventStmt:          q.upsertRainEventStmt,
+		upsertRecordStmt:             q.upsertRecordStmt,
+		upsertStationRecordStmt:      q.upsertStationRecordStmt,
+		writeRecordStmt:              q.writeRecordStmt,
 	}
 }
@@ -5,15 +5,81 @@
 package postgres
 
 import (
+	"database/sql"
 	"time"
 )
 
+type Calibration struct {
+	StationID     string    `json:"station_id"`
+	Version       int32     `json:"version"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	Curves        string    `json:"curves"`
+	RecordedAt    time.Time `json:"recorded_at"`
+}
+
+type DailySummary struct {
+	StationID        string          `json:"station_id"`
+	Day              time.Time       `json:"day"`
+	PeriodStart      time.Time       `json:"period_start"`
+	PeriodEnd        time.Time       `json:"period_end"`
+	Samples          int32           `json:"samples"`
+	TemperatureMax   sql.NullFloat64 `json:"temperature_max"`
+	TemperatureMaxAt sql.NullTime    `json:"temperature_max_at"`
+	TemperatureMin   sql.NullFloat64 `json:"temperature_min"`
+	TemperatureMinAt sql.NullTime    `json:"temperature_min_at"`
+	TemperatureMean  sql.NullFloat64 `json:"temperature_mean"`
+	RainTotal        float64         `json:"rain_total"`
+	RainRateMax      sql.NullFloat64 `json:"rain_rate_max"`
+	WindGustMax      sql.NullFloat64 `json:"wind_gust_max"`
+	WindGustDir      sql.NullFloat64 `json:"wind_gust_dir"`
+	WindGustAt       sql.NullTime    `json:"wind_gust_at"`
+	WindSpeedMean    sql.NullFloat64 `json:"wind_speed_mean"`
+	PressureMin      sql.NullFloat64 `json:"pressure_min"`
+	PressureMax      sql.NullFloat64 `json:"pressure_max"`
+	ComputedAt       time.Time       `json:"computed_at"`
+	WindDirDominant  sql.NullFloat64 `json:"wind_dir_dominant"`
+}
+
+type RainEvent struct {
+	StationID  string        `json:"station_id"`
+	StartAt    time.Time     `json:"start_at"`
+	EndAt      time.Time     `json:"end_at"`
+	Ongoing    bool          `json:"ongoing"`
+	TotalMm    float64       `json:"total_mm"`
+	DurationS  int64         `json:"duration_s"`
+	Peak5min   float64       `json:"peak_5min"`
+	Peak10min  float64       `json:"peak_10min"`
+	Peak60min  float64       `json:"peak_60min"`
+	DryBeforeS sql.NullInt64 `json:"dry_before_s"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+type StationRecord struct {
+	StationID  string    `json:"station_id"`
+	Scope      string    `json:"scope"`
+	Period     string    `json:"period"`
+	Metric     string    `json:"metric"`
+	Value      float64   `json:"value"`
+	ObservedAt time.Time `json:"observed_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
 type Weather struct {
-	RecordDate    time.Time `json:"record_date"`
-	Temperature   float64   `json:"temperature"`
-	Pressure      float64   `json:"pressure"`
-	RainMm        float64   `json:"rain_mm"`
-	WindSpeed     float64   `json:"wind_speed"`
-	WindGust      float64   `json:"wind_gust"`
-	WindDirection float64   `json:"wind_direction"`
+	Temperature        sql.NullFloat64 `json:"temperature"`
+	Pressure           sql.NullFloat64 `json:"pressure"`
+	RainMm             float64         `json:"rain_mm"`
+	WindSpeed          sql.NullFloat64 `json:"wind_speed"`
+	WindGust           sql.NullFloat64 `json:"wind_gust"`
+	WindDirection      sql.NullFloat64 `json:"wind_direction"`
+	StationID          string          `json:"station_id"`
+	ObservedAt         time.Time       `json:"observed_at"`
+	Humidity           sql.NullFloat64 `json:"humidity"`
+	DewPoint           sql.NullFloat64 `json:"dew_point"`
+	Mslp               sql.NullFloat64 `json:"mslp"`
+	RainRate           sql.NullFloat64 `json:"rain_rate"`
+	RainDay            sql.NullFloat64 `json:"rain_day"`
+	QcFlags            sql.NullString  `json:"qc_flags"`
+	TemperatureSource  sql.NullString  `json:"temperature_source"`
+	CalibrationVersion sql.NullInt32   `json:"calibration_version"`
+	RainRawMm          sql.NullFloat64 `json:"rain_raw_mm"`
 }
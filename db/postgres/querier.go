@@ -6,10 +6,29 @@ package postgres
 
 import (
 	"context"
+	"time"
 )
 
 type Querier interface {
+	DeleteStationRecords(ctx context.Context, stationID string) error
 	GetAllRecords(ctx context.Context) ([]Weather, error)
+	GetCalibrations(ctx context.Context, stationID string) ([]Calibration, error)
+	GetDailySummaries(ctx context.Context, arg GetDailySummariesParams) ([]GetDailySummariesRow, error)
+	GetDailySummariesBetween(ctx context.Context, arg GetDailySummariesBetweenParams) ([]DailySummary, error)
+	GetDailySummaryDays(ctx context.Context, arg GetDailySummaryDaysParams) ([]time.Time, error)
+	GetExtremes(ctx context.Context, arg GetExtremesParams) (GetExtremesRow, error)
+	GetFirstObservation(ctx context.Context, stationID string) (time.Time, error)
+	GetLatestRainEvent(ctx context.Context, stationID string) (RainEvent, error)
+	GetObservations(ctx context.Context, arg GetObservationsParams) ([]GetObservationsRow, error)
+	GetRainEvents(ctx context.Context, arg GetRainEventsParams) ([]RainEvent, error)
+	GetRecordsBetween(ctx context.Context, arg GetRecordsBetweenParams) ([]Weather, error)
+	GetStationRecords(ctx context.Context, stationID string) ([]StationRecord, error)
+	InsertCalibration(ctx context.Context, arg InsertCalibrationParams) error
+	UpdateCalibratedRecord(ctx context.Context, arg UpdateCalibratedRecordParams) error
+	UpsertDailySummary(ctx context.Context, arg UpsertDailySummaryParams) error
+	UpsertRainEvent(ctx context.Context, arg UpsertRainEventParams) error
+	UpsertRecord(ctx context.Context, arg UpsertRecordParams) error
+	UpsertStationRecord(ctx context.Context, arg UpsertStationRecordParams) error
 	WriteRecord(ctx context.Context, arg WriteRecordParams) error
 }
 
@@ -7,10 +7,22 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
+const deleteStationRecords = `-- name: DeleteStationRecords :exec
+DELETE FROM station_records
+WHERE station_id = $1
+`
+
+func (q *Queries) DeleteStationRecords(ctx context.Context, stationID string) error {
+	_, err := q.exec(ctx, q.deleteStationRecordsStmt, deleteStationRecords, stationID)
+	return err
+}
+
 const getAllRecords = `-- name: GetAllRecords :many
-SELECT record_date, temperature, pressure, rain_mm, wind_speed, wind_gust, wind_direction from weather
+SELECT temperature, pressure, rain_mm, wind_speed, wind_gust, wind_direction, station_id, observed_at, humidity, dew_point, mslp, rain_rate, rain_day, qc_flags, temperature_source, calibration_version, rain_raw_mm from weather
 `
 
 func (q *Queries) GetAllRecords(ctx context.Context) ([]Weather, error) {
@@ -23,13 +35,591 @@ func (q *Queries) GetAllRecords(ctx context.Context) ([]Weather, error) {
 	for rows.Next() {
 		var i Weather
 		if err := rows.Scan(
-			&i.RecordDate,
 			&i.Temperature,
 			&i.Pressure,
 			&i.RainMm,
 			&i.WindSpeed,
 			&i.WindGust,
 			&i.WindDirection,
+			&i.StationID,
+			&i.ObservedAt,
+			&i.Humidity,
+			&i.DewPoint,
+			&i.Mslp,
+			&i.RainRate,
+			&i.RainDay,
+			&i.QcFlags,
+			&i.TemperatureSource,
+			&i.CalibrationVersion,
+			&i.RainRawMm,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCalibrations = `-- name: GetCalibrations :many
+SELECT station_id, version, effective_from, curves, recorded_at FROM calibrations
+WHERE station_id = $1
+ORDER BY effective_from
+`
+
+func (q *Queries) GetCalibrations(ctx context.Context, stationID string) ([]Calibration, error) {
+	rows, err := q.query(ctx, q.getCalibrationsStmt, getCalibrations, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Calibration
+	for rows.Next() {
+		var i Calibration
+		if err := rows.Scan(
+			&i.StationID,
+			&i.Version,
+			&i.EffectiveFrom,
+			&i.Curves,
+			&i.RecordedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDailySummaries = `-- name: GetDailySummaries :many
+SELECT
+    date_trunc('day', observed_at AT TIME ZONE $1::text)::date AS day,
+    count(*) AS samples,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_min,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_max,
+    avg(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_mean,
+    COALESCE(sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL), 0)::float AS rain_mm,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL)::float AS wind_gust_max,
+    avg(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL)::float AS wind_speed_mean,
+    min(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL)::float AS pressure_min,
+    max(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL)::float AS pressure_max
+FROM weather
+WHERE station_id = $2
+  AND observed_at >= $3
+  AND observed_at < $4
+GROUP BY day
+ORDER BY day
+LIMIT $5 OFFSET $6
+`
+
+type GetDailySummariesParams struct {
+	TimeZone  string    `json:"time_zone"`
+	StationID string    `json:"station_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+	RowLimit  int32     `json:"row_limit"`
+	RowOffset int32     `json:"row_offset"`
+}
+
+type GetDailySummariesRow struct {
+	Day             time.Time       `json:"day"`
+	Samples         int64           `json:"samples"`
+	TemperatureMin  sql.NullFloat64 `json:"temperature_min"`
+	TemperatureMax  sql.NullFloat64 `json:"temperature_max"`
+	TemperatureMean sql.NullFloat64 `json:"temperature_mean"`
+	RainMm          float64         `json:"rain_mm"`
+	WindGustMax     sql.NullFloat64 `json:"wind_gust_max"`
+	WindSpeedMean   sql.NullFloat64 `json:"wind_speed_mean"`
+	PressureMin     sql.NullFloat64 `json:"pressure_min"`
+	PressureMax     sql.NullFloat64 `json:"pressure_max"`
+}
+
+func (q *Queries) GetDailySummaries(ctx context.Context, arg GetDailySummariesParams) ([]GetDailySummariesRow, error) {
+	rows, err := q.query(ctx, q.getDailySummariesStmt, getDailySummaries,
+		arg.TimeZone,
+		arg.StationID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDailySummariesRow
+	for rows.Next() {
+		var i GetDailySummariesRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.Samples,
+			&i.TemperatureMin,
+			&i.TemperatureMax,
+			&i.TemperatureMean,
+			&i.RainMm,
+			&i.WindGustMax,
+			&i.WindSpeedMean,
+			&i.PressureMin,
+			&i.PressureMax,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDailySummariesBetween = `-- name: GetDailySummariesBetween :many
+SELECT station_id, day, period_start, period_end, samples, temperature_max, temperature_max_at, temperature_min, temperature_min_at, temperature_mean, rain_total, rain_rate_max, wind_gust_max, wind_gust_dir, wind_gust_at, wind_speed_mean, pressure_min, pressure_max, computed_at, wind_dir_dominant FROM daily_summary
+WHERE station_id = $1
+  AND day >= $2
+  AND day <= $3
+ORDER BY day
+`
+
+type GetDailySummariesBetweenParams struct {
+	StationID string    `json:"station_id"`
+	FromDay   time.Time `json:"from_day"`
+	ToDay     time.Time `json:"to_day"`
+}
+
+func (q *Queries) GetDailySummariesBetween(ctx context.Context, arg GetDailySummariesBetweenParams) ([]DailySummary, error) {
+	rows, err := q.query(ctx, q.getDailySummariesBetweenStmt, getDailySummariesBetween, arg.StationID, arg.FromDay, arg.ToDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DailySummary
+	for rows.Next() {
+		var i DailySummary
+		if err := rows.Scan(
+			&i.StationID,
+			&i.Day,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.Samples,
+			&i.TemperatureMax,
+			&i.TemperatureMaxAt,
+			&i.TemperatureMin,
+			&i.TemperatureMinAt,
+			&i.TemperatureMean,
+			&i.RainTotal,
+			&i.RainRateMax,
+			&i.WindGustMax,
+			&i.WindGustDir,
+			&i.WindGustAt,
+			&i.WindSpeedMean,
+			&i.PressureMin,
+			&i.PressureMax,
+			&i.ComputedAt,
+			&i.WindDirDominant,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDailySummaryDays = `-- name: GetDailySummaryDays :many
+SELECT day FROM daily_summary
+WHERE station_id = $1
+  AND day >= $2
+ORDER BY day
+`
+
+type GetDailySummaryDaysParams struct {
+	StationID string    `json:"station_id"`
+	Day       time.Time `json:"day"`
+}
+
+func (q *Queries) GetDailySummaryDays(ctx context.Context, arg GetDailySummaryDaysParams) ([]time.Time, error) {
+	rows, err := q.query(ctx, q.getDailySummaryDaysStmt, getDailySummaryDays, arg.StationID, arg.Day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		items = append(items, day)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExtremes = `-- name: GetExtremes :one
+SELECT
+    count(*) AS samples,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_max,
+    (array_agg(observed_at ORDER BY temperature DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL))[1] AS temperature_max_at,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_min,
+    (array_agg(observed_at ORDER BY temperature ASC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL))[1] AS temperature_min_at,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL) AS wind_gust_max,
+    (array_agg(observed_at ORDER BY wind_gust DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL))[1] AS wind_gust_max_at,
+    max(rain_rate) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL) AS rain_rate_max,
+    (array_agg(observed_at ORDER BY rain_rate DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL))[1] AS rain_rate_max_at,
+    max(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_max,
+    (array_agg(observed_at ORDER BY pressure DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS pressure_max_at,
+    min(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_min,
+    (array_agg(observed_at ORDER BY pressure ASC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS pressure_min_at,
+    max(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_max,
+    (array_agg(observed_at ORDER BY mslp DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS mslp_max_at,
+    min(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_min,
+    (array_agg(observed_at ORDER BY mslp ASC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS mslp_min_at,
+    COALESCE(sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL), 0)::float AS rain_total
+FROM weather
+WHERE station_id = $1
+  AND observed_at >= $2
+  AND observed_at < $3
+`
+
+type GetExtremesParams struct {
+	StationID string    `json:"station_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+type GetExtremesRow struct {
+	Samples          int64           `json:"samples"`
+	TemperatureMax   sql.NullFloat64 `json:"temperature_max"`
+	TemperatureMaxAt sql.NullTime    `json:"temperature_max_at"`
+	TemperatureMin   sql.NullFloat64 `json:"temperature_min"`
+	TemperatureMinAt sql.NullTime    `json:"temperature_min_at"`
+	WindGustMax      sql.NullFloat64 `json:"wind_gust_max"`
+	WindGustMaxAt    sql.NullTime    `json:"wind_gust_max_at"`
+	RainRateMax      sql.NullFloat64 `json:"rain_rate_max"`
+	RainRateMaxAt    sql.NullTime    `json:"rain_rate_max_at"`
+	PressureMax      sql.NullFloat64 `json:"pressure_max"`
+	PressureMaxAt    sql.NullTime    `json:"pressure_max_at"`
+	PressureMin      sql.NullFloat64 `json:"pressure_min"`
+	PressureMinAt    sql.NullTime    `json:"pressure_min_at"`
+	MslpMax          sql.NullFloat64 `json:"mslp_max"`
+	MslpMaxAt        sql.NullTime    `json:"mslp_max_at"`
+	MslpMin          sql.NullFloat64 `json:"mslp_min"`
+	MslpMinAt        sql.NullTime    `json:"mslp_min_at"`
+	RainTotal        float64         `json:"rain_total"`
+}
+
+func (q *Queries) GetExtremes(ctx context.Context, arg GetExtremesParams) (GetExtremesRow, error) {
+	row := q.queryRow(ctx, q.getExtremesStmt, getExtremes, arg.StationID, arg.FromTime, arg.ToTime)
+	var i GetExtremesRow
+	err := row.Scan(
+		&i.Samples,
+		&i.TemperatureMax,
+		&i.TemperatureMaxAt,
+		&i.TemperatureMin,
+		&i.TemperatureMinAt,
+		&i.WindGustMax,
+		&i.WindGustMaxAt,
+		&i.RainRateMax,
+		&i.RainRateMaxAt,
+		&i.PressureMax,
+		&i.PressureMaxAt,
+		&i.PressureMin,
+		&i.PressureMinAt,
+		&i.MslpMax,
+		&i.MslpMaxAt,
+		&i.MslpMin,
+		&i.MslpMinAt,
+		&i.RainTotal,
+	)
+	return i, err
+}
+
+const getFirstObservation = `-- name: GetFirstObservation :one
+SELECT observed_at FROM weather
+WHERE station_id = $1
+ORDER BY observed_at
+LIMIT 1
+`
+
+func (q *Queries) GetFirstObservation(ctx context.Context, stationID string) (time.Time, error) {
+	row := q.queryRow(ctx, q.getFirstObservationStmt, getFirstObservation, stationID)
+	var observed_at time.Time
+	err := row.Scan(&observed_at)
+	return observed_at, err
+}
+
+const getLatestRainEvent = `-- name: GetLatestRainEvent :one
+SELECT station_id, start_at, end_at, ongoing, total_mm, duration_s, peak_5min, peak_10min, peak_60min, dry_before_s, updated_at FROM rain_events
+WHERE station_id = $1
+ORDER BY start_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestRainEvent(ctx context.Context, stationID string) (RainEvent, error) {
+	row := q.queryRow(ctx, q.getLatestRainEventStmt, getLatestRainEvent, stationID)
+	var i RainEvent
+	err := row.Scan(
+		&i.StationID,
+		&i.StartAt,
+		&i.EndAt,
+		&i.Ongoing,
+		&i.TotalMm,
+		&i.DurationS,
+		&i.Peak5min,
+		&i.Peak10min,
+		&i.Peak60min,
+		&i.DryBeforeS,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getObservations = `-- name: GetObservations :many
+SELECT
+    date_trunc($1::text, observed_at)::timestamptz AS bucket,
+    count(*) AS samples,
+    avg(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_min,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_max,
+    avg(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL)::float AS pressure,
+    avg(humidity) FILTER (WHERE (qc_flags::jsonb -> 'humidity') IS NULL) AS humidity,
+    avg(dew_point) FILTER (WHERE (qc_flags::jsonb -> 'dew_point') IS NULL) AS dew_point,
+    avg(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp,
+    COALESCE(sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL), 0)::float AS rain_mm,
+    max(rain_rate) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL) AS rain_rate_max,
+    avg(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL)::float AS wind_speed,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL)::float AS wind_gust,
+    mod(degrees(atan2(avg(sin(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL), avg(cos(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL))) + 360, 360)::float AS wind_direction
+FROM weather
+WHERE station_id = $2
+  AND observed_at >= $3
+  AND observed_at < $4
+GROUP BY bucket
+ORDER BY bucket
+LIMIT $5 OFFSET $6
+`
+
+type GetObservationsParams struct {
+	Resolution string    `json:"resolution"`
+	StationID  string    `json:"station_id"`
+	FromTime   time.Time `json:"from_time"`
+	ToTime     time.Time `json:"to_time"`
+	RowLimit   int32     `json:"row_limit"`
+	RowOffset  int32     `json:"row_offset"`
+}
+
+type GetObservationsRow struct {
+	Bucket         time.Time       `json:"bucket"`
+	Samples        int64           `json:"samples"`
+	Temperature    sql.NullFloat64 `json:"temperature"`
+	TemperatureMin sql.NullFloat64 `json:"temperature_min"`
+	TemperatureMax sql.NullFloat64 `json:"temperature_max"`
+	Pressure       sql.NullFloat64 `json:"pressure"`
+	Humidity       sql.NullFloat64 `json:"humidity"`
+	DewPoint       sql.NullFloat64 `json:"dew_point"`
+	Mslp           sql.NullFloat64 `json:"mslp"`
+	RainMm         float64         `json:"rain_mm"`
+	RainRateMax    sql.NullFloat64 `json:"rain_rate_max"`
+	WindSpeed      sql.NullFloat64 `json:"wind_speed"`
+	WindGust       sql.NullFloat64 `json:"wind_gust"`
+	WindDirection  sql.NullFloat64 `json:"wind_direction"`
+}
+
+func (q *Queries) GetObservations(ctx context.Context, arg GetObservationsParams) ([]GetObservationsRow, error) {
+	rows, err := q.query(ctx, q.getObservationsStmt, getObservations,
+		arg.Resolution,
+		arg.StationID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetObservationsRow
+	for rows.Next() {
+		var i GetObservationsRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Samples,
+			&i.Temperature,
+			&i.TemperatureMin,
+			&i.TemperatureMax,
+			&i.Pressure,
+			&i.Humidity,
+			&i.DewPoint,
+			&i.Mslp,
+			&i.RainMm,
+			&i.RainRateMax,
+			&i.WindSpeed,
+			&i.WindGust,
+			&i.WindDirection,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRainEvents = `-- name: GetRainEvents :many
+SELECT station_id, start_at, end_at, ongoing, total_mm, duration_s, peak_5min, peak_10min, peak_60min, dry_before_s, updated_at FROM rain_events
+WHERE station_id = $1
+  AND start_at >= $2
+  AND start_at < $3
+ORDER BY start_at
+`
+
+type GetRainEventsParams struct {
+	StationID string    `json:"station_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+func (q *Queries) GetRainEvents(ctx context.Context, arg GetRainEventsParams) ([]RainEvent, error) {
+	rows, err := q.query(ctx, q.getRainEventsStmt, getRainEvents, arg.StationID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RainEvent
+	for rows.Next() {
+		var i RainEvent
+		if err := rows.Scan(
+			&i.StationID,
+			&i.StartAt,
+			&i.EndAt,
+			&i.Ongoing,
+			&i.TotalMm,
+			&i.DurationS,
+			&i.Peak5min,
+			&i.Peak10min,
+			&i.Peak60min,
+			&i.DryBeforeS,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecordsBetween = `-- name: GetRecordsBetween :many
+SELECT temperature, pressure, rain_mm, wind_speed, wind_gust, wind_direction, station_id, observed_at, humidity, dew_point, mslp, rain_rate, rain_day, qc_flags, temperature_source, calibration_version, rain_raw_mm FROM weather
+WHERE station_id = $1
+  AND observed_at > $2
+  AND observed_at <= $3
+ORDER BY observed_at
+`
+
+type GetRecordsBetweenParams struct {
+	StationID string    `json:"station_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+func (q *Queries) GetRecordsBetween(ctx context.Context, arg GetRecordsBetweenParams) ([]Weather, error) {
+	rows, err := q.query(ctx, q.getRecordsBetweenStmt, getRecordsBetween, arg.StationID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Weather
+	for rows.Next() {
+		var i Weather
+		if err := rows.Scan(
+			&i.Temperature,
+			&i.Pressure,
+			&i.RainMm,
+			&i.WindSpeed,
+			&i.WindGust,
+			&i.WindDirection,
+			&i.StationID,
+			&i.ObservedAt,
+			&i.Humidity,
+			&i.DewPoint,
+			&i.Mslp,
+			&i.RainRate,
+			&i.RainDay,
+			&i.QcFlags,
+			&i.TemperatureSource,
+			&i.CalibrationVersion,
+			&i.RainRawMm,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStationRecords = `-- name: GetStationRecords :many
+SELECT station_id, scope, period, metric, value, observed_at, updated_at FROM station_records
+WHERE station_id = $1
+ORDER BY scope, period, metric
+`
+
+func (q *Queries) GetStationRecords(ctx context.Context, stationID string) ([]StationRecord, error) {
+	rows, err := q.query(ctx, q.getStationRecordsStmt, getStationRecords, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StationRecord
+	for rows.Next() {
+		var i StationRecord
+		if err := rows.Scan(
+			&i.StationID,
+			&i.Scope,
+			&i.Period,
+			&i.Metric,
+			&i.Value,
+			&i.ObservedAt,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -44,37 +634,421 @@ func (q *Queries) GetAllRecords(ctx context.Context) ([]Weather, error) {
 	return items, nil
 }
 
+const insertCalibration = `-- name: InsertCalibration :exec
+INSERT INTO calibrations (
+    station_id,
+    version,
+    effective_from,
+    curves
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (station_id, version) DO NOTHING
+`
+
+type InsertCalibrationParams struct {
+	StationID     string    `json:"station_id"`
+	Version       int32     `json:"version"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	Curves        string    `json:"curves"`
+}
+
+func (q *Queries) InsertCalibration(ctx context.Context, arg InsertCalibrationParams) error {
+	_, err := q.exec(ctx, q.insertCalibrationStmt, insertCalibration,
+		arg.StationID,
+		arg.Version,
+		arg.EffectiveFrom,
+		arg.Curves,
+	)
+	return err
+}
+
+const updateCalibratedRecord = `-- name: UpdateCalibratedRecord :exec
+UPDATE weather SET
+    temperature = $3,
+    pressure = $4,
+    rain_mm = $5,
+    wind_speed = $6,
+    wind_gust = $7,
+    humidity = $8,
+    dew_point = $9,
+    mslp = $10,
+    rain_rate = $11,
+    rain_day = $12,
+    calibration_version = $13,
+    rain_raw_mm = $14
+WHERE station_id = $1 AND observed_at = $2
+`
+
+type UpdateCalibratedRecordParams struct {
+	StationID          string          `json:"station_id"`
+	ObservedAt         time.Time       `json:"observed_at"`
+	Temperature        sql.NullFloat64 `json:"temperature"`
+	Pressure           sql.NullFloat64 `json:"pressure"`
+	RainMm             float64         `json:"rain_mm"`
+	WindSpeed          sql.NullFloat64 `json:"wind_speed"`
+	WindGust           sql.NullFloat64 `json:"wind_gust"`
+	Humidity           sql.NullFloat64 `json:"humidity"`
+	DewPoint           sql.NullFloat64 `json:"dew_point"`
+	Mslp               sql.NullFloat64 `json:"mslp"`
+	RainRate           sql.NullFloat64 `json:"rain_rate"`
+	RainDay            sql.NullFloat64 `json:"rain_day"`
+	CalibrationVersion sql.NullInt32   `json:"calibration_version"`
+	RainRawMm          sql.NullFloat64 `json:"rain_raw_mm"`
+}
+
+func (q *Queries) UpdateCalibratedRecord(ctx context.Context, arg UpdateCalibratedRecordParams) error {
+	_, err := q.exec(ctx, q.updateCalibratedRecordStmt, updateCalibratedRecord,
+		arg.StationID,
+		arg.ObservedAt,
+		arg.Temperature,
+		arg.Pressure,
+		arg.RainMm,
+		arg.WindSpeed,
+		arg.WindGust,
+		arg.Humidity,
+		arg.DewPoint,
+		arg.Mslp,
+		arg.RainRate,
+		arg.RainDay,
+		arg.CalibrationVersion,
+		arg.RainRawMm,
+	)
+	return err
+}
+
+const upsertDailySummary = `-- name: UpsertDailySummary :exec
+INSERT INTO daily_summary (
+    station_id,
+    day,
+    period_start,
+    period_end,
+    samples,
+    temperature_max,
+    temperature_max_at,
+    temperature_min,
+    temperature_min_at,
+    temperature_mean,
+    rain_total,
+    rain_rate_max,
+    wind_gust_max,
+    wind_gust_dir,
+    wind_gust_at,
+    wind_speed_mean,
+    pressure_min,
+    pressure_max,
+    wind_dir_dominant
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+)
+ON CONFLICT (station_id, day) DO UPDATE SET
+    period_start = EXCLUDED.period_start,
+    period_end = EXCLUDED.period_end,
+    samples = EXCLUDED.samples,
+    temperature_max = EXCLUDED.temperature_max,
+    temperature_max_at = EXCLUDED.temperature_max_at,
+    temperature_min = EXCLUDED.temperature_min,
+    temperature_min_at = EXCLUDED.temperature_min_at,
+    temperature_mean = EXCLUDED.temperature_mean,
+    rain_total = EXCLUDED.rain_total,
+    rain_rate_max = EXCLUDED.rain_rate_max,
+    wind_gust_max = EXCLUDED.wind_gust_max,
+    wind_gust_dir = EXCLUDED.wind_gust_dir,
+    wind_gust_at = EXCLUDED.wind_gust_at,
+    wind_speed_mean = EXCLUDED.wind_speed_mean,
+    pressure_min = EXCLUDED.pressure_min,
+    pressure_max = EXCLUDED.pressure_max,
+    wind_dir_dominant = EXCLUDED.wind_dir_dominant,
+    computed_at = now()
+`
+
+type UpsertDailySummaryParams struct {
+	StationID        string          `json:"station_id"`
+	Day              time.Time       `json:"day"`
+	PeriodStart      time.Time       `json:"period_start"`
+	PeriodEnd        time.Time       `json:"period_end"`
+	Samples          int32           `json:"samples"`
+	TemperatureMax   sql.NullFloat64 `json:"temperature_max"`
+	TemperatureMaxAt sql.NullTime    `json:"temperature_max_at"`
+	TemperatureMin   sql.NullFloat64 `json:"temperature_min"`
+	TemperatureMinAt sql.NullTime    `json:"temperature_min_at"`
+	TemperatureMean  sql.NullFloat64 `json:"temperature_mean"`
+	RainTotal        float64         `json:"rain_total"`
+	RainRateMax      sql.NullFloat64 `json:"rain_rate_max"`
+	WindGustMax      sql.NullFloat64 `json:"wind_gust_max"`
+	WindGustDir      sql.NullFloat64 `json:"wind_gust_dir"`
+	WindGustAt       sql.NullTime    `json:"wind_gust_at"`
+	WindSpeedMean    sql.NullFloat64 `json:"wind_speed_mean"`
+	PressureMin      sql.NullFloat64 `json:"pressure_min"`
+	PressureMax      sql.NullFloat64 `json:"pressure_max"`
+	WindDirDominant  sql.NullFloat64 `json:"wind_dir_dominant"`
+}
+
+func (q *Queries) UpsertDailySummary(ctx context.Context, arg UpsertDailySummaryParams) error {
+	_, err := q.exec(ctx, q.upsertDailySummaryStmt, upsertDailySummary,
+		arg.StationID,
+		arg.Day,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.Samples,
+		arg.TemperatureMax,
+		arg.TemperatureMaxAt,
+		arg.TemperatureMin,
+		arg.TemperatureMinAt,
+		arg.TemperatureMean,
+		arg.RainTotal,
+		arg.RainRateMax,
+		arg.WindGustMax,
+		arg.WindGustDir,
+		arg.WindGustAt,
+		arg.WindSpeedMean,
+		arg.PressureMin,
+		arg.PressureMax,
+		arg.WindDirDominant,
+	)
+	return err
+}
+
+const upsertRainEvent = `-- name: UpsertRainEvent :exec
+INSERT INTO rain_events (
+    station_id,
+    start_at,
+    end_at,
+    ongoing,
+    total_mm,
+    duration_s,
+    peak_5min,
+    peak_10min,
+    peak_60min,
+    dry_before_s
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+ON CONFLICT (station_id, start_at) DO UPDATE SET
+    end_at = EXCLUDED.end_at,
+    ongoing = EXCLUDED.ongoing,
+    total_mm = EXCLUDED.total_mm,
+    duration_s = EXCLUDED.duration_s,
+    peak_5min = EXCLUDED.peak_5min,
+    peak_10min = EXCLUDED.peak_10min,
+    peak_60min = EXCLUDED.peak_60min,
+    dry_before_s = EXCLUDED.dry_before_s,
+    updated_at = now()
+`
+
+type UpsertRainEventParams struct {
+	StationID  string        `json:"station_id"`
+	StartAt    time.Time     `json:"start_at"`
+	EndAt      time.Time     `json:"end_at"`
+	Ongoing    bool          `json:"ongoing"`
+	TotalMm    float64       `json:"total_mm"`
+	DurationS  int64         `json:"duration_s"`
+	Peak5min   float64       `json:"peak_5min"`
+	Peak10min  float64       `json:"peak_10min"`
+	Peak60min  float64       `json:"peak_60min"`
+	DryBeforeS sql.NullInt64 `json:"dry_before_s"`
+}
+
+func (q *Queries) UpsertRainEvent(ctx context.Context, arg UpsertRainEventParams) error {
+	_, err := q.exec(ctx, q.upsertRainEventStmt, upsertRainEvent,
+		arg.StationID,
+		arg.StartAt,
+		arg.EndAt,
+		arg.Ongoing,
+		arg.TotalMm,
+		arg.DurationS,
+		arg.Peak5min,
+		arg.Peak10min,
+		arg.Peak60min,
+		arg.DryBeforeS,
+	)
+	return err
+}
+
+const upsertRecord = `-- name: UpsertRecord :exec
+INSERT INTO weather (
+    station_id,
+    observed_at,
+    temperature,
+    pressure,
+    rain_mm,
+    wind_speed,
+    wind_gust,
+    wind_direction,
+    humidity,
+    dew_point,
+    mslp,
+    rain_rate,
+    rain_day,
+    qc_flags,
+    temperature_source,
+    calibration_version,
+    rain_raw_mm
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+)
+ON CONFLICT (station_id, observed_at) DO UPDATE SET
+    temperature = EXCLUDED.temperature,
+    pressure = EXCLUDED.pressure,
+    rain_mm = EXCLUDED.rain_mm,
+    wind_speed = EXCLUDED.wind_speed,
+    wind_gust = EXCLUDED.wind_gust,
+    wind_direction = EXCLUDED.wind_direction,
+    humidity = EXCLUDED.humidity,
+    dew_point = EXCLUDED.dew_point,
+    mslp = EXCLUDED.mslp,
+    rain_rate = EXCLUDED.rain_rate,
+    rain_day = EXCLUDED.rain_day,
+    qc_flags = EXCLUDED.qc_flags,
+    temperature_source = EXCLUDED.temperature_source,
+    calibration_version = EXCLUDED.calibration_version,
+    rain_raw_mm = EXCLUDED.rain_raw_mm
+`
+
+type UpsertRecordParams struct {
+	StationID          string          `json:"station_id"`
+	ObservedAt         time.Time       `json:"observed_at"`
+	Temperature        sql.NullFloat64 `json:"temperature"`
+	Pressure           sql.NullFloat64 `json:"pressure"`
+	RainMm             float64         `json:"rain_mm"`
+	WindSpeed          sql.NullFloat64 `json:"wind_speed"`
+	WindGust           sql.NullFloat64 `json:"wind_gust"`
+	WindDirection      sql.NullFloat64 `json:"wind_direction"`
+	Humidity           sql.NullFloat64 `json:"humidity"`
+	DewPoint           sql.NullFloat64 `json:"dew_point"`
+	Mslp               sql.NullFloat64 `json:"mslp"`
+	RainRate           sql.NullFloat64 `json:"rain_rate"`
+	RainDay            sql.NullFloat64 `json:"rain_day"`
+	QcFlags            sql.NullString  `json:"qc_flags"`
+	TemperatureSource  sql.NullString  `json:"temperature_source"`
+	CalibrationVersion sql.NullInt32   `json:"calibration_version"`
+	RainRawMm          sql.NullFloat64 `json:"rain_raw_mm"`
+}
+
+func (q *Queries) UpsertRecord(ctx context.Context, arg UpsertRecordParams) error {
+	_, err := q.exec(ctx, q.upsertRecordStmt, upsertRecord,
+		arg.StationID,
+		arg.ObservedAt,
+		arg.Temperature,
+		arg.Pressure,
+		arg.RainMm,
+		arg.WindSpeed,
+		arg.WindGust,
+		arg.WindDirection,
+		arg.Humidity,
+		arg.DewPoint,
+		arg.Mslp,
+		arg.RainRate,
+		arg.RainDay,
+		arg.QcFlags,
+		arg.TemperatureSource,
+		arg.CalibrationVersion,
+		arg.RainRawMm,
+	)
+	return err
+}
+
+const upsertStationRecord = `-- name: UpsertStationRecord :exec
+INSERT INTO station_records (
+    station_id,
+    scope,
+    period,
+    metric,
+    value,
+    observed_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (station_id, scope, period, metric) DO UPDATE SET
+    value = EXCLUDED.value,
+    observed_at = EXCLUDED.observed_at,
+    updated_at = now()
+`
+
+type UpsertStationRecordParams struct {
+	StationID  string    `json:"station_id"`
+	Scope      string    `json:"scope"`
+	Period     string    `json:"period"`
+	Metric     string    `json:"metric"`
+	Value      float64   `json:"value"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+func (q *Queries) UpsertStationRecord(ctx context.Context, arg UpsertStationRecordParams) error {
+	_, err := q.exec(ctx, q.upsertStationRecordStmt, upsertStationRecord,
+		arg.StationID,
+		arg.Scope,
+		arg.Period,
+		arg.Metric,
+		arg.Value,
+		arg.ObservedAt,
+	)
+	return err
+}
+
 const writeRecord = `-- name: WriteRecord :exec
 INSERT INTO weather (
-    record_date,
+    station_id,
+    observed_at,
     temperature,
     pressure,
     rain_mm,
     wind_speed,
     wind_gust,
-    wind_direction
+    wind_direction,
+    humidity,
+    dew_point,
+    mslp,
+    rain_rate,
+    rain_day,
+    qc_flags,
+    temperature_source,
+    calibration_version,
+    rain_raw_mm
 ) VALUES (
-    now(), $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
 )
 `
 
 type WriteRecordParams struct {
-	Temperature   float64 `json:"temperature"`
-	Pressure      float64 `json:"pressure"`
-	RainMm        float64 `json:"rain_mm"`
-	WindSpeed     float64 `json:"wind_speed"`
-	WindGust      float64 `json:"wind_gust"`
-	WindDirection float64 `json:"wind_direction"`
+	StationID          string          `json:"station_id"`
+	ObservedAt         time.Time       `json:"observed_at"`
+	Temperature        sql.NullFloat64 `json:"temperature"`
+	Pressure           sql.NullFloat64 `json:"pressure"`
+	RainMm             float64         `json:"rain_mm"`
+	WindSpeed          sql.NullFloat64 `json:"wind_speed"`
+	WindGust           sql.NullFloat64 `json:"wind_gust"`
+	WindDirection      sql.NullFloat64 `json:"wind_direction"`
+	Humidity           sql.NullFloat64 `json:"humidity"`
+	DewPoint           sql.NullFloat64 `json:"dew_point"`
+	Mslp               sql.NullFloat64 `json:"mslp"`
+	RainRate           sql.NullFloat64 `json:"rain_rate"`
+	RainDay            sql.NullFloat64 `json:"rain_day"`
+	QcFlags            sql.NullString  `json:"qc_flags"`
+	TemperatureSource  sql.NullString  `json:"temperature_source"`
+	CalibrationVersion sql.NullInt32   `json:"calibration_version"`
+	RainRawMm          sql.NullFloat64 `json:"rain_raw_mm"`
 }
 
 func (q *Queries) WriteRecord(ctx context.Context, arg WriteRecordParams) error {
 	_, err := q.exec(ctx, q.writeRecordStmt, writeRecord,
+		arg.StationID,
+		arg.ObservedAt,
 		arg.Temperature,
 		arg.Pressure,
 		arg.RainMm,
 		arg.WindSpeed,
 		arg.WindGust,
 		arg.WindDirection,
+		arg.Humidity,
+		arg.DewPoint,
+		arg.Mslp,
+		arg.RainRate,
+		arg.RainDay,
+		arg.QcFlags,
+		arg.TemperatureSource,
+		arg.CalibrationVersion,
+		arg.RainRawMm,
 	)
 	return err
 }
@@ -0,0 +1,105 @@
+package postgres
+
+// Not generated: sqlc can't parse the timescale views, so the queries that
+// read them are written here.
+
+import (
+	"context"
+	"fmt"
+)
+
+// Timescale is a Querier that answers GetObservations from the continuous
+// aggregates where the resolution allows it.
+type Timescale struct {
+	*Queries
+	db DBTX
+}
+
+func NewTimescale(db DBTX) *Timescale {
+	return &Timescale{Queries: New(db), db: db}
+}
+
+// rollup rebuilds coarser buckets from an aggregate's sums and counts. The
+// %s is the aggregate view.
+const rollup = `
+SELECT
+    date_trunc($1::text, bucket)::timestamptz,
+    sum(samples)::bigint,
+    sum(temperature_sum) / nullif(sum(temperature_n), 0),
+    min(temperature_min),
+    max(temperature_max),
+    sum(pressure_sum) / nullif(sum(pressure_n), 0),
+    sum(humidity_sum) / nullif(sum(humidity_n), 0),
+    sum(dew_point_sum) / nullif(sum(dew_point_n), 0),
+    sum(mslp_sum) / nullif(sum(mslp_n), 0),
+    coalesce(sum(rain_mm), 0)::float,
+    max(rain_rate_max),
+    sum(wind_speed_sum) / nullif(sum(wind_speed_n), 0),
+    max(wind_gust),
+    mod(degrees(atan2(sum(wind_dir_sin), sum(wind_dir_cos))) + 360, 360)
+FROM %s
+WHERE station_id = $2
+  AND bucket >= $3
+  AND bucket < $4
+GROUP BY 1
+ORDER BY 1
+LIMIT $5 OFFSET $6
+`
+
+// GetObservations reads hourly buckets from weather_hourly and anything
+// coarser from weather_daily. Minutes come from the raw table. Aggregate
+// buckets are whole, so a range that starts part way through one includes
+// the earlier part of it.
+func (t *Timescale) GetObservations(ctx context.Context, arg GetObservationsParams) ([]GetObservationsRow, error) {
+	var view string
+	switch arg.Resolution {
+	case "minute":
+		return t.Queries.GetObservations(ctx, arg)
+	case "hour":
+		view = "weather_hourly"
+	default:
+		view = "weather_daily"
+	}
+	rows, err := t.db.QueryContext(ctx, fmt.Sprintf(rollup, view),
+		arg.Resolution,
+		arg.StationID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetObservationsRow
+	for rows.Next() {
+		var i GetObservationsRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Samples,
+			&i.Temperature,
+			&i.TemperatureMin,
+			&i.TemperatureMax,
+			&i.Pressure,
+			&i.Humidity,
+			&i.DewPoint,
+			&i.Mslp,
+			&i.RainMm,
+			&i.RainRateMax,
+			&i.WindSpeed,
+			&i.WindGust,
+			&i.WindDirection,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
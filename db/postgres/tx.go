@@ -0,0 +1,27 @@
+package postgres
+
+// Not generated: sqlc leaves transactions to the caller.
+
+import (
+	"context"
+	"database/sql"
+)
+
+// InTx runs f with queries inside one transaction, committed if f succeeds
+// and rolled back if not. Queries already in a transaction, or on a
+// connection that can't begin one, run f as they are.
+func (q *Queries) InTx(ctx context.Context, f func(Querier) error) error {
+	db, ok := q.db.(*sql.DB)
+	if !ok {
+		return f(q)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(q.WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
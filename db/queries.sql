@@ -3,13 +3,297 @@ SELECT * from weather;
 
 -- name: WriteRecord :exec
 INSERT INTO weather (
-    record_date,
+    station_id,
+    observed_at,
     temperature,
     pressure,
     rain_mm,
     wind_speed,
     wind_gust,
-    wind_direction
+    wind_direction,
+    humidity,
+    dew_point,
+    mslp,
+    rain_rate,
+    rain_day,
+    qc_flags,
+    temperature_source,
+    calibration_version,
+    rain_raw_mm
 ) VALUES (
-    now(), $1, $2, $3, $4, $5, $6
-);
\ No newline at end of file
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+);
+
+-- name: UpsertRecord :exec
+INSERT INTO weather (
+    station_id,
+    observed_at,
+    temperature,
+    pressure,
+    rain_mm,
+    wind_speed,
+    wind_gust,
+    wind_direction,
+    humidity,
+    dew_point,
+    mslp,
+    rain_rate,
+    rain_day,
+    qc_flags,
+    temperature_source,
+    calibration_version,
+    rain_raw_mm
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+)
+ON CONFLICT (station_id, observed_at) DO UPDATE SET
+    temperature = EXCLUDED.temperature,
+    pressure = EXCLUDED.pressure,
+    rain_mm = EXCLUDED.rain_mm,
+    wind_speed = EXCLUDED.wind_speed,
+    wind_gust = EXCLUDED.wind_gust,
+    wind_direction = EXCLUDED.wind_direction,
+    humidity = EXCLUDED.humidity,
+    dew_point = EXCLUDED.dew_point,
+    mslp = EXCLUDED.mslp,
+    rain_rate = EXCLUDED.rain_rate,
+    rain_day = EXCLUDED.rain_day,
+    qc_flags = EXCLUDED.qc_flags,
+    temperature_source = EXCLUDED.temperature_source,
+    calibration_version = EXCLUDED.calibration_version,
+    rain_raw_mm = EXCLUDED.rain_raw_mm;
+
+-- name: GetObservations :many
+SELECT
+    date_trunc(sqlc.arg(resolution)::text, observed_at)::timestamptz AS bucket,
+    count(*) AS samples,
+    avg(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_min,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_max,
+    avg(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL)::float AS pressure,
+    avg(humidity) FILTER (WHERE (qc_flags::jsonb -> 'humidity') IS NULL) AS humidity,
+    avg(dew_point) FILTER (WHERE (qc_flags::jsonb -> 'dew_point') IS NULL) AS dew_point,
+    avg(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp,
+    COALESCE(sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL), 0)::float AS rain_mm,
+    max(rain_rate) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL) AS rain_rate_max,
+    avg(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL)::float AS wind_speed,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL)::float AS wind_gust,
+    mod(degrees(atan2(avg(sin(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL), avg(cos(radians(wind_direction))) FILTER (WHERE (qc_flags::jsonb -> 'wind_direction') IS NULL))) + 360, 360)::float AS wind_direction
+FROM weather
+WHERE station_id = sqlc.arg(station_id)
+  AND observed_at >= sqlc.arg(from_time)
+  AND observed_at < sqlc.arg(to_time)
+GROUP BY bucket
+ORDER BY bucket
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: GetDailySummaries :many
+SELECT
+    date_trunc('day', observed_at AT TIME ZONE sqlc.arg(time_zone)::text)::date AS day,
+    count(*) AS samples,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_min,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_max,
+    avg(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL)::float AS temperature_mean,
+    COALESCE(sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL), 0)::float AS rain_mm,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL)::float AS wind_gust_max,
+    avg(wind_speed) FILTER (WHERE (qc_flags::jsonb -> 'wind_speed') IS NULL)::float AS wind_speed_mean,
+    min(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL)::float AS pressure_min,
+    max(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL)::float AS pressure_max
+FROM weather
+WHERE station_id = sqlc.arg(station_id)
+  AND observed_at >= sqlc.arg(from_time)
+  AND observed_at < sqlc.arg(to_time)
+GROUP BY day
+ORDER BY day
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: GetExtremes :one
+SELECT
+    count(*) AS samples,
+    max(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_max,
+    (array_agg(observed_at ORDER BY temperature DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL))[1] AS temperature_max_at,
+    min(temperature) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL) AS temperature_min,
+    (array_agg(observed_at ORDER BY temperature ASC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'temperature') IS NULL))[1] AS temperature_min_at,
+    max(wind_gust) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL) AS wind_gust_max,
+    (array_agg(observed_at ORDER BY wind_gust DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'wind_gust') IS NULL))[1] AS wind_gust_max_at,
+    max(rain_rate) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL) AS rain_rate_max,
+    (array_agg(observed_at ORDER BY rain_rate DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'rain_rate') IS NULL))[1] AS rain_rate_max_at,
+    max(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_max,
+    (array_agg(observed_at ORDER BY pressure DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS pressure_max_at,
+    min(pressure) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS pressure_min,
+    (array_agg(observed_at ORDER BY pressure ASC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS pressure_min_at,
+    max(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_max,
+    (array_agg(observed_at ORDER BY mslp DESC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS mslp_max_at,
+    min(mslp) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL) AS mslp_min,
+    (array_agg(observed_at ORDER BY mslp ASC NULLS LAST) FILTER (WHERE (qc_flags::jsonb -> 'pressure') IS NULL))[1] AS mslp_min_at,
+    COALESCE(sum(rain_mm) FILTER (WHERE (qc_flags::jsonb -> 'rain_day') IS NULL), 0)::float AS rain_total
+FROM weather
+WHERE station_id = sqlc.arg(station_id)
+  AND observed_at >= sqlc.arg(from_time)
+  AND observed_at < sqlc.arg(to_time);
+
+-- name: GetRecordsBetween :many
+SELECT * FROM weather
+WHERE station_id = sqlc.arg(station_id)
+  AND observed_at > sqlc.arg(from_time)
+  AND observed_at <= sqlc.arg(to_time)
+ORDER BY observed_at;
+
+-- name: GetFirstObservation :one
+SELECT observed_at FROM weather
+WHERE station_id = $1
+ORDER BY observed_at
+LIMIT 1;
+
+-- name: GetDailySummaryDays :many
+SELECT day FROM daily_summary
+WHERE station_id = $1
+  AND day >= $2
+ORDER BY day;
+
+-- name: GetDailySummariesBetween :many
+SELECT * FROM daily_summary
+WHERE station_id = sqlc.arg(station_id)
+  AND day >= sqlc.arg(from_day)
+  AND day <= sqlc.arg(to_day)
+ORDER BY day;
+
+-- name: UpsertDailySummary :exec
+INSERT INTO daily_summary (
+    station_id,
+    day,
+    period_start,
+    period_end,
+    samples,
+    temperature_max,
+    temperature_max_at,
+    temperature_min,
+    temperature_min_at,
+    temperature_mean,
+    rain_total,
+    rain_rate_max,
+    wind_gust_max,
+    wind_gust_dir,
+    wind_gust_at,
+    wind_speed_mean,
+    pressure_min,
+    pressure_max,
+    wind_dir_dominant
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+)
+ON CONFLICT (station_id, day) DO UPDATE SET
+    period_start = EXCLUDED.period_start,
+    period_end = EXCLUDED.period_end,
+    samples = EXCLUDED.samples,
+    temperature_max = EXCLUDED.temperature_max,
+    temperature_max_at = EXCLUDED.temperature_max_at,
+    temperature_min = EXCLUDED.temperature_min,
+    temperature_min_at = EXCLUDED.temperature_min_at,
+    temperature_mean = EXCLUDED.temperature_mean,
+    rain_total = EXCLUDED.rain_total,
+    rain_rate_max = EXCLUDED.rain_rate_max,
+    wind_gust_max = EXCLUDED.wind_gust_max,
+    wind_gust_dir = EXCLUDED.wind_gust_dir,
+    wind_gust_at = EXCLUDED.wind_gust_at,
+    wind_speed_mean = EXCLUDED.wind_speed_mean,
+    pressure_min = EXCLUDED.pressure_min,
+    pressure_max = EXCLUDED.pressure_max,
+    wind_dir_dominant = EXCLUDED.wind_dir_dominant,
+    computed_at = now();
+
+-- name: GetStationRecords :many
+SELECT * FROM station_records
+WHERE station_id = $1
+ORDER BY scope, period, metric;
+
+-- name: UpsertStationRecord :exec
+INSERT INTO station_records (
+    station_id,
+    scope,
+    period,
+    metric,
+    value,
+    observed_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (station_id, scope, period, metric) DO UPDATE SET
+    value = EXCLUDED.value,
+    observed_at = EXCLUDED.observed_at,
+    updated_at = now();
+
+-- name: DeleteStationRecords :exec
+DELETE FROM station_records
+WHERE station_id = $1;
+
+-- name: GetCalibrations :many
+SELECT * FROM calibrations
+WHERE station_id = $1
+ORDER BY effective_from;
+
+-- name: InsertCalibration :exec
+INSERT INTO calibrations (
+    station_id,
+    version,
+    effective_from,
+    curves
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (station_id, version) DO NOTHING;
+
+-- name: UpdateCalibratedRecord :exec
+UPDATE weather SET
+    temperature = $3,
+    pressure = $4,
+    rain_mm = $5,
+    wind_speed = $6,
+    wind_gust = $7,
+    humidity = $8,
+    dew_point = $9,
+    mslp = $10,
+    rain_rate = $11,
+    rain_day = $12,
+    calibration_version = $13,
+    rain_raw_mm = $14
+WHERE station_id = $1 AND observed_at = $2;
+
+-- name: UpsertRainEvent :exec
+INSERT INTO rain_events (
+    station_id,
+    start_at,
+    end_at,
+    ongoing,
+    total_mm,
+    duration_s,
+    peak_5min,
+    peak_10min,
+    peak_60min,
+    dry_before_s
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+ON CONFLICT (station_id, start_at) DO UPDATE SET
+    end_at = EXCLUDED.end_at,
+    ongoing = EXCLUDED.ongoing,
+    total_mm = EXCLUDED.total_mm,
+    duration_s = EXCLUDED.duration_s,
+    peak_5min = EXCLUDED.peak_5min,
+    peak_10min = EXCLUDED.peak_10min,
+    peak_60min = EXCLUDED.peak_60min,
+    dry_before_s = EXCLUDED.dry_before_s,
+    updated_at = now();
+
+-- name: GetLatestRainEvent :one
+SELECT * FROM rain_events
+WHERE station_id = $1
+ORDER BY start_at DESC
+LIMIT 1;
+
+-- name: GetRainEvents :many
+SELECT * FROM rain_events
+WHERE station_id = sqlc.arg(station_id)
+  AND start_at >= sqlc.arg(from_time)
+  AND start_at < sqlc.arg(to_time)
+ORDER BY start_at;
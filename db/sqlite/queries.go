@@ -0,0 +1,793 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+)
+
+// The queries follow db/queries.sql. Where sqlite has no equivalent of a
+// postgres function the difference is noted on the method.
+
+const weatherColumns = `temperature, pressure, rain_mm, wind_speed, wind_gust, wind_direction, station_id, observed_at, humidity, dew_point, mslp, rain_rate, rain_day, qc_flags, temperature_source, calibration_version, rain_raw_mm`
+
+// weatherFields are the destinations for weatherColumns
+func weatherFields(i *postgres.Weather) []interface{} {
+	return []interface{}{
+		&i.Temperature,
+		&i.Pressure,
+		&i.RainMm,
+		&i.WindSpeed,
+		&i.WindGust,
+		&i.WindDirection,
+		&i.StationID,
+		unixTime{&i.ObservedAt},
+		&i.Humidity,
+		&i.DewPoint,
+		&i.Mslp,
+		&i.RainRate,
+		&i.RainDay,
+		&i.QcFlags,
+		&i.TemperatureSource,
+		&i.CalibrationVersion,
+		&i.RainRawMm,
+	}
+}
+
+func scanWeather(rows *sql.Rows) ([]postgres.Weather, error) {
+	defer rows.Close()
+	var items []postgres.Weather
+	for rows.Next() {
+		var i postgres.Weather
+		if err := rows.Scan(weatherFields(&i)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) DeleteStationRecords(ctx context.Context, stationID string) error {
+	_, err := q.db.ExecContext(ctx, `DELETE FROM station_records WHERE station_id = ?`, stationID)
+	return err
+}
+
+func (q *Queries) GetAllRecords(ctx context.Context) ([]postgres.Weather, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+weatherColumns+` FROM weather`)
+	if err != nil {
+		return nil, err
+	}
+	return scanWeather(rows)
+}
+
+func (q *Queries) GetCalibrations(ctx context.Context, stationID string) ([]postgres.Calibration, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT station_id, version, effective_from, curves, recorded_at FROM calibrations
+WHERE station_id = ?
+ORDER BY effective_from`, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []postgres.Calibration
+	for rows.Next() {
+		var i postgres.Calibration
+		if err := rows.Scan(
+			&i.StationID,
+			&i.Version,
+			unixTime{&i.EffectiveFrom},
+			&i.Curves,
+			unixTime{&i.RecordedAt},
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetDailySummaries groups by calendar day in arg.TimeZone. sqlite has no
+// time zone database so the grouping is done here rather than in SQL.
+func (q *Queries) GetDailySummaries(ctx context.Context, arg postgres.GetDailySummariesParams) ([]postgres.GetDailySummariesRow, error) {
+	loc, err := time.LoadLocation(arg.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.db.QueryContext(ctx, `SELECT `+weatherColumns+` FROM weather
+WHERE station_id = ? AND observed_at >= ? AND observed_at < ?
+ORDER BY observed_at`, arg.StationID, unix(arg.FromTime), unix(arg.ToTime))
+	if err != nil {
+		return nil, err
+	}
+	records, err := scanWeather(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	type day struct {
+		samples                                    int64
+		temperature, pressure, windSpeed, windGust nullStats
+		rain                                       float64
+	}
+	byDay := make(map[time.Time]*day)
+	var days []time.Time
+	for _, r := range records {
+		// flagged values are left out, as the FILTER clauses do in postgres
+		r = r.Checked()
+		y, m, d := r.ObservedAt.In(loc).Date()
+		date := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		s, ok := byDay[date]
+		if !ok {
+			s = &day{}
+			byDay[date] = s
+			days = append(days, date)
+		}
+		s.samples++
+		s.temperature.add(r.Temperature)
+		s.pressure.add(r.Pressure)
+		s.windSpeed.add(r.WindSpeed)
+		s.windGust.add(r.WindGust)
+		s.rain += r.RainMm
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var items []postgres.GetDailySummariesRow
+	for i, date := range days {
+		if i < int(arg.RowOffset) {
+			continue
+		}
+		if len(items) == int(arg.RowLimit) {
+			break
+		}
+		s := byDay[date]
+		items = append(items, postgres.GetDailySummariesRow{
+			Day:             date,
+			Samples:         s.samples,
+			TemperatureMin:  s.temperature.min(),
+			TemperatureMax:  s.temperature.max(),
+			TemperatureMean: s.temperature.mean(),
+			RainMm:          s.rain,
+			WindGustMax:     s.windGust.max(),
+			WindSpeedMean:   s.windSpeed.mean(),
+			PressureMin:     s.pressure.min(),
+			PressureMax:     s.pressure.max(),
+		})
+	}
+	return items, nil
+}
+
+// nullStats aggregates a column as postgres does, ignoring NULLs and giving
+// NULL when there was nothing else
+type nullStats struct {
+	n             int
+	lo, hi, total float64
+}
+
+func (s *nullStats) add(v sql.NullFloat64) {
+	if !v.Valid {
+		return
+	}
+	if s.n == 0 || v.Float64 < s.lo {
+		s.lo = v.Float64
+	}
+	if s.n == 0 || v.Float64 > s.hi {
+		s.hi = v.Float64
+	}
+	s.total += v.Float64
+	s.n++
+}
+
+func (s nullStats) min() sql.NullFloat64 {
+	return sql.NullFloat64{Float64: s.lo, Valid: s.n > 0}
+}
+
+func (s nullStats) max() sql.NullFloat64 {
+	return sql.NullFloat64{Float64: s.hi, Valid: s.n > 0}
+}
+
+func (s nullStats) mean() sql.NullFloat64 {
+	if s.n == 0 {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: s.total / float64(s.n), Valid: true}
+}
+
+const dailySummaryColumns = `station_id, day, period_start, period_end, samples, temperature_max, temperature_max_at, temperature_min, temperature_min_at, temperature_mean, rain_total, rain_rate_max, wind_gust_max, wind_gust_dir, wind_gust_at, wind_speed_mean, pressure_min, pressure_max, computed_at, wind_dir_dominant`
+
+// dailySummaryFields are the destinations for dailySummaryColumns
+func dailySummaryFields(i *postgres.DailySummary) []interface{} {
+	return []interface{}{
+		&i.StationID,
+		unixTime{&i.Day},
+		unixTime{&i.PeriodStart},
+		unixTime{&i.PeriodEnd},
+		&i.Samples,
+		&i.TemperatureMax,
+		nullUnixTime{&i.TemperatureMaxAt},
+		&i.TemperatureMin,
+		nullUnixTime{&i.TemperatureMinAt},
+		&i.TemperatureMean,
+		&i.RainTotal,
+		&i.RainRateMax,
+		&i.WindGustMax,
+		&i.WindGustDir,
+		nullUnixTime{&i.WindGustAt},
+		&i.WindSpeedMean,
+		&i.PressureMin,
+		&i.PressureMax,
+		unixTime{&i.ComputedAt},
+		&i.WindDirDominant,
+	}
+}
+
+func scanDailySummaries(rows *sql.Rows) ([]postgres.DailySummary, error) {
+	defer rows.Close()
+	var items []postgres.DailySummary
+	for rows.Next() {
+		var i postgres.DailySummary
+		if err := rows.Scan(dailySummaryFields(&i)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) GetDailySummariesBetween(ctx context.Context, arg postgres.GetDailySummariesBetweenParams) ([]postgres.DailySummary, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+dailySummaryColumns+` FROM daily_summary
+WHERE station_id = ? AND day >= ? AND day <= ?
+ORDER BY day`, arg.StationID, unix(arg.FromDay), unix(arg.ToDay))
+	if err != nil {
+		return nil, err
+	}
+	return scanDailySummaries(rows)
+}
+
+func (q *Queries) GetDailySummaryDays(ctx context.Context, arg postgres.GetDailySummaryDaysParams) ([]time.Time, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT day FROM daily_summary
+WHERE station_id = ? AND day >= ?
+ORDER BY day`, arg.StationID, unix(arg.Day))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(unixTime{&day}); err != nil {
+			return nil, err
+		}
+		items = append(items, day)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetExtremes finds the time of each extreme with a sub query per metric in
+// place of the array_agg used in postgres, and leaves out flagged values by
+// blanking them in place of its FILTER clauses.
+const getExtremes = `
+WITH w AS (
+    SELECT
+        observed_at,
+        CASE WHEN json_extract(qc_flags, '$.temperature') IS NULL THEN temperature END AS temperature,
+        CASE WHEN json_extract(qc_flags, '$.wind_gust') IS NULL THEN wind_gust END AS wind_gust,
+        CASE WHEN json_extract(qc_flags, '$.rain_rate') IS NULL THEN rain_rate END AS rain_rate,
+        CASE WHEN json_extract(qc_flags, '$.pressure') IS NULL THEN pressure END AS pressure,
+        CASE WHEN json_extract(qc_flags, '$.pressure') IS NULL THEN mslp END AS mslp,
+        CASE WHEN json_extract(qc_flags, '$.rain_day') IS NULL THEN rain_mm END AS rain_mm
+    FROM weather
+    WHERE station_id = ?1 AND observed_at >= ?2 AND observed_at < ?3
+)
+SELECT
+    (SELECT count(*) FROM w),
+    (SELECT max(temperature) FROM w),
+    (SELECT observed_at FROM w WHERE temperature IS NOT NULL ORDER BY temperature DESC LIMIT 1),
+    (SELECT min(temperature) FROM w),
+    (SELECT observed_at FROM w WHERE temperature IS NOT NULL ORDER BY temperature ASC LIMIT 1),
+    (SELECT max(wind_gust) FROM w),
+    (SELECT observed_at FROM w WHERE wind_gust IS NOT NULL ORDER BY wind_gust DESC LIMIT 1),
+    (SELECT max(rain_rate) FROM w),
+    (SELECT observed_at FROM w WHERE rain_rate IS NOT NULL ORDER BY rain_rate DESC LIMIT 1),
+    (SELECT max(pressure) FROM w),
+    (SELECT observed_at FROM w WHERE pressure IS NOT NULL ORDER BY pressure DESC LIMIT 1),
+    (SELECT min(pressure) FROM w),
+    (SELECT observed_at FROM w WHERE pressure IS NOT NULL ORDER BY pressure ASC LIMIT 1),
+    (SELECT max(mslp) FROM w),
+    (SELECT observed_at FROM w WHERE mslp IS NOT NULL ORDER BY mslp DESC LIMIT 1),
+    (SELECT min(mslp) FROM w),
+    (SELECT observed_at FROM w WHERE mslp IS NOT NULL ORDER BY mslp ASC LIMIT 1),
+    (SELECT COALESCE(sum(rain_mm), 0.0) FROM w)
+`
+
+func (q *Queries) GetExtremes(ctx context.Context, arg postgres.GetExtremesParams) (postgres.GetExtremesRow, error) {
+	row := q.db.QueryRowContext(ctx, getExtremes, arg.StationID, unix(arg.FromTime), unix(arg.ToTime))
+	var i postgres.GetExtremesRow
+	err := row.Scan(
+		&i.Samples,
+		&i.TemperatureMax,
+		nullUnixTime{&i.TemperatureMaxAt},
+		&i.TemperatureMin,
+		nullUnixTime{&i.TemperatureMinAt},
+		&i.WindGustMax,
+		nullUnixTime{&i.WindGustMaxAt},
+		&i.RainRateMax,
+		nullUnixTime{&i.RainRateMaxAt},
+		&i.PressureMax,
+		nullUnixTime{&i.PressureMaxAt},
+		&i.PressureMin,
+		nullUnixTime{&i.PressureMinAt},
+		&i.MslpMax,
+		nullUnixTime{&i.MslpMaxAt},
+		&i.MslpMin,
+		nullUnixTime{&i.MslpMinAt},
+		&i.RainTotal,
+	)
+	return i, err
+}
+
+func (q *Queries) GetFirstObservation(ctx context.Context, stationID string) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, `SELECT observed_at FROM weather
+WHERE station_id = ?
+ORDER BY observed_at
+LIMIT 1`, stationID)
+	var observedAt time.Time
+	err := row.Scan(unixTime{&observedAt})
+	return observedAt, err
+}
+
+func (q *Queries) GetLatestRainEvent(ctx context.Context, stationID string) (postgres.RainEvent, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+rainEventColumns+` FROM rain_events
+WHERE station_id = ?
+ORDER BY start_at DESC
+LIMIT 1`, stationID)
+	if err != nil {
+		return postgres.RainEvent{}, err
+	}
+	items, err := scanRainEvents(rows)
+	if err != nil {
+		return postgres.RainEvent{}, err
+	}
+	if len(items) == 0 {
+		return postgres.RainEvent{}, sql.ErrNoRows
+	}
+	return items[0], nil
+}
+
+// GetObservations buckets in UTC, where postgres date_trunc uses the
+// session time zone. Weeks start on Monday as they do in postgres.
+const getObservations = `
+SELECT
+    CASE ?1
+        WHEN 'minute' THEN observed_at / 60 * 60
+        WHEN 'hour' THEN observed_at / 3600 * 3600
+        WHEN 'day' THEN observed_at / 86400 * 86400
+        WHEN 'week' THEN CAST(strftime('%s', observed_at, 'unixepoch', 'weekday 0', '-6 days', 'start of day') AS INTEGER)
+        WHEN 'month' THEN CAST(strftime('%s', observed_at, 'unixepoch', 'start of month') AS INTEGER)
+        ELSE CAST(strftime('%s', observed_at, 'unixepoch', 'start of year') AS INTEGER)
+    END AS bucket,
+    count(*) AS samples,
+    avg(temperature) FILTER (WHERE json_extract(qc_flags, '$.temperature') IS NULL) AS temperature,
+    min(temperature) FILTER (WHERE json_extract(qc_flags, '$.temperature') IS NULL) AS temperature_min,
+    max(temperature) FILTER (WHERE json_extract(qc_flags, '$.temperature') IS NULL) AS temperature_max,
+    avg(pressure) FILTER (WHERE json_extract(qc_flags, '$.pressure') IS NULL) AS pressure,
+    avg(humidity) FILTER (WHERE json_extract(qc_flags, '$.humidity') IS NULL) AS humidity,
+    avg(dew_point) FILTER (WHERE json_extract(qc_flags, '$.dew_point') IS NULL) AS dew_point,
+    avg(mslp) FILTER (WHERE json_extract(qc_flags, '$.pressure') IS NULL) AS mslp,
+    COALESCE(sum(rain_mm) FILTER (WHERE json_extract(qc_flags, '$.rain_day') IS NULL), 0.0) AS rain_mm,
+    max(rain_rate) FILTER (WHERE json_extract(qc_flags, '$.rain_rate') IS NULL) AS rain_rate_max,
+    avg(wind_speed) FILTER (WHERE json_extract(qc_flags, '$.wind_speed') IS NULL) AS wind_speed,
+    max(wind_gust) FILTER (WHERE json_extract(qc_flags, '$.wind_gust') IS NULL) AS wind_gust,
+    mod(degrees(atan2(avg(sin(radians(wind_direction))) FILTER (WHERE json_extract(qc_flags, '$.wind_direction') IS NULL), avg(cos(radians(wind_direction))) FILTER (WHERE json_extract(qc_flags, '$.wind_direction') IS NULL))) + 360, 360) AS wind_direction
+FROM weather
+WHERE station_id = ?2
+  AND observed_at >= ?3
+  AND observed_at < ?4
+GROUP BY bucket
+ORDER BY bucket
+LIMIT ?5 OFFSET ?6
+`
+
+func (q *Queries) GetObservations(ctx context.Context, arg postgres.GetObservationsParams) ([]postgres.GetObservationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getObservations,
+		arg.Resolution,
+		arg.StationID,
+		unix(arg.FromTime),
+		unix(arg.ToTime),
+		arg.RowLimit,
+		arg.RowOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []postgres.GetObservationsRow
+	for rows.Next() {
+		var i postgres.GetObservationsRow
+		if err := rows.Scan(
+			unixTime{&i.Bucket},
+			&i.Samples,
+			&i.Temperature,
+			&i.TemperatureMin,
+			&i.TemperatureMax,
+			&i.Pressure,
+			&i.Humidity,
+			&i.DewPoint,
+			&i.Mslp,
+			&i.RainMm,
+			&i.RainRateMax,
+			&i.WindSpeed,
+			&i.WindGust,
+			&i.WindDirection,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rainEventColumns = `station_id, start_at, end_at, ongoing, total_mm, duration_s, peak_5min, peak_10min, peak_60min, dry_before_s, updated_at`
+
+// rainEventFields are the destinations for rainEventColumns
+func rainEventFields(i *postgres.RainEvent) []interface{} {
+	return []interface{}{
+		&i.StationID,
+		unixTime{&i.StartAt},
+		unixTime{&i.EndAt},
+		&i.Ongoing,
+		&i.TotalMm,
+		&i.DurationS,
+		&i.Peak5min,
+		&i.Peak10min,
+		&i.Peak60min,
+		&i.DryBeforeS,
+		unixTime{&i.UpdatedAt},
+	}
+}
+
+func scanRainEvents(rows *sql.Rows) ([]postgres.RainEvent, error) {
+	defer rows.Close()
+	var items []postgres.RainEvent
+	for rows.Next() {
+		var i postgres.RainEvent
+		if err := rows.Scan(rainEventFields(&i)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) GetRainEvents(ctx context.Context, arg postgres.GetRainEventsParams) ([]postgres.RainEvent, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+rainEventColumns+` FROM rain_events
+WHERE station_id = ? AND start_at >= ? AND start_at < ?
+ORDER BY start_at`, arg.StationID, unix(arg.FromTime), unix(arg.ToTime))
+	if err != nil {
+		return nil, err
+	}
+	return scanRainEvents(rows)
+}
+
+func (q *Queries) GetRecordsBetween(ctx context.Context, arg postgres.GetRecordsBetweenParams) ([]postgres.Weather, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+weatherColumns+` FROM weather
+WHERE station_id = ? AND observed_at > ? AND observed_at <= ?
+ORDER BY observed_at`, arg.StationID, unix(arg.FromTime), unix(arg.ToTime))
+	if err != nil {
+		return nil, err
+	}
+	return scanWeather(rows)
+}
+
+func (q *Queries) GetStationRecords(ctx context.Context, stationID string) ([]postgres.StationRecord, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT station_id, scope, period, metric, value, observed_at, updated_at FROM station_records
+WHERE station_id = ?
+ORDER BY scope, period, metric`, stationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []postgres.StationRecord
+	for rows.Next() {
+		var i postgres.StationRecord
+		if err := rows.Scan(
+			&i.StationID,
+			&i.Scope,
+			&i.Period,
+			&i.Metric,
+			&i.Value,
+			unixTime{&i.ObservedAt},
+			unixTime{&i.UpdatedAt},
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) InsertCalibration(ctx context.Context, arg postgres.InsertCalibrationParams) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO calibrations (
+    station_id, version, effective_from, curves, recorded_at
+) VALUES (
+    ?, ?, ?, ?, ?
+)
+ON CONFLICT (station_id, version) DO NOTHING`,
+		arg.StationID,
+		arg.Version,
+		unix(arg.EffectiveFrom),
+		arg.Curves,
+		unix(time.Now()),
+	)
+	return err
+}
+
+// UpdateCalibratedRecord marks the row unsynced, with a new version, so the
+// reprocessed values reach postgres.
+func (q *Queries) UpdateCalibratedRecord(ctx context.Context, arg postgres.UpdateCalibratedRecordParams) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE weather SET
+    temperature = ?,
+    pressure = ?,
+    rain_mm = ?,
+    wind_speed = ?,
+    wind_gust = ?,
+    humidity = ?,
+    dew_point = ?,
+    mslp = ?,
+    rain_rate = ?,
+    rain_day = ?,
+    calibration_version = ?,
+    rain_raw_mm = ?,
+    synced = 0,
+    version = version + 1
+WHERE station_id = ? AND observed_at = ?`,
+		arg.Temperature,
+		arg.Pressure,
+		arg.RainMm,
+		arg.WindSpeed,
+		arg.WindGust,
+		arg.Humidity,
+		arg.DewPoint,
+		arg.Mslp,
+		arg.RainRate,
+		arg.RainDay,
+		arg.CalibrationVersion,
+		arg.RainRawMm,
+		arg.StationID,
+		unix(arg.ObservedAt),
+	)
+	return err
+}
+
+// A changed summary is marked unsynced again, with a new version, so the
+// new figures reach postgres.
+const upsertDailySummary = `
+INSERT INTO daily_summary (
+    station_id, day, period_start, period_end, samples,
+    temperature_max, temperature_max_at, temperature_min, temperature_min_at, temperature_mean,
+    rain_total, rain_rate_max, wind_gust_max, wind_gust_dir, wind_gust_at,
+    wind_speed_mean, pressure_min, pressure_max, wind_dir_dominant, computed_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (station_id, day) DO UPDATE SET
+    period_start = excluded.period_start,
+    period_end = excluded.period_end,
+    samples = excluded.samples,
+    temperature_max = excluded.temperature_max,
+    temperature_max_at = excluded.temperature_max_at,
+    temperature_min = excluded.temperature_min,
+    temperature_min_at = excluded.temperature_min_at,
+    temperature_mean = excluded.temperature_mean,
+    rain_total = excluded.rain_total,
+    rain_rate_max = excluded.rain_rate_max,
+    wind_gust_max = excluded.wind_gust_max,
+    wind_gust_dir = excluded.wind_gust_dir,
+    wind_gust_at = excluded.wind_gust_at,
+    wind_speed_mean = excluded.wind_speed_mean,
+    pressure_min = excluded.pressure_min,
+    pressure_max = excluded.pressure_max,
+    wind_dir_dominant = excluded.wind_dir_dominant,
+    computed_at = excluded.computed_at,
+    synced = 0,
+    version = version + 1
+`
+
+func (q *Queries) UpsertDailySummary(ctx context.Context, arg postgres.UpsertDailySummaryParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDailySummary,
+		arg.StationID,
+		unix(arg.Day),
+		unix(arg.PeriodStart),
+		unix(arg.PeriodEnd),
+		arg.Samples,
+		arg.TemperatureMax,
+		nullUnix(arg.TemperatureMaxAt),
+		arg.TemperatureMin,
+		nullUnix(arg.TemperatureMinAt),
+		arg.TemperatureMean,
+		arg.RainTotal,
+		arg.RainRateMax,
+		arg.WindGustMax,
+		arg.WindGustDir,
+		nullUnix(arg.WindGustAt),
+		arg.WindSpeedMean,
+		arg.PressureMin,
+		arg.PressureMax,
+		arg.WindDirDominant,
+		unix(time.Now()),
+	)
+	return err
+}
+
+// An event is marked unsynced, with a new version, on every change, so
+// postgres sees it grow and end.
+func (q *Queries) UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO rain_events (
+    station_id, start_at, end_at, ongoing, total_mm, duration_s,
+    peak_5min, peak_10min, peak_60min, dry_before_s, updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (station_id, start_at) DO UPDATE SET
+    end_at = excluded.end_at,
+    ongoing = excluded.ongoing,
+    total_mm = excluded.total_mm,
+    duration_s = excluded.duration_s,
+    peak_5min = excluded.peak_5min,
+    peak_10min = excluded.peak_10min,
+    peak_60min = excluded.peak_60min,
+    dry_before_s = excluded.dry_before_s,
+    updated_at = excluded.updated_at,
+    synced = 0,
+    version = version + 1`,
+		arg.StationID,
+		unix(arg.StartAt),
+		unix(arg.EndAt),
+		arg.Ongoing,
+		arg.TotalMm,
+		arg.DurationS,
+		arg.Peak5min,
+		arg.Peak10min,
+		arg.Peak60min,
+		arg.DryBeforeS,
+		unix(time.Now()),
+	)
+	return err
+}
+
+const writeRecord = `
+INSERT INTO weather (
+    station_id, observed_at, temperature, pressure, rain_mm, wind_speed, wind_gust,
+    wind_direction, humidity, dew_point, mslp, rain_rate, rain_day, qc_flags,
+    temperature_source, calibration_version, rain_raw_mm
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+func (q *Queries) UpsertRecord(ctx context.Context, arg postgres.UpsertRecordParams) error {
+	_, err := q.db.ExecContext(ctx, writeRecord+`ON CONFLICT (station_id, observed_at) DO UPDATE SET
+    temperature = excluded.temperature,
+    pressure = excluded.pressure,
+    rain_mm = excluded.rain_mm,
+    wind_speed = excluded.wind_speed,
+    wind_gust = excluded.wind_gust,
+    wind_direction = excluded.wind_direction,
+    humidity = excluded.humidity,
+    dew_point = excluded.dew_point,
+    mslp = excluded.mslp,
+    rain_rate = excluded.rain_rate,
+    rain_day = excluded.rain_day,
+    qc_flags = excluded.qc_flags,
+    temperature_source = excluded.temperature_source,
+    calibration_version = excluded.calibration_version,
+    rain_raw_mm = excluded.rain_raw_mm,
+    synced = 0,
+    version = version + 1`,
+		arg.StationID,
+		unix(arg.ObservedAt),
+		arg.Temperature,
+		arg.Pressure,
+		arg.RainMm,
+		arg.WindSpeed,
+		arg.WindGust,
+		arg.WindDirection,
+		arg.Humidity,
+		arg.DewPoint,
+		arg.Mslp,
+		arg.RainRate,
+		arg.RainDay,
+		arg.QcFlags,
+		arg.TemperatureSource,
+		arg.CalibrationVersion,
+		arg.RainRawMm,
+	)
+	return err
+}
+
+func (q *Queries) UpsertStationRecord(ctx context.Context, arg postgres.UpsertStationRecordParams) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO station_records (
+    station_id, scope, period, metric, value, observed_at, updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (station_id, scope, period, metric) DO UPDATE SET
+    value = excluded.value,
+    observed_at = excluded.observed_at,
+    updated_at = excluded.updated_at`,
+		arg.StationID,
+		arg.Scope,
+		arg.Period,
+		arg.Metric,
+		arg.Value,
+		unix(arg.ObservedAt),
+		unix(time.Now()),
+	)
+	return err
+}
+
+func (q *Queries) WriteRecord(ctx context.Context, arg postgres.WriteRecordParams) error {
+	_, err := q.db.ExecContext(ctx, writeRecord,
+		arg.StationID,
+		unix(arg.ObservedAt),
+		arg.Temperature,
+		arg.Pressure,
+		arg.RainMm,
+		arg.WindSpeed,
+		arg.WindGust,
+		arg.WindDirection,
+		arg.Humidity,
+		arg.DewPoint,
+		arg.Mslp,
+		arg.RainRate,
+		arg.RainDay,
+		arg.QcFlags,
+		arg.TemperatureSource,
+		arg.CalibrationVersion,
+		arg.RainRawMm,
+	)
+	return err
+}
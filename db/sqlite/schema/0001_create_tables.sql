@@ -0,0 +1,67 @@
+-- +migrate up
+
+-- The local store mirrors the postgres schema. Times are unix seconds so
+-- that bucketing and comparisons stay simple integer arithmetic, and rows
+-- carry a synced flag until the sync has copied them to postgres.
+CREATE TABLE IF NOT EXISTS weather (
+    station_id TEXT NOT NULL DEFAULT 'default',
+    observed_at INTEGER NOT NULL,
+    temperature REAL NOT NULL,
+    pressure REAL NOT NULL,
+    rain_mm REAL NOT NULL,
+    wind_speed REAL NOT NULL,
+    wind_gust REAL NOT NULL,
+    wind_direction REAL NOT NULL,
+    humidity REAL,
+    dew_point REAL,
+    mslp REAL,
+    rain_rate REAL,
+    rain_day REAL,
+    synced INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (station_id, observed_at)
+);
+
+CREATE INDEX IF NOT EXISTS weather_unsynced ON weather (observed_at) WHERE synced = 0;
+
+-- day is midnight UTC of the date the climatological day starts on
+CREATE TABLE IF NOT EXISTS daily_summary (
+    station_id TEXT NOT NULL,
+    day INTEGER NOT NULL,
+    period_start INTEGER NOT NULL,
+    period_end INTEGER NOT NULL,
+    samples INTEGER NOT NULL,
+    temperature_max REAL NOT NULL,
+    temperature_max_at INTEGER NOT NULL,
+    temperature_min REAL NOT NULL,
+    temperature_min_at INTEGER NOT NULL,
+    temperature_mean REAL NOT NULL,
+    rain_total REAL NOT NULL,
+    rain_rate_max REAL,
+    wind_gust_max REAL NOT NULL,
+    wind_gust_dir REAL NOT NULL,
+    wind_gust_at INTEGER NOT NULL,
+    wind_speed_mean REAL NOT NULL,
+    pressure_min REAL NOT NULL,
+    pressure_max REAL NOT NULL,
+    computed_at INTEGER NOT NULL,
+    wind_dir_dominant REAL,
+    synced INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (station_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS station_records (
+    station_id TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    period TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    value REAL NOT NULL,
+    observed_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (station_id, scope, period, metric)
+);
+
+-- +migrate down
+
+DROP TABLE IF EXISTS station_records;
+DROP TABLE IF EXISTS daily_summary;
+DROP TABLE IF EXISTS weather;
@@ -0,0 +1,7 @@
+-- +migrate up
+
+ALTER TABLE weather ADD COLUMN qc_flags TEXT;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN qc_flags;
@@ -0,0 +1,7 @@
+-- +migrate up
+
+ALTER TABLE weather ADD COLUMN temperature_source TEXT;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN temperature_source;
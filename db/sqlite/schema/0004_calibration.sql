@@ -0,0 +1,17 @@
+-- +migrate up
+
+CREATE TABLE calibrations (
+    station_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    effective_from INTEGER NOT NULL,
+    curves TEXT NOT NULL,
+    recorded_at INTEGER NOT NULL,
+    PRIMARY KEY (station_id, version)
+);
+
+ALTER TABLE weather ADD COLUMN calibration_version INTEGER;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN calibration_version;
+DROP TABLE calibrations;
@@ -0,0 +1,7 @@
+-- +migrate up
+
+ALTER TABLE weather ADD COLUMN rain_raw_mm REAL;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN rain_raw_mm;
@@ -0,0 +1,21 @@
+-- +migrate up
+
+CREATE TABLE rain_events (
+    station_id TEXT NOT NULL,
+    start_at INTEGER NOT NULL,
+    end_at INTEGER NOT NULL,
+    ongoing INTEGER NOT NULL,
+    total_mm REAL NOT NULL,
+    duration_s INTEGER NOT NULL,
+    peak_5min REAL NOT NULL,
+    peak_10min REAL NOT NULL,
+    peak_60min REAL NOT NULL,
+    dry_before_s INTEGER,
+    updated_at INTEGER NOT NULL,
+    synced INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (station_id, start_at)
+);
+
+-- +migrate down
+
+DROP TABLE rain_events;
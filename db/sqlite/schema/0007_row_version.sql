@@ -0,0 +1,9 @@
+-- +migrate up
+
+-- version counts the changes to an observation, so the sync only marks one
+-- synced if it hasn't changed since it was read
+ALTER TABLE weather ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+-- +migrate down
+
+ALTER TABLE weather DROP COLUMN version;
@@ -0,0 +1,81 @@
+-- +migrate up
+
+-- A reading from a sensor that is off or couldn't be read is NULL rather
+-- than 0, as in postgres. sqlite can't drop a NOT NULL constraint, so the
+-- tables are rebuilt.
+CREATE TABLE weather_new (
+    station_id TEXT NOT NULL DEFAULT 'default',
+    observed_at INTEGER NOT NULL,
+    temperature REAL,
+    pressure REAL,
+    rain_mm REAL NOT NULL,
+    wind_speed REAL,
+    wind_gust REAL,
+    wind_direction REAL,
+    humidity REAL,
+    dew_point REAL,
+    mslp REAL,
+    rain_rate REAL,
+    rain_day REAL,
+    synced INTEGER NOT NULL DEFAULT 0,
+    qc_flags TEXT,
+    temperature_source TEXT,
+    calibration_version INTEGER,
+    rain_raw_mm REAL,
+    version INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (station_id, observed_at)
+);
+
+INSERT INTO weather_new (
+    station_id, observed_at, temperature, pressure, rain_mm, wind_speed, wind_gust,
+    wind_direction, humidity, dew_point, mslp, rain_rate, rain_day, synced, qc_flags,
+    temperature_source, calibration_version, rain_raw_mm, version
+)
+SELECT
+    station_id, observed_at, temperature, pressure, rain_mm, wind_speed, wind_gust,
+    wind_direction, humidity, dew_point, mslp, rain_rate, rain_day, synced, qc_flags,
+    temperature_source, calibration_version, rain_raw_mm, version
+FROM weather;
+
+DROP TABLE weather;
+ALTER TABLE weather_new RENAME TO weather;
+CREATE INDEX IF NOT EXISTS weather_unsynced ON weather (observed_at) WHERE synced = 0;
+
+CREATE TABLE daily_summary_new (
+    station_id TEXT NOT NULL,
+    day INTEGER NOT NULL,
+    period_start INTEGER NOT NULL,
+    period_end INTEGER NOT NULL,
+    samples INTEGER NOT NULL,
+    temperature_max REAL,
+    temperature_max_at INTEGER,
+    temperature_min REAL,
+    temperature_min_at INTEGER,
+    temperature_mean REAL,
+    rain_total REAL NOT NULL,
+    rain_rate_max REAL,
+    wind_gust_max REAL,
+    wind_gust_dir REAL,
+    wind_gust_at INTEGER,
+    wind_speed_mean REAL,
+    pressure_min REAL,
+    pressure_max REAL,
+    computed_at INTEGER NOT NULL,
+    wind_dir_dominant REAL,
+    synced INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (station_id, day)
+);
+
+INSERT INTO daily_summary_new SELECT
+    station_id, day, period_start, period_end, samples,
+    temperature_max, temperature_max_at, temperature_min, temperature_min_at, temperature_mean,
+    rain_total, rain_rate_max, wind_gust_max, wind_gust_dir, wind_gust_at,
+    wind_speed_mean, pressure_min, pressure_max, computed_at, wind_dir_dominant, synced
+FROM daily_summary;
+
+DROP TABLE daily_summary;
+ALTER TABLE daily_summary_new RENAME TO daily_summary;
+
+-- +migrate down
+
+-- the NOT NULL constraints are not restored
@@ -0,0 +1,13 @@
+-- +migrate up
+
+-- as for observations, version counts the changes to a daily summary or
+-- rain event, so the sync only marks one synced if it hasn't changed since
+-- it was read. Their computed and updated times are whole seconds, so a
+-- change within the same second went unnoticed.
+ALTER TABLE daily_summary ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rain_events ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+-- +migrate down
+
+ALTER TABLE daily_summary DROP COLUMN version;
+ALTER TABLE rain_events DROP COLUMN version;
@@ -0,0 +1,105 @@
+// Package sqlite is the local store on the Pi. It implements the same
+// Querier as the postgres package so the rest of the station can use either,
+// and keeps track of which rows still need copying to postgres.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/pointer2null/weather/db/migrations"
+	"github.com/pointer2null/weather/db/postgres"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed schema/*.sql
+var schema embed.FS
+
+type Queries struct {
+	db postgres.DBTX
+}
+
+var _ postgres.Querier = (*Queries)(nil)
+
+// Open opens, creating if needed, the database at path and brings its
+// schema up to date.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	// WAL lets the API read while the reporting loop writes, and the busy
+	// timeout covers the moments they still collide.
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)", path))
+	if err != nil {
+		return nil, err
+	}
+	files, err := fs.Sub(schema, "schema")
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := migrations.Apply(ctx, db, files, migrations.SQLite); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to migrate sqlite database: %w", err)
+	}
+	return db, nil
+}
+
+func New(db *sql.DB) *Queries {
+	return &Queries{db: db}
+}
+
+// InTx runs f with queries inside one transaction, committed if f succeeds
+// and rolled back if not
+func (q *Queries) InTx(ctx context.Context, f func(postgres.Querier) error) error {
+	db, ok := q.db.(*sql.DB)
+	if !ok {
+		return f(q)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(&Queries{db: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Times are stored as unix seconds
+
+func unix(t time.Time) int64 {
+	return t.Unix()
+}
+
+func nullUnix(t sql.NullTime) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.Time.Unix(), Valid: t.Valid}
+}
+
+type unixTime struct {
+	t *time.Time
+}
+
+func (u unixTime) Scan(v any) error {
+	n, ok := v.(int64)
+	if !ok {
+		return fmt.Errorf("expected unix time, got %T", v)
+	}
+	*u.t = time.Unix(n, 0).UTC()
+	return nil
+}
+
+type nullUnixTime struct {
+	t *sql.NullTime
+}
+
+func (u nullUnixTime) Scan(v any) error {
+	if v == nil {
+		*u.t = sql.NullTime{}
+		return nil
+	}
+	u.t.Valid = true
+	return unixTime{&u.t.Time}.Scan(v)
+}
@@ -0,0 +1,487 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+func openTest(t *testing.T) *Queries {
+	db, err := Open(context.Background(), filepath.Join(t.TempDir(), "weather.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return New(db)
+}
+
+func record(at time.Time, temp float64, dir float64) postgres.WriteRecordParams {
+	return postgres.WriteRecordParams{
+		StationID:     "home",
+		ObservedAt:    at,
+		Temperature:   valid(temp),
+		Pressure:      valid(1000 + temp),
+		RainMm:        0.2,
+		WindSpeed:     valid(2),
+		WindGust:      valid(temp / 2),
+		WindDirection: valid(dir),
+		Humidity:      sql.NullFloat64{Float64: 80, Valid: true},
+	}
+}
+
+func valid(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: true}
+}
+
+var t0 = time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+
+func TestQueries(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+
+	_, err := q.GetFirstObservation(ctx, "home")
+	require.True(t, errors.Is(err, sql.ErrNoRows))
+
+	for i, temp := range []float64{10, 14, 12, 8} {
+		// wind either side of north
+		dir := 350.0
+		if i%2 == 1 {
+			dir = 10
+		}
+		require.NoError(t, q.WriteRecord(ctx, record(t0.Add(time.Duration(i)*30*time.Minute), temp, dir)))
+	}
+	require.Error(t, q.WriteRecord(ctx, record(t0, 1, 0)))
+
+	first, err := q.GetFirstObservation(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, t0, first)
+
+	obs, err := q.GetObservations(ctx, postgres.GetObservationsParams{
+		Resolution: "hour", StationID: "home", FromTime: t0, ToTime: t0.Add(24 * time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, obs, 2)
+	require.Equal(t, t0, obs[0].Bucket)
+	require.Equal(t, int64(2), obs[0].Samples)
+	require.Equal(t, valid(12), obs[0].Temperature)
+	require.Equal(t, valid(14), obs[0].TemperatureMax)
+	require.InDelta(t, 0.4, obs[0].RainMm, 1e-9)
+	require.Equal(t, sql.NullFloat64{Float64: 80, Valid: true}, obs[0].Humidity)
+	require.False(t, obs[0].Mslp.Valid)
+	require.True(t, obs[0].WindDirection.Float64 < 1 || obs[0].WindDirection.Float64 > 359)
+
+	week, err := q.GetObservations(ctx, postgres.GetObservationsParams{
+		Resolution: "week", StationID: "home", FromTime: t0, ToTime: t0.Add(24 * time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, week, 1)
+	// 12 October 2026 is a monday
+	require.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), week[0].Bucket)
+
+	ext, err := q.GetExtremes(ctx, postgres.GetExtremesParams{StationID: "home", FromTime: t0, ToTime: t0.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), ext.Samples)
+	require.Equal(t, 14.0, ext.TemperatureMax.Float64)
+	require.Equal(t, t0.Add(30*time.Minute), ext.TemperatureMaxAt.Time)
+	require.Equal(t, t0.Add(90*time.Minute), ext.TemperatureMinAt.Time)
+	require.False(t, ext.RainRateMax.Valid)
+	require.False(t, ext.RainRateMaxAt.Valid)
+	// no sea level pressure was recorded
+	require.False(t, ext.MslpMax.Valid)
+	require.False(t, ext.MslpMinAt.Valid)
+
+	daily, err := q.GetDailySummaries(ctx, postgres.GetDailySummariesParams{
+		TimeZone: "Europe/London", StationID: "home", FromTime: t0, ToTime: t0.Add(24 * time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	require.Equal(t, valid(11), daily[0].TemperatureMean)
+	require.Equal(t, valid(8), daily[0].TemperatureMin)
+
+	between, err := q.GetRecordsBetween(ctx, postgres.GetRecordsBetweenParams{StationID: "home", FromTime: t0, ToTime: t0.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, between, 2)
+	require.Equal(t, t0.Add(time.Hour), between[1].ObservedAt)
+}
+
+func TestUnreadReadings(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+
+	// the temperature sensor and anemometer were out for the second record
+	require.NoError(t, q.WriteRecord(ctx, record(t0, 10, 90)))
+	out := record(t0.Add(30*time.Minute), 0, 0)
+	out.Temperature, out.WindSpeed, out.WindGust, out.WindDirection = sql.NullFloat64{}, sql.NullFloat64{}, sql.NullFloat64{}, sql.NullFloat64{}
+	require.NoError(t, q.WriteRecord(ctx, out))
+
+	obs, err := q.GetObservations(ctx, postgres.GetObservationsParams{
+		Resolution: "minute", StationID: "home", FromTime: t0, ToTime: t0.Add(time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, obs, 2)
+	require.False(t, obs[1].Temperature.Valid)
+	require.False(t, obs[1].WindDirection.Valid)
+
+	obs, err = q.GetObservations(ctx, postgres.GetObservationsParams{
+		Resolution: "hour", StationID: "home", FromTime: t0, ToTime: t0.Add(time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, valid(10), obs[0].Temperature)
+	require.Equal(t, valid(10), obs[0].TemperatureMin)
+
+	ext, err := q.GetExtremes(ctx, postgres.GetExtremesParams{StationID: "home", FromTime: t0, ToTime: t0.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, valid(10), ext.TemperatureMin)
+	require.Equal(t, t0, ext.TemperatureMinAt.Time)
+	require.Equal(t, valid(5), ext.WindGustMax)
+
+	daily, err := q.GetDailySummaries(ctx, postgres.GetDailySummariesParams{
+		TimeZone: "Europe/London", StationID: "home", FromTime: t0, ToTime: t0.Add(24 * time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	require.Equal(t, valid(10), daily[0].TemperatureMin)
+	require.Equal(t, valid(10), daily[0].TemperatureMean)
+	require.Equal(t, valid(2), daily[0].WindSpeedMean)
+}
+
+func TestFlaggedReadings(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.WriteRecord(ctx, record(t0, 10, 90)))
+	bad := record(t0.Add(30*time.Minute), 60, 90)
+	bad.RainMm = 25
+	bad.QcFlags = sql.NullString{String: `{"temperature":"failed","wind_gust":"suspect","rain_day":"failed"}`, Valid: true}
+	require.NoError(t, q.WriteRecord(ctx, bad))
+
+	obs, err := q.GetObservations(ctx, postgres.GetObservationsParams{
+		Resolution: "hour", StationID: "home", FromTime: t0, ToTime: t0.Add(time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), obs[0].Samples)
+	require.Equal(t, valid(10), obs[0].Temperature)
+	require.Equal(t, valid(10), obs[0].TemperatureMax)
+	require.Equal(t, valid(5), obs[0].WindGust)
+	require.InDelta(t, 0.2, obs[0].RainMm, 1e-9)
+	// the pressure wasn't flagged
+	require.Equal(t, valid(1035), obs[0].Pressure)
+
+	ext, err := q.GetExtremes(ctx, postgres.GetExtremesParams{StationID: "home", FromTime: t0, ToTime: t0.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, valid(10), ext.TemperatureMax)
+	require.Equal(t, t0, ext.TemperatureMaxAt.Time)
+	require.Equal(t, valid(5), ext.WindGustMax)
+	require.InDelta(t, 0.2, ext.RainTotal, 1e-9)
+	require.Equal(t, valid(1060), ext.PressureMax)
+
+	daily, err := q.GetDailySummaries(ctx, postgres.GetDailySummariesParams{
+		TimeZone: "Europe/London", StationID: "home", FromTime: t0, ToTime: t0.Add(24 * time.Hour), RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, valid(10), daily[0].TemperatureMax)
+	require.Equal(t, valid(5), daily[0].WindGustMax)
+	require.InDelta(t, 0.2, daily[0].RainMm, 1e-9)
+}
+
+type fakeRemote struct {
+	records   []postgres.UpsertRecordParams
+	summaries []postgres.UpsertDailySummaryParams
+	events    []postgres.UpsertRainEventParams
+	err       error
+}
+
+func (f *fakeRemote) UpsertRecord(ctx context.Context, arg postgres.UpsertRecordParams) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.records = append(f.records, arg)
+	return nil
+}
+
+func (f *fakeRemote) UpsertDailySummary(ctx context.Context, arg postgres.UpsertDailySummaryParams) error {
+	f.summaries = append(f.summaries, arg)
+	return nil
+}
+
+func (f *fakeRemote) UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error {
+	f.events = append(f.events, arg)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+	remote := &fakeRemote{err: errors.New("connection refused")}
+	prepared := 0
+	s := NewSyncer(q, remote, func(ctx context.Context) error {
+		prepared++
+		return nil
+	})
+
+	require.NoError(t, q.WriteRecord(ctx, record(t0, 10, 0)))
+	flagged := record(t0.Add(time.Minute), 11, 0)
+	flagged.QcFlags = sql.NullString{String: `{"temperature":"suspect"}`, Valid: true}
+	flagged.TemperatureSource = sql.NullString{String: "bme280", Valid: true}
+	flagged.CalibrationVersion = sql.NullInt32{Int32: 2, Valid: true}
+	flagged.RainRawMm = sql.NullFloat64{Float64: 0.2794, Valid: true}
+	require.NoError(t, q.WriteRecord(ctx, flagged))
+
+	// postgres down, nothing is lost
+	_, err := s.Sync(ctx)
+	require.Error(t, err)
+	unsynced, err := q.GetUnsyncedRecords(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, unsynced, 2)
+
+	var from, to time.Time
+	s.OnSynced(func(ctx context.Context, start time.Time, end time.Time) error {
+		from, to = start, end
+		return nil
+	})
+	remote.err = nil
+	n, err := s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, t0, from)
+	require.Equal(t, t0.Add(time.Minute), to)
+	require.Equal(t, t0, remote.records[0].ObservedAt)
+	require.False(t, remote.records[0].QcFlags.Valid)
+	require.Equal(t, flagged.QcFlags, remote.records[1].QcFlags)
+	require.Equal(t, flagged.TemperatureSource, remote.records[1].TemperatureSource)
+	require.Equal(t, flagged.CalibrationVersion, remote.records[1].CalibrationVersion)
+	require.Equal(t, flagged.RainRawMm, remote.records[1].RainRawMm)
+	require.Equal(t, 1, prepared)
+
+	n, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// a recalibrated record goes again
+	require.NoError(t, q.UpdateCalibratedRecord(ctx, postgres.UpdateCalibratedRecordParams{
+		StationID:          "home",
+		ObservedAt:         t0,
+		Temperature:        valid(10.5),
+		Pressure:           valid(1010),
+		CalibrationVersion: sql.NullInt32{Int32: 3, Valid: true},
+	}))
+	n, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, valid(10.5), remote.records[2].Temperature)
+	require.Equal(t, int32(3), remote.records[2].CalibrationVersion.Int32)
+
+	summary := postgres.UpsertDailySummaryParams{StationID: "home", Day: time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), RainTotal: 1}
+	require.NoError(t, q.UpsertDailySummary(ctx, summary))
+	n, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	// a recomputed summary goes again
+	summary.RainTotal = 2
+	require.NoError(t, q.UpsertDailySummary(ctx, summary))
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Len(t, remote.summaries, 2)
+	require.Equal(t, 2.0, remote.summaries[1].RainTotal)
+}
+
+func TestCalibrations(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+
+	later := postgres.InsertCalibrationParams{StationID: "home", Version: 2, EffectiveFrom: t0.Add(time.Hour), Curves: `{"pressure":{"offset":1}}`}
+	require.NoError(t, q.InsertCalibration(ctx, later))
+	require.NoError(t, q.InsertCalibration(ctx, postgres.InsertCalibrationParams{StationID: "home", Version: 1, EffectiveFrom: t0, Curves: `{}`}))
+	// a recorded version is never replaced
+	require.NoError(t, q.InsertCalibration(ctx, postgres.InsertCalibrationParams{StationID: "home", Version: 2, EffectiveFrom: t0, Curves: `{}`}))
+	require.NoError(t, q.InsertCalibration(ctx, postgres.InsertCalibrationParams{StationID: "away", Version: 1, EffectiveFrom: t0, Curves: `{}`}))
+
+	got, err := q.GetCalibrations(ctx, "home")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, int32(1), got[0].Version)
+	require.Equal(t, later.EffectiveFrom, got[1].EffectiveFrom)
+	require.Equal(t, later.Curves, got[1].Curves)
+	require.False(t, got[1].RecordedAt.IsZero())
+}
+
+func TestRainEvents(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+	remote := &fakeRemote{}
+	s := NewSyncer(q, remote, nil)
+
+	_, err := q.GetLatestRainEvent(ctx, "home")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	first := postgres.UpsertRainEventParams{StationID: "home", StartAt: t0, EndAt: t0.Add(time.Hour), TotalMm: 4.2, DurationS: 3600, Peak5min: 12, Peak10min: 9, Peak60min: 4.2}
+	require.NoError(t, q.UpsertRainEvent(ctx, first))
+	event := postgres.UpsertRainEventParams{StationID: "home", StartAt: t0.Add(5 * time.Hour), EndAt: t0.Add(5 * time.Hour), Ongoing: true, TotalMm: 0.2794, Peak5min: 3.35, Peak10min: 1.68, Peak60min: 0.28,
+		DryBeforeS: sql.NullInt64{Int64: 4 * 3600, Valid: true}}
+	require.NoError(t, q.UpsertRainEvent(ctx, event))
+
+	latest, err := q.GetLatestRainEvent(ctx, "home")
+	require.NoError(t, err)
+	require.Equal(t, event.StartAt, latest.StartAt)
+	require.True(t, latest.Ongoing)
+	require.Equal(t, event.DryBeforeS, latest.DryBeforeS)
+	got, err := q.GetRainEvents(ctx, postgres.GetRainEventsParams{StationID: "home", FromTime: t0, ToTime: t0.Add(5 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, 12.0, got[0].Peak5min)
+	require.False(t, got[0].DryBeforeS.Valid)
+
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Len(t, remote.events, 2)
+
+	// the event ending goes again
+	event.Ongoing = false
+	event.TotalMm = 1.1176
+	require.NoError(t, q.UpsertRainEvent(ctx, event))
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Len(t, remote.events, 3)
+	require.False(t, remote.events[2].Ongoing)
+	require.Equal(t, 1.1176, remote.events[2].TotalMm)
+}
+
+func TestInTx(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+	rec := postgres.UpsertStationRecordParams{StationID: "home", Scope: "all", Metric: "wind_gust", Value: 40, ObservedAt: t0}
+	require.NoError(t, q.UpsertStationRecord(ctx, rec))
+
+	// a failure part way leaves the records as they were
+	failed := errors.New("failed")
+	err := q.InTx(ctx, func(db postgres.Querier) error {
+		if err := db.DeleteStationRecords(ctx, "home"); err != nil {
+			return err
+		}
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	got, err := q.GetStationRecords(ctx, "home")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	require.NoError(t, q.InTx(ctx, func(db postgres.Querier) error {
+		if err := db.DeleteStationRecords(ctx, "home"); err != nil {
+			return err
+		}
+		rec.Value = 45
+		return db.UpsertStationRecord(ctx, rec)
+	}))
+	got, err = q.GetStationRecords(ctx, "home")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, 45.0, got[0].Value)
+}
+
+// changingRemote changes the local row while its first copy is in flight
+type changingRemote struct {
+	fakeRemote
+	change func()
+}
+
+// changed makes the change once, as the first row is copied
+func (c *changingRemote) changed() {
+	if c.change != nil {
+		c.change()
+		c.change = nil
+	}
+}
+
+func (c *changingRemote) UpsertRecord(ctx context.Context, arg postgres.UpsertRecordParams) error {
+	c.changed()
+	return c.fakeRemote.UpsertRecord(ctx, arg)
+}
+
+func (c *changingRemote) UpsertDailySummary(ctx context.Context, arg postgres.UpsertDailySummaryParams) error {
+	c.changed()
+	return c.fakeRemote.UpsertDailySummary(ctx, arg)
+}
+
+func (c *changingRemote) UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error {
+	c.changed()
+	return c.fakeRemote.UpsertRainEvent(ctx, arg)
+}
+
+func TestSyncRecordChangedWhileCopying(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+	require.NoError(t, q.WriteRecord(ctx, record(t0, 10, 0)))
+	remote := &changingRemote{change: func() {
+		require.NoError(t, q.UpdateCalibratedRecord(ctx, postgres.UpdateCalibratedRecordParams{
+			StationID: "home", ObservedAt: t0, Temperature: valid(10.5), Pressure: valid(1010),
+		}))
+	}}
+	s := NewSyncer(q, remote, nil)
+
+	_, err := s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, valid(10), remote.records[0].Temperature)
+	// the change wasn't copied, so the row is still to sync
+	unsynced, err := q.GetUnsyncedRecords(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, unsynced, 1)
+
+	n, err := s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, valid(10.5), remote.records[1].Temperature)
+	unsynced, err = q.GetUnsyncedRecords(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, unsynced)
+}
+
+func TestSyncSummaryAndEventChangedWithinTheSecond(t *testing.T) {
+	q := openTest(t)
+	ctx := context.Background()
+	summary := postgres.UpsertDailySummaryParams{StationID: "home", Day: t0, PeriodStart: t0, PeriodEnd: t0.Add(24 * time.Hour), Samples: 1, RainTotal: 1}
+	require.NoError(t, q.UpsertDailySummary(ctx, summary))
+	// recomputed while it is copied, in the same second as before
+	remote := &changingRemote{change: func() {
+		summary.RainTotal = 2
+		require.NoError(t, q.UpsertDailySummary(ctx, summary))
+	}}
+	s := NewSyncer(q, remote, nil)
+
+	_, err := s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, remote.summaries[0].RainTotal)
+	unsynced, err := q.GetUnsyncedDailySummaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, unsynced, 1)
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, remote.summaries[1].RainTotal)
+	unsynced, err = q.GetUnsyncedDailySummaries(ctx)
+	require.NoError(t, err)
+	require.Empty(t, unsynced)
+
+	event := postgres.UpsertRainEventParams{StationID: "home", StartAt: t0, EndAt: t0, Ongoing: true, TotalMm: 0.2}
+	require.NoError(t, q.UpsertRainEvent(ctx, event))
+	remote.change = func() {
+		event.TotalMm = 0.4
+		require.NoError(t, q.UpsertRainEvent(ctx, event))
+	}
+
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0.2, remote.events[0].TotalMm)
+	events, err := q.GetUnsyncedRainEvents(ctx)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	_, err = s.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0.4, remote.events[1].TotalMm)
+	events, err = q.GetUnsyncedRainEvents(ctx)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
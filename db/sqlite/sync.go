@@ -0,0 +1,299 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Remote is the part of the postgres Querier the sync writes through
+type Remote interface {
+	UpsertRecord(ctx context.Context, arg postgres.UpsertRecordParams) error
+	UpsertDailySummary(ctx context.Context, arg postgres.UpsertDailySummaryParams) error
+	UpsertRainEvent(ctx context.Context, arg postgres.UpsertRainEventParams) error
+}
+
+// UnsyncedRecord is an observation to copy, with the version of the row it
+// was read at
+type UnsyncedRecord struct {
+	postgres.Weather
+	Version int64
+}
+
+// GetUnsyncedRecords returns up to limit observations not yet copied to
+// postgres, oldest first.
+func (q *Queries) GetUnsyncedRecords(ctx context.Context, limit int32) ([]UnsyncedRecord, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+weatherColumns+`, version FROM weather
+WHERE synced = 0
+ORDER BY observed_at
+LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UnsyncedRecord
+	for rows.Next() {
+		var i UnsyncedRecord
+		if err := rows.Scan(append(weatherFields(&i.Weather), &i.Version)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MarkRecordSynced only marks the row if it is still at the version that
+// was read, so a change made while it was being copied goes on the next sync.
+func (q *Queries) MarkRecordSynced(ctx context.Context, stationID string, observedAt time.Time, version int64) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE weather SET synced = 1 WHERE station_id = ? AND observed_at = ? AND version = ?`,
+		stationID, unix(observedAt), version)
+	return err
+}
+
+// UnsyncedDailySummary is a daily summary to copy, with the version of the
+// row it was read at
+type UnsyncedDailySummary struct {
+	postgres.DailySummary
+	Version int64
+}
+
+func (q *Queries) GetUnsyncedDailySummaries(ctx context.Context) ([]UnsyncedDailySummary, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+dailySummaryColumns+`, version FROM daily_summary
+WHERE synced = 0
+ORDER BY day`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UnsyncedDailySummary
+	for rows.Next() {
+		var i UnsyncedDailySummary
+		if err := rows.Scan(append(dailySummaryFields(&i.DailySummary), &i.Version)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MarkDailySummarySynced only marks the row if it is still at the version
+// that was read, so a recomputation while it was being copied goes on the
+// next sync.
+func (q *Queries) MarkDailySummarySynced(ctx context.Context, stationID string, day time.Time, version int64) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE daily_summary SET synced = 1 WHERE station_id = ? AND day = ? AND version = ?`,
+		stationID, unix(day), version)
+	return err
+}
+
+// UnsyncedRainEvent is a rain event to copy, with the version of the row it
+// was read at
+type UnsyncedRainEvent struct {
+	postgres.RainEvent
+	Version int64
+}
+
+func (q *Queries) GetUnsyncedRainEvents(ctx context.Context) ([]UnsyncedRainEvent, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+rainEventColumns+`, version FROM rain_events
+WHERE synced = 0
+ORDER BY start_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UnsyncedRainEvent
+	for rows.Next() {
+		var i UnsyncedRainEvent
+		if err := rows.Scan(append(rainEventFields(&i.RainEvent), &i.Version)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MarkRainEventSynced only marks the row if it is still at the version that
+// was read, so a change while it was being copied goes on the next sync.
+func (q *Queries) MarkRainEventSynced(ctx context.Context, stationID string, startAt time.Time, version int64) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE rain_events SET synced = 1 WHERE station_id = ? AND start_at = ? AND version = ?`,
+		stationID, unix(startAt), version)
+	return err
+}
+
+// Syncer copies observations, daily summaries and rain events from the local store to
+// postgres. Every write is an upsert on the primary key, so a row copied
+// twice after a failure part way through a batch does no harm.
+type Syncer struct {
+	local  *Queries
+	remote Remote
+	// prepare runs before the first sync that reaches postgres, to bring
+	// its schema up to date once the server is reachable.
+	prepare  func(ctx context.Context) error
+	prepared bool
+	synced   func(ctx context.Context, from time.Time, to time.Time) error
+}
+
+func NewSyncer(local *Queries, remote Remote, prepare func(ctx context.Context) error) *Syncer {
+	return &Syncer{local: local, remote: remote, prepare: prepare}
+}
+
+// OnSynced sets a function to call with the time range of the observations
+// each sync copied. Call it before Run.
+func (s *Syncer) OnSynced(f func(ctx context.Context, from time.Time, to time.Time) error) {
+	s.synced = f
+}
+
+// Run syncs every env.SyncInterval, forever
+func (s *Syncer) Run() {
+	logger.Info("Postgres sync started")
+	for {
+		n, err := s.Sync(context.Background())
+		if err != nil {
+			logger.Warnf("Postgres sync failed, will retry [%v]", err)
+		} else if n > 0 {
+			logger.Infof("Synced %d rows to postgres", n)
+		}
+		time.Sleep(env.SyncInterval)
+	}
+}
+
+// Sync copies everything outstanding and returns the number of rows copied
+func (s *Syncer) Sync(ctx context.Context) (int, error) {
+	if !s.prepared && s.prepare != nil {
+		if err := s.prepare(ctx); err != nil {
+			return 0, fmt.Errorf("failed to prepare postgres: %w", err)
+		}
+		s.prepared = true
+	}
+
+	count := 0
+	var from, to time.Time
+	for {
+		records, err := s.local.GetUnsyncedRecords(ctx, env.SyncBatchSize)
+		if err != nil {
+			return count, err
+		}
+		for _, r := range records {
+			if err := s.remote.UpsertRecord(ctx, postgres.UpsertRecordParams{
+				StationID:          r.StationID,
+				ObservedAt:         r.ObservedAt,
+				Temperature:        r.Temperature,
+				Pressure:           r.Pressure,
+				RainMm:             r.RainMm,
+				WindSpeed:          r.WindSpeed,
+				WindGust:           r.WindGust,
+				WindDirection:      r.WindDirection,
+				Humidity:           r.Humidity,
+				DewPoint:           r.DewPoint,
+				Mslp:               r.Mslp,
+				RainRate:           r.RainRate,
+				RainDay:            r.RainDay,
+				QcFlags:            r.QcFlags,
+				TemperatureSource:  r.TemperatureSource,
+				CalibrationVersion: r.CalibrationVersion,
+				RainRawMm:          r.RainRawMm,
+			}); err != nil {
+				return count, err
+			}
+			if err := s.local.MarkRecordSynced(ctx, r.StationID, r.ObservedAt, r.Version); err != nil {
+				return count, err
+			}
+			if count == 0 || r.ObservedAt.Before(from) {
+				from = r.ObservedAt
+			}
+			if r.ObservedAt.After(to) {
+				to = r.ObservedAt
+			}
+			count++
+		}
+		if len(records) < int(env.SyncBatchSize) {
+			break
+		}
+	}
+	if count > 0 && s.synced != nil {
+		if err := s.synced(ctx, from, to); err != nil {
+			return count, err
+		}
+	}
+
+	summaries, err := s.local.GetUnsyncedDailySummaries(ctx)
+	if err != nil {
+		return count, err
+	}
+	for _, d := range summaries {
+		if err := s.remote.UpsertDailySummary(ctx, postgres.UpsertDailySummaryParams{
+			StationID:        d.StationID,
+			Day:              d.Day,
+			PeriodStart:      d.PeriodStart,
+			PeriodEnd:        d.PeriodEnd,
+			Samples:          d.Samples,
+			TemperatureMax:   d.TemperatureMax,
+			TemperatureMaxAt: d.TemperatureMaxAt,
+			TemperatureMin:   d.TemperatureMin,
+			TemperatureMinAt: d.TemperatureMinAt,
+			TemperatureMean:  d.TemperatureMean,
+			RainTotal:        d.RainTotal,
+			RainRateMax:      d.RainRateMax,
+			WindGustMax:      d.WindGustMax,
+			WindGustDir:      d.WindGustDir,
+			WindGustAt:       d.WindGustAt,
+			WindSpeedMean:    d.WindSpeedMean,
+			PressureMin:      d.PressureMin,
+			PressureMax:      d.PressureMax,
+			WindDirDominant:  d.WindDirDominant,
+		}); err != nil {
+			return count, err
+		}
+		if err := s.local.MarkDailySummarySynced(ctx, d.StationID, d.Day, d.Version); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	events, err := s.local.GetUnsyncedRainEvents(ctx)
+	if err != nil {
+		return count, err
+	}
+	for _, e := range events {
+		if err := s.remote.UpsertRainEvent(ctx, postgres.UpsertRainEventParams{
+			StationID:  e.StationID,
+			StartAt:    e.StartAt,
+			EndAt:      e.EndAt,
+			Ongoing:    e.Ongoing,
+			TotalMm:    e.TotalMm,
+			DurationS:  e.DurationS,
+			Peak5min:   e.Peak5min,
+			Peak10min:  e.Peak10min,
+			Peak60min:  e.Peak60min,
+			DryBeforeS: e.DryBeforeS,
+		}); err != nil {
+			return count, err
+		}
+		if err := s.local.MarkRainEventSynced(ctx, e.StationID, e.StartAt, e.Version); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -46,6 +46,23 @@ const (
 	MmToInch      = 25.4
 	ReportFreqMin = 10
 
+	// River aOD is 16.61, river height at 4.1m is level with the road and I'm 3m above that
+	StationElevation = 24.71
+
+	// the climatological day runs 09:00 - 09:00 station time (Met Office convention)
+	ClimateDayStartHour = 9
+	// daily summaries are built a few minutes after the day ends so the 09:00
+	// record and the rain reset are in place first
+	SummaryDelay = time.Minute * 5
+	// a broken record is announced at most once in this period
+	RecordEventHoldOff = time.Hour
+	// the local store is copied to postgres this often, in batches
+	SyncInterval  = time.Minute
+	SyncBatchSize = int32(500)
+	// the timescale aggregate policies refresh this far back, so older
+	// synced rows have to be refreshed explicitly
+	AggregateRefreshWindow = time.Hour * 24 * 3
+
 	LEDFlashDuration = time.Millisecond * 50
 
 	MastHead uint16 = 0x55
@@ -56,4 +73,42 @@ const (
 	// it is sampled at high frequency (every 0.25 sec)
 	WindSamplesPerSecond    = 4
 	WindBufferLengthSeconds = 60
+	// more pulses than this in one sample, over 140 mph, is a garbled read
+	// from the masthead rather than wind
+	MaxPulsesPerSample = 25
+	// the slower sensors are read this often by the sampler
+	AtmosphereInterval = time.Second * 10
+
+	// an I2C device is degraded after this many errors in a row, and failed
+	// and reopened after SensorFailedAfter. If reopening it this many times
+	// doesn't help the whole bus is reopened.
+	SensorDegradedAfter = 3
+	SensorFailedAfter   = 10
+	SensorBusResetAfter = 3
+	// a reading the sensor hasn't refreshed for this long is dropped rather
+	// than repeated
+	SensorStale = AtmosphereInterval * 3
+	// the MCP9808 and BME280 temperatures are compared over this long
+	TemperatureDriftWindow = time.Minute * 10
+	// an edge on the rain pin must stay low for RainDenoise, and tips be
+	// RainDebounce apart, unless set with -rainDenoise and -rainDebounce
+	RainDenoise  = time.Millisecond * 100
+	RainDebounce = time.Millisecond * 500
+	// a tip is rejected if the IMU sees the mast shake this close to it
+	RainShakeWindow = time.Second
+	// edges on the rain pin queue this deep while earlier ones are decided
+	// on, and waiting for one is retried this often after a failure
+	RainEdgeQueue = 256
+	RainPinRetry  = time.Second
+
+	// the raw wind archive writes a batch this often and holds at most an
+	// hour of samples while its store is unavailable
+	WindArchiveFlush   = time.Second * 30
+	WindArchivePending = WindSamplesPerSecond * 60 * 60
+	WindArchivePrune   = time.Hour
+
+	// /api/v1/stream queues this many events for each client, and pings
+	// idle connections this often
+	StreamBuffer    = 64
+	StreamHeartbeat = time.Second * 15
 )
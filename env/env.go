@@ -1,5 +1,7 @@
 package env
 
+import "time"
+
 type Args struct {
 	Test               *bool
 	NoWow              *bool
@@ -11,4 +13,20 @@ type Args struct {
 	WindEnabled        *bool
 	AtmosphericEnabled *bool
 	RainEnabled        *bool
+	StationID          *string
+	TimeZone           *string
+	LocalDB            *string
+	Postgres           *bool
+	PgRetention        *time.Duration
+	WindArchive        *string
+	WindArchiveDir     *string
+	WindRetention      *time.Duration
+	QCConfig           *string
+	TempDelta          *float64
+	Calibration        *string
+	Vane               *string
+	RainDry            *time.Duration
+	RainDenoise        *time.Duration
+	RainDebounce       *time.Duration
+	RainShake          *float64
 }
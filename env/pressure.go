@@ -0,0 +1,29 @@
+package env
+
+import "math"
+
+// the hypsometric reduction to sea level the Met Office describes for WOW,
+// https://wow.metoffice.gov.uk/support/dataformats
+const (
+	Rd      = 287.1 // J/(kg K)
+	Gravity = 9.807 // m/s2
+	Kelvin  = 273.1
+)
+
+// scaleHeight is H = RdT/g, with the temperature converted to Kelvin by
+// adding 273.1 to the Celsius value
+func scaleHeight(tempC float64) float64 {
+	return (Rd * (tempC + Kelvin)) / Gravity
+}
+
+// SeaLevelPressure is psl = p0 exp(z0/H), where p0 is the pressure observed
+// at StationElevation. It is in the units of pressure.
+func SeaLevelPressure(pressure float64, tempC float64) float64 {
+	return pressure * math.Exp(StationElevation/scaleHeight(tempC))
+}
+
+// StationPressure reverses SeaLevelPressure, for sources that only kept the
+// sea level pressure
+func StationPressure(mslp float64, tempC float64) float64 {
+	return mslp * math.Exp(-StationElevation/scaleHeight(tempC))
+}
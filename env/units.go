@@ -0,0 +1,22 @@
+package env
+
+import "fmt"
+
+// WindUnitsPerMph is how much 1 mph is in each wind unit the station reads
+// and writes
+var WindUnitsPerMph = map[string]float64{
+	"mph":   1,
+	"kmh":   1.609344,
+	"ms":    0.44704,
+	"knots": 0.868976,
+}
+
+// WindUnit is how much 1 mph is in unit, an error if it is not one of
+// WindUnitsPerMph
+func WindUnit(unit string) (float64, error) {
+	perMph, ok := WindUnitsPerMph[unit]
+	if !ok {
+		return 0, fmt.Errorf("wind unit must be mph, kmh, ms or knots, not [%v]", unit)
+	}
+	return perMph, nil
+}
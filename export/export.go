@@ -0,0 +1,275 @@
+// Package export streams observations out of the archive as csv, JSON lines
+// or parquet, converted to the chosen units and with a few derived fields,
+// for loading into pandas or a spreadsheet.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+)
+
+// Resolutions are "raw", one row per stored observation, or a bucket size
+// understood by GetObservations.
+var Resolutions = map[string]bool{
+	"raw":    true,
+	"minute": true,
+	"hour":   true,
+	"day":    true,
+	"week":   true,
+	"month":  true,
+}
+
+// Formats maps each format to its content type
+var Formats = map[string]string{
+	"csv":     "text/csv",
+	"jsonl":   "application/x-ndjson",
+	"parquet": "application/vnd.apache.parquet",
+}
+
+type Query struct {
+	Station    string
+	From       time.Time
+	To         time.Time
+	Resolution string
+	Format     string
+	Units      Units
+}
+
+func (q Query) Validate() error {
+	if !Resolutions[q.Resolution] {
+		return fmt.Errorf("invalid resolution [%v]", q.Resolution)
+	}
+	if _, ok := Formats[q.Format]; !ok {
+		return fmt.Errorf("format must be csv, jsonl or parquet, not [%v]", q.Format)
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	return q.Units.Validate()
+}
+
+// row is one observation or bucket as stored, before unit conversion
+type row struct {
+	Time           time.Time
+	Samples        int64
+	Temperature    *float64
+	TemperatureMin *float64
+	TemperatureMax *float64
+	Pressure       *float64
+	Mslp           *float64
+	Humidity       *float64
+	DewPoint       *float64
+	Rain           float64
+	RainRateMax    *float64
+	WindSpeed      *float64
+	WindGust       *float64
+	WindDirection  *float64
+}
+
+// pageSize rows are read at a time so an export of any length streams
+const pageSize = 10000
+
+// rawChunk is how much of the raw archive is read at a time
+const rawChunk = time.Hour * 24 * 7
+
+// rows calls f with every row in the query's range, oldest first
+func rows(ctx context.Context, db postgres.Querier, q Query, f func(row) error) error {
+	if q.Resolution == "raw" {
+		for from := q.From; from.Before(q.To); from = from.Add(rawChunk) {
+			to := from.Add(rawChunk)
+			if to.After(q.To) {
+				to = q.To
+			}
+			records, err := db.GetRecordsBetween(ctx, postgres.GetRecordsBetweenParams{StationID: q.Station, FromTime: from, ToTime: to})
+			if err != nil {
+				return err
+			}
+			for _, r := range records {
+				// flagged values are left out, as they are from the buckets
+				r = r.Checked()
+				if err := f(row{
+					Time:           r.ObservedAt,
+					Samples:        1,
+					Temperature:    nullable(r.Temperature),
+					TemperatureMin: nullable(r.Temperature),
+					TemperatureMax: nullable(r.Temperature),
+					Pressure:       nullable(r.Pressure),
+					Mslp:           nullable(r.Mslp),
+					Humidity:       nullable(r.Humidity),
+					DewPoint:       nullable(r.DewPoint),
+					Rain:           r.RainMm,
+					RainRateMax:    nullable(r.RainRate),
+					WindSpeed:      nullable(r.WindSpeed),
+					WindGust:       nullable(r.WindGust),
+					WindDirection:  nullable(r.WindDirection),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for offset := int32(0); ; offset += pageSize {
+		obs, err := db.GetObservations(ctx, postgres.GetObservationsParams{
+			Resolution: q.Resolution,
+			StationID:  q.Station,
+			FromTime:   q.From,
+			ToTime:     q.To,
+			RowLimit:   pageSize,
+			RowOffset:  offset,
+		})
+		if err != nil {
+			return err
+		}
+		for _, o := range obs {
+			if err := f(row{
+				Time:           o.Bucket,
+				Samples:        o.Samples,
+				Temperature:    nullable(o.Temperature),
+				TemperatureMin: nullable(o.TemperatureMin),
+				TemperatureMax: nullable(o.TemperatureMax),
+				Pressure:       nullable(o.Pressure),
+				Mslp:           nullable(o.Mslp),
+				Humidity:       nullable(o.Humidity),
+				DewPoint:       nullable(o.DewPoint),
+				Rain:           o.RainMm,
+				RainRateMax:    nullable(o.RainRateMax),
+				WindSpeed:      nullable(o.WindSpeed),
+				WindGust:       nullable(o.WindGust),
+				WindDirection:  nullable(o.WindDirection),
+			}); err != nil {
+				return err
+			}
+		}
+		if len(obs) < pageSize {
+			return nil
+		}
+	}
+}
+
+func nullable(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// Write streams the query's rows to w in the query's format
+func Write(ctx context.Context, db postgres.Querier, q Query, w io.Writer) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	cols := columns(q.Units)
+	var out writer
+	switch q.Format {
+	case "csv":
+		out = newCSVWriter(w, cols)
+	case "jsonl":
+		out = newJSONLWriter(w, cols)
+	case "parquet":
+		out = newParquetWriter(w, cols)
+	}
+	err := rows(ctx, db, q, func(r row) error {
+		return out.write(values(cols, r))
+	})
+	if err != nil {
+		return err
+	}
+	return out.close()
+}
+
+type writer interface {
+	write(values []any) error
+	close() error
+}
+
+type kind int
+
+const (
+	kindTime kind = iota
+	kindInt
+	kindFloat
+	kindString
+)
+
+// column is one exported field. value returns nil when there is no value.
+type column struct {
+	name  string
+	kind  kind
+	value func(r row) any
+}
+
+func columns(u Units) []column {
+	temp, pres, wind, rain := u.Converters()
+	opt := func(v *float64, conv func(float64) float64) any {
+		if v == nil {
+			return nil
+		}
+		return conv(*v)
+	}
+	return []column{
+		{"time", kindTime, func(r row) any { return r.Time.UTC() }},
+		{"samples", kindInt, func(r row) any { return r.Samples }},
+		{"temperature_" + u.Temperature, kindFloat, func(r row) any { return opt(r.Temperature, temp) }},
+		{"temperature_min_" + u.Temperature, kindFloat, func(r row) any { return opt(r.TemperatureMin, temp) }},
+		{"temperature_max_" + u.Temperature, kindFloat, func(r row) any { return opt(r.TemperatureMax, temp) }},
+		{"dew_point_" + u.Temperature, kindFloat, func(r row) any {
+			if r.DewPoint == nil && r.Temperature != nil && r.Humidity != nil && *r.Humidity > 0 {
+				return temp(dewPoint(*r.Temperature, *r.Humidity))
+			}
+			return opt(r.DewPoint, temp)
+		}},
+		{"feels_like_" + u.Temperature, kindFloat, func(r row) any {
+			if r.Temperature == nil {
+				return nil
+			}
+			return temp(feelsLike(*r.Temperature, r.WindSpeed, r.Humidity))
+		}},
+		{"humidity_RH", kindFloat, func(r row) any { return opt(r.Humidity, func(v float64) float64 { return v }) }},
+		{"pressure_" + u.Pressure, kindFloat, func(r row) any { return opt(r.Pressure, pres) }},
+		{"mslp_" + u.Pressure, kindFloat, func(r row) any { return opt(r.Mslp, pres) }},
+		{"rain_" + u.Rain, kindFloat, func(r row) any { return rain(r.Rain) }},
+		{"rain_rate_max_" + u.Rain + "_hr", kindFloat, func(r row) any { return opt(r.RainRateMax, rain) }},
+		{"wind_speed_" + u.Wind, kindFloat, func(r row) any { return opt(r.WindSpeed, wind) }},
+		{"wind_gust_" + u.Wind, kindFloat, func(r row) any { return opt(r.WindGust, wind) }},
+		{"wind_dir", kindFloat, func(r row) any { return opt(r.WindDirection, func(v float64) float64 { return v }) }},
+		{"wind_compass", kindString, func(r row) any {
+			if r.WindDirection == nil {
+				return nil
+			}
+			return compass(*r.WindDirection)
+		}},
+		{"beaufort", kindInt, func(r row) any {
+			if r.WindSpeed == nil {
+				return nil
+			}
+			return beaufort(*r.WindSpeed)
+		}},
+	}
+}
+
+func values(cols []column, r row) []any {
+	v := make([]any, len(cols))
+	for i, c := range cols {
+		v[i] = c.value(r)
+	}
+	return v
+}
+
+// sortedIndex returns the column positions in name order, which is the
+// order parquet keeps a group's fields in.
+func sortedIndex(cols []column) []int {
+	idx := make([]int, len(cols))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return cols[idx[a]].name < cols[idx[b]].name })
+	return idx
+}
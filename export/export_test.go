@@ -0,0 +1,119 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeQuerier struct {
+	postgres.Querier
+	records []postgres.Weather
+}
+
+func (f *fakeQuerier) GetRecordsBetween(ctx context.Context, arg postgres.GetRecordsBetweenParams) ([]postgres.Weather, error) {
+	var out []postgres.Weather
+	for _, r := range f.records {
+		if r.ObservedAt.After(arg.FromTime) && !r.ObservedAt.After(arg.ToTime) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+var t0 = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func testQuery(format string) Query {
+	return Query{
+		Station:    "home",
+		From:       t0.Add(-time.Hour),
+		To:         t0.Add(30 * 24 * time.Hour),
+		Resolution: "raw",
+		Format:     format,
+		Units:      Units{Temperature: "F", Pressure: "hPa", Wind: "kmh", Rain: "mm"},
+	}
+}
+
+func valid(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: true}
+}
+
+func testDB() *fakeQuerier {
+	return &fakeQuerier{records: []postgres.Weather{
+		{ObservedAt: t0, Temperature: valid(0), Pressure: valid(1000), WindSpeed: valid(10), WindDirection: valid(350), Humidity: valid(50)},
+		// in the next raw chunk, with the anemometer unread
+		{ObservedAt: t0.Add(8 * 24 * time.Hour), Temperature: valid(10), Pressure: valid(1010), RainMm: 1.2, WindDirection: valid(90)},
+	}}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), testDB(), testQuery("csv"), &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[0], "time,samples,temperature_F,"))
+	require.Contains(t, lines[0], ",wind_speed_kmh,")
+	require.True(t, strings.HasPrefix(lines[1], "2026-01-10T12:00:00Z,1,32,32,32,"))
+	require.True(t, strings.HasSuffix(lines[1], ",N,3"))
+	// no humidity so no dew point
+	require.Contains(t, lines[2], ",50,50,,")
+}
+
+func TestJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), testDB(), testQuery("jsonl"), &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var first map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.InDelta(t, 16.09, first["wind_speed_kmh"], 0.01)
+	require.Nil(t, first["mslp_hPa"])
+	// Magnus dew point at 0C and 50%
+	require.InDelta(t, (-9.19)*9/5+32, first["dew_point_F"], 0.05)
+	require.True(t, strings.HasPrefix(lines[0], `{"time":"2026-01-10T12:00:00Z","samples":1,`))
+}
+
+func TestParquet(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(context.Background(), testDB(), testQuery("parquet"), &buf))
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), f.NumRows())
+
+	type out struct {
+		Time        int64    `parquet:"time"`
+		Temperature *float64 `parquet:"temperature_F,optional"`
+		Mslp        *float64 `parquet:"mslp_hPa,optional"`
+		Compass     *string  `parquet:"wind_compass,optional"`
+		Beaufort    *int64   `parquet:"beaufort,optional"`
+	}
+	rows, err := parquet.Read[out](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, t0.UnixMilli(), rows[0].Time)
+	require.Equal(t, 32.0, *rows[0].Temperature)
+	require.Nil(t, rows[0].Mslp)
+	require.Equal(t, "E", *rows[1].Compass)
+	require.Equal(t, int64(3), *rows[0].Beaufort)
+	require.Nil(t, rows[1].Beaufort)
+}
+
+func TestValidate(t *testing.T) {
+	q := testQuery("xlsx")
+	require.Error(t, q.Validate())
+	q = testQuery("csv")
+	q.Units.Wind = "furlongs"
+	require.Error(t, q.Validate())
+	q = testQuery("csv")
+	q.Resolution = "second"
+	require.Error(t, q.Validate())
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+type csvWriter struct {
+	w      *csv.Writer
+	cols   []column
+	header bool
+}
+
+func newCSVWriter(w io.Writer, cols []column) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w), cols: cols}
+}
+
+func (c *csvWriter) writeHeader() error {
+	names := make([]string, len(c.cols))
+	for i, col := range c.cols {
+		names[i] = col.name
+	}
+	c.header = true
+	return c.w.Write(names)
+}
+
+func (c *csvWriter) write(values []any) error {
+	if !c.header {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			// empty
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			record[i] = v
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) close() error {
+	if !c.header {
+		// an empty export still gets its header
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	w    *bufio.Writer
+	cols []column
+}
+
+func newJSONLWriter(w io.Writer, cols []column) *jsonlWriter {
+	return &jsonlWriter{w: bufio.NewWriter(w), cols: cols}
+}
+
+// write builds the object by hand so the keys keep the column order
+func (j *jsonlWriter) write(values []any) error {
+	_ = j.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			_ = j.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(j.cols[i].name)
+		_, _ = j.w.Write(key)
+		_ = j.w.WriteByte(':')
+		js, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, _ = j.w.Write(js)
+	}
+	_, err := j.w.WriteString("}\n")
+	return err
+}
+
+func (j *jsonlWriter) close() error {
+	return j.w.Flush()
+}
+
+// parquet rows are buffered into row groups by the writer; parquetBatch
+// rows are handed over at a time.
+const parquetBatch = 1000
+
+type parquetWriter struct {
+	w     *parquet.Writer
+	cols  []column
+	order []int
+	rows  []parquet.Row
+}
+
+func newParquetWriter(w io.Writer, cols []column) *parquetWriter {
+	group := parquet.Group{}
+	for _, c := range cols {
+		var node parquet.Node
+		switch c.kind {
+		case kindTime:
+			node = parquet.Timestamp(parquet.Millisecond)
+		case kindInt:
+			node = parquet.Optional(parquet.Int(64))
+		case kindFloat:
+			node = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		case kindString:
+			node = parquet.Optional(parquet.String())
+		}
+		group[c.name] = node
+	}
+	schema := parquet.NewSchema("weather", group)
+	return &parquetWriter{
+		w:     parquet.NewWriter(w, schema, parquet.Compression(&zstd.Codec{})),
+		cols:  cols,
+		order: sortedIndex(cols),
+	}
+}
+
+func (p *parquetWriter) write(values []any) error {
+	row := make(parquet.Row, len(values))
+	for columnIndex, i := range p.order {
+		var v parquet.Value
+		switch x := values[i].(type) {
+		case nil:
+			v = parquet.NullValue().Level(0, 0, columnIndex)
+		case time.Time:
+			v = parquet.Int64Value(x.UnixMilli()).Level(0, 0, columnIndex)
+		case float64:
+			// every column but the time is optional
+			v = parquet.DoubleValue(x).Level(0, 1, columnIndex)
+		case int64:
+			v = parquet.Int64Value(x).Level(0, 1, columnIndex)
+		case string:
+			v = parquet.ByteArrayValue([]byte(x)).Level(0, 1, columnIndex)
+		}
+		row[columnIndex] = v
+	}
+	p.rows = append(p.rows, row)
+	if len(p.rows) >= parquetBatch {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetWriter) flush() error {
+	if _, err := p.w.WriteRows(p.rows); err != nil {
+		return err
+	}
+	p.rows = p.rows[:0]
+	return nil
+}
+
+func (p *parquetWriter) close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.w.Close()
+}
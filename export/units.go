@@ -0,0 +1,104 @@
+package export
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pointer2null/weather/env"
+)
+
+// Units selects how exported values are expressed. The database holds
+// Celsius, hPa, mm and mph.
+type Units struct {
+	Temperature string
+	Pressure    string
+	Wind        string
+	Rain        string
+}
+
+var DefaultUnits = Units{Temperature: "C", Pressure: "hPa", Wind: "mph", Rain: "mm"}
+
+var (
+	temperatureUnits = map[string]func(float64) float64{
+		"C": func(c float64) float64 { return c },
+		"F": func(c float64) float64 { return c*9/5 + 32 },
+	}
+	pressureUnits = map[string]func(float64) float64{
+		"hPa":  func(p float64) float64 { return p },
+		"inHg": func(p float64) float64 { return p * env.HPaToInHg },
+	}
+	rainUnits = map[string]func(float64) float64{
+		"mm": func(v float64) float64 { return v },
+		"in": func(v float64) float64 { return v / env.MmToInch },
+	}
+)
+
+// Validate checks every unit is one we can convert to
+func (u Units) Validate() error {
+	if temperatureUnits[u.Temperature] == nil {
+		return fmt.Errorf("temperature unit must be C or F, not [%v]", u.Temperature)
+	}
+	if pressureUnits[u.Pressure] == nil {
+		return fmt.Errorf("pressure unit must be hPa or inHg, not [%v]", u.Pressure)
+	}
+	if _, err := env.WindUnit(u.Wind); err != nil {
+		return err
+	}
+	if rainUnits[u.Rain] == nil {
+		return fmt.Errorf("rain unit must be mm or in, not [%v]", u.Rain)
+	}
+	return nil
+}
+
+// Converters returns the functions taking stored temperature, pressure, wind
+// and rain values to u. Validate u first.
+func (u Units) Converters() (temp, pressure, wind, rain func(float64) float64) {
+	perMph, _ := env.WindUnit(u.Wind)
+	wind = func(v float64) float64 { return v * perMph }
+	return temperatureUnits[u.Temperature], pressureUnits[u.Pressure], wind, rainUnits[u.Rain]
+}
+
+var compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+func compass(deg float64) string {
+	return compassPoints[int(math.Round(math.Mod(deg+360, 360)/22.5))%16]
+}
+
+// upper bound in mph of each beaufort force below 12
+var beaufortMph = []float64{1, 4, 8, 13, 19, 25, 32, 39, 47, 55, 64, 73}
+
+func beaufort(mph float64) int64 {
+	for force, limit := range beaufortMph {
+		if mph < limit {
+			return int64(force)
+		}
+	}
+	return 12
+}
+
+// dewPoint uses the Magnus formula
+func dewPoint(tempC float64, rh float64) float64 {
+	const b, c = 17.62, 243.12
+	gamma := math.Log(rh/100) + b*tempC/(c+tempC)
+	return c * gamma / (b - gamma)
+}
+
+// feelsLike is the wind chill in the cold, the heat index in humid heat and
+// the air temperature otherwise.
+func feelsLike(tempC float64, windMph *float64, rh *float64) float64 {
+	if tempC <= 10 && windMph != nil {
+		if kmh := *windMph * env.WindUnitsPerMph["kmh"]; kmh > 4.8 {
+			v := math.Pow(kmh, 0.16)
+			return 13.12 + 0.6215*tempC - 11.37*v + 0.3965*tempC*v
+		}
+	}
+	if tempC >= 27 && rh != nil && *rh >= 40 {
+		// Rothfusz regression, in Fahrenheit
+		t := tempC*9/5 + 32
+		r := *rh
+		hi := -42.379 + 2.04901523*t + 10.14333127*r - 0.22475541*t*r - 0.00683783*t*t -
+			0.05481717*r*r + 0.00122874*t*t*r + 0.00085282*t*r*r - 0.00000199*t*t*r*r
+		return (hi - 32) * 5 / 9
+	}
+	return tempC
+}
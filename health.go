@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pointer2null/weather/sensors"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/sirupsen/logrus"
+)
+
+// sensor health, from the sampler's snapshots
+
+type healthData struct {
+	Time    time.Time              `json:"time"`
+	Status  sensors.State          `json:"status"`
+	Devices []sensors.DeviceHealth `json:"devices"`
+}
+
+// health serves the health of each I2C device, with a 503 if any has failed
+// so a plain http check can alert on it
+func (w *weatherstation) health(rw http.ResponseWriter, r *http.Request) {
+	snap := w.sampler.Latest()
+	hd := healthData{Time: snap.Time, Status: sensors.Worst(snap.Health), Devices: snap.Health}
+	if hd.Devices == nil {
+		hd.Devices = []sensors.DeviceHealth{}
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if hd.Status == sensors.Failed {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(rw).Encode(hd); err != nil {
+		logger.Errorf("Failed to write health [%v]", err)
+	}
+}
+
+var (
+	healthStateDesc = prometheus.NewDesc("sensor_health",
+		"Sensor health: 0 ok, 1 degraded, 2 failed", []string{"device"}, nil)
+	healthErrorsDesc = prometheus.NewDesc("sensor_errors_total",
+		"Failed sensor reads", []string{"device"}, nil)
+	healthReopensDesc = prometheus.NewDesc("sensor_reopens_total",
+		"Times a sensor, or the i2c bus, was reopened after failing", []string{"device"}, nil)
+)
+
+// healthCollector reports the health in the latest snapshot, so the
+// devices are only ever touched by the sampler
+type healthCollector struct {
+	sampler *sensors.Sampler
+}
+
+func (c healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- healthStateDesc
+	ch <- healthErrorsDesc
+	ch <- healthReopensDesc
+}
+
+func (c healthCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, d := range c.sampler.Latest().Health {
+		ch <- prometheus.MustNewConstMetric(healthStateDesc, prometheus.GaugeValue, float64(d.State), d.Device)
+		ch <- prometheus.MustNewConstMetric(healthErrorsDesc, prometheus.CounterValue, float64(d.Errors), d.Device)
+		ch <- prometheus.MustNewConstMetric(healthReopensDesc, prometheus.CounterValue, float64(d.Reopens), d.Device)
+	}
+}
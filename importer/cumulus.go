@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+)
+
+// Cumulus monthly log fields, from the Cumulus 1 and MX documentation
+const (
+	cumulusDate = iota
+	cumulusTime
+	cumulusTemperature
+	cumulusHumidity
+	cumulusDewPoint
+	cumulusWindSpeed // average over the logging interval
+	cumulusWindGust  // highest in the last ten minutes
+	cumulusWindBearing
+	cumulusRainRate
+	cumulusRainToday
+	cumulusPressure // sea level
+	cumulusFields
+)
+
+// CumulusUnits are the Cumulus defaults
+var CumulusUnits = Units{Temperature: "C", Pressure: "hPa", Wind: "mph", Rain: "mm"}
+
+// Cumulus reads Cumulus monthly log files (e.g. Oct26log.txt). Each line
+// is one logging interval in the station's local time, in the units
+// Cumulus was set up with. Rain today resets at Cumulus's rollover, which
+// need not match ours, so the rain in each interval comes from the change
+// in that total. Files should be read oldest first.
+type Cumulus struct {
+	Units    Units
+	Location *time.Location
+	rain     accumulation
+}
+
+func (c *Cumulus) Read(ctx context.Context, name string, r io.Reader, im *Importer) error {
+	if err := c.Units.Validate(); err != nil {
+		return err
+	}
+	im.file("cumulus", c.Units.String())
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		rec, err := c.parse(text)
+		if err != nil {
+			im.Skip(fmt.Sprintf("%v:%d", name, line), err.Error())
+			continue
+		}
+		if err := im.Add(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Cumulus) parse(line string) (postgres.WriteRecordParams, error) {
+	var rec postgres.WriteRecordParams
+	// locales with a decimal comma separate fields with semicolons
+	fields := strings.Split(line, ",")
+	if strings.Contains(line, ";") {
+		fields = strings.Split(strings.ReplaceAll(line, ",", "."), ";")
+	}
+	if len(fields) < cumulusFields {
+		return rec, fmt.Errorf("only %d fields", len(fields))
+	}
+
+	t, err := cumulusTimestamp(fields[cumulusDate], fields[cumulusTime], c.Location)
+	if err != nil {
+		return rec, err
+	}
+	rec.ObservedAt = t
+
+	values := make([]float64, cumulusFields)
+	for i := cumulusTemperature; i < cumulusFields; i++ {
+		v, err := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+		if err != nil {
+			return rec, fmt.Errorf("invalid field %d [%v]", i+1, fields[i])
+		}
+		values[i] = v
+	}
+
+	temp := temperatureUnits[c.Units.Temperature]
+	wind := windUnits[c.Units.Wind]
+	rain := rainUnits[c.Units.Rain]
+	mslp := pressureUnits[c.Units.Pressure](values[cumulusPressure])
+
+	rec.Temperature = sql.NullFloat64{Float64: temp(values[cumulusTemperature]), Valid: true}
+	rec.Humidity = sql.NullFloat64{Float64: values[cumulusHumidity], Valid: true}
+	rec.DewPoint = sql.NullFloat64{Float64: temp(values[cumulusDewPoint]), Valid: true}
+	rec.WindSpeed = sql.NullFloat64{Float64: wind(values[cumulusWindSpeed]), Valid: true}
+	rec.WindGust = sql.NullFloat64{Float64: wind(values[cumulusWindGust]), Valid: true}
+	rec.WindDirection = sql.NullFloat64{Float64: values[cumulusWindBearing], Valid: true}
+	rec.RainRate = sql.NullFloat64{Float64: rain(values[cumulusRainRate]), Valid: true}
+	rec.RainDay = sql.NullFloat64{Float64: rain(values[cumulusRainToday]), Valid: true}
+	rec.RainMm = c.rain.next(rec.RainDay.Float64)
+	rec.Mslp = sql.NullFloat64{Float64: mslp, Valid: true}
+	rec.Pressure = sql.NullFloat64{Float64: env.StationPressure(mslp, rec.Temperature.Float64), Valid: true}
+	return rec, nil
+}
+
+// cumulusTimestamp reads a dd/mm/yy date, whose separator follows the
+// locale, or the yyyy-mm-dd that later versions of MX write, and an hh:mm
+// time
+func cumulusTimestamp(date string, clock string, loc *time.Location) (time.Time, error) {
+	parts := strings.FieldsFunc(strings.TrimSpace(date), func(r rune) bool { return r == '/' || r == '-' || r == '.' })
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date [%v]", date)
+	}
+	var dmy [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date [%v]", date)
+		}
+		dmy[i] = v
+	}
+	if len(parts[0]) == 4 {
+		dmy[0], dmy[2] = dmy[2], dmy[0]
+	}
+	hm, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time [%v]", clock)
+	}
+	if dmy[1] < 1 || dmy[1] > 12 || dmy[0] < 1 || dmy[0] > 31 {
+		return time.Time{}, fmt.Errorf("invalid date [%v]", date)
+	}
+	return time.Date(2000+dmy[2]%100, time.Month(dmy[1]), dmy[0], hm.Hour(), hm.Minute(), 0, 0, loc), nil
+}
+
+var cumulusMonths = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// CumulusMonth reads the month from a log file name such as Oct26log.txt,
+// so files can be read in order
+func CumulusMonth(name string) (time.Time, bool) {
+	base := strings.ToLower(name[strings.LastIndexAny(name, `/\`)+1:])
+	if len(base) < 5 || !strings.HasPrefix(base[5:], "log") {
+		return time.Time{}, false
+	}
+	m, ok := cumulusMonths[base[:3]]
+	if !ok {
+		return time.Time{}, false
+	}
+	y, err := strconv.Atoi(base[3:5])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(2000+y, m, 1, 0, 0, 0, 0, time.UTC), true
+}
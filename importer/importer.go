@@ -0,0 +1,217 @@
+// Package importer loads history recorded by other weather software - Cumulus
+// monthly logs, a WeeWX archive database and WOW csv downloads - into the
+// weather table, converted to the station's units. Observations at a time
+// already in the table are left alone.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+)
+
+// Units describes how a source records its values. The database holds
+// Celsius, hPa, mph and mm.
+type Units struct {
+	Temperature string
+	Pressure    string
+	Wind        string
+	Rain        string
+}
+
+var (
+	temperatureUnits = map[string]func(float64) float64{
+		"C": func(v float64) float64 { return v },
+		"F": func(v float64) float64 { return (v - 32) * 5 / 9 },
+	}
+	pressureUnits = map[string]func(float64) float64{
+		"hPa":  func(v float64) float64 { return v },
+		"mb":   func(v float64) float64 { return v },
+		"inHg": func(v float64) float64 { return v / env.HPaToInHg },
+	}
+	windUnits = windToMph()
+	rainUnits = map[string]func(float64) float64{
+		"mm": func(v float64) float64 { return v },
+		"cm": func(v float64) float64 { return v * 10 },
+		"in": func(v float64) float64 { return v * env.MmToInch },
+	}
+)
+
+// windToMph converts from each of env.WindUnitsPerMph
+func windToMph() map[string]func(float64) float64 {
+	units := make(map[string]func(float64) float64, len(env.WindUnitsPerMph))
+	for unit, perMph := range env.WindUnitsPerMph {
+		perMph := perMph
+		units[unit] = func(v float64) float64 { return v / perMph }
+	}
+	return units
+}
+
+// Validate checks every unit is one we can convert from
+func (u Units) Validate() error {
+	if temperatureUnits[u.Temperature] == nil {
+		return fmt.Errorf("temperature unit must be C or F, not [%v]", u.Temperature)
+	}
+	if pressureUnits[u.Pressure] == nil {
+		return fmt.Errorf("pressure unit must be hPa, mb or inHg, not [%v]", u.Pressure)
+	}
+	if _, err := env.WindUnit(u.Wind); err != nil {
+		return err
+	}
+	if rainUnits[u.Rain] == nil {
+		return fmt.Errorf("rain unit must be mm, cm or in, not [%v]", u.Rain)
+	}
+	return nil
+}
+
+func (u Units) String() string {
+	return fmt.Sprintf("%v, %v, %v, %v", u.Temperature, u.Pressure, u.Wind, u.Rain)
+}
+
+// accumulation turns a running daily rain total into the rain since the
+// previous observation. A total that drops has been reset, so all of it fell
+// since the reset. The first total seen counts as no rain, as there is
+// nothing to compare it with.
+type accumulation struct {
+	last float64
+	ok   bool
+}
+
+func (a *accumulation) next(total float64) float64 {
+	defer func() { a.last, a.ok = total, true }()
+	switch {
+	case !a.ok:
+		return 0
+	case total < a.last:
+		return total
+	default:
+		return total - a.last
+	}
+}
+
+// maxProblems is how many unreadable rows are described in a report
+const maxProblems = 20
+
+// Report counts what an import found
+type Report struct {
+	Format     string
+	Units      string
+	Files      int
+	Rows       int
+	New        int
+	Duplicates int
+	Skipped    int
+	Problems   []string
+	// First and Last bound the new observations
+	First time.Time
+	Last  time.Time
+}
+
+func (r Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%v import: %d files, %d rows", r.Format, r.Files, r.Rows)
+	if r.Units != "" {
+		fmt.Fprintf(b, " in %v", r.Units)
+	}
+	fmt.Fprintf(b, "\n  new:        %d", r.New)
+	if r.New > 0 {
+		fmt.Fprintf(b, " (%v to %v)", r.First.UTC().Format(time.RFC3339), r.Last.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(b, "\n  duplicates: %d\n  skipped:    %d\n", r.Duplicates, r.Skipped)
+	for _, p := range r.Problems {
+		fmt.Fprintf(b, "    %v\n", p)
+	}
+	if r.Skipped > len(r.Problems) {
+		fmt.Fprintf(b, "    ... and %d more\n", r.Skipped-len(r.Problems))
+	}
+	return b.String()
+}
+
+// Importer writes observations for one station, skipping any time already
+// in the table or already seen in this import. A dry run writes nothing but
+// reports the same counts.
+type Importer struct {
+	db      postgres.Querier
+	station string
+	dryRun  bool
+	report  Report
+	// times by UTC day, loaded from the table when a day is first seen
+	seen map[time.Time]map[int64]bool
+}
+
+func New(db postgres.Querier, station string, dryRun bool) *Importer {
+	return &Importer{
+		db:      db,
+		station: station,
+		dryRun:  dryRun,
+		seen:    make(map[time.Time]map[int64]bool),
+	}
+}
+
+func (im *Importer) Report() Report {
+	return im.report
+}
+
+// Add writes an observation unless its time is already taken
+func (im *Importer) Add(ctx context.Context, rec postgres.WriteRecordParams) error {
+	im.report.Rows++
+	rec.StationID = im.station
+	rec.ObservedAt = rec.ObservedAt.UTC().Truncate(time.Second)
+
+	day := rec.ObservedAt.Truncate(24 * time.Hour)
+	times, ok := im.seen[day]
+	if !ok {
+		existing, err := im.db.GetRecordsBetween(ctx, postgres.GetRecordsBetweenParams{
+			StationID: im.station,
+			FromTime:  day.Add(-time.Second),
+			ToTime:    day.Add(24*time.Hour - time.Second),
+		})
+		if err != nil {
+			return err
+		}
+		times = make(map[int64]bool, len(existing))
+		for _, e := range existing {
+			times[e.ObservedAt.Unix()] = true
+		}
+		im.seen[day] = times
+	}
+	if times[rec.ObservedAt.Unix()] {
+		im.report.Duplicates++
+		return nil
+	}
+	times[rec.ObservedAt.Unix()] = true
+
+	if !im.dryRun {
+		if err := im.db.WriteRecord(ctx, rec); err != nil {
+			return err
+		}
+	}
+	im.report.New++
+	if im.report.First.IsZero() || rec.ObservedAt.Before(im.report.First) {
+		im.report.First = rec.ObservedAt
+	}
+	if rec.ObservedAt.After(im.report.Last) {
+		im.report.Last = rec.ObservedAt
+	}
+	return nil
+}
+
+// Skip counts a row that could not be read; where is usually file:line
+func (im *Importer) Skip(where string, reason string) {
+	im.report.Rows++
+	im.report.Skipped++
+	if len(im.report.Problems) < maxProblems {
+		im.report.Problems = append(im.report.Problems, fmt.Sprintf("%v: %v", where, reason))
+	}
+}
+
+// file is called once for every file read, with the units it was read in
+func (im *Importer) file(format string, units string) {
+	im.report.Format = format
+	im.report.Units = units
+	im.report.Files++
+}
@@ -0,0 +1,174 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeQuerier struct {
+	postgres.Querier
+	existing []postgres.Weather
+	written  []postgres.WriteRecordParams
+}
+
+func (f *fakeQuerier) GetRecordsBetween(ctx context.Context, arg postgres.GetRecordsBetweenParams) ([]postgres.Weather, error) {
+	var out []postgres.Weather
+	for _, r := range f.existing {
+		if r.ObservedAt.After(arg.FromTime) && !r.ObservedAt.After(arg.ToTime) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeQuerier) WriteRecord(ctx context.Context, arg postgres.WriteRecordParams) error {
+	f.written = append(f.written, arg)
+	return nil
+}
+
+func TestCumulus(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	// 00:10 on the 1st is already in the table
+	db := &fakeQuerier{existing: []postgres.Weather{{ObservedAt: time.Date(2025, 9, 30, 23, 10, 0, 0, time.UTC)}}}
+	im := New(db, "home", false)
+	c := &Cumulus{Units: Units{Temperature: "F", Pressure: "inHg", Wind: "kmh", Rain: "in"}, Location: london}
+
+	log := strings.Join([]string{
+		"01/10/25,00:05,50.0,87,46.4,16.1,32.2,225,0.0,0.10,30.00,12.3,68.0,55,20.0,50.0,50.0",
+		"01/10/25,00:10,50.0,87,46.4,16.1,32.2,225,0.0,0.10,30.00,12.3,68.0,55,20.0,50.0,50.0",
+		"01/10/25,00:15,51.8,88,46.4,0.0,8.0,180,0.5,0.30,29.90,12.5,68.0,55,20.0,50.0,50.0",
+		"01/10/25,00:20,not a number,88,46.4,0.0,8.0,180,0.5,0.30,29.90,12.5,68.0,55,20.0,50.0,50.0",
+		"",
+		// rolled over: all 0.05in since the reset is new
+		"01.10.25;09:05;51,8;88;46,4;0,0;8,0;180;0,5;0,05;29,90;12,5",
+		"01/10/25,00:15,51.8,88,46.4,0.0,8.0,180,0.5,0.30,29.90,12.5,68.0,55,20.0,50.0,50.0",
+		"01/10/25,00:25",
+	}, "\n")
+	require.NoError(t, c.Read(context.Background(), "Oct25log.txt", strings.NewReader(log), im))
+
+	r := im.Report()
+	require.Equal(t, 7, r.Rows)
+	require.Equal(t, 3, r.New)
+	require.Equal(t, 2, r.Duplicates)
+	require.Equal(t, 2, r.Skipped)
+	require.Equal(t, []string{"Oct25log.txt:4: invalid field 3 [not a number]", "Oct25log.txt:8: only 2 fields"}, r.Problems)
+	require.Len(t, db.written, 3)
+
+	first := db.written[0]
+	require.Equal(t, "home", first.StationID)
+	// BST
+	require.Equal(t, time.Date(2025, 9, 30, 23, 5, 0, 0, time.UTC), first.ObservedAt)
+	require.InDelta(t, 10, first.Temperature.Float64, 1e-9)
+	require.InDelta(t, 8, first.DewPoint.Float64, 1e-9)
+	require.InDelta(t, 10, first.WindSpeed.Float64, 0.01)
+	require.InDelta(t, 20, first.WindGust.Float64, 0.01)
+	require.InDelta(t, 1015.9, first.Mslp.Float64, 0.1)
+	// about 3hPa less at 25m
+	require.InDelta(t, first.Mslp.Float64-3.03, first.Pressure.Float64, 0.01)
+	require.InDelta(t, 2.54, first.RainDay.Float64, 1e-9)
+	require.Zero(t, first.RainMm)
+
+	require.InDelta(t, 5.08, db.written[1].RainMm, 1e-9)
+	require.InDelta(t, 12.7, db.written[1].RainRate.Float64, 1e-9)
+	require.InDelta(t, 1.27, db.written[2].RainMm, 1e-9)
+	require.Equal(t, first.ObservedAt, r.First)
+	require.Equal(t, time.Date(2025, 10, 1, 8, 5, 0, 0, time.UTC), r.Last)
+}
+
+func TestDryRun(t *testing.T) {
+	db := &fakeQuerier{}
+	im := New(db, "home", true)
+	c := &Cumulus{Units: CumulusUnits, Location: time.UTC}
+	log := "2025-10-01,00:05,10.0,87,8.0,10,20,225,0.0,0.0,1015.0,12.3\n2025-10-01,00:05,10.0,87,8.0,10,20,225,0.0,0.0,1015.0,12.3\n"
+	require.NoError(t, c.Read(context.Background(), "log", strings.NewReader(log), im))
+	require.Empty(t, db.written)
+	require.Equal(t, 1, im.Report().New)
+	require.Equal(t, 1, im.Report().Duplicates)
+	require.Contains(t, im.Report().String(), "new:        1 (2025-10-01T00:05:00Z to 2025-10-01T00:05:00Z)")
+}
+
+func TestWOW(t *testing.T) {
+	db := &fakeQuerier{}
+	im := New(db, "home", false)
+	w := &WOW{Units: WOWUnits}
+	csv := strings.Join([]string{
+		`"Id","Site Id","Report Date / Time","Air Temperature (°F)","Relative Humidity","Mean Sea-Level Pressure","Wind Speed","Wind Direction","Wind Gust","Daily Rainfall Accumulation (in)"`,
+		`1,99,"2025-10-01 10:00:00",50,80,1013.0,10,90,,0.1`,
+		`2,99,"2025-10-01 10:05:00",50,,1013.0,,,,0.2`,
+		`3,99,"2025-10-01 10:10:00",,80,1013.0,10,90,20,0.2`,
+	}, "\n")
+	require.NoError(t, w.Read(context.Background(), "wow.csv", strings.NewReader(csv), im))
+	require.Equal(t, 2, im.Report().New)
+	require.Equal(t, []string{"wow.csv:4: no temperature"}, im.Report().Problems)
+
+	first := db.written[0]
+	require.Equal(t, time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC), first.ObservedAt)
+	require.InDelta(t, 10, first.Temperature.Float64, 1e-9)
+	require.Equal(t, sql.NullFloat64{Float64: 80, Valid: true}, first.Humidity)
+	require.InDelta(t, 11.51, first.WindSpeed.Float64, 0.01)
+	require.False(t, first.WindGust.Valid)
+	require.Less(t, first.Pressure.Float64, 1013.0)
+	require.InDelta(t, 2.54, first.RainDay.Float64, 1e-9)
+
+	second := db.written[1]
+	require.False(t, second.Humidity.Valid)
+	require.InDelta(t, 2.54, second.RainMm, 1e-9)
+
+	_, err := (&WOW{Units: WOWUnits}).columns([]string{"Air Temperature"})
+	require.Error(t, err)
+}
+
+func TestWeeWX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weewx.sdb")
+	src, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = src.Exec(`CREATE TABLE archive (dateTime INTEGER NOT NULL PRIMARY KEY, usUnits INTEGER NOT NULL, interval INTEGER NOT NULL,
+		outTemp REAL, pressure REAL, barometer REAL, outHumidity REAL, dewpoint REAL,
+		windSpeed REAL, windGust REAL, windDir REAL, rain REAL, rainRate REAL)`)
+	require.NoError(t, err)
+	_, err = src.Exec(`INSERT INTO archive VALUES
+		(1759312800, 1, 5, 50, NULL, 30.0, 80, 44, 10, 15, 270, 0.01, 0.12),
+		(1759313100, 17, 5, 10, 1000, NULL, NULL, NULL, 5, NULL, NULL, 0.2, NULL),
+		(1759313400, 17, 5, NULL, 1000, NULL, NULL, NULL, 5, NULL, NULL, 0.2, NULL),
+		(1759313700, 2, 5, 10, 1000, NULL, NULL, NULL, 5, NULL, NULL, 0.2, NULL)`)
+	require.NoError(t, err)
+	require.NoError(t, src.Close())
+
+	db := &fakeQuerier{}
+	im := New(db, "home", false)
+	require.NoError(t, WeeWX(context.Background(), path, im))
+	r := im.Report()
+	require.Equal(t, 2, r.New)
+	require.Equal(t, 2, r.Skipped)
+	require.Contains(t, r.Problems[1], "unknown unit system 2")
+
+	us := db.written[0]
+	require.Equal(t, time.Unix(1759312800, 0).UTC(), us.ObservedAt)
+	require.InDelta(t, 10, us.Temperature.Float64, 1e-9)
+	require.InDelta(t, 1015.9, us.Mslp.Float64, 0.1)
+	require.InDelta(t, 0.254, us.RainMm, 1e-9)
+	require.InDelta(t, 3.048, us.RainRate.Float64, 1e-9)
+	require.False(t, us.RainDay.Valid)
+
+	metric := db.written[1]
+	require.InDelta(t, 11.18, metric.WindSpeed.Float64, 0.01)
+	require.Equal(t, 1000.0, metric.Pressure.Float64)
+	require.Greater(t, metric.Mslp.Float64, 1000.0)
+	require.False(t, metric.Humidity.Valid)
+}
+
+func TestCumulusMonth(t *testing.T) {
+	m, ok := CumulusMonth("data/Oct25log.txt")
+	require.True(t, ok)
+	require.Equal(t, time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), m)
+	_, ok = CumulusMonth("dayfile.txt")
+	require.False(t, ok)
+}
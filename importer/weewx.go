@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+	_ "modernc.org/sqlite"
+)
+
+// WeeWX unit systems, the usUnits column of each archive row
+var weewxUnits = map[int64]Units{
+	1:  {Temperature: "F", Pressure: "inHg", Wind: "mph", Rain: "in"}, // US
+	16: {Temperature: "C", Pressure: "mb", Wind: "kmh", Rain: "cm"},   // METRIC
+	17: {Temperature: "C", Pressure: "mb", Wind: "ms", Rain: "mm"},    // METRICWX
+}
+
+const weewxArchive = `SELECT dateTime, usUnits, outTemp, pressure, barometer, outHumidity,
+    dewpoint, windSpeed, windGust, windDir, rain, rainRate
+FROM archive
+ORDER BY dateTime`
+
+// WeeWX reads the archive table of a WeeWX sqlite database. Each row carries
+// its own unit system. The archive has no daily rain total, so rain_day is
+// left empty; summaries and records add up rain_mm.
+func WeeWX(ctx context.Context, path string, im *Importer) error {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	im.file("weewx", "")
+
+	rows, err := db.QueryContext(ctx, weewxArchive)
+	if err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			dateTime, usUnits                             int64
+			temp, pressure, barometer, humidity, dewPoint sql.NullFloat64
+			windSpeed, windGust, windDir, rain, rainRate  sql.NullFloat64
+		)
+		if err := rows.Scan(&dateTime, &usUnits, &temp, &pressure, &barometer, &humidity,
+			&dewPoint, &windSpeed, &windGust, &windDir, &rain, &rainRate); err != nil {
+			return err
+		}
+		where := fmt.Sprintf("%v:%d", path, dateTime)
+		units, ok := weewxUnits[usUnits]
+		if !ok {
+			im.Skip(where, fmt.Sprintf("unknown unit system %d", usUnits))
+			continue
+		}
+		if !temp.Valid {
+			im.Skip(where, "no temperature")
+			continue
+		}
+		if !pressure.Valid && !barometer.Valid {
+			im.Skip(where, "no pressure")
+			continue
+		}
+
+		tempC := temperatureUnits[units.Temperature]
+		hPa := pressureUnits[units.Pressure]
+		wind := windUnits[units.Wind]
+		mm := rainUnits[units.Rain]
+		convert := func(v sql.NullFloat64, f func(float64) float64) sql.NullFloat64 {
+			if v.Valid {
+				v.Float64 = f(v.Float64)
+			}
+			return v
+		}
+
+		rec := postgres.WriteRecordParams{
+			ObservedAt:    time.Unix(dateTime, 0),
+			Temperature:   convert(temp, tempC),
+			WindSpeed:     convert(windSpeed, wind),
+			WindGust:      convert(windGust, wind),
+			WindDirection: windDir,
+			RainMm:        mm(rain.Float64),
+			Humidity:      humidity,
+			DewPoint:      convert(dewPoint, tempC),
+			Mslp:          convert(barometer, hPa),
+			RainRate:      convert(rainRate, mm),
+		}
+		// many drivers only fill one of station and sea level pressure
+		if pressure.Valid {
+			rec.Pressure = convert(pressure, hPa)
+		} else {
+			rec.Pressure = sql.NullFloat64{Float64: env.StationPressure(rec.Mslp.Float64, rec.Temperature.Float64), Valid: true}
+		}
+		if !rec.Mslp.Valid {
+			rec.Mslp = sql.NullFloat64{Float64: env.SeaLevelPressure(rec.Pressure.Float64, rec.Temperature.Float64), Valid: true}
+		}
+		if err := im.Add(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
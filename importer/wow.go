@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/env"
+)
+
+// WOWUnits are those of the WOW site's observation downloads
+var WOWUnits = Units{Temperature: "C", Pressure: "hPa", Wind: "knots", Rain: "mm"}
+
+// wow columns are matched on their header with case, punctuation and any
+// bracketed unit removed, e.g. "Mean Sea-Level Pressure (hPa)"
+var wowColumns = map[string]string{
+	"reportdatetime":            "time",
+	"reportdate":                "time",
+	"datetime":                  "time",
+	"airtemperature":            "temperature",
+	"dewpoint":                  "dew_point",
+	"relativehumidity":          "humidity",
+	"meansealevelpressure":      "mslp",
+	"pressureatstation":         "pressure",
+	"stationpressure":           "pressure",
+	"windspeed":                 "wind_speed",
+	"winddirection":             "wind_direction",
+	"windgust":                  "wind_gust",
+	"rainfallrate":              "rain_rate",
+	"rainfallaccumulation":      "rain",
+	"dailyrainfallaccumulation": "rain_day",
+}
+
+// bracketed units that override the configured ones for their column
+var wowHeaderUnits = map[string]string{
+	"°c": "C", "c": "C", "degc": "C",
+	"°f": "F", "f": "F", "degf": "F",
+	"hpa": "hPa", "mb": "mb", "mbar": "mb", "inhg": "inHg",
+	"mph": "mph", "knots": "knots", "kt": "knots", "kts": "knots", "km/h": "kmh", "kmh": "kmh", "m/s": "ms",
+	"mm": "mm", "mm/hr": "mm", "mm/h": "mm", "in": "in", "in/hr": "in", "inches": "in",
+}
+
+var wowTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	time.RFC3339,
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+}
+
+// WOW reads csv observation downloads from the Met Office WOW site. Columns
+// are found by name, times are UTC and a column's unit may be given in
+// brackets in its header. The rain in each observation is its own column if
+// there is one, otherwise the change in the daily accumulation.
+type WOW struct {
+	Units Units
+	rain  accumulation
+}
+
+type wowColumn struct {
+	index   int
+	convert func(float64) float64
+}
+
+func (w *WOW) Read(ctx context.Context, name string, r io.Reader, im *Importer) error {
+	if err := w.Units.Validate(); err != nil {
+		return err
+	}
+	im.file("wow", w.Units.String())
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cols, err := w.columns(header)
+	if err != nil {
+		return fmt.Errorf("%v: %w", name, err)
+	}
+
+	line := 1
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		line++
+		where := fmt.Sprintf("%v:%d", name, line)
+		if err != nil {
+			im.Skip(where, err.Error())
+			continue
+		}
+		rec, err := w.parse(fields, cols)
+		if err != nil {
+			im.Skip(where, err.Error())
+			continue
+		}
+		if err := im.Add(ctx, rec); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *WOW) columns(header []string) (map[string]wowColumn, error) {
+	type quantity struct {
+		units map[string]func(float64) float64
+		unit  string
+	}
+	temperature := quantity{temperatureUnits, w.Units.Temperature}
+	pressure := quantity{pressureUnits, w.Units.Pressure}
+	wind := quantity{windUnits, w.Units.Wind}
+	rain := quantity{rainUnits, w.Units.Rain}
+	quantities := map[string]quantity{
+		"temperature": temperature,
+		"dew_point":   temperature,
+		"mslp":        pressure,
+		"pressure":    pressure,
+		"wind_speed":  wind,
+		"wind_gust":   wind,
+		"rain_rate":   rain,
+		"rain":        rain,
+		"rain_day":    rain,
+	}
+
+	cols := make(map[string]wowColumn)
+	for i, h := range header {
+		key, unit := wowHeader(h)
+		field, ok := wowColumns[key]
+		if !ok {
+			continue
+		}
+		if _, dup := cols[field]; dup {
+			continue
+		}
+		convert := func(v float64) float64 { return v }
+		if q, ok := quantities[field]; ok {
+			u := q.unit
+			if hu, ok := wowHeaderUnits[unit]; ok && q.units[hu] != nil {
+				u = hu
+			}
+			convert = q.units[u]
+		}
+		cols[field] = wowColumn{index: i, convert: convert}
+	}
+	if _, ok := cols["time"]; !ok {
+		return nil, fmt.Errorf("no report date / time column")
+	}
+	if _, ok := cols["temperature"]; !ok {
+		return nil, fmt.Errorf("no air temperature column")
+	}
+	return cols, nil
+}
+
+// wowHeader splits a header into its normalised name and bracketed unit
+func wowHeader(h string) (key string, unit string) {
+	h = strings.ToLower(strings.TrimSpace(h))
+	if open := strings.IndexAny(h, "(["); open >= 0 {
+		unit = strings.Trim(h[open+1:], ")] ")
+		h = h[:open]
+	}
+	b := &strings.Builder{}
+	for _, r := range h {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), unit
+}
+
+func (w *WOW) parse(fields []string, cols map[string]wowColumn) (postgres.WriteRecordParams, error) {
+	var rec postgres.WriteRecordParams
+	value := func(field string) (float64, bool) {
+		c, ok := cols[field]
+		if !ok || c.index >= len(fields) {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(fields[c.index]), 64)
+		if err != nil {
+			return 0, false
+		}
+		return c.convert(v), true
+	}
+	nullable := func(field string) sql.NullFloat64 {
+		v, ok := value(field)
+		return sql.NullFloat64{Float64: v, Valid: ok}
+	}
+
+	t, err := wowTime(fields[cols["time"].index])
+	if err != nil {
+		return rec, err
+	}
+	rec.ObservedAt = t
+
+	if rec.Temperature = nullable("temperature"); !rec.Temperature.Valid {
+		return rec, fmt.Errorf("no temperature")
+	}
+	rec.Mslp = nullable("mslp")
+	if rec.Pressure = nullable("pressure"); !rec.Pressure.Valid {
+		if !rec.Mslp.Valid {
+			return rec, fmt.Errorf("no pressure")
+		}
+		rec.Pressure = sql.NullFloat64{Float64: env.StationPressure(rec.Mslp.Float64, rec.Temperature.Float64), Valid: true}
+	}
+	if !rec.Mslp.Valid {
+		rec.Mslp = sql.NullFloat64{Float64: env.SeaLevelPressure(rec.Pressure.Float64, rec.Temperature.Float64), Valid: true}
+	}
+	rec.Humidity = nullable("humidity")
+	rec.DewPoint = nullable("dew_point")
+	rec.WindSpeed = nullable("wind_speed")
+	rec.WindGust = nullable("wind_gust")
+	rec.WindDirection = nullable("wind_direction")
+	rec.RainRate = nullable("rain_rate")
+	rec.RainDay = nullable("rain_day")
+	if rain, ok := value("rain"); ok {
+		rec.RainMm = rain
+	} else if rec.RainDay.Valid {
+		rec.RainMm = w.rain.next(rec.RainDay.Float64)
+	}
+	return rec, nil
+}
+
+func wowTime(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	for _, layout := range wowTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time [%v]", v)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/sensors"
+	"github.com/pointer2null/weather/stream"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// live readings published to /api/v1/stream
+
+type windEvent struct {
+	Speed     float64 `json:"speed_mph"` // over the last second
+	Average   float64 `json:"average_mph"`
+	Gust      float64 `json:"gust_mph"`
+	Direction float64 `json:"direction"`
+}
+
+type atmosphereEvent struct {
+	Temperature float64 `json:"temperature_C"`
+	Humidity    float64 `json:"humidity_RH"`
+	Pressure    float64 `json:"pressure_hPa"`
+	Mslp        float64 `json:"mslp_hPa"`
+	DewPoint    float64 `json:"dew_point_C"`
+}
+
+type rainEvent struct {
+	Tip  bool    `json:"tip"`
+	Day  float64 `json:"day_mm"`
+	Rate float64 `json:"rate_mm_hr"`
+}
+
+// streamSensors publishes each new snapshot: the wind every second, the
+// atmosphere when it has been read again and the rain when it changes, as
+// well as every rain tip as it happens. Clients never cause a sensor read.
+func (w *weatherstation) streamSensors() {
+	var atmosphere *sensors.AtmosphereReading
+	var rain rainEvent
+	w.sampler.OnSnapshot(func(snap *sensors.Snapshot) {
+		if wind := snap.Wind; wind != nil {
+			w.stream.Publish("wind", wind.Time, windEvent{
+				Speed:     wind.Instant,
+				Average:   wind.Speed,
+				Gust:      wind.Gust,
+				Direction: wind.Direction,
+			})
+		}
+		if atm := snap.Atmosphere; atm != nil && atm != atmosphere {
+			atmosphere = atm
+			w.stream.Publish("atmosphere", atm.Time, newAtmosphereEvent(atm))
+		}
+		if r := snap.Rain; r != nil {
+			// the rate decays between tips, so a change below 0.1 mm/h
+			// isn't news
+			e := rainEvent{Day: r.Day.Float64(), Rate: math.Round(r.Rate.Float64()*10) / 10}
+			if e != rain {
+				rain = e
+				w.stream.Publish("rain", r.Time, e)
+			}
+		}
+	})
+	if w.s.Rain != nil {
+		w.s.Rain.OnTip(func(t time.Time, _ float64) {
+			day, _ := w.s.Rain.GetDayAccumulation()
+			w.stream.Publish("rain", t, rainEvent{
+				Tip:  true,
+				Day:  day.Float64(),
+				Rate: w.s.Rain.GetRate().Float64(),
+			})
+		})
+	}
+}
+
+func newAtmosphereEvent(atm *sensors.AtmosphereReading) atmosphereEvent {
+	tempC := atm.Temperature.Float64()
+	humidity := atm.Humidity.Float64()
+	return atmosphereEvent{
+		Temperature: tempC,
+		Humidity:    humidity,
+		Pressure:    atm.Pressure.Float64(),
+		Mslp:        env.SeaLevelPressure(atm.Pressure.Float64(), tempC),
+		DewPoint:    tempC - ((100 - humidity) / 5.0),
+	}
+}
+
+func registerStreamMetrics(hub *stream.Hub) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "stream_clients",
+			Help: "Clients connected to the live stream",
+		}, func() float64 { return float64(hub.Clients()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "stream_dropped_events_total",
+			Help: "Live stream events skipped for clients that fell behind",
+		}, func() float64 { return float64(hub.Dropped()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "stream_slow_disconnects_total",
+			Help: "Live stream clients disconnected for being too slow",
+		}, func() float64 { return float64(hub.Disconnected()) }),
+	)
+}
@@ -1,21 +1,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"database/sql"
 
 	_ "github.com/lib/pq"
 
+	"github.com/pointer2null/weather/api"
+	"github.com/pointer2null/weather/calibration"
+	"github.com/pointer2null/weather/climate"
 	"github.com/pointer2null/weather/data"
+	"github.com/pointer2null/weather/db/migrations"
 	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/db/sqlite"
 	"github.com/pointer2null/weather/env"
 	"github.com/pointer2null/weather/led"
+	"github.com/pointer2null/weather/qc"
 	"github.com/pointer2null/weather/sensors"
+	"github.com/pointer2null/weather/stream"
+	"github.com/pointer2null/weather/ui"
+	"github.com/pointer2null/weather/windarchive"
+	"github.com/pointer2null/weather/windrose"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
@@ -30,26 +45,47 @@ const (
 	user     = "weather"
 	password = "weather01."
 	dbname   = "weather"
+
+	// observations are written here first and copied to postgres
+	localDB = "/home/pi/weather.db"
 )
 
 type weatherstation struct {
 	s            *sensors.Sensors
 	data         *data.WeatherData
-	Db           *postgres.Queries
+	Db           postgres.Querier
 	HeartbeatLed *led.LED
 	args         *env.Args
+	location     *time.Location
+	records      *climate.RecordTracker
+	stream       *stream.Hub
+	sampler      *sensors.Sampler
+	qc           *qc.Checker
+	cal          calibration.Set
+	rainEvents   *climate.RainEvents
+	// tempDrift is set while the two temperature sensors disagree
+	tempDrift atomic.Bool
 }
 
+// webdata is the latest snapshot. Values from a sensor that is off are
+// null, and the ages say how old the readings are in seconds.
 type webdata struct {
-	TimeNow   string  `json:"time"`
-	TempHiRes float64 `json:"hiResTemp_C"`
-	Humidity  float64 `json:"humidity_RH"`
-	Pressure  float64 `json:"pressure_hPa"`
-	RainHr    float64 `json:"rain_mm_hr"`
-	RainRate  float64 `json:"rain_rate"`
-	WindDir   float64 `json:"wind_dir"`
-	WindSpeed float64 `json:"wind_speed"`
-	WindGust  float64 `json:"wind_gust"`
+	TimeNow       string   `json:"time"`
+	Age           *float64 `json:"age_s"`
+	AtmosphereAge *float64 `json:"atmosphere_age_s"`
+	TempHiRes     *float64 `json:"hiResTemp_C"`
+	TempSource    *string  `json:"temperature_source"`
+	TempSecondary *float64 `json:"secondaryTemp_C"`
+	Humidity      *float64 `json:"humidity_RH"`
+	Pressure      *float64 `json:"pressure_hPa"`
+	Mslp          *float64 `json:"mslp_hPa"`
+	RainRate      *float64 `json:"rain_rate_mm_hr"`
+	RainHour      *float64 `json:"rain_hour_mm"`
+	RainMinute    *float64 `json:"rain_minute_mm"`
+	Raining       *bool    `json:"raining"`
+	WindDir       *float64 `json:"wind_dir"`
+	WindSpeed     *float64 `json:"wind_speed"`
+	WindGust      *float64 `json:"wind_gust"`
 }
 
 var Prom_atmPresure = prometheus.NewGauge(
@@ -66,6 +102,13 @@ var Prom_rainRatePerMin = prometheus.NewGauge(
 	},
 )
 
+var Prom_rainRate = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "rain_rate",
+		Help: "Rain rate mm/h from the time between tips",
+	},
+)
+
 var Prom_rainDayTotal = prometheus.NewGauge(
 	prometheus.GaugeOpts{
 		Name: "rain_day",
@@ -73,6 +116,20 @@ var Prom_rainDayTotal = prometheus.NewGauge(
 	},
 )
 
+var Prom_rainDayRaw = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "rain_day_raw",
+		Help: "The rain total today from the tips, without the intensity correction",
+	},
+)
+
+var Prom_raining = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "raining",
+		Help: "1 during a rain event, 0 when dry",
+	},
+)
+
 var Prom_humidity = prometheus.NewGauge(
 	prometheus.GaugeOpts{
 		Name: "relative_humidity",
@@ -108,6 +165,59 @@ var Prom_windDirection = prometheus.NewGauge(
 	},
 )
 
+var Prom_recordsBroken = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "station_records_broken_total",
+		Help: "Station records broken, by metric and scope",
+	},
+	[]string{"metric", "scope"},
+)
+
+var Prom_qcFlags = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "qc_flags_total",
+		Help: "Minutes a value was flagged by quality control, by variable and flag",
+	},
+	[]string{"variable", "flag"},
+)
+
+var Prom_windRose = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "wind_rose_seconds_total",
+		Help: "Seconds of wind from each compass point, by speed band, in one minute means",
+	},
+	[]string{"sector", "band"},
+)
+
+var Prom_windCalm = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "wind_calm_seconds_total",
+		Help: "Seconds of calm, a one minute mean below 1 mph",
+	},
+)
+
+var Prom_temperatureSecondary = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "temperature_secondary",
+		Help: "BME280 temperature C",
+	},
+)
+
+var Prom_temperatureDifference = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "temperature_sensor_difference",
+		Help: "MCP9808 less BME280 temperature C, averaged over 10 minutes",
+	},
+)
+
+var Prom_temperatureSource = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "temperature_source",
+		Help: "1 for the sensor the temperature comes from",
+	},
+	[]string{"sensor"},
+)
+
 // called by prometheus
 func init() {
 	logger.Infof("%v: Initialize prometheus...", time.Now().Format(time.RFC822))
@@ -115,14 +225,29 @@ func init() {
 		Prom_atmPresure,
 		Prom_humidity,
 		Prom_rainRatePerMin,
+		Prom_rainRate,
 		Prom_rainDayTotal,
+		Prom_rainDayRaw,
+		Prom_raining,
 		Prom_temperature,
 		Prom_windspeed,
 		Prom_windgust,
-		Prom_windDirection)
+		Prom_windDirection,
+		Prom_windRose,
+		Prom_windCalm,
+		Prom_qcFlags,
+		Prom_temperatureSecondary,
+		Prom_temperatureDifference,
+		Prom_temperatureSource,
+		Prom_recordsBroken)
 }
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	logger.Infof("Starting weather station [%v]", version)
 	w := weatherstation{}
 	w.args = &env.Args{}
@@ -137,25 +262,73 @@ func main() {
 	w.args.WindEnabled = flag.Bool("windOn", true, "disables the anemometer")
 	w.args.AtmosphericEnabled = flag.Bool("atmOn", true, "disables atmospheric sensor")
 	w.args.RainEnabled = flag.Bool("rainOn", true, "disables rain sensor")
+	w.args.StationID = flag.String("station", "default", "station id recorded against each observation")
+	w.args.TimeZone = flag.String("tz", "Europe/London", "station time zone used for daily summaries")
+	w.args.LocalDB = flag.String("db", localDB, "local sqlite database")
+	w.args.Postgres = flag.Bool("pg", true, "copies the local database to postgres")
+	w.args.WindArchive = flag.String("windArchive", "", "archives every raw wind sample to file or pg")
+	w.args.WindArchiveDir = flag.String("windArchiveDir", "/home/pi/wind", "directory for -windArchive file")
+	w.args.WindRetention = flag.Duration("windRetention", 30*24*time.Hour, "how long to keep raw wind samples (0 keeps them)")
+	w.args.PgRetention = flag.Duration("pgRetention", 0, "with TimescaleDB, drops raw observations older than this (0 keeps them)")
+	w.args.QCConfig = flag.String("qc", "", "JSON table of quality control limits over the defaults")
+	w.args.TempDelta = flag.Float64("tempDelta", 1.0, "difference in C between the MCP9808 and BME280 temperatures flagged as drift")
+	w.args.Calibration = flag.String("calibration", "", "JSON list of sensor calibration versions")
+	w.args.Vane = flag.String("vane", "", "JSON vane lookup table, from calibrate vane")
+	w.args.RainDry = flag.Duration("rainDry", 30*time.Minute, "time without a tip that ends a rain event")
+	w.args.RainDenoise = flag.Duration("rainDenoise", env.RainDenoise, "time the rain pin must stay low for an edge to be a tip")
+	w.args.RainDebounce = flag.Duration("rainDebounce", env.RainDebounce, "shortest time between rain tips, edges sooner are bounces")
+	w.args.RainShake = flag.Float64("rainShake", 0, "rejects rain tips within a second of the IMU reading over this many g, 0 to accept them all (needs -imu)")
 	flag.Parse()
 
 	if *w.args.Test {
 		logger.Info("TEST MODE")
 	}
 
-	// connect to database
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-
-	db, err := sql.Open("postgres", psqlInfo)
+	location, err := time.LoadLocation(*w.args.TimeZone)
 	if err != nil {
-		logger.Errorf("Failed to initialise database: [%v]", err)
+		logger.Errorf("Invalid time zone [%v]", err)
 		logger.Exit(1)
 	}
-	defer db.Close()
-
-	logger.Info("Successfully connected to db.")
+	w.location = location
+
+	qcConfig := qc.DefaultConfig
+	if *w.args.QCConfig != "" {
+		if qcConfig, err = qc.Load(*w.args.QCConfig); err != nil {
+			logger.Errorf("Invalid quality control limits [%v]", err)
+			logger.Exit(1)
+		}
+	}
+	w.qc = qc.NewChecker(qcConfig)
 
-	w.Db = postgres.New(db)
+	// the local database takes every write, so the station keeps recording
+	// while the home server is down
+	local, err := openLocal(*w.args.LocalDB)
+	if err != nil {
+		logger.Errorf("Failed to initialise local database: [%v]", err)
+		logger.Exit(1)
+	}
+	defer local.Close()
+	w.Db = sqlite.New(local)
+
+	if *w.args.Calibration != "" {
+		if w.cal, err = calibration.Load(*w.args.Calibration); err != nil {
+			logger.Errorf("Invalid calibration [%v]", err)
+			logger.Exit(1)
+		}
+		// observations name their version, so it must be recorded first
+		if err := calibration.Record(context.Background(), w.Db, *w.args.StationID, w.cal); err != nil {
+			logger.Errorf("Failed to record calibration [%v]", err)
+			logger.Exit(1)
+		}
+		logger.Infof("Calibration version %d", w.cal.At(time.Now()).Number())
+	}
+	vane := calibration.DefaultVane
+	if *w.args.Vane != "" {
+		if vane, err = calibration.LoadVane(*w.args.Vane); err != nil {
+			logger.Errorf("Invalid vane table [%v]", err)
+			logger.Exit(1)
+		}
+	}
 
 	logger.Info("Initializing sensors...")
 
@@ -165,47 +338,248 @@ func main() {
 		logger.Exit(1)
 	}
 	defer (*w.s.Closer).Close()
+	w.s.Calibrate(w.cal, vane)
 
 	//setup heartbeat
 	w.HeartbeatLed = led.NewLED("Heartbeat LED", env.HeartbeatLed)
-	go w.Heartbeat()
 
 	w.data = data.CreateWeatherData()
 
+	// nothing but the sampler reads the sensors
+	w.sampler = sensors.NewSampler(w.s, *w.args)
+	w.stream = stream.NewHub(env.StreamBuffer, env.StreamHeartbeat)
+	registerStreamMetrics(w.stream)
+	prometheus.MustRegister(healthCollector{w.sampler})
+	w.streamSensors()
+	w.countWindRose()
+	w.compareTemperatures()
+	if w.s.Rain != nil {
+		w.trackRainEvents()
+		w.registerRainMetrics()
+	}
+	go w.sampler.Run()
+	go w.Heartbeat()
+
+	w.records = climate.NewRecordTracker(w.Db, *w.args.StationID, location)
+	w.records.OnRecord(climate.LogRecord)
+	w.records.OnRecord(func(e climate.Event) {
+		Prom_recordsBroken.WithLabelValues(string(e.Record.Metric), e.Record.Scope).Inc()
+	})
+	if url, ok := os.LookupEnv("RECORDWEBHOOK"); ok {
+		w.records.OnRecord(climate.WebhookNotifier(url))
+	}
+	// before reporting starts, so no record broken live is replaced
+	if err := w.records.Recompute(context.Background()); err != nil {
+		logger.Errorf("Failed to recompute station records [%v]", err)
+		if err := w.records.Load(context.Background()); err != nil {
+			logger.Errorf("Failed to load station records [%v]", err)
+		}
+	}
+
 	go w.Reporting()
+	go climate.NewSummariser(w.Db, *w.args.StationID, location).Run()
 
 	// start web service
 	logger.Info("Starting webservice...")
-	http.HandleFunc("/", w.handler)
+	ui.Register(http.DefaultServeMux)
+	http.HandleFunc("/api/v1/current", w.current)
+	http.HandleFunc("/api/v1/health", w.health)
 	http.Handle("/metrics", promhttp.Handler())
+	server := api.New(w.Db, *w.args.StationID, location, w.records)
+	server.SetCalibration(w.cal)
+	if w.rainEvents != nil {
+		server.SetRainEvents(w.rainEvents)
+	}
+
+	var pg *sql.DB
+	if *w.args.Postgres {
+		if pg, err = openPostgres(); err != nil {
+			logger.Errorf("Failed to initialise postgres: [%v]", err)
+			logger.Exit(1)
+		}
+		defer pg.Close()
+		go w.syncPostgres(local, pg, server)
+	}
+
+	if archive := w.windArchive(pg); archive != nil {
+		w.s.Wind.OnSample(archive.Add)
+		server.SetWindArchive(archive)
+		go archive.Run()
+	}
+	server.SetStream(w.stream)
+	server.Register(http.DefaultServeMux)
 
 	logger.Info(http.ListenAndServe(":80", nil))
 	w.HeartbeatLed.Off()
-	w.s.Rain.GetLED().Off()
+	if w.s.Rain != nil {
+		w.s.Rain.GetLED().Off()
+	}
 	defer logger.Info("Exiting...")
 }
 
+// openLocal opens the local database and brings its schema up to date
+func openLocal(path string) (*sql.DB, error) {
+	db, err := sqlite.Open(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Using local database %v", path)
+	return db, nil
+}
+
+// openPostgres prepares the postgres connection. Nothing is sent until the
+// first query, so this succeeds while the server is down.
+func openPostgres() (*sql.DB, error) {
+	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+	return sql.Open("postgres", psqlInfo)
+}
+
+// syncPostgres copies the local database to postgres. The schema is brought
+// up to date on first contact, and if TimescaleDB is available the history
+// api switches to its aggregates.
+func (w *weatherstation) syncPostgres(local *sql.DB, pg *sql.DB, server *api.Server) {
+	timescale := false
+	syncer := sqlite.NewSyncer(sqlite.New(local), postgres.New(pg), func(ctx context.Context) error {
+		if err := migrations.Run(ctx, pg); err != nil {
+			return err
+		}
+		if err := calibration.Record(ctx, postgres.New(pg), *w.args.StationID, w.cal); err != nil {
+			if !errors.Is(err, calibration.ErrChanged) {
+				return err
+			}
+			// the observations still sync, but reprocessing them in postgres
+			// would use the wrong curves
+			logger.Errorf("Postgres has a different calibration [%v]", err)
+		}
+		ok, err := migrations.Timescale(ctx, pg)
+		if err != nil {
+			return err
+		}
+		if ok {
+			logger.Info("TimescaleDB enabled")
+			if err := migrations.SetRetention(ctx, pg, "weather", *w.args.PgRetention); err != nil {
+				return err
+			}
+			server.SetHistory(postgres.NewTimescale(pg))
+		}
+		timescale = ok
+		return nil
+	})
+	syncer.OnSynced(func(ctx context.Context, from time.Time, to time.Time) error {
+		// rows inside the refresh policy window are picked up by the policy
+		if !timescale || time.Since(from) < env.AggregateRefreshWindow {
+			return nil
+		}
+		return migrations.RefreshAggregates(ctx, pg, from.Truncate(24*time.Hour), to.Truncate(24*time.Hour).Add(24*time.Hour))
+	})
+	syncer.Run()
+}
+
+// windArchive returns the raw wind archive chosen by -windArchive, or nil if
+// it is off.
+func (w *weatherstation) windArchive(pg *sql.DB) *windarchive.Archive {
+	if *w.args.WindArchive == "" {
+		return nil
+	}
+	if w.s.Wind == nil {
+		logger.Warn("Wind archive needs the anemometer")
+		return nil
+	}
+	var store windarchive.Store
+	switch *w.args.WindArchive {
+	case "file":
+		fs, err := windarchive.NewFileStore(*w.args.WindArchiveDir)
+		if err != nil {
+			logger.Errorf("Failed to create wind archive [%v]", err)
+			logger.Exit(1)
+		}
+		store = fs
+	case "pg":
+		if pg == nil {
+			logger.Error("-windArchive pg needs -pg")
+			logger.Exit(1)
+		}
+		store = windarchive.NewPostgresStore(pg, *w.args.StationID)
+	default:
+		logger.Errorf("Invalid -windArchive [%v]", *w.args.WindArchive)
+		logger.Exit(1)
+	}
+	return windarchive.New(store, *w.args.WindRetention)
+}
+
+// countWindRose adds each minute of wind to the wind rose counters, which
+// Grafana can draw as a rose from their increase over any range
+func (w *weatherstation) countWindRose() {
+	if w.s.Wind == nil {
+		return
+	}
+	mph := func(v float64) float64 { return v }
+	bands := make([]string, len(windrose.Bands))
+	for b := range windrose.Bands {
+		bands[b] = windrose.BandName(b, mph, "mph")
+		// every series exists from the start, so a rose has all its sectors
+		for _, sector := range windrose.Points {
+			Prom_windRose.WithLabelValues(sector, bands[b])
+		}
+	}
+	avg := windrose.NewAverager(func(r windrose.Reading) {
+		b := windrose.Band(w.cal.WindSpeed(r.Time, r.Speed))
+		if b < 0 {
+			Prom_windCalm.Add(r.Seconds)
+			return
+		}
+		Prom_windRose.WithLabelValues(windrose.Points[windrose.Sector(r.Direction)], bands[b]).Add(r.Seconds)
+	})
+	w.s.Wind.OnSample(avg.Add)
+}
+
+// Heartbeat flashes once a minute while every sensor is healthy, and twice
+// for a degraded or three times for a failed one every 10 seconds
 func (w *weatherstation) Heartbeat() {
 	logger.Info("Heartbeat started")
 	for {
-		w.HeartbeatLed.Flash()
-		time.Sleep(time.Second * 60)
+		state := sensors.Worst(w.sampler.Latest().Health)
+		if state == sensors.OK {
+			w.HeartbeatLed.Flash()
+			time.Sleep(time.Second * 60)
+			continue
+		}
+		w.HeartbeatLed.Flicker(int(state) + 1)
+		time.Sleep(time.Second * 10)
 	}
 }
 
-func (w *weatherstation) handler(rw http.ResponseWriter, r *http.Request) {
+// current serves the latest snapshot as JSON
+func (w *weatherstation) current(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Set("Content-Type", "application/json")
-	pres, hum := w.s.Atm.GetHumidityAndPressure()
-	wd := webdata{
-		TempHiRes: w.s.Atm.GetTemperature().Float64(),
-		Humidity:  hum.Float64(),
-		Pressure:  pres.Float64(),
-		RainHr:    w.s.Rain.GetRate().Float64(),
-		RainRate:  w.s.Rain.GetMinuteRate().Float64(),
-		TimeNow:   time.Now().Format(time.RFC822),
-		WindDir:   w.s.Wind.GetDirection(),
-		WindSpeed: w.s.Wind.GetSpeed(),
-		WindGust:  w.s.Wind.GetGust(),
+	now := time.Now()
+	snap := w.sampler.Latest()
+	wd := webdata{TimeNow: now.Format(time.RFC822)}
+	if !snap.Time.IsZero() {
+		wd.Age = ptr(now.Sub(snap.Time).Seconds())
+	}
+	if atm := snap.Atmosphere; atm != nil {
+		wd.AtmosphereAge = ptr(now.Sub(atm.Time).Seconds())
+		wd.TempHiRes = ptr(atm.Temperature.Float64())
+		wd.TempSource = ptr(atm.TemperatureSource)
+		wd.TempSecondary = ptr(atm.Secondary.Float64())
+		wd.Humidity = ptr(atm.Humidity.Float64())
+		wd.Pressure = ptr(atm.Pressure.Float64())
+		wd.Mslp = ptr(env.SeaLevelPressure(atm.Pressure.Float64(), atm.Temperature.Float64()))
+	}
+	if rain := snap.Rain; rain != nil {
+		wd.RainRate = ptr(rain.Rate.Float64())
+		wd.RainHour = ptr(rain.Hour.Float64())
+		wd.RainMinute = ptr(rain.MinuteRate.Float64())
+	}
+	if w.rainEvents != nil {
+		_, raining := w.rainEvents.Current()
+		wd.Raining = &raining
+	}
+	if wind := snap.Wind; wind != nil {
+		wd.WindDir = ptr(wind.Direction)
+		wd.WindSpeed = ptr(wind.Speed)
+		wd.WindGust = ptr(wind.Gust)
 	}
 
 	js, err := json.Marshal(wd)
@@ -215,6 +589,10 @@ func (w *weatherstation) handler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logger.Infof("Web read: \n[%v]", string(js))
+	logger.Debugf("Web read: \n[%v]", string(js))
 	_, _ = rw.Write(js) // not much we can do if this fails
 }
+
+func ptr[T any](v T) *T {
+	return &v
+}
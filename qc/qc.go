@@ -0,0 +1,329 @@
+// Package qc quality controls the readings before they are recorded or
+// uploaded, with the WMO's basic checks: a plausible range, a limit on how
+// fast a value can change, a value stuck for too long, and consistency
+// between related values. A value that fails is flagged rather than
+// changed, so the flags travel with the observation into the database and
+// the uploaders leave flagged values out.
+package qc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Flag int
+
+const (
+	Good Flag = iota
+	// Suspect values passed the range check but changed too fast, stuck,
+	// or disagree with a related value
+	Suspect
+	// Failed values are impossible: out of range or inconsistent
+	Failed
+	// Missing values weren't read, so what is recorded in their place means
+	// nothing
+	Missing
+)
+
+var flagNames = map[Flag]string{Good: "good", Suspect: "suspect", Failed: "failed", Missing: "missing"}
+
+func (f Flag) String() string {
+	return flagNames[f]
+}
+
+func (f Flag) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Flag) UnmarshalText(b []byte) error {
+	for flag, name := range flagNames {
+		if name == string(b) {
+			*f = flag
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown qc flag [%s]", b)
+}
+
+// Variable names a checked value, as its column is named in the weather table
+type Variable string
+
+const (
+	Temperature   Variable = "temperature"
+	Humidity      Variable = "humidity"
+	Pressure      Variable = "pressure"
+	DewPoint      Variable = "dew_point"
+	WindSpeed     Variable = "wind_speed"
+	WindGust      Variable = "wind_gust"
+	WindDirection Variable = "wind_direction"
+	RainRate      Variable = "rain_rate"
+	RainDay       Variable = "rain_day"
+)
+
+// Duration reads from config as a string such as "90m"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	*d = Duration(v)
+	return err
+}
+
+// Limits are the checks on one variable, in the units it is stored in
+type Limits struct {
+	// values outside [Min, Max] fail
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	// a change of more than Step a minute is suspect, 0 turns it off
+	Step float64 `json:"step"`
+	// a value that has changed by less than Delta in Persist is suspect,
+	// unless it is one of Rest, such as 0 for calm or 100% for fog. 0 turns
+	// it off.
+	Persist Duration  `json:"persist"`
+	Delta   float64   `json:"delta"`
+	Rest    []float64 `json:"rest"`
+}
+
+func (l Limits) rests(v float64) bool {
+	for _, r := range l.Rest {
+		if math.Abs(v-r) <= l.Delta {
+			return true
+		}
+	}
+	return false
+}
+
+type Config map[Variable]Limits
+
+// DefaultConfig suits a lowland UK station: C, %, hPa, mph and mm
+var DefaultConfig = Config{
+	Temperature:   {Min: -30, Max: 45, Step: 3, Persist: Duration(time.Hour * 2), Delta: 0.05},
+	Humidity:      {Min: 1, Max: 100, Step: 10, Persist: Duration(time.Hour * 6), Delta: 0.5, Rest: []float64{100}},
+	Pressure:      {Min: 900, Max: 1080, Step: 1, Persist: Duration(time.Hour * 3), Delta: 0.01},
+	DewPoint:      {Min: -40, Max: 35, Step: 3},
+	WindSpeed:     {Min: 0, Max: 100, Step: 30, Persist: Duration(time.Hour * 3), Delta: 0.01, Rest: []float64{0}},
+	WindGust:      {Min: 0, Max: 120},
+	WindDirection: {Min: 0, Max: 360, Persist: Duration(time.Hour * 6), Delta: 1},
+	RainRate:      {Min: 0, Max: 300, Persist: Duration(time.Hour), Delta: 0.001, Rest: []float64{0}},
+	RainDay:       {Min: 0, Max: 300},
+}
+
+// Load reads a JSON table of limits over the defaults, e.g.
+//
+//	{"temperature": {"min": -20, "persist": "90m"}}
+//
+// sets those two and keeps the rest of the temperature defaults.
+func Load(path string) (Config, error) {
+	c := Config{}
+	for v, l := range DefaultConfig {
+		c[v] = l
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table map[Variable]json.RawMessage
+	if err := json.Unmarshal(b, &table); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	for v, raw := range table {
+		l, ok := c[v]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown variable [%v]", path, v)
+		}
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&l); err != nil {
+			return nil, fmt.Errorf("%v: %v: %w", path, v, err)
+		}
+		if l.Min > l.Max {
+			return nil, fmt.Errorf("%v: %v: min is above max", path, v)
+		}
+		c[v] = l
+	}
+	return c, nil
+}
+
+// Flags holds the variables that are not Good
+type Flags map[Variable]Flag
+
+// Raise sets v's flag, unless it already has a worse one
+func (f Flags) Raise(v Variable, flag Flag) {
+	if flag > f[v] {
+		f[v] = flag
+	}
+}
+
+// Flagged is true if v is anything but good
+func (f Flags) Flagged(v Variable) bool {
+	return f[v] != Good
+}
+
+// String is the JSON stored in the weather table's qc_flags column, empty
+// when everything is good
+func (f Flags) String() string {
+	if len(f) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(map[Variable]Flag(f)) // can't fail
+	return string(b)
+}
+
+// ParseFlags reads a qc_flags column
+func ParseFlags(s string) (Flags, error) {
+	f := Flags{}
+	if s == "" {
+		return f, nil
+	}
+	return f, json.Unmarshal([]byte(s), &f)
+}
+
+// Summary lists the flags for a log line, e.g. "temperature failed"
+func (f Flags) Summary() string {
+	var parts []string
+	for v, flag := range f {
+		parts = append(parts, fmt.Sprintf("%v %v", v, flag))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// calmMph is below the anemometer's first pulse, where direction means nothing
+const calmMph = 1.0
+
+// stale history is not compared with, the step check needs recent values
+const stale = time.Minute * 10
+
+type history struct {
+	last time.Time
+	prev float64
+	// since is when the value last moved by more than Delta, from held
+	since time.Time
+	held  float64
+}
+
+// Checker checks each set of readings against the ones before
+type Checker struct {
+	config  Config
+	history map[Variable]*history
+}
+
+func NewChecker(c Config) *Checker {
+	return &Checker{config: c, history: make(map[Variable]*history)}
+}
+
+// Check flags the readings taken at t. Readings should come about a minute
+// apart; values missing from readings are not checked.
+func (c *Checker) Check(t time.Time, readings map[Variable]float64) Flags {
+	flags := Flags{}
+	for v, value := range readings {
+		l, ok := c.config[v]
+		if !ok {
+			continue
+		}
+		if math.IsNaN(value) || value < l.Min || value > l.Max {
+			flags.Raise(v, Failed)
+		}
+		h := c.history[v]
+		if h == nil || t.Sub(h.last) > stale {
+			c.history[v] = &history{last: t, prev: value, since: t, held: value}
+			continue
+		}
+		minutes := math.Max(t.Sub(h.last).Minutes(), 1)
+		if l.Step > 0 && math.Abs(value-h.prev) > l.Step*minutes {
+			flags.Raise(v, Suspect)
+		}
+		switch {
+		case math.Abs(value-h.held) > l.Delta || l.rests(value):
+			h.since, h.held = t, value
+		case v == WindDirection && readings[WindSpeed] < calmMph:
+			// the vane only has to move when there is wind to move it
+			h.since = t
+		case l.Persist > 0 && t.Sub(h.since) >= time.Duration(l.Persist):
+			flags.Raise(v, Suspect)
+		}
+		h.last, h.prev = t, value
+	}
+
+	c.consistency(readings, flags)
+	return flags
+}
+
+// consistency compares related values
+func (c *Checker) consistency(r map[Variable]float64, flags Flags) {
+	has := func(vs ...Variable) bool {
+		for _, v := range vs {
+			if _, ok := r[v]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	// dew point can't be above the temperature, allowing for rounding
+	if has(Temperature, DewPoint) && r[DewPoint] > r[Temperature]+0.5 {
+		flags.Raise(DewPoint, Failed)
+		flags.Raise(Humidity, Suspect)
+	}
+	// a gust is the peak 3 second mean, so can't be below the mean
+	if has(WindSpeed, WindGust) && r[WindGust] < r[WindSpeed]-0.5 {
+		flags.Raise(WindSpeed, Suspect)
+		flags.Raise(WindGust, Suspect)
+	}
+	// a failed input spoils what is derived from it
+	if flags[Temperature] == Failed || flags[Humidity] == Failed {
+		flags.Raise(DewPoint, Failed)
+	}
+}
+
+// Redundant compares two instruments measuring the same thing. Their
+// difference is averaged over Window, so one lagging the other as the value
+// changes isn't taken for drift.
+type Redundant struct {
+	Delta  float64
+	Window time.Duration
+	diffs  []difference
+}
+
+type difference struct {
+	t    time.Time
+	diff float64
+}
+
+// Add compares a reading from each at t and returns their mean difference,
+// a less b, over the window
+func (r *Redundant) Add(t time.Time, a, b float64) float64 {
+	r.diffs = append(r.diffs, difference{t: t, diff: a - b})
+	keep := 0
+	for keep < len(r.diffs) && t.Sub(r.diffs[keep].t) >= r.Window {
+		keep++
+	}
+	r.diffs = r.diffs[keep:]
+	return r.Difference()
+}
+
+// Difference is the mean difference over the window, 0 with nothing to compare
+func (r *Redundant) Difference() float64 {
+	if len(r.diffs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, d := range r.diffs {
+		sum += d.diff
+	}
+	return sum / float64(len(r.diffs))
+}
+
+// Drifting is true once the instruments disagree by more than Delta
+func (r *Redundant) Drifting() bool {
+	return math.Abs(r.Difference()) > r.Delta
+}
@@ -0,0 +1,162 @@
+package qc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestRange(t *testing.T) {
+	c := NewChecker(DefaultConfig)
+	flags := c.Check(start, map[Variable]float64{
+		Temperature: 12,
+		Pressure:    850,
+		WindGust:    500,
+		Humidity:    70,
+	})
+	require.Equal(t, Flags{Pressure: Failed, WindGust: Failed}, flags)
+	require.False(t, flags.Flagged(Temperature))
+}
+
+func TestStep(t *testing.T) {
+	c := NewChecker(DefaultConfig)
+	require.Empty(t, c.Check(start, map[Variable]float64{Temperature: 12}))
+	require.Empty(t, c.Check(start.Add(time.Minute), map[Variable]float64{Temperature: 14}))
+	require.Equal(t, Flags{Temperature: Suspect}, c.Check(start.Add(time.Minute*2), map[Variable]float64{Temperature: 18}))
+	// the step allowed grows with the time between readings
+	require.Empty(t, c.Check(start.Add(time.Minute*5), map[Variable]float64{Temperature: 24}))
+	// and a gap resets the history
+	require.Empty(t, c.Check(start.Add(time.Hour), map[Variable]float64{Temperature: 5}))
+}
+
+func TestPersistence(t *testing.T) {
+	c := NewChecker(DefaultConfig)
+	var flags Flags
+	for m := 0; m <= 180; m++ {
+		flags = c.Check(start.Add(time.Minute*time.Duration(m)), map[Variable]float64{
+			Pressure:  1012.3,
+			WindSpeed: 0,
+			Humidity:  100,
+		})
+		if m < 180 {
+			require.Empty(t, flags, "minute %d", m)
+		}
+	}
+	// calm and fog can last, a stuck barometer can't
+	require.Equal(t, Flags{Pressure: Suspect}, flags)
+
+	// it clears once the value moves
+	require.Empty(t, c.Check(start.Add(time.Minute*181), map[Variable]float64{Pressure: 1012.4}))
+}
+
+func TestDirectionInCalm(t *testing.T) {
+	c := NewChecker(DefaultConfig)
+	check := func(m int, speed float64) Flags {
+		return c.Check(start.Add(time.Minute*time.Duration(m)), map[Variable]float64{
+			WindDirection: 270,
+			WindSpeed:     speed,
+			WindGust:      speed,
+		})
+	}
+	for m := 0; m < 6*60; m++ {
+		require.Empty(t, check(m, 0))
+	}
+	// the vane only counts as stuck for the time it has had wind to move it,
+	// 6 hours from the last calm minute
+	for m := 6 * 60; m < 12*60-1; m++ {
+		require.Empty(t, check(m, float64(5+m%3)))
+	}
+	require.Equal(t, Flags{WindDirection: Suspect}, check(12*60-1, 6))
+}
+
+func TestConsistency(t *testing.T) {
+	c := NewChecker(DefaultConfig)
+	require.Equal(t, Flags{DewPoint: Failed, Humidity: Suspect}, c.Check(start, map[Variable]float64{
+		Temperature: 10,
+		DewPoint:    12,
+		Humidity:    90,
+	}))
+	require.Equal(t, Flags{WindSpeed: Suspect, WindGust: Suspect}, c.Check(start, map[Variable]float64{
+		WindSpeed: 10,
+		WindGust:  5,
+	}))
+	// a failed temperature spoils the dew point worked out from it
+	require.Equal(t, Flags{Temperature: Failed, DewPoint: Failed}, NewChecker(DefaultConfig).Check(start, map[Variable]float64{
+		Temperature: 85,
+		DewPoint:    8,
+	}))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qc.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"temperature": {"min": -20, "persist": "90m"}}`), 0o644))
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, -20.0, c[Temperature].Min)
+	require.Equal(t, 45.0, c[Temperature].Max)
+	require.Equal(t, Duration(time.Minute*90), c[Temperature].Persist)
+	require.Equal(t, DefaultConfig[Pressure], c[Pressure])
+	// the defaults are left alone
+	require.Equal(t, -30.0, DefaultConfig[Temperature].Min)
+
+	for _, bad := range []string{
+		`{"visibility": {"min": 0}}`,
+		`{"temperature": {"minimum": 0}}`,
+		`{"temperature": {"min": 50}}`,
+		`{"temperature": {"persist": "a while"}}`,
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(bad), 0o644))
+		_, err := Load(path)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestFlagsString(t *testing.T) {
+	require.Equal(t, "", Flags{}.String())
+
+	f := Flags{Temperature: Suspect, WindGust: Failed}
+	require.Equal(t, `{"temperature":"suspect","wind_gust":"failed"}`, f.String())
+	require.Equal(t, "temperature suspect, wind_gust failed", f.Summary())
+
+	parsed, err := ParseFlags(f.String())
+	require.NoError(t, err)
+	require.Equal(t, f, parsed)
+
+	parsed, err = ParseFlags("")
+	require.NoError(t, err)
+	require.Empty(t, parsed)
+
+	_, err = ParseFlags(`{"temperature":"dubious"}`)
+	require.Error(t, err)
+
+	// the worse flag stays
+	f.Raise(Temperature, Missing)
+	f.Raise(Temperature, Suspect)
+	f.Raise(WindGust, Suspect)
+	require.Equal(t, Flags{Temperature: Missing, WindGust: Failed}, f)
+}
+
+func TestRedundant(t *testing.T) {
+	r := &Redundant{Delta: 1, Window: time.Minute * 10}
+	require.Zero(t, r.Difference())
+	require.False(t, r.Drifting())
+
+	// one reading lagging the other isn't drift
+	require.InDelta(t, 0.2, r.Add(start, 12.2, 12), 1e-9)
+	require.InDelta(t, 0.85, r.Add(start.Add(time.Minute), 13.5, 12), 1e-9)
+	require.False(t, r.Drifting())
+
+	for m := 2; m < 12; m++ {
+		r.Add(start.Add(time.Minute*time.Duration(m)), 10, 12)
+	}
+	// the first two have left the window
+	require.InDelta(t, -2, r.Difference(), 1e-9)
+	require.True(t, r.Drifting())
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/sensors"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "github.com/sirupsen/logrus"
+)
+
+type rainingEvent struct {
+	Raining bool              `json:"raining"`
+	Event   climate.RainEvent `json:"event"`
+}
+
+// trackRainEvents follows the tips into rain events, which end after
+// -rainDry without a tip. Whether it is raining is published to prometheus
+// and the live stream as each event starts and ends.
+func (w *weatherstation) trackRainEvents() {
+	w.rainEvents = climate.NewRainEvents(w.Db, *w.args.StationID, *w.args.RainDry)
+	if err := w.rainEvents.Load(context.Background(), time.Now()); err != nil {
+		logger.Errorf("Failed to load the last rain event [%v]", err)
+	}
+	if _, ok := w.rainEvents.Current(); ok {
+		Prom_raining.Set(1)
+	}
+	w.rainEvents.OnChange(func(e climate.RainEvent) {
+		if e.Ongoing {
+			Prom_raining.Set(1)
+		} else {
+			Prom_raining.Set(0)
+		}
+		w.stream.Publish("raining", time.Now(), rainingEvent{Raining: e.Ongoing, Event: e})
+	})
+	w.s.Rain.OnTip(w.rainEvents.Add)
+	go w.rainEvents.Run()
+}
+
+// registerRainMetrics counts what became of each edge on the rain pin:
+// accepted as a tip, or rejected as noise, a bounce or the mast shaking
+func (w *weatherstation) registerRainMetrics() {
+	for _, result := range sensors.EdgeResults {
+		result := result
+		prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "rain_edges_total",
+			Help:        "Edges on the rain gauge pin, by what became of them",
+			ConstLabels: prometheus.Labels{"result": result.String()},
+		}, func() float64 { return float64(w.s.Rain.EdgeCount(result)) }))
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/pointer2null/weather/calibration"
+	"github.com/pointer2null/weather/climate"
+	"github.com/pointer2null/weather/db/postgres"
+	"github.com/pointer2null/weather/db/sqlite"
+	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/qc"
+	"github.com/pointer2null/weather/sensors"
+	logger "github.com/sirupsen/logrus"
+)
+
+// recalibrateCommand reprocesses observations with the calibration in force
+// at their time. Each value is taken back to the raw reading with the
+// version it was recorded with, then calibrated again, so running it twice
+// changes nothing. The affected days are summarised again and the station
+// records recomputed. The local database marks the rows unsynced, and the
+// sync carries them to postgres.
+func recalibrateCommand(args []string) error {
+	fs := flag.NewFlagSet("recalibrate", flag.ExitOnError)
+	station, tz := stationFlags(fs)
+	path := fs.String("db", localDB, "local sqlite database")
+	file := fs.String("calibration", "", "JSON list of sensor calibration versions")
+	from := fs.String("from", "", "start, YYYY-MM-DD or RFC3339")
+	to := fs.String("to", "", "end, YYYY-MM-DD or RFC3339 (defaults to now)")
+	dryRun := fs.Bool("dry-run", false, "count the observations that would change without writing them")
+	_ = fs.Parse(args)
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	first, err := climate.ParseTime(*from, location)
+	if err != nil {
+		return fmt.Errorf("invalid -from [%v]", *from)
+	}
+	last := time.Now().UTC()
+	if *to != "" {
+		if last, err = climate.ParseTime(*to, location); err != nil {
+			return fmt.Errorf("invalid -to [%v]", *to)
+		}
+	}
+	if *file == "" {
+		return fmt.Errorf("-calibration is required")
+	}
+	set, err := calibration.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	db, err := openLocal(*path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	q := sqlite.New(db)
+	ctx := context.Background()
+
+	// new versions are recorded before any observation names them
+	if !*dryRun {
+		if err := calibration.Record(ctx, q, *station, set); err != nil {
+			return err
+		}
+	}
+	recorded, err := calibration.Recorded(ctx, q, *station)
+	if err != nil {
+		return err
+	}
+	// a dry run hasn't recorded the new versions
+	for _, v := range set {
+		if _, ok := recorded.Find(v.Version); !ok {
+			recorded = append(recorded, v)
+		}
+	}
+
+	summariser := climate.NewSummariser(q, *station, location)
+	changed := 0
+	for day := climate.DayOf(first, location); !day.After(climate.DayOf(last, location)); day = climate.NextDay(day) {
+		start, end := climate.DayBounds(day, location)
+		// the rain before the day is read for the intensity it fell at
+		rows, err := q.GetRecordsBetween(ctx, postgres.GetRecordsBetweenParams{
+			StationID: *station,
+			FromTime:  start.Add(-calibration.RainIntensityWindow),
+			ToTime:    end,
+		})
+		if err != nil {
+			return err
+		}
+		rain, err := rainObservations(rows, recorded, set, first, last)
+		if err != nil {
+			return err
+		}
+		calibration.RecalibrateRain(rain, start)
+		n := 0
+		for i, row := range rows {
+			if !row.ObservedAt.After(start) || row.ObservedAt.Before(first) || row.ObservedAt.After(last) {
+				continue
+			}
+			update, ok, err := recalibrate(row, recorded, set.At(row.ObservedAt), rain[i])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			n++
+			if *dryRun {
+				continue
+			}
+			if err := q.UpdateCalibratedRecord(ctx, update); err != nil {
+				return err
+			}
+		}
+		if n > 0 && !*dryRun {
+			if err := summariser.SummariseDay(ctx, day); err != nil {
+				return err
+			}
+		}
+		changed += n
+	}
+	logger.Infof("Recalibrated %d observations", changed)
+	if changed == 0 || *dryRun {
+		return nil
+	}
+	// a running station keeps the records it loaded until it restarts
+	return climate.NewRecordTracker(q, *station, location).Recompute(ctx)
+}
+
+// rainObservations are the rows' rain for calibration.RecalibrateRain. Rows
+// outside first to last, or whose rain was missing, keep their version so
+// are left as they are.
+func rainObservations(rows []postgres.Weather, recorded calibration.Set, set calibration.Set, first time.Time, last time.Time) ([]calibration.RainObservation, error) {
+	obs := make([]calibration.RainObservation, len(rows))
+	for i, row := range rows {
+		was, ok := recorded.Find(int(row.CalibrationVersion.Int32))
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown calibration version %d", row.ObservedAt, row.CalibrationVersion.Int32)
+		}
+		flags, err := qc.ParseFlags(row.QcFlags.String)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", row.ObservedAt, err)
+		}
+		o := calibration.RainObservation{
+			Time:    row.ObservedAt,
+			Raw:     row.RainMm,
+			Rain:    row.RainMm,
+			Rate:    row.RainRate.Float64,
+			Day:     row.RainDay.Float64,
+			Was:     was,
+			Version: set.At(row.ObservedAt),
+		}
+		// before the raw total was kept nothing was corrected for intensity
+		if row.RainRawMm.Valid {
+			o.Raw = row.RainRawMm.Float64
+		}
+		if row.ObservedAt.Before(first) || row.ObservedAt.After(last) || flags[qc.RainDay] == qc.Missing {
+			o.Version = was
+		}
+		obs[i] = o
+	}
+	return obs, nil
+}
+
+// recalibrate converts an observation from the version it was recorded with
+// to v, with its rain from calibration.RecalibrateRain. False if nothing
+// changed. Values flagged missing were never read, so they are left alone.
+func recalibrate(row postgres.Weather, recorded calibration.Set, v *calibration.Version, rain calibration.RainObservation) (postgres.UpdateCalibratedRecordParams, bool, error) {
+	was, ok := recorded.Find(int(row.CalibrationVersion.Int32))
+	if !ok {
+		return postgres.UpdateCalibratedRecordParams{}, false, fmt.Errorf("%v: unknown calibration version %d", row.ObservedAt, row.CalibrationVersion.Int32)
+	}
+	if was.Number() == v.Number() && rain.Day == row.RainDay.Float64 {
+		return postgres.UpdateCalibratedRecordParams{}, false, nil
+	}
+	flags, err := qc.ParseFlags(row.QcFlags.String)
+	if err != nil {
+		return postgres.UpdateCalibratedRecordParams{}, false, fmt.Errorf("%v: %w", row.ObservedAt, err)
+	}
+	convert := func(variable qc.Variable, q calibration.Quantity, y float64) float64 {
+		if flags[variable] == qc.Missing {
+			return y
+		}
+		x, ok := was.Invert(q, y)
+		if !ok {
+			logger.Warnf("%v: can't invert the %v calibration at %v, left as is", row.ObservedAt, q, y)
+			return y
+		}
+		return v.Apply(q, x)
+	}
+	nullable := func(variable qc.Variable, q calibration.Quantity, y sql.NullFloat64) sql.NullFloat64 {
+		if y.Valid {
+			y.Float64 = convert(variable, q, y.Float64)
+		}
+		return y
+	}
+	wind := func(variable qc.Variable, y sql.NullFloat64) sql.NullFloat64 {
+		// calm stays calm
+		if y.Float64 == 0 {
+			return y
+		}
+		return nullable(variable, calibration.WindSpeed, y)
+	}
+	rebuilt := func(variable qc.Variable, y sql.NullFloat64, rebuilt float64) sql.NullFloat64 {
+		if y.Valid && flags[variable] != qc.Missing {
+			y.Float64 = rebuilt
+		}
+		return y
+	}
+	temperature := calibration.Temperature
+	if row.TemperatureSource.String == sensors.SourceBME280 {
+		temperature = calibration.TemperatureSecondary
+	}
+
+	u := postgres.UpdateCalibratedRecordParams{
+		StationID:          row.StationID,
+		ObservedAt:         row.ObservedAt,
+		Temperature:        nullable(qc.Temperature, temperature, row.Temperature),
+		Pressure:           nullable(qc.Pressure, calibration.Pressure, row.Pressure),
+		RainMm:             rain.Rain,
+		WindSpeed:          wind(qc.WindSpeed, row.WindSpeed),
+		WindGust:           wind(qc.WindGust, row.WindGust),
+		Humidity:           nullable(qc.Humidity, calibration.Humidity, row.Humidity),
+		DewPoint:           row.DewPoint,
+		Mslp:               row.Mslp,
+		RainRate:           rebuilt(qc.RainRate, row.RainRate, rain.Rate),
+		RainDay:            rebuilt(qc.RainDay, row.RainDay, rain.Day),
+		CalibrationVersion: calibration.NullVersion(v),
+		RainRawMm:          rebuilt(qc.RainDay, row.RainRawMm, rain.Raw),
+	}
+	if u.Humidity.Valid {
+		u.Humidity.Float64 = max(0, min(100, u.Humidity.Float64))
+	}
+	// derived the way prepData derives them
+	if u.DewPoint.Valid && u.Humidity.Valid && u.Temperature.Valid {
+		u.DewPoint.Float64 = u.Temperature.Float64 - ((100 - u.Humidity.Float64) / 5.0)
+	}
+	if u.Mslp.Valid && u.Pressure.Valid && u.Temperature.Valid {
+		u.Mslp.Float64 = env.SeaLevelPressure(u.Pressure.Float64, u.Temperature.Float64)
+	}
+	return u, true, nil
+}
@@ -2,24 +2,23 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
 	"github.com/google/go-querystring/query"
+	"github.com/pointer2null/weather/calibration"
+	"github.com/pointer2null/weather/climate"
 	"github.com/pointer2null/weather/db/postgres"
 	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/qc"
 
 	logger "github.com/sirupsen/logrus"
 )
 
-const Rd = 287.1
-const g = 9.807  // gravity
-const z0 = 24.71 // River aOD is 16.61, river height at 4.1m is level with the road and I'm 3m above that
-const kelvin = 273.1
-
 /*
 
 https://wow.metoffice.gov.uk/support/dataformats
@@ -83,6 +82,16 @@ type weatherData struct {
 	WindDir      float64 `url:"winddir,omitempty"`
 	WindSpeedMph float64 `url:"windspeedmph,omitempty"`
 	WindGustMph  float64 `url:"windgustmph,omitempty"`
+	// recorded in the db only
+	DewPointC    float64 `url:"-"`
+	MslpHpa      float64 `url:"-"`
+	RainRateMMHr float64 `url:"-"`
+	RainDayMM    float64 `url:"-"`
+	RainRawMM    float64 `url:"-"`
+	RainHourMM   float64 `url:"-"`
+	TempSource   string  `url:"-"`
+	// values quality control flagged, which are recorded but not uploaded
+	Flags qc.Flags `url:"-"`
 }
 
 // Reporting called as a go routine:
@@ -111,15 +120,19 @@ func (w *weatherstation) Reporting() {
 	for t := range time.Tick(duration) {
 		func() {
 			data, msg := w.prepData()
+			w.check(t, data)
 
 			vals, _ := query.Values(data)
+			omitFlagged(vals, data.Flags)
 
 			if *w.args.Verbose {
 				logger.Infof("Sensor data: %v", msg)
 			}
-			if *w.args.Imuon {
-				x, y, z := w.s.IMU.ReadAccel(true)
-				logger.Infof("IMU x [%v], y [%v], z [%v]", x, y, z)
+			if !*w.args.Test {
+				w.records.Observe(t, w.recordValues(data))
+			}
+			if imu := w.sampler.Latest().IMU; *w.args.Imuon && imu != nil {
+				logger.Infof("IMU x [%v], y [%v], z [%v]", imu.X, imu.Y, imu.Z)
 			}
 			if *w.args.Test {
 				// flash LED's only
@@ -130,31 +143,44 @@ func (w *weatherstation) Reporting() {
 				}
 			} else if t.Minute()%env.ReportFreqMin == 0 {
 
-				// if *w.args.RainEnabled {
-				// 	// get the rain accumulation since we last reported it
-				// 	data.RainIn = mmToIn(w.s.Rain.GetAccumulation().Float64())
-				// 	Prom_rainRatePerMin.Set(w.s.Rain.GetAccumulation().Float64())
-				// 	data.RainDayIn = mmToIn(w.s.Rain.GetDayAccumulation().Float64())
-				// 	if t.Minute() == 0 && t.Hour() == 9 {
-				// 		// reset daily rain accumulation
-				// 		w.s.Rain.ResetDayAccumulation()
-				// 		Prom_rainDayTotal.Set(0)
-				// 	}
-				// }
+				if *w.args.RainEnabled {
+					// rain since the last record
+					rain, raw := w.s.Rain.GetAccumulation()
+					data.RainMM, data.RainRawMM = rain.Float64(), raw.Float64()
+				}
 				// write data to db
 				logger.Info("Saving record to db")
 				err := w.Db.WriteRecord(context.Background(), postgres.WriteRecordParams{
-					Temperature:   data.TempC,
-					Pressure:      data.PressureHpa,
-					RainMm:        data.RainMM,
-					WindSpeed:     data.WindSpeedMph,
-					WindGust:      data.WindGustMph,
-					WindDirection: data.WindDir,
+					StationID:          *w.args.StationID,
+					ObservedAt:         t.UTC().Truncate(time.Minute),
+					Temperature:        nullFloat(data.TempC, data.present(*w.args.AtmosphericEnabled, qc.Temperature)),
+					Pressure:           nullFloat(data.PressureHpa, data.present(*w.args.AtmosphericEnabled, qc.Pressure)),
+					RainMm:             data.RainMM,
+					WindSpeed:          nullFloat(data.WindSpeedMph, data.present(*w.args.WindEnabled, qc.WindSpeed)),
+					WindGust:           nullFloat(data.WindGustMph, data.present(*w.args.WindEnabled, qc.WindGust)),
+					WindDirection:      nullFloat(data.WindDir, data.present(*w.args.WindEnabled, qc.WindDirection)),
+					Humidity:           nullFloat(data.Humidity, data.present(*w.args.AtmosphericEnabled, qc.Humidity)),
+					DewPoint:           nullFloat(data.DewPointC, data.present(*w.args.AtmosphericEnabled, qc.DewPoint)),
+					Mslp:               nullFloat(data.MslpHpa, data.present(*w.args.AtmosphericEnabled, qc.Pressure)),
+					RainRate:           nullFloat(data.RainRateMMHr, data.present(*w.args.RainEnabled, qc.RainRate)),
+					RainDay:            nullFloat(data.RainDayMM, data.present(*w.args.RainEnabled, qc.RainDay)),
+					QcFlags:            sql.NullString{String: data.Flags.String(), Valid: len(data.Flags) > 0},
+					TemperatureSource:  sql.NullString{String: data.TempSource, Valid: data.TempSource != ""},
+					CalibrationVersion: calibration.NullVersion(w.cal.At(t)),
+					RainRawMm:          nullFloat(data.RainRawMM, *w.args.RainEnabled),
 				})
 				if err != nil {
 					logger.Errorf("Failed to write to db [%v]", err)
 				}
 
+				local := t.In(w.location)
+				if *w.args.RainEnabled && local.Hour() == env.ClimateDayStartHour && local.Minute() == 0 {
+					// the rain day ends at 09:00, after its final record has been written
+					w.s.Rain.ResetDayAccumulation()
+					Prom_rainDayTotal.Set(0)
+					Prom_rainDayRaw.Set(0)
+				}
+
 				if !(*w.args.NoWow) {
 					wowsiteid, idok := os.LookupEnv("WOWSITEID")
 					wowpin, pinok := os.LookupEnv("WOWPIN")
@@ -187,7 +213,7 @@ func (w *weatherstation) Reporting() {
 
 // build the map with the required data
 func (w *weatherstation) prepData() (*weatherData, string) {
-	wd := weatherData{}
+	wd := weatherData{Flags: qc.Flags{}}
 	msg := ""
 	// Timestamp
 	// go magic date is Mon Jan 2 15:04:05 MST 2006
@@ -195,43 +221,26 @@ func (w *weatherstation) prepData() (*weatherData, string) {
 	wd.DateString = time.Now().UTC().Format("2006-01-02+15:04:05")
 	// system info
 	wd.SoftwareType = version
+	snap := w.sampler.Latest()
 
-	if *w.args.AtmosphericEnabled {
+	if atm := snap.Atmosphere; *w.args.AtmosphericEnabled && atm != nil {
 
-		tempC := w.s.Atm.GetTemperature().Float64()
+		tempC := atm.Temperature.Float64()
 		wd.TempC = tempC
+		wd.TempSource = atm.TemperatureSource
 		tempf := ctof(tempC)
 
 		Prom_temperature.Set(float64(tempC))
 
-		pressure, humidity := w.s.Atm.GetHumidityAndPressure()
+		pressure, humidity := atm.Pressure, atm.Humidity
 		wd.PressureHpa = pressure.Float64()
 
 		Prom_humidity.Set(humidity.Float64())
 
 		pressureInHg := pressure * env.HPaToInHg
 
-		/*
-			3. Convert the average temperature to Kelvin by adding 273.1 to the Celsius value.
-		*/
-
-		tempK := tempC + kelvin
-
-		/*
-			4. Compute the scale height H = RdT/g, where Rd = 287.1 J/(kg K) and g = 9.807 m/s2.
-			Be sure to record H to at least 4 significant figures.
-		*/
-
-		H := (Rd * tempK) / g
-
-		/*
-			5. Compute the sea level pressure psl from
-			psl = p0 exp(z0/H)
-			where p0 is the observed pressure and z0 is the altitude above sea level where you
-			made your pressure observation.
-		*/
-
-		mslp := pressureInHg.Float64() * math.Exp(z0/H)
+		mslp := env.SeaLevelPressure(pressureInHg.Float64(), tempC)
+		wd.MslpHpa = env.SeaLevelPressure(pressure.Float64(), tempC)
 		Prom_atmPresure.Set(pressure.Float64())
 
 		wd.Humidity = humidity.Float64()
@@ -239,32 +248,44 @@ func (w *weatherstation) prepData() (*weatherData, string) {
 		//Td = T - ((100 - RH)/5.)
 		dewPoint_f := ((((tempC + 273) - ((100 - (humidity.Float64())) / 5.0)) - 273) * 9 / 5.0) + 32
 		wd.DewPointF = dewPoint_f
+		wd.DewPointC = tempC - ((100 - humidity.Float64()) / 5.0)
 
 		wd.PressureIn = mslp
 		msg = fmt.Sprintf("Pressure [%2f], Humidity [%2f], Temperature [%2f]", pressure, humidity, tempC)
 	} else {
 		msg = msg + "Pressure [-], Humidity [-], Temperature [-]"
+		if *w.args.AtmosphericEnabled {
+			wd.missing(qc.Temperature, qc.Humidity, qc.Pressure, qc.DewPoint)
+		}
 	}
 
-	if *w.args.RainEnabled {
+	if rain := snap.Rain; *w.args.RainEnabled && rain != nil {
 		// we have to work out the values we send to the met office when we send it as they
 		// what amount since last sent
-		acc := w.s.Rain.GetDayAccumulation().Float64()
+		acc := rain.Day.Float64()
 		rainInch := mmToIn(acc)
 		wd.RainIn = rainInch
+		wd.RainDayMM = acc
+		wd.RainRateMMHr = rain.Rate.Float64()
+		wd.RainHourMM = rain.Hour.Float64()
+		Prom_rainRate.Set(rain.Rate.Float64())
 		Prom_rainDayTotal.Set(rainInch)
-		Prom_rainRatePerMin.Set(w.s.Rain.GetMinuteRate().Float64())
+		Prom_rainDayRaw.Set(mmToIn(rain.RawDay.Float64()))
+		Prom_rainRatePerMin.Set(rain.MinuteRate.Float64())
 		msg = msg + fmt.Sprintf(", Rain accumulation [%v]", acc)
 	} else {
 		msg = msg + ", Rain accumulation [-]"
+		if *w.args.RainEnabled {
+			wd.missing(qc.RainRate, qc.RainDay)
+		}
 	}
 
-	if *w.args.WindEnabled {
-		windDirection := w.s.Wind.GetDirection()
+	if wind := snap.Wind; *w.args.WindEnabled && wind != nil {
+		windDirection := wind.Direction
 		Prom_windDirection.Set(windDirection)
 
-		windSpeed := w.s.Wind.GetSpeed()
-		windGust := w.s.Wind.GetGust()
+		windSpeed := wind.Speed
+		windGust := wind.Gust
 
 		Prom_windspeed.Set(windSpeed)
 		Prom_windgust.Set(windGust)
@@ -272,14 +293,138 @@ func (w *weatherstation) prepData() (*weatherData, string) {
 		wd.WindDir = windDirection
 		wd.WindSpeedMph = windSpeed
 		wd.WindGustMph = windGust
-		msg = msg + fmt.Sprintf(", Dir [%2f] (%v), Speed [%2f] Gust [%2f]", windDirection, w.s.Wind.DirStr, windSpeed, windGust)
+		msg = msg + fmt.Sprintf(", Dir [%2f] (%v), Speed [%2f] Gust [%2f]", windDirection, wind.Compass, windSpeed, windGust)
 	} else {
 		msg = msg + ", Dir [-], Speed [-], Gust [-]"
+		if *w.args.WindEnabled {
+			wd.missing(qc.WindSpeed, qc.WindGust, qc.WindDirection)
+		}
 	}
 
 	return &wd, msg
 }
 
+// missing flags values an enabled sensor didn't give, so the zeros in their
+// place are neither uploaded nor taken for readings
+func (wd *weatherData) missing(vs ...qc.Variable) {
+	for _, v := range vs {
+		wd.Flags.Raise(v, qc.Missing)
+	}
+}
+
+// present is true if the sensor is on and v was read
+func (wd *weatherData) present(enabled bool, v qc.Variable) bool {
+	return enabled && wd.Flags[v] != qc.Missing
+}
+
+// qcReadings picks out the values quality control checks, from the sensors
+// that are on and were read
+func (w *weatherstation) qcReadings(data *weatherData) map[qc.Variable]float64 {
+	readings := make(map[qc.Variable]float64)
+	if data.present(*w.args.AtmosphericEnabled, qc.Temperature) {
+		readings[qc.Temperature] = data.TempC
+		readings[qc.Humidity] = data.Humidity
+		readings[qc.Pressure] = data.PressureHpa
+		readings[qc.DewPoint] = data.DewPointC
+	}
+	if data.present(*w.args.WindEnabled, qc.WindSpeed) {
+		readings[qc.WindSpeed] = data.WindSpeedMph
+		readings[qc.WindGust] = data.WindGustMph
+		readings[qc.WindDirection] = data.WindDir
+	}
+	if data.present(*w.args.RainEnabled, qc.RainRate) {
+		readings[qc.RainRate] = data.RainRateMMHr
+		readings[qc.RainDay] = data.RainDayMM
+	}
+	return readings
+}
+
+// check flags the values in data, every minute so the step and persistence
+// checks see each reading
+func (w *weatherstation) check(t time.Time, data *weatherData) {
+	flags := w.qc.Check(t, w.qcReadings(data))
+	for v, flag := range data.Flags {
+		flags.Raise(v, flag)
+	}
+	if w.tempDrift.Load() && data.present(*w.args.AtmosphericEnabled, qc.Temperature) {
+		// one of the two sensors is wrong, but there's no telling which
+		flags.Raise(qc.Temperature, qc.Suspect)
+	}
+	data.Flags = flags
+	if len(data.Flags) == 0 {
+		return
+	}
+	for v, flag := range data.Flags {
+		Prom_qcFlags.WithLabelValues(string(v), flag.String()).Inc()
+	}
+	logger.Warnf("Quality control flagged [%v]", data.Flags.Summary())
+}
+
+// wowFields are the upload keys that each checked variable goes into
+var wowFields = map[qc.Variable][]string{
+	qc.Temperature:   {"tempf", "dewptf"},
+	qc.Humidity:      {"humidity", "dewptf"},
+	qc.Pressure:      {"baromin"},
+	qc.DewPoint:      {"dewptf"},
+	qc.WindSpeed:     {"windspeedmph"},
+	qc.WindGust:      {"windgustmph"},
+	qc.WindDirection: {"winddir"},
+	qc.RainDay:       {"dailyrainin", "rainin"},
+}
+
+// omitFlagged leaves flagged values out of the upload
+func omitFlagged(vals url.Values, flags qc.Flags) {
+	for v := range flags {
+		for _, key := range wowFields[v] {
+			vals.Del(key)
+		}
+	}
+}
+
+// recordVariables are the checked variables behind each station record
+var recordVariables = map[climate.Metric]qc.Variable{
+	climate.TemperatureHigh: qc.Temperature,
+	climate.TemperatureLow:  qc.Temperature,
+	climate.PressureHigh:    qc.Pressure,
+	climate.PressureLow:     qc.Pressure,
+	climate.WindGust:        qc.WindGust,
+	climate.RainRate:        qc.RainRate,
+	climate.WettestDay:      qc.RainDay,
+	climate.WettestHour:     qc.RainDay,
+}
+
+// recordValues picks out the values that station records are kept for,
+// leaving out flagged ones so a bad reading can't set a record
+func (w *weatherstation) recordValues(data *weatherData) map[climate.Metric]float64 {
+	values := make(map[climate.Metric]float64)
+	if *w.args.AtmosphericEnabled {
+		values[climate.TemperatureHigh] = data.TempC
+		values[climate.TemperatureLow] = data.TempC
+		values[climate.PressureHigh] = data.PressureHpa
+		values[climate.PressureLow] = data.PressureHpa
+	}
+	if *w.args.WindEnabled {
+		values[climate.WindGust] = data.WindGustMph
+	}
+	if *w.args.RainEnabled {
+		values[climate.RainRate] = data.RainRateMMHr
+		values[climate.WettestDay] = data.RainDayMM
+		// the 60 minutes to now, as ComputeRecords finds it
+		values[climate.WettestHour] = data.RainHourMM
+	}
+	for m, v := range recordVariables {
+		if data.Flags.Flagged(v) {
+			delete(values, m)
+		}
+	}
+	return values
+}
+
+// nullFloat marks values from a disabled or unread sensor as NULL in the db
+func nullFloat(v float64, valid bool) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: valid}
+}
+
 func ctof(c float64) float64 {
 	//(0°C × 9/5) + 32 = 32°F
 	return ((c * 9 / 5) + 32)
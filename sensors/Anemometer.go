@@ -1,9 +1,12 @@
 package sensors
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pointer2null/weather/buffer"
+	"github.com/pointer2null/weather/calibration"
 	"github.com/pointer2null/weather/env"
 	logger "github.com/sirupsen/logrus"
 	"periph.io/x/periph/conn/i2c"
@@ -20,38 +23,35 @@ type Anemometer struct {
 	DirStr   string
 	masthead *i2c.Dev
 	args     env.Args
-}
+	cal      calibration.Set
+	vane     calibration.Vane
+
+	mastheadHealth *health
+	vaneHealth     *health
 
-var lastVal float64 = 0
+	sampleLock sync.Mutex
+	onSample   []func(t time.Time, pulses uint32, direction float64)
+}
 
 func NewAnemometer(bus *i2c.Bus, args env.Args) *Anemometer {
 	a := &Anemometer{}
 	a.args = args
 	a.Bus = bus
+	a.vane = calibration.DefaultVane
+	a.mastheadHealth = newHealth("masthead", a.openMasthead)
+	a.vaneHealth = newHealth("vane", a.openVane)
 
 	logger.Infof("Starting Masthead I2C [%x] Speed test flag is %v", env.MastHead, *a.args.Speedon)
-	a.masthead = &i2c.Dev{Addr: env.MastHead, Bus: *bus}
-
-	logger.Infof("Starting Wind direction ADC I2C [%x] Dir test flag is %v", ads1x15.DefaultOpts.I2cAddress, *a.args.Diron)
-	// Create a new ADS1115 ADC.
-	adc, err := ads1x15.NewADS1115(*a.Bus, &ads1x15.DefaultOpts)
-	if err != nil {
-		logger.Error(err)
+	if err := a.openMasthead(*bus); err != nil {
+		logger.Errorf("Masthead did not respond [%v]", err)
 		return nil
 	}
 
-	// Obtain an analog pin from the ADC.
-	dirPin, err := adc.PinForChannel(ads1x15.Channel3, 5*physic.Volt, 1*physic.Hertz, ads1x15.SaveEnergy)
-	if err != nil {
+	logger.Infof("Starting Wind direction ADC I2C [%x] Dir test flag is %v", ads1x15.DefaultOpts.I2cAddress, *a.args.Diron)
+	if err := a.openVane(*bus); err != nil {
 		logger.Error(err)
 		return nil
 	}
-	a.dirADC = &dirPin
-	// check connection
-	if err := a.masthead.Tx([]byte{0x00}, make([]byte, 4)); err != nil {
-		logger.Errorf("Masthead did not respond [%v]", err)
-		return nil
-	}
 
 	sps := env.WindSamplesPerSecond
 	if *a.args.Test {
@@ -63,46 +63,91 @@ func NewAnemometer(bus *i2c.Bus, args env.Args) *Anemometer {
 	a.gustBuf = buffer.NewBuffer(sps * env.WindBufferLengthSeconds)
 	a.dirBuf = buffer.NewBuffer(sps * env.WindBufferLengthSeconds)
 
-	a.monitorWindGPIO()
-
 	return a
 }
 
-func (a *Anemometer) monitorWindGPIO() {
-	logger.Info("Starting wind sensor")
+func (a *Anemometer) openMasthead(bus i2c.Bus) error {
+	a.Bus = &bus
+	a.masthead = &i2c.Dev{Addr: env.MastHead, Bus: bus}
+	// check connection
+	return a.masthead.Tx([]byte{0x00}, make([]byte, 4))
+}
 
-	period := time.Millisecond * (time.Second / time.Millisecond / env.WindSamplesPerSecond)
-	if *a.args.Test {
-		logger.Info("Wind sensor period set to 1 second for test")
-		period = time.Second * 1
+func (a *Anemometer) openVane(bus i2c.Bus) error {
+	dirPin, err := vanePin(bus)
+	if err != nil {
+		return err
 	}
+	a.dirADC = dirPin
+	return nil
+}
 
-	go func() {
-		// record the count every 250ms
-		write := []byte{0x00} // we don't need to send any command
-		read := make([]byte, 2)
-		for range time.Tick(period) {
-			if err := a.masthead.Tx(write, read); err != nil {
-				logger.Errorf("Failed to request count from masthead [%v]", err)
-			}
-			pulseCount := uint32(read[0])
-			if pulseCount > 25 {
-				logger.Errorf("Pulse count error [%v] [%v] [%b]", pulseCount, read, read)
-				pulseCount = 0
-			}
-			a.speedBuf.AddItem(float64(pulseCount))
-			a.gustBuf.AddItem(float64(pulseCount))
-			if pulseCount > 0 || *a.args.Diron {
-				a.dirBuf.AddItem(a.readDirection())
-			} else {
-				// if we have no wind the dir is garbage
-				a.dirBuf.AddItem(a.dirBuf.GetLast())
-			}
-			if *a.args.Speedon {
-				logger.Infof("MPH raw [%.2f], calc [%v] Count read [%v]", (float64(pulseCount) * env.MphPerTick), a.GetSpeed(), pulseCount)
-			}
-		}
-	}()
+// vanePin is the ADS1115 channel the vane's resistor network is on
+func vanePin(bus i2c.Bus) (*ads1x15.PinADC, error) {
+	// Create a new ADS1115 ADC.
+	adc, err := ads1x15.NewADS1115(bus, &ads1x15.DefaultOpts)
+	if err != nil {
+		return nil, err
+	}
+	// Obtain an analog pin from the ADC.
+	dirPin, err := adc.PinForChannel(ads1x15.Channel3, 5*physic.Volt, 1*physic.Hertz, ads1x15.SaveEnergy)
+	if err != nil {
+		return nil, err
+	}
+	return &dirPin, nil
+}
+
+// sample reads the pulses counted since the last sample and the vane, and
+// returns the pulse count. The sampler calls it 4 times a second. A failed or
+// garbled read is left out, rather than counted as calm.
+func (a *Anemometer) sample(t time.Time) uint32 {
+	pulseCount, err := readPulses(a.masthead)
+	if err != nil {
+		a.mastheadHealth.fail(err)
+		return 0
+	}
+	a.mastheadHealth.ok(t)
+	a.speedBuf.AddItem(float64(pulseCount))
+	a.gustBuf.AddItem(float64(pulseCount))
+	dir := a.dirBuf.GetLast()
+	if pulseCount > 0 || *a.args.Diron {
+		dir = a.readDirection(t)
+	}
+	// if we have no wind the dir is garbage, so the last one is kept
+	a.dirBuf.AddItem(dir)
+	a.sampleLock.Lock()
+	onSample := a.onSample
+	a.sampleLock.Unlock()
+	for _, f := range onSample {
+		f(t, pulseCount, dir)
+	}
+	if *a.args.Speedon {
+		logger.Infof("MPH raw [%.2f], calc [%v] Count read [%v]", (float64(pulseCount) * env.MphPerTick), a.GetSpeed(), pulseCount)
+	}
+	return pulseCount
+}
+
+// readPulses reads the pulses the masthead has counted since it was last
+// read
+func readPulses(masthead *i2c.Dev) (uint32, error) {
+	write := []byte{0x00} // we don't need to send any command
+	read := make([]byte, 2)
+	if err := masthead.Tx(write, read); err != nil {
+		return 0, fmt.Errorf("failed to request count from masthead: %w", err)
+	}
+	pulseCount := uint32(read[0])
+	if pulseCount > env.MaxPulsesPerSample {
+		return 0, fmt.Errorf("pulse count error [%v] [%b]", pulseCount, read)
+	}
+	return pulseCount, nil
+}
+
+// OnSample adds a function to receive every raw sample, 4 times a second.
+// It is called from the sampling loop so must not block.
+func (a *Anemometer) OnSample(f func(t time.Time, pulses uint32, direction float64)) {
+	a.sampleLock.Lock()
+	defer a.sampleLock.Unlock()
+	a.onSample = append(a.onSample, f)
 }
 
 func (a *Anemometer) GetSpeed() float64 { // WindBufferLengthSeconds min rolling average
@@ -116,13 +161,8 @@ func (a *Anemometer) GetSpeed() float64 { // WindBufferLengthSeconds min rolling
 		ticksPerSec = 0
 	}
 	// so the avg speed for the last WindBufferLengthSeconds seconds is...
-	speed := env.MphPerTick * float64(ticksPerSec)
-	if speed > 100 {
-		d, _, p := a.speedBuf.GetRawData()
-		logger.Errorf("Speed valculation error [%v] pos [%v]\n%v", speed, p, d)
-		speed = 0
-	}
-	return speed
+	// implausible speeds are flagged by qc rather than dropped here
+	return a.cal.WindSpeed(time.Now(), env.MphPerTick*float64(ticksPerSec))
 }
 
 func (a *Anemometer) GetGust() float64 { // "the maximum three second average wind speed occurring in any period (10 min)"
@@ -143,16 +183,9 @@ func (a *Anemometer) GetGust() float64 { // "the maximum three second average wi
 			threeSecMax = x
 		}
 	}
-	// we still occasionally get stupid values (500MPH)
-	// these are either caused by em interference or by
-	// switch bounce. Either way we need to filter them out
-	// until we can find the root cause and remove it.
-	val := (threeSecMax / threeSecond) * env.MphPerTick
-	if val > 120 {
-		val = lastVal
-	}
-	lastVal = val
-	return val
+	// the odd impossible gust, from em interference or switch bounce, is
+	// flagged by qc rather than replaced here
+	return a.cal.WindSpeed(time.Now(), (threeSecMax/threeSecond)*env.MphPerTick)
 }
 
 func getWrappedIndex(x int, size int) int {
@@ -167,13 +200,14 @@ func (a *Anemometer) GetDirection() float64 {
 	return float64(avg)
 }
 
-func (a *Anemometer) readDirection() float64 {
+func (a *Anemometer) readDirection(t time.Time) float64 {
 	sample, err := (*a.dirADC).Read()
 	if err != nil {
-		logger.Debugf("Error reading wind direction value [%v]", err)
+		a.vaneHealth.fail(err)
 		return a.dirBuf.GetLast()
 	}
-	deg, str := voltToDegrees(float64(sample.V) / float64(physic.Volt))
+	a.vaneHealth.ok(t)
+	deg, str := a.vane.Direction(float64(sample.V) / float64(physic.Volt))
 	a.DirStr = str
 	if *a.args.Diron {
 		logger.Infof("Volts [%v], Deg [%v] : %s", float64(sample.V)/float64(physic.Volt), deg, str)
@@ -181,45 +215,6 @@ func (a *Anemometer) readDirection() float64 {
 	return deg
 }
 
-func voltToDegrees(v float64) (float64, string) {
-	// this is based on actual measurements of output voltage for each cardinal point
-	// threhold voltage is midway between the two recorded values.
-	switch {
-	case v < 0.376:
-		return 112.5, "ESE"
-	case v < 0.441:
-		return 67.5, "ENE"
-	case v < 0.548:
-		return 90.0, "E"
-	case v < 0.775:
-		return 157.5, "SSE"
-	case v < 1.069:
-		return 135.0, "SE"
-	case v < 1.324:
-		return 202.5, "SSW"
-	case v < 1.726:
-		return 180.0, "S"
-	case v < 2.161:
-		return 22.5, "NNE"
-	case v < 2.64:
-		return 45.0, "NE"
-	case v < 3.055:
-		return 247.5, "WSW"
-	case v < 3.315:
-		return 225.0, "SW"
-	case v < 3.705:
-		return 337.5, "NNW"
-	case v < 4.013:
-		return 0, "N"
-	case v < 4.258:
-		return 292.5, "WNW"
-	case v < 4.550:
-		return 315.0, "NW"
-	default:
-		return 270.0, "W"
-	}
-}
-
 /*
 Measuring gusts and wind intensity
 
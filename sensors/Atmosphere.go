@@ -2,8 +2,11 @@ package sensors
 
 import (
 	"flag"
+	"fmt"
 	"math"
+	"time"
 
+	"github.com/pointer2null/weather/calibration"
 	"github.com/pointer2null/weather/env"
 	logger "github.com/sirupsen/logrus"
 	"periph.io/x/periph/conn/i2c"
@@ -17,6 +20,12 @@ const (
 	BMP280_I2C  = 0x76
 )
 
+// the sensors a temperature can come from
+const (
+	SourceMCP9808 = "mcp9808"
+	SourceBME280  = "bme280"
+)
+
 type PressurehPa float64
 type RelHumidity float64
 type TemperatureC float64
@@ -36,58 +45,97 @@ func (t TemperatureC) Float64() float64 {
 type atmosphere struct {
 	PH   *bmxx80.Dev  // BME280 Pressure & humidity
 	Temp *mcp9808.Dev // MCP9808 temperature sensor
+
+	tempAddr   int
+	tempHealth *health
+	phHealth   *health
+	// source is the sensor the last temperature came from
+	source string
+	cal    calibration.Set
 }
 
 func NewAtmosphere(bus *i2c.Bus, args env.Args) *atmosphere {
 	a := &atmosphere{}
+	a.source = SourceMCP9808
+	a.tempHealth = newHealth(SourceMCP9808, a.openTemp)
+	a.phHealth = newHealth(SourceBME280, a.openPH)
 
 	temperatureAddr := flag.Int("address", MCP9808_I2C, "I²C address")
+	a.tempAddr = *temperatureAddr
 	logger.Infof("Starting MCP9808 Temperature Sensor [%x]", MCP9808_I2C)
-	// Create a new temperature sensor with hig res
-	tempSensor, err := mcp9808.New(*bus, &mcp9808.Opts{Addr: *temperatureAddr, Res: mcp9808.High})
-	if err != nil {
+	if err := a.openTemp(*bus); err != nil {
 		logger.Errorf("Failed to open MCP9808 sensor: %v", err)
 		return nil
 	}
-	a.Temp = tempSensor
 
 	logger.Infof("Starting BMP280 reader [%x]", BMP280_I2C)
-	bme, err := bmxx80.NewI2C(*bus, BMP280_I2C, &bmxx80.DefaultOpts)
-	if err != nil {
+	if err := a.openPH(*bus); err != nil {
 		logger.Errorf("failed to initialize bme280: %v", err)
 		return nil
 	}
-	a.PH = bme
 
 	return a
 }
 
-func (a *atmosphere) GetHumidityAndPressure() (PressurehPa, RelHumidity) {
-	em := physic.Env{}
-	if a.PH != nil {
-		if err := a.PH.Sense(&em); err != nil {
-			logger.Errorf("BME280 read failed [%v]", err)
-			return 0, 0
-		}
-		// convert raw sensor output
-		humidity := RelHumidity(math.Round(float64(em.Humidity) / float64(physic.PercentRH)))
-		pressure := PressurehPa(math.Round((float64(em.Pressure)/float64(100*physic.Pascal))*100) / 100)
-
-		return pressure, humidity
+func (a *atmosphere) openTemp(bus i2c.Bus) error {
+	// Create a new temperature sensor with hig res
+	tempSensor, err := mcp9808.New(bus, &mcp9808.Opts{Addr: a.tempAddr, Res: mcp9808.High})
+	if err != nil {
+		return err
 	}
-	return 0, 0
+	a.Temp = tempSensor
+	return nil
+}
+
+func (a *atmosphere) openPH(bus i2c.Bus) error {
+	bme, err := bmxx80.NewI2C(bus, BMP280_I2C, &bmxx80.DefaultOpts)
+	if err != nil {
+		return err
+	}
+	a.PH = bme
+	return nil
 }
 
-func (a *atmosphere) GetTemperature() TemperatureC {
+// read takes a temperature from the MCP9808 and pressure, humidity and a
+// second temperature from the BME280, each calibrated. If the MCP9808 fails
+// the BME280's temperature is used instead. Only the sampler calls it.
+func (a *atmosphere) read(t time.Time) (*AtmosphereReading, error) {
 	hiT := physic.Env{}
-	if a.Temp != nil {
-		if err := a.Temp.Sense(&hiT); err != nil {
-			logger.Errorf("MCP9808 read failed [%v]", err)
-			return 0
-		}
-		temp := TemperatureC(hiT.Temperature.Celsius())
-
-		return temp
+	tempErr := a.Temp.Sense(&hiT)
+	if tempErr != nil {
+		a.tempHealth.fail(tempErr)
+	} else {
+		a.tempHealth.ok(t)
+	}
+	em := physic.Env{}
+	if err := a.PH.Sense(&em); err != nil {
+		a.phHealth.fail(err)
+		return nil, fmt.Errorf("BME280 read failed: %w", err)
+	}
+	a.phHealth.ok(t)
+	cal := a.cal.At(t)
+	// convert raw sensor output
+	humidity := cal.Apply(calibration.Humidity, float64(em.Humidity)/float64(physic.PercentRH))
+	pressure := cal.Apply(calibration.Pressure, float64(em.Pressure)/float64(100*physic.Pascal))
+	r := &AtmosphereReading{
+		Time:      t,
+		Secondary: TemperatureC(cal.Apply(calibration.TemperatureSecondary, em.Temperature.Celsius())),
+		// a gain can take humidity past saturation
+		Humidity: RelHumidity(math.Round(math.Max(0, math.Min(100, humidity)))),
+		Pressure: PressurehPa(math.Round(pressure*100) / 100),
+	}
+	source := SourceMCP9808
+	if tempErr != nil {
+		source = SourceBME280
+		r.Temperature = r.Secondary
+	} else {
+		primary := TemperatureC(cal.Apply(calibration.Temperature, hiT.Temperature.Celsius()))
+		r.Temperature, r.Primary = primary, &primary
+	}
+	if source != a.source {
+		logger.Warnf("Temperature now from the %v", source)
+		a.source = source
 	}
-	return 0
+	r.TemperatureSource = source
+	return r, nil
 }
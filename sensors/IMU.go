@@ -30,6 +30,8 @@ var (
 type IMU struct {
 	Sensor *i2c.Dev
 	Ok     bool
+
+	health *health
 }
 
 type xG float64
@@ -50,8 +52,9 @@ func (z zG) Float64() float64 {
 
 func NewIMU(bus *i2c.Bus, args env.Args) *IMU {
 	i := IMU{}
+	i.health = newHealth("imu", i.open)
 	// Create a connection to the MPU6050.
-	i.Sensor = &i2c.Dev{Addr: MPU6050_ADDRESS, Bus: *bus}
+	_ = i.open(*bus)
 	i.Ok = true
 	// Calibrate the accelerometer.
 	logger.Info("Calibrating IMU...")
@@ -66,11 +69,21 @@ func NewIMU(bus *i2c.Bus, args env.Args) *IMU {
 	return &i
 }
 
+// open only reconnects, the calibration is kept
+func (imu *IMU) open(bus i2c.Bus) error {
+	imu.Sensor = &i2c.Dev{Addr: MPU6050_ADDRESS, Bus: bus}
+	return nil
+}
+
 func (imu *IMU) calibrateAccel(samples int) {
 	var totalX, totalY, totalZ int64
 
 	for i := 0; i < samples; i++ {
-		accelX, accelY, accelZ := imu.readRawAccel(false)
+		accelX, accelY, accelZ, err := imu.readRawAccel(false)
+		if err != nil {
+			logger.Errorf("IMU read failed [%v]", err)
+			imu.Ok = false
+		}
 
 		totalX += int64(accelX)
 		totalY += int64(accelY)
@@ -87,14 +100,12 @@ func (imu *IMU) calibrateAccel(samples int) {
 	accelOffsetZ = int16(totalZ / int64(samples)) //- 16384 // Assumes sensor is flat, Z should be -1g
 }
 
-func (imu *IMU) readRawAccel(verbose bool) (int16, int16, int16) {
+func (imu *IMU) readRawAccel(verbose bool) (int16, int16, int16, error) {
 	write := []byte{ACCEL_XOUT_H}
 	read := make([]byte, 8)
 
 	if err := imu.Sensor.Tx(write, read); err != nil {
-		logger.Errorf("IMU read failed [%v]", err)
-		imu.Ok = false
-		return -1, -1, -1
+		return -1, -1, -1, err
 	}
 
 	accelX := int16(read[0])<<8 | int16(read[1])
@@ -104,11 +115,16 @@ func (imu *IMU) readRawAccel(verbose bool) (int16, int16, int16) {
 		logger.Infof("IMU raw [%v] [%v] [%v]", accelX, accelY, accelZ)
 	}
 
-	return accelX, accelY, accelZ
+	return accelX, accelY, accelZ, nil
 }
 
-func (imu *IMU) ReadAccel(verbose bool) (xG, yG, zG) {
-	accelX, accelY, accelZ := imu.readRawAccel(verbose)
+// ReadAccel returns the filtered acceleration. A failed read leaves the
+// filter alone.
+func (imu *IMU) ReadAccel(verbose bool) (xG, yG, zG, error) {
+	accelX, accelY, accelZ, err := imu.readRawAccel(verbose)
+	if err != nil {
+		return xG(filteredAccelX), yG(filteredAccelY), zG(filteredAccelZ), err
+	}
 
 	accelX -= accelOffsetX
 	accelY -= accelOffsetY
@@ -119,5 +135,16 @@ func (imu *IMU) ReadAccel(verbose bool) (xG, yG, zG) {
 	filteredAccelY = math.Round((alpha*float64(accelY)+(1.0-alpha)*filteredAccelY)*100) / 100
 	filteredAccelZ = math.Round((alpha*float64(accelZ)+(1.0-alpha)*filteredAccelZ)*100) / 100
 
-	return xG(filteredAccelX), yG(filteredAccelY), zG(filteredAccelZ)
+	return xG(filteredAccelX), yG(filteredAccelY), zG(filteredAccelZ), nil
+}
+
+// read is ReadAccel for the sampler, keeping the IMU's health
+func (imu *IMU) read(t time.Time) (*IMUReading, error) {
+	x, y, z, err := imu.ReadAccel(false)
+	if err != nil {
+		imu.health.fail(err)
+		return nil, err
+	}
+	imu.health.ok(t)
+	return &IMUReading{Time: t, X: x, Y: y, Z: z}, nil
 }
@@ -1,25 +1,37 @@
 package sensors
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pointer2null/weather/buffer"
+	"github.com/pointer2null/weather/calibration"
 	"github.com/pointer2null/weather/env"
 	"github.com/pointer2null/weather/led"
 	logger "github.com/sirupsen/logrus"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/i2c"
-	"periph.io/x/periph/experimental/conn/gpio/gpioutil"
 )
 
 type rainmeter struct {
-	gpioPin         *gpio.PinIO // Rain bucket tip pin
-	dayAccumulation int64
-	accumulation    int64
+	gpioPin *gpio.PinIO // Rain bucket tip pin
+	// the totals, raw and corrected for intensity, and the last two tips,
+	// guarded by lock
+	lock            sync.Mutex
+	dayAccumulation rainTotal
+	accumulation    rainTotal
+	lastTip         time.Time
+	previousTip     time.Time
 	ledOut          *led.LED
 	tipBuf          *buffer.SampleBuffer
 	args            env.Args
+	cal             calibration.Set
+	filter          *TipFilter
+
+	tipLock sync.Mutex
+	onTip   []func(t time.Time, mm float64)
 }
 
 type mmHr float64
@@ -37,31 +49,31 @@ func (m mm) Float64() float64 {
 	return float64(m)
 }
 
-func toMM(v int64) mm {
-	return mm(v)
+// rainTotal is the rain from the tips at their calibrated volume, and the
+// same corrected for the intensity it fell at
+type rainTotal struct {
+	raw       float64
+	corrected float64
+}
+
+// mmPerTip is the calibrated volume of a tip in millimetres of rain
+func (r *rainmeter) mmPerTip() float64 {
+	return r.cal.At(time.Now()).Apply(calibration.Rain, env.MMPerBucketTip)
 }
 
-func NewRainmeter(bus *i2c.Bus, args env.Args) *rainmeter {
+// NewRainmeter starts counting tips. shake, if set, rejects tips while the
+// mast is shaking.
+func NewRainmeter(bus *i2c.Bus, args env.Args, shake *Shake) *rainmeter {
 	r := &rainmeter{}
 	r.args = args
 
-	// Lookup a rainpin by its number:
-	rp := gpioreg.ByName(env.RainSensorIn)
-	if rp == nil {
-		logger.Errorf("Failed to find %v - rain pin", env.RainSensorIn)
-		return nil
-	}
-
-	logger.Infof("Rain Pin: %s: %s", rp, rp.Function())
-
-	// Set up debounced pin
-	// Ignore glitches lasting less than 100ms, and ignore repeated edges within 500ms.
-	rainpin, err := gpioutil.Debounce(rp, 100*time.Millisecond, 500*time.Millisecond, gpio.FallingEdge)
+	rainpin, err := openRainPin()
 	if err != nil {
-		logger.Errorf("Failed to set debounce [%v]", err)
+		logger.Error(err)
 		return nil
 	}
 	r.gpioPin = &rainpin
+	r.filter = &TipFilter{Denoise: *args.RainDenoise, Debounce: *args.RainDebounce, shake: shake}
 
 	r.ledOut = led.NewLED("Rain Tip", env.RainTipLed)
 
@@ -71,29 +83,120 @@ func NewRainmeter(bus *i2c.Bus, args env.Args) *rainmeter {
 	return r
 }
 
+// openRainPin opens the bucket's pin for falling edges. A TipFilter
+// debounces them, as gpioutil.Debounce doesn't.
+func openRainPin() (gpio.PinIO, error) {
+	// Lookup a rainpin by its number:
+	rp := gpioreg.ByName(env.RainSensorIn)
+	if rp == nil {
+		return nil, fmt.Errorf("failed to find %v - rain pin", env.RainSensorIn)
+	}
+
+	logger.Infof("Rain Pin: %s: %s", rp, rp.Function())
+
+	if err := rp.In(gpio.PullNoChange, gpio.FallingEdge); err != nil {
+		return nil, fmt.Errorf("failed to watch the rain pin: %w", err)
+	}
+	return rp, nil
+}
+
+// rainRateTimeout is how long after a tip the rate falls to 0, and the
+// longest interval between tips that gives a rate, as on Davis consoles
+const rainRateTimeout = 15 * time.Minute
+
+// GetRate is the rain rate from the time between the last two tips, or
+// since the last tip once that is longer, so it decays toward zero while
+// no tip comes. It is corrected for its intensity.
 func (r *rainmeter) GetRate() mmHr {
+	r.lock.Lock()
+	previous, last := r.previousTip, r.lastTip
+	r.lock.Unlock()
+	now := time.Now()
+	raw := r.mmPerTip() * tipsPerHour(previous, last, now)
+	return toMMHr(raw * r.cal.At(now).RainFactor(raw))
+}
+
+// tipsPerHour is the tip rate at now from the last two tips
+func tipsPerHour(previous time.Time, last time.Time, now time.Time) float64 {
+	if previous.IsZero() || now.Sub(last) >= rainRateTimeout {
+		return 0
+	}
+	interval := last.Sub(previous)
+	if interval >= rainRateTimeout {
+		// the first tip after a dry spell
+		return 0
+	}
+	interval = max(interval, now.Sub(last))
+	if interval <= 0 {
+		return 0
+	}
+	return float64(time.Hour) / float64(interval)
+}
+
+// GetHourAccumulation is the rain over the last hour, corrected for its
+// intensity
+func (r *rainmeter) GetHourAccumulation() mm {
 	_, _, _, sum := r.tipBuf.GetAverageMinMaxSum()
-	return toMMHr(env.MMPerBucketTip * float64(sum))
+	raw := r.mmPerTip() * float64(sum)
+	return mm(raw * r.cal.At(time.Now()).RainFactor(raw))
 }
 
+// GetMinuteRate is the rain over the last minute
 func (r *rainmeter) GetMinuteRate() mm {
 	sum, _, _ := r.tipBuf.SumMinMaxLast(6) // last minute
-	return mm(int64(env.MMPerBucketTip * sum))
+	return mm(r.mmPerTip() * float64(sum))
+}
+
+// intensity is the uncorrected rate over the last
+// calibration.RainIntensityWindow, mm/h
+func (r *rainmeter) intensity() float64 {
+	sum, _, _ := r.tipBuf.SumMinMaxLast(int(calibration.RainIntensityWindow / (time.Second * 10)))
+	return r.mmPerTip() * float64(sum) * float64(time.Hour) / float64(calibration.RainIntensityWindow)
+}
+
+// tip adds a tip to the totals and returns its corrected amount
+func (r *rainmeter) tip(t time.Time) mm {
+	raw := r.mmPerTip()
+	corrected := raw * r.cal.At(t).RainFactor(r.intensity())
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.previousTip, r.lastTip = r.lastTip, t
+	r.dayAccumulation.raw += raw
+	r.dayAccumulation.corrected += corrected
+	r.accumulation.raw += raw
+	r.accumulation.corrected += corrected
+	return mm(corrected)
 }
 
-func (r *rainmeter) GetDayAccumulation() mm {
-	return toMM(r.dayAccumulation)
+// GetDayAccumulation is the corrected and the raw rain today
+func (r *rainmeter) GetDayAccumulation() (mm, mm) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return mm(r.dayAccumulation.corrected), mm(r.dayAccumulation.raw)
 }
 
 func (r *rainmeter) ResetDayAccumulation() {
-	r.dayAccumulation = 0
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.dayAccumulation = rainTotal{}
 }
 
-// returns the accumulation since last called.
-func (r *rainmeter) GetAccumulation() mm {
+// returns the corrected and the raw accumulation since last called.
+func (r *rainmeter) GetAccumulation() (mm, mm) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	a := r.accumulation
-	r.accumulation = 0
-	return toMM(a)
+	r.accumulation = rainTotal{}
+	return mm(a.corrected), mm(a.raw)
+}
+
+// OnTip adds a function to call with every bucket tip and its corrected
+// amount, after it has been counted. It is called from the gpio loop so
+// must not block.
+func (r *rainmeter) OnTip(f func(t time.Time, mm float64)) {
+	r.tipLock.Lock()
+	defer r.tipLock.Unlock()
+	r.onTip = append(r.onTip, f)
 }
 
 func (r *rainmeter) monitorRainGPIO() {
@@ -101,18 +204,26 @@ func (r *rainmeter) monitorRainGPIO() {
 	rainTip := 0
 	go func() {
 		defer func() { _ = (*r.gpioPin).Halt() }()
-		for {
-			(*r.gpioPin).WaitForEdge(-1)
-			if (*r.gpioPin).Read() == gpio.Low {
-				rainTip += 1           // for rates
-				r.dayAccumulation += 1 // for day
-				r.accumulation += 1    // for accumulations
+		r.filter.watch(*r.gpioPin, func(now time.Time, result EdgeResult) {
+			if result != EdgeAccepted {
 				if *r.args.Rainon {
-					logger.Infof("Bucket tip. [%v] @ %v", rainTip, time.Now().Format(time.ANSIC))
+					logger.Infof("Rain pin edge rejected, %v @ %v", result, now.Format(time.StampMilli))
 				}
-				r.ledOut.Flash()
+				return
 			}
-		}
+			rainTip += 1         // for rates
+			amount := r.tip(now) // for the day and accumulations
+			if *r.args.Rainon {
+				logger.Infof("Bucket tip. [%v] @ %v", rainTip, now.Format(time.ANSIC))
+			}
+			r.ledOut.Flash()
+			r.tipLock.Lock()
+			onTip := r.onTip
+			r.tipLock.Unlock()
+			for _, f := range onTip {
+				f(now, amount.Float64())
+			}
+		})
 	}()
 	go func() {
 		// record the count every ten seconds
@@ -123,6 +234,11 @@ func (r *rainmeter) monitorRainGPIO() {
 	}()
 }
 
+// EdgeCount is the number of edges on the rain pin with the result so far
+func (r *rainmeter) EdgeCount(result EdgeResult) uint64 {
+	return r.filter.Count(result)
+}
+
 func (r *rainmeter) GetLED() *led.LED {
 	return r.ledOut
 }
@@ -0,0 +1,31 @@
+package sensors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTipsPerHour(t *testing.T) {
+	t0 := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	// no tips, or only one
+	require.Equal(t, 0.0, tipsPerHour(time.Time{}, time.Time{}, at(0)))
+	require.Equal(t, 0.0, tipsPerHour(time.Time{}, at(0), at(10)))
+
+	// tips 2 minutes apart are 30 an hour until 2 minutes have passed
+	require.Equal(t, 30.0, tipsPerHour(at(0), at(120), at(120)))
+	require.Equal(t, 30.0, tipsPerHour(at(0), at(120), at(240)))
+	// then it decays
+	require.Equal(t, 15.0, tipsPerHour(at(0), at(120), at(360)))
+	// to nothing after 15 minutes
+	require.InDelta(t, 4.0, tipsPerHour(at(0), at(120), at(120+899)), 0.01)
+	require.Equal(t, 0.0, tipsPerHour(at(0), at(120), at(120+900)))
+
+	// light rain, a tip every 10 minutes, doesn't read 0
+	require.Equal(t, 6.0, tipsPerHour(at(0), at(600), at(660)))
+	// but the first tip after a dry spell gives no rate
+	require.Equal(t, 0.0, tipsPerHour(at(0), at(3600), at(3610)))
+}
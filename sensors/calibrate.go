@@ -0,0 +1,133 @@
+package sensors
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/experimental/devices/ads1x15"
+	"periph.io/x/periph/host"
+)
+
+// the devices the calibrate command opens on their own
+
+// VaneADC reads the vane's voltage on its own, for calibrating it while the
+// station is stopped
+type VaneADC struct {
+	closer i2c.BusCloser
+	pin    *ads1x15.PinADC
+}
+
+func OpenVaneADC(bus string) (*VaneADC, error) {
+	closer, err := openBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	pin, err := vanePin(closer)
+	if err != nil {
+		_ = closer.Close()
+		return nil, err
+	}
+	return &VaneADC{closer: closer, pin: pin}, nil
+}
+
+// Volts reads the vane once
+func (v *VaneADC) Volts() (float64, error) {
+	sample, err := (*v.pin).Read()
+	if err != nil {
+		return 0, err
+	}
+	return float64(sample.V) / float64(physic.Volt), nil
+}
+
+func (v *VaneADC) Close() error {
+	_ = (*v.pin).Halt()
+	return v.closer.Close()
+}
+
+// Masthead counts the anemometer's pulses on its own, for calibrating it
+// while the station is stopped
+type Masthead struct {
+	closer i2c.BusCloser
+	dev    *i2c.Dev
+}
+
+func OpenMasthead(bus string) (*Masthead, error) {
+	closer, err := openBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	m := &Masthead{closer: closer, dev: &i2c.Dev{Addr: env.MastHead, Bus: closer}}
+	// the first read clears the count so far
+	if _, err := m.Pulses(); err != nil {
+		_ = closer.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Pulses is the count since the last call
+func (m *Masthead) Pulses() (uint32, error) {
+	return readPulses(m.dev)
+}
+
+func (m *Masthead) Close() error {
+	return m.closer.Close()
+}
+
+// TipCounter counts the rain bucket's tips on its own, for calibrating it
+// while the station is stopped
+type TipCounter struct {
+	pin    gpio.PinIO
+	filter *TipFilter
+	tips   atomic.Int64
+}
+
+// OpenTipCounter starts counting with the station's debounce times. onTip
+// is called with the count after every tip, from the gpio loop.
+func OpenTipCounter(denoise time.Duration, debounce time.Duration, onTip func(tips int)) (*TipCounter, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, fmt.Errorf("failed to init gpio: %w", err)
+	}
+	pin, err := openRainPin()
+	if err != nil {
+		return nil, err
+	}
+	c := &TipCounter{pin: pin, filter: &TipFilter{Denoise: denoise, Debounce: debounce}}
+	go c.filter.watch(c.pin, func(t time.Time, r EdgeResult) {
+		if r == EdgeAccepted {
+			onTip(int(c.tips.Add(1)))
+		}
+	})
+	return c, nil
+}
+
+// Tips is the count since it was opened
+func (c *TipCounter) Tips() int {
+	return int(c.tips.Load())
+}
+
+// Rejected is the count of edges that weren't tips
+func (c *TipCounter) Rejected() uint64 {
+	return c.filter.Count(EdgeNoise) + c.filter.Count(EdgeBounce)
+}
+
+func (c *TipCounter) Close() error {
+	return c.pin.Halt()
+}
+
+func openBus(bus string) (i2c.BusCloser, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, fmt.Errorf("failed to init i2c bus: %w", err)
+	}
+	closer, err := i2creg.Open(bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I²C: %w", err)
+	}
+	return closer, nil
+}
@@ -0,0 +1,100 @@
+package sensors
+
+import (
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// Every I2C device counts its errors in a row. A few and it is degraded,
+// SensorFailedAfter and it has failed and the sampler opens it again. If that
+// doesn't help the whole bus is reopened.
+
+type State int
+
+const (
+	OK State = iota
+	Degraded
+	Failed
+)
+
+var stateNames = map[State]string{OK: "ok", Degraded: "degraded", Failed: "failed"}
+
+func (s State) String() string {
+	return stateNames[s]
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// DeviceHealth is a device's health when a snapshot was taken
+type DeviceHealth struct {
+	Device string `json:"device"`
+	State  State  `json:"state"`
+	// Consecutive is the errors since the device last worked
+	Consecutive int       `json:"consecutive_errors"`
+	Errors      int64     `json:"errors"`
+	Reopens     int64     `json:"reopens"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastOK      time.Time `json:"last_ok"`
+}
+
+// Worst is the state of the least healthy device
+func Worst(devices []DeviceHealth) State {
+	worst := OK
+	for _, d := range devices {
+		if d.State > worst {
+			worst = d.State
+		}
+	}
+	return worst
+}
+
+// health tracks one device. Only the sampler uses it.
+type health struct {
+	DeviceHealth
+	// open opens the device again on a bus, nil if it can't be
+	open func(bus i2c.Bus) error
+	// due is set when the device should be reopened
+	due bool
+	// reopens since the device last worked
+	tries int
+}
+
+func newHealth(device string, open func(bus i2c.Bus) error) *health {
+	return &health{DeviceHealth: DeviceHealth{Device: device}, open: open}
+}
+
+// ok records a good read
+func (h *health) ok(t time.Time) {
+	if h.State != OK {
+		logger.Infof("%v recovered after %d errors", h.Device, h.Consecutive)
+	}
+	h.State, h.Consecutive, h.LastOK, h.due, h.tries = OK, 0, t, false, 0
+}
+
+// fail records a failed read. Only changes of state are logged, a failed
+// masthead would otherwise log 4 times a second.
+func (h *health) fail(err error) {
+	h.Consecutive++
+	h.Errors++
+	h.LastError = err.Error()
+	was := h.State
+	switch {
+	case h.Consecutive >= env.SensorFailedAfter:
+		h.State = Failed
+	case h.Consecutive >= env.SensorDegradedAfter:
+		h.State = Degraded
+	}
+	if h.State != was {
+		logger.Warnf("%v %v after %d errors [%v]", h.Device, h.State, h.Consecutive, err)
+	} else {
+		logger.Debugf("%v read failed [%v]", h.Device, err)
+	}
+	if h.Consecutive%env.SensorFailedAfter == 0 {
+		h.due = true
+	}
+}
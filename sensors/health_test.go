@@ -0,0 +1,74 @@
+package sensors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	"github.com/stretchr/testify/require"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+)
+
+// fakeBus is an i2c.Bus that is never used, the opens here don't talk to it
+type fakeBus struct{}
+
+func (fakeBus) String() string                    { return "fake" }
+func (fakeBus) Tx(addr uint16, w, r []byte) error { return nil }
+func (fakeBus) SetSpeed(f physic.Frequency) error { return nil }
+
+func TestHealth(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	h := newHealth("mcp9808", nil)
+	h.ok(now)
+	require.Equal(t, OK, h.State)
+
+	failed := errors.New("remote I/O error")
+	for i := 1; i < env.SensorDegradedAfter; i++ {
+		h.fail(failed)
+	}
+	require.Equal(t, OK, h.State)
+	h.fail(failed)
+	require.Equal(t, Degraded, h.State)
+	for i := env.SensorDegradedAfter; i < env.SensorFailedAfter; i++ {
+		require.False(t, h.due)
+		h.fail(failed)
+	}
+	require.Equal(t, Failed, h.State)
+	require.True(t, h.due)
+	require.Equal(t, "remote I/O error", h.LastError)
+	require.Equal(t, int64(env.SensorFailedAfter), h.Errors)
+
+	h.ok(now.Add(time.Minute))
+	require.Equal(t, OK, h.State)
+	require.Zero(t, h.Consecutive)
+	require.False(t, h.due)
+	require.Equal(t, int64(env.SensorFailedAfter), h.Errors)
+	require.Equal(t, now.Add(time.Minute), h.LastOK)
+
+	require.Equal(t, Failed, Worst([]DeviceHealth{{State: Degraded}, {State: Failed}, {State: OK}}))
+	require.Equal(t, OK, Worst(nil))
+}
+
+func TestRecover(t *testing.T) {
+	opened := 0
+	h := newHealth("masthead", func(bus i2c.Bus) error {
+		opened++
+		return errors.New("still there")
+	})
+	s := &Sensors{bus: fakeBus{}, devices: []*health{h}}
+
+	// reopened each time it fails another SensorFailedAfter reads
+	for round := 1; round <= env.SensorBusResetAfter+1; round++ {
+		s.recover(time.Time{})
+		require.Equal(t, round-1, opened)
+		for i := 0; i < env.SensorFailedAfter; i++ {
+			h.fail(errors.New("remote I/O error"))
+		}
+	}
+	// without a bus to reopen it carries on with the device
+	s.recover(time.Time{})
+	require.Equal(t, env.SensorBusResetAfter+1, opened)
+	require.Equal(t, int64(opened), s.Health()[0].Reopens)
+}
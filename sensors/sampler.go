@@ -0,0 +1,199 @@
+package sensors
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+)
+
+// Snapshot is the latest reading from each sensor. A published snapshot is
+// never changed, so it can be shared without locking. A reading is nil if
+// its sensor is off, hasn't been read yet or has stopped answering, and
+// carries the time it was taken, which is older than the snapshot's if the
+// last reads failed.
+type Snapshot struct {
+	Time       time.Time
+	Atmosphere *AtmosphereReading
+	Wind       *WindReading
+	Rain       *RainReading
+	IMU        *IMUReading
+	// Health is every I2C device's health
+	Health []DeviceHealth
+}
+
+type AtmosphereReading struct {
+	Time time.Time
+	// Temperature is the MCP9808's, or the BME280's if it failed, as named
+	// by TemperatureSource
+	Temperature       TemperatureC
+	TemperatureSource string
+	// Primary is the MCP9808's temperature, nil if it failed, and Secondary
+	// the BME280's
+	Primary   *TemperatureC
+	Secondary TemperatureC
+	Pressure  PressurehPa
+	Humidity  RelHumidity
+}
+
+type WindReading struct {
+	Time      time.Time
+	Instant   float64 // mph over the last second
+	Speed     float64 // mph, rolling average
+	Gust      float64 // mph
+	Direction float64
+	Compass   string
+}
+
+type RainReading struct {
+	Time time.Time
+	// Rate is from the time between tips, Hour and MinuteRate are the rain
+	// over the last hour and minute
+	Rate       mmHr
+	Hour       mm
+	MinuteRate mm
+	// Day is corrected for intensity, RawDay isn't
+	Day    mm
+	RawDay mm
+}
+
+type IMUReading struct {
+	Time time.Time
+	X    xG
+	Y    yG
+	Z    zG
+}
+
+// Sampler is the only goroutine that talks to the I2C bus. It samples the
+// anemometer 4 times a second, reads the atmosphere and IMU every
+// AtmosphereInterval, and publishes a new snapshot every second.
+type Sampler struct {
+	s         *Sensors
+	imu       bool
+	period    time.Duration
+	perSecond int
+
+	latest atomic.Pointer[Snapshot]
+	// next time the atmosphere and IMU are due, so a failing sensor isn't
+	// retried every second
+	nextSlow time.Time
+
+	lock      sync.Mutex
+	listeners []func(*Snapshot)
+}
+
+func NewSampler(s *Sensors, args env.Args) *Sampler {
+	sm := &Sampler{
+		s:         s,
+		imu:       *args.Imuon,
+		period:    time.Second / env.WindSamplesPerSecond,
+		perSecond: env.WindSamplesPerSecond,
+	}
+	if *args.Test {
+		logger.Info("Sampler period set to 1 second for test")
+		sm.period = time.Second
+		sm.perSecond = 1
+	}
+	sm.latest.Store(&Snapshot{})
+	return sm
+}
+
+// Latest returns the newest snapshot; before the first it is empty
+func (sm *Sampler) Latest() *Snapshot {
+	return sm.latest.Load()
+}
+
+// OnSnapshot adds a function to call with each new snapshot. It is called
+// from the sampler so must not block.
+func (sm *Sampler) OnSnapshot(f func(*Snapshot)) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	sm.listeners = append(sm.listeners, f)
+}
+
+func (sm *Sampler) Run() {
+	logger.Info("Starting sampler")
+	var pulses uint32
+	samples := 0
+	for t := range time.Tick(sm.period) {
+		if sm.s.Wind != nil {
+			pulses += sm.s.Wind.sample(t)
+		}
+		if sm.s.Shake != nil {
+			// a shake is over too quickly for the slow reads to catch
+			if reading, err := sm.s.IMU.read(t); err == nil {
+				sm.s.Shake.add(t, reading.X.Float64(), reading.Y.Float64(), reading.Z.Float64())
+			}
+		}
+		samples++
+		if samples < sm.perSecond {
+			continue
+		}
+		sm.publish(t, pulses)
+		pulses, samples = 0, 0
+	}
+}
+
+// publish builds the next snapshot from the previous one, taking a second's
+// worth of wind pulses
+func (sm *Sampler) publish(t time.Time, pulses uint32) {
+	prev := sm.Latest()
+	next := &Snapshot{Time: t, Atmosphere: prev.Atmosphere, IMU: prev.IMU}
+
+	if !t.Before(sm.nextSlow) {
+		sm.nextSlow = t.Add(env.AtmosphereInterval)
+		if sm.s.Atm != nil {
+			if reading, err := sm.s.Atm.read(t); err == nil {
+				next.Atmosphere = reading
+			}
+		}
+		if sm.imu && sm.s.IMU != nil {
+			if reading, err := sm.s.IMU.read(t); err == nil {
+				next.IMU = reading
+			}
+		}
+	}
+	// an old reading is dropped rather than passed off as current
+	if a := next.Atmosphere; a != nil && t.Sub(a.Time) > env.SensorStale {
+		next.Atmosphere = nil
+	}
+	if i := next.IMU; i != nil && t.Sub(i.Time) > env.SensorStale {
+		next.IMU = nil
+	}
+	// the buffers only hold good samples, but once the masthead has failed
+	// they are no longer current
+	if w := sm.s.Wind; w != nil && w.mastheadHealth.State != Failed {
+		next.Wind = &WindReading{
+			Time:      t,
+			Instant:   w.cal.WindSpeed(t, float64(pulses)*env.MphPerTick),
+			Speed:     w.GetSpeed(),
+			Gust:      w.GetGust(),
+			Direction: w.GetDirection(),
+			Compass:   w.DirStr,
+		}
+	}
+	if r := sm.s.Rain; r != nil {
+		day, rawDay := r.GetDayAccumulation()
+		next.Rain = &RainReading{
+			Time:       t,
+			Rate:       r.GetRate(),
+			Hour:       r.GetHourAccumulation(),
+			MinuteRate: r.GetMinuteRate(),
+			Day:        day,
+			RawDay:     rawDay,
+		}
+	}
+
+	sm.s.recover(t)
+	next.Health = sm.s.Health()
+
+	sm.latest.Store(next)
+	sm.lock.Lock()
+	listeners := sm.listeners
+	sm.lock.Unlock()
+	for _, f := range listeners {
+		f(next)
+	}
+}
@@ -0,0 +1,79 @@
+package sensors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/buffer"
+	"github.com/pointer2null/weather/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler(t *testing.T) {
+	test, imu := false, false
+	wind := &Anemometer{
+		speedBuf: buffer.NewBuffer(env.WindSamplesPerSecond * env.WindBufferLengthSeconds),
+		gustBuf:  buffer.NewBuffer(env.WindSamplesPerSecond * env.WindBufferLengthSeconds),
+		dirBuf:   buffer.NewBuffer(env.WindSamplesPerSecond * env.WindBufferLengthSeconds),
+		DirStr:   "E",
+
+		mastheadHealth: newHealth("masthead", nil),
+		vaneHealth:     newHealth("vane", nil),
+	}
+	wind.speedBuf.AddItem(1)
+	wind.gustBuf.AddItem(1)
+	wind.dirBuf.AddItem(90)
+	// atmosphere and rain are off
+	sm := NewSampler(&Sensors{Wind: wind}, env.Args{Test: &test, Imuon: &imu})
+
+	first := sm.Latest()
+	require.NotNil(t, first)
+	require.True(t, first.Time.IsZero())
+
+	var got []*Snapshot
+	sm.OnSnapshot(func(s *Snapshot) { got = append(got, s) })
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sm.publish(now, 3)
+
+	snap := sm.Latest()
+	require.Equal(t, []*Snapshot{snap}, got)
+	require.Equal(t, now, snap.Time)
+	require.Nil(t, snap.Atmosphere)
+	require.Nil(t, snap.Rain)
+	require.Equal(t, &WindReading{
+		Time:      now,
+		Instant:   3 * env.MphPerTick,
+		Speed:     env.WindSamplesPerSecond * env.MphPerTick,
+		Gust:      env.WindSamplesPerSecond * env.MphPerTick,
+		Direction: 90,
+		Compass:   "E",
+	}, snap.Wind)
+	// published snapshots are left alone
+	require.Nil(t, first.Wind)
+
+	sm.publish(now.Add(time.Second), 0)
+	require.Zero(t, sm.Latest().Wind.Instant)
+	require.Equal(t, 3*env.MphPerTick, snap.Wind.Instant)
+	require.Equal(t, []DeviceHealth{{Device: "masthead"}, {Device: "vane"}}, sm.Latest().Health)
+
+	// a failed masthead's wind is no longer current
+	for i := 0; i < env.SensorFailedAfter; i++ {
+		wind.mastheadHealth.fail(errors.New("remote I/O error"))
+	}
+	sm.publish(now.Add(time.Second*2), 0)
+	require.Nil(t, sm.Latest().Wind)
+	require.Equal(t, Failed, sm.Latest().Health[0].State)
+}
+
+func TestSamplerDropsStaleReadings(t *testing.T) {
+	test, imu := false, false
+	sm := NewSampler(&Sensors{}, env.Args{Test: &test, Imuon: &imu})
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sm.latest.Store(&Snapshot{Atmosphere: &AtmosphereReading{Time: now, Temperature: 12}})
+
+	sm.publish(now.Add(env.SensorStale), 0)
+	require.NotNil(t, sm.Latest().Atmosphere)
+	sm.publish(now.Add(env.SensorStale+time.Second), 0)
+	require.Nil(t, sm.Latest().Atmosphere)
+}
@@ -2,7 +2,9 @@ package sensors
 
 import (
 	"flag"
+	"time"
 
+	"github.com/pointer2null/weather/calibration"
 	"github.com/pointer2null/weather/env"
 	logger "github.com/sirupsen/logrus"
 	"periph.io/x/periph/conn/i2c"
@@ -16,6 +18,14 @@ type Sensors struct {
 	Wind   *Anemometer
 	IMU    *IMU
 	Closer *i2c.BusCloser
+	// Shake is fed by the sampler when tips are checked against the IMU
+	Shake *Shake
+
+	// the bus the devices are on, reopened with busName
+	bus       i2c.Bus
+	busName   string
+	busHealth *health
+	devices   []*health
 }
 
 func InitSensors(args *env.Args) *Sensors {
@@ -35,18 +45,119 @@ func InitSensors(args *env.Args) *Sensors {
 	}
 	s.Closer = &closer
 	bus := i2c.Bus(closer)
+	s.bus = bus
+	s.busName = *i2cbus
+	s.busHealth = newHealth("i2c", nil)
 
 	if *args.AtmosphericEnabled {
 		s.Atm = NewAtmosphere(&bus, *args)
 	}
+	if *args.Imuon {
+		s.IMU = NewIMU(&bus, *args)
+	}
+	if *args.RainShake > 0 {
+		if s.IMU != nil {
+			s.Shake = NewShake(*args.RainShake, env.RainShakeWindow)
+		} else {
+			logger.Warn("Rain tips can't be checked for shaking without the IMU (-imu)")
+		}
+	}
 	if *args.RainEnabled {
-		s.Rain = NewRainmeter(&bus, *args)
+		s.Rain = NewRainmeter(&bus, *args, s.Shake)
 	}
 	if *args.WindEnabled {
 		s.Wind = NewAnemometer(&bus, *args)
 	}
-	if *args.Imuon {
-		s.IMU = NewIMU(&bus, *args)
-	}
 	return s
 }
+
+// Calibrate sets the calibration the readings are corrected with and the
+// vane's lookup table. Call it before the sampler starts.
+func (s *Sensors) Calibrate(set calibration.Set, vane calibration.Vane) {
+	if s.Atm != nil {
+		s.Atm.cal = set
+	}
+	if s.Wind != nil {
+		s.Wind.cal = set
+		s.Wind.vane = vane
+	}
+	if s.Rain != nil {
+		s.Rain.cal = set
+	}
+}
+
+// healthOf lists every device on the bus that is in use
+func (s *Sensors) healthOf() []*health {
+	if s.devices != nil {
+		return s.devices
+	}
+	if s.busHealth != nil {
+		s.devices = append(s.devices, s.busHealth)
+	}
+	if s.Atm != nil {
+		s.devices = append(s.devices, s.Atm.tempHealth, s.Atm.phHealth)
+	}
+	if s.Wind != nil {
+		s.devices = append(s.devices, s.Wind.mastheadHealth, s.Wind.vaneHealth)
+	}
+	if s.IMU != nil {
+		s.devices = append(s.devices, s.IMU.health)
+	}
+	return s.devices
+}
+
+// Health copies the health of every device. Only the sampler calls it.
+func (s *Sensors) Health() []DeviceHealth {
+	var devices []DeviceHealth
+	for _, h := range s.healthOf() {
+		devices = append(devices, h.DeviceHealth)
+	}
+	return devices
+}
+
+// recover reopens the devices that are due, or the whole bus once a device
+// has been reopened SensorBusResetAfter times without working. Only the
+// sampler calls it, as it is the only thing using the bus.
+func (s *Sensors) recover(t time.Time) {
+	for _, h := range s.healthOf() {
+		if !h.due || h.open == nil || s.bus == nil {
+			continue
+		}
+		h.due = false
+		if h.tries >= env.SensorBusResetAfter && s.Closer != nil {
+			s.reopenBus(t)
+			return
+		}
+		h.tries++
+		h.Reopens++
+		logger.Warnf("Reopening %v", h.Device)
+		if err := h.open(s.bus); err != nil {
+			logger.Errorf("Failed to reopen %v [%v]", h.Device, err)
+		}
+	}
+}
+
+// reopenBus closes the bus and opens it and every device on it again
+func (s *Sensors) reopenBus(t time.Time) {
+	logger.Warnf("Reopening I2C bus [%v]", s.busName)
+	s.busHealth.Reopens++
+	_ = (*s.Closer).Close()
+	closer, err := i2creg.Open(s.busName)
+	if err != nil {
+		s.busHealth.fail(err)
+		// try again when the next device is due
+		return
+	}
+	*s.Closer = closer
+	s.bus = closer
+	s.busHealth.ok(t)
+	for _, h := range s.healthOf() {
+		if h.open == nil {
+			continue
+		}
+		h.due, h.tries = false, 0
+		if err := h.open(closer); err != nil {
+			logger.Errorf("Failed to reopen %v [%v]", h.Device, err)
+		}
+	}
+}
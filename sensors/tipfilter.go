@@ -0,0 +1,150 @@
+package sensors
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+	"periph.io/x/periph/conn/gpio"
+)
+
+// EdgeResult is what became of a falling edge on the rain pin
+type EdgeResult int
+
+const (
+	EdgeAccepted EdgeResult = iota
+	// EdgeNoise didn't stay low for the denoise time
+	EdgeNoise
+	// EdgeBounce came within the debounce time of the last tip
+	EdgeBounce
+	// EdgeShake came while the IMU saw the mast shaking
+	EdgeShake
+)
+
+var EdgeResults = []EdgeResult{EdgeAccepted, EdgeNoise, EdgeBounce, EdgeShake}
+
+func (r EdgeResult) String() string {
+	return [...]string{"accepted", "noise", "bounce", "shake"}[r]
+}
+
+// TipFilter decides which edges on the rain pin are tips, and counts what
+// became of each
+type TipFilter struct {
+	// Denoise is how long the pin must stay low after an edge, Debounce
+	// the shortest time between tips
+	Denoise  time.Duration
+	Debounce time.Duration
+	// shake, if set, rejects tips while the mast is shaking
+	shake *Shake
+
+	last   time.Time
+	counts [4]atomic.Uint64
+}
+
+// edge decides on an edge at t, low if the pin was still low after Denoise
+func (f *TipFilter) edge(t time.Time, low bool) EdgeResult {
+	r := EdgeAccepted
+	switch {
+	case !low:
+		r = EdgeNoise
+	case !f.last.IsZero() && t.Sub(f.last) < f.Debounce:
+		r = EdgeBounce
+	case f.shake != nil && f.shake.Shaking(t):
+		r = EdgeShake
+	default:
+		f.last = t
+	}
+	f.counts[r].Add(1)
+	return r
+}
+
+// Count is the number of edges with the result so far
+func (f *TipFilter) Count(r EdgeResult) uint64 {
+	return f.counts[r].Load()
+}
+
+// watch passes every edge on pin through the filter, forever. The loop
+// waiting on the pin only timestamps edges, so none arrive unseen while
+// earlier ones wait out the denoise time or a shake window: one goroutine
+// reads the pin once each edge's denoise time has passed, and another
+// decides on them in order.
+func (f *TipFilter) watch(pin gpio.PinIO, onEdge func(t time.Time, r EdgeResult)) {
+	edges := make(chan time.Time, env.RainEdgeQueue)
+	type settled struct {
+		t   time.Time
+		low bool
+	}
+	reads := make(chan settled, env.RainEdgeQueue)
+	go func() {
+		for t := range edges {
+			time.Sleep(time.Until(t.Add(f.Denoise)))
+			reads <- settled{t, pin.Read() == gpio.Low}
+		}
+	}()
+	go func() {
+		for r := range reads {
+			onEdge(r.t, f.edge(r.t, r.low))
+		}
+	}()
+	for {
+		if !pin.WaitForEdge(-1) {
+			logger.Errorf("Failed to wait for an edge on the rain pin, retrying in %v", env.RainPinRetry)
+			time.Sleep(env.RainPinRetry)
+			continue
+		}
+		edges <- time.Now()
+	}
+}
+
+// imuCountsPerG is the MPU6050's scale at its default ±2g range
+const imuCountsPerG = 16384
+
+type shakeSample struct {
+	t time.Time
+	g float64
+}
+
+// Shake keeps the IMU's recent acceleration, to tell when the mast is
+// shaking hard enough to tip the bucket. The IMU is calibrated at rest, so
+// its readings are the acceleration beyond gravity.
+type Shake struct {
+	threshold float64 // g
+	window    time.Duration
+
+	lock    sync.Mutex
+	samples []shakeSample
+}
+
+func NewShake(threshold float64, window time.Duration) *Shake {
+	return &Shake{threshold: threshold, window: window}
+}
+
+// add records a reading, in IMU counts
+func (s *Shake) add(t time.Time, x, y, z float64) {
+	g := math.Sqrt(x*x+y*y+z*z) / imuCountsPerG
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.samples = append(s.samples, shakeSample{t, g})
+	// nothing older than a tip still being decided on is needed
+	for len(s.samples) > 0 && t.Sub(s.samples[0].t) > 4*s.window {
+		s.samples = s.samples[1:]
+	}
+}
+
+// Shaking reports whether the acceleration passed the threshold within the
+// window either side of t. It waits for the window after t to pass. With no
+// readings, e.g. the IMU failing, nothing is rejected.
+func (s *Shake) Shaking(t time.Time) bool {
+	time.Sleep(time.Until(t.Add(s.window)))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, sample := range s.samples {
+		if sample.t.Sub(t).Abs() <= s.window && sample.g > s.threshold {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package sensors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+func TestTipFilter(t *testing.T) {
+	t0 := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+	f := &TipFilter{Denoise: 100 * time.Millisecond, Debounce: 500 * time.Millisecond}
+
+	require.Equal(t, EdgeAccepted, f.edge(at(0), true))
+	// the contacts bouncing as the bucket settles
+	require.Equal(t, EdgeBounce, f.edge(at(40), true))
+	require.Equal(t, EdgeBounce, f.edge(at(499), true))
+	// a spike that had gone by the time the pin was read again
+	require.Equal(t, EdgeNoise, f.edge(at(700), false))
+	require.Equal(t, EdgeAccepted, f.edge(at(800), true))
+	// bounces are timed from the last tip, not the last edge
+	require.Equal(t, EdgeAccepted, f.edge(at(1300), true))
+
+	require.Equal(t, uint64(3), f.Count(EdgeAccepted))
+	require.Equal(t, uint64(2), f.Count(EdgeBounce))
+	require.Equal(t, uint64(1), f.Count(EdgeNoise))
+	require.Equal(t, uint64(0), f.Count(EdgeShake))
+	require.Equal(t, "bounce", EdgeBounce.String())
+}
+
+func TestShake(t *testing.T) {
+	t0 := time.Now().Add(-time.Minute)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+	s := NewShake(0.5, time.Second)
+	f := &TipFilter{Denoise: 100 * time.Millisecond, Debounce: 500 * time.Millisecond, shake: s}
+
+	// no readings, so nothing to reject on
+	require.Equal(t, EdgeAccepted, f.edge(at(0), true))
+
+	for ms := 0; ms <= 7000; ms += 250 {
+		x := 200.0
+		if ms == 5000 {
+			// a gust shakes the mast, 0.75g
+			x = 12288
+		}
+		s.add(at(ms), x, 100, -300)
+	}
+	require.False(t, s.Shaking(at(3000)))
+	// the shake came just after the edge
+	require.Equal(t, EdgeShake, f.edge(at(4500), true))
+	require.Equal(t, EdgeShake, f.edge(at(6000), true))
+	require.Equal(t, EdgeAccepted, f.edge(at(6100), true))
+	require.Equal(t, uint64(2), f.Count(EdgeShake))
+}
+
+func TestWatchCountsEdgesWhileDeciding(t *testing.T) {
+	pin := &gpiotest.Pin{N: "rain", EdgesChan: make(chan gpio.Level)}
+	// no IMU readings, but every tip still waits out the shake window
+	f := &TipFilter{Denoise: 20 * time.Millisecond, Debounce: 50 * time.Millisecond, shake: NewShake(0.5, 200*time.Millisecond)}
+	type decided struct {
+		t time.Time
+		r EdgeResult
+	}
+	results := make(chan decided, 4)
+	go f.watch(pin, func(t time.Time, r EdgeResult) { results <- decided{t, r} })
+
+	start := time.Now()
+	pin.EdgesChan <- gpio.Low
+	time.Sleep(100 * time.Millisecond)
+	// arrives while the first tip is waiting for its shake window
+	pin.EdgesChan <- gpio.Low
+
+	first, second := <-results, <-results
+	require.Equal(t, EdgeAccepted, first.r)
+	require.Equal(t, EdgeAccepted, second.r)
+	// timed when it came rather than when the first was decided on
+	require.Less(t, second.t.Sub(start), 180*time.Millisecond)
+	require.Equal(t, uint64(2), f.Count(EdgeAccepted))
+}
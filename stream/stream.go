@@ -0,0 +1,154 @@
+// Package stream fans live readings out to any number of browsers, over
+// server-sent events or a websocket, from one producer.
+//
+// Every event is a JSON object {"type": ..., "time": ..., "data": {...}},
+// marshalled once however many clients there are. Each client has a small
+// queue; a client that falls behind loses its oldest events, and one that
+// reads nothing for a whole queue's worth is disconnected. EventSource
+// reconnects by itself, and a new client is sent the latest event of each
+// type straight away.
+package stream
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	logger "github.com/sirupsen/logrus"
+)
+
+type message struct {
+	kind string
+	data []byte
+}
+
+type client struct {
+	ch chan message
+	// events dropped since this client last kept up
+	lagging int
+}
+
+type Hub struct {
+	buffer    int
+	heartbeat time.Duration
+
+	lock    sync.Mutex
+	clients map[*client]struct{}
+	// the latest event of each type, in the order the types first appeared
+	last  map[string]message
+	kinds []string
+
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+}
+
+// NewHub queues up to buffer events for each client and sends a heartbeat
+// to idle connections every heartbeat.
+func NewHub(buffer int, heartbeat time.Duration) *Hub {
+	return &Hub{
+		buffer:    buffer,
+		heartbeat: heartbeat,
+		clients:   make(map[*client]struct{}),
+		last:      make(map[string]message),
+	}
+}
+
+type envelope struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// Publish sends an event to every client. It never blocks on a client.
+func (h *Hub) Publish(kind string, t time.Time, data any) {
+	js, err := json.Marshal(envelope{Type: kind, Time: t.UTC(), Data: data})
+	if err != nil {
+		logger.Errorf("Failed to marshal %v event [%v]", kind, err)
+		return
+	}
+	m := message{kind: kind, data: js}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.last[kind]; !ok {
+		h.kinds = append(h.kinds, kind)
+	}
+	h.last[kind] = m
+	for c := range h.clients {
+		h.deliver(c, m)
+	}
+}
+
+// deliver queues m for c, making room by dropping c's oldest event if it
+// has to. Only the hub sends, under its lock, so the send after making room
+// can't block.
+func (h *Hub) deliver(c *client, m message) {
+	select {
+	case c.ch <- m:
+		c.lagging = 0
+		return
+	default:
+	}
+	select {
+	case <-c.ch:
+	default:
+	}
+	c.ch <- m
+	h.dropped.Add(1)
+	c.lagging++
+	if c.lagging >= h.buffer {
+		h.disconnected.Add(1)
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
+
+func (h *Hub) subscribe() *client {
+	c := &client{ch: make(chan message, h.buffer)}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, kind := range h.kinds {
+		h.deliver(c, h.last[kind])
+	}
+	h.clients[c] = struct{}{}
+	return c
+}
+
+func (h *Hub) unsubscribe(c *client) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
+
+// Clients is the number connected
+func (h *Hub) Clients() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return len(h.clients)
+}
+
+// Dropped counts events skipped for clients that were behind
+func (h *Hub) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// Disconnected counts clients dropped for being too slow
+func (h *Hub) Disconnected() uint64 {
+	return h.disconnected.Load()
+}
+
+// ServeHTTP streams events over a websocket if the request asks for one,
+// and as server-sent events otherwise.
+func (h *Hub) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(rw, r)
+		return
+	}
+	h.serveEvents(rw, r)
+}
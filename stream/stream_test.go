@@ -0,0 +1,167 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestBackpressure(t *testing.T) {
+	h := NewHub(3, time.Minute)
+	slow := h.subscribe()
+	fast := h.subscribe()
+
+	h.Publish("wind", t0, 1)
+	<-fast.ch
+	h.Publish("wind", t0, 2)
+	<-fast.ch
+	h.Publish("wind", t0, 3)
+	<-fast.ch
+	h.Publish("wind", t0, 4)
+	<-fast.ch
+	// slow has lost the first event and lags by one
+	require.Equal(t, uint64(1), h.Dropped())
+	require.Equal(t, 2, h.Clients())
+	m := <-slow.ch
+	require.Contains(t, string(m.data), `"data":2`)
+
+	// reading resets the lag, but three more drops in a row is too slow
+	h.Publish("wind", t0, 5)
+	for i := 6; i <= 8; i++ {
+		h.Publish("wind", t0, i)
+		<-fast.ch
+	}
+	<-fast.ch
+	require.Equal(t, 1, h.Clients())
+	require.Equal(t, uint64(1), h.Disconnected())
+	var got []string
+	for m := range slow.ch {
+		got = append(got, string(m.data))
+	}
+	// the queue keeps the newest
+	require.Len(t, got, 3)
+	require.Contains(t, got[2], `"data":8`)
+
+	// a closed client is only unsubscribed once
+	h.unsubscribe(slow)
+	h.unsubscribe(fast)
+	require.Zero(t, h.Clients())
+}
+
+func TestLatest(t *testing.T) {
+	h := NewHub(8, time.Minute)
+	h.Publish("atmosphere", t0, map[string]float64{"temperature_C": 10})
+	h.Publish("wind", t0, 1)
+	h.Publish("wind", t0.Add(time.Second), 2)
+
+	c := h.subscribe()
+	a := <-c.ch
+	require.Equal(t, "atmosphere", a.kind)
+	require.JSONEq(t, `{"type":"atmosphere","time":"2026-10-18T12:00:00Z","data":{"temperature_C":10}}`, string(a.data))
+	w := <-c.ch
+	require.Contains(t, string(w.data), `"data":2`)
+	require.Empty(t, c.ch)
+}
+
+func waitForClients(t *testing.T, h *Hub, n int) {
+	require.Eventually(t, func() bool { return h.Clients() == n }, time.Second, time.Millisecond)
+}
+
+func TestEvents(t *testing.T) {
+	h := NewHub(8, 20*time.Millisecond)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	waitForClients(t, h, 1)
+
+	h.Publish("rain", t0, map[string]float64{"day_mm": 0.2794})
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 5 {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimRight(line, "\n"))
+		if strings.HasPrefix(line, ": ping") {
+			break
+		}
+	}
+	require.Equal(t, []string{
+		"retry: 5000",
+		"",
+		"event: rain",
+		`data: {"type":"rain","time":"2026-10-18T12:00:00Z","data":{"day_mm":0.2794}}`,
+		"",
+	}, lines)
+	// then the heartbeat
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, ": ping\n", line)
+
+	resp.Body.Close()
+	waitForClients(t, h, 0)
+}
+
+func TestWebSocket(t *testing.T) {
+	h := NewHub(8, 20*time.Millisecond)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForClients(t, h, 1)
+
+	pings := make(chan struct{}, 10)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	h.Publish("wind", t0, map[string]float64{"speed_mph": 5.7})
+
+	kind, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.TextMessage, kind)
+	var e struct {
+		Type string             `json:"type"`
+		Time time.Time          `json:"time"`
+		Data map[string]float64 `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &e))
+	require.Equal(t, "wind", e.Type)
+	require.Equal(t, t0, e.Time)
+	require.Equal(t, 5.7, e.Data["speed_mph"])
+
+	// pings are only seen while reading; answered, they keep the connection
+	// open well past the two heartbeat read deadline
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		select {
+		case <-pings:
+		case <-time.After(time.Second):
+			t.Fatal("no ping")
+		}
+	}
+	require.Equal(t, 1, h.Clients())
+
+	conn.Close()
+	waitForClients(t, h, 0)
+}
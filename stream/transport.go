@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	// a write to a client that takes longer than this ends its connection
+	writeTimeout = time.Second * 10
+	// how soon EventSource should reconnect after a restart or being dropped
+	retry = time.Second * 5
+)
+
+// serveEvents streams as text/event-stream. The heartbeat is a comment line,
+// which EventSource ignores but which keeps proxies from timing out.
+func (h *Hub) serveEvents(rw http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(rw)
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	// stop nginx buffering the stream
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	c := h.subscribe()
+	defer h.unsubscribe(c)
+	ping := time.NewTicker(h.heartbeat)
+	defer ping.Stop()
+
+	write := func(format string, args ...any) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := fmt.Fprintf(rw, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := write("retry: %d\n\n", retry.Milliseconds()); err != nil {
+		return
+	}
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case m, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			err = write("event: %s\ndata: %s\n\n", m.kind, m.data)
+		case <-ping.C:
+			err = write(": ping\n\n")
+		}
+		if err != nil {
+			logger.Debugf("Event stream closed [%v]", err)
+			return
+		}
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  512,
+	WriteBufferSize: 4096,
+	// the stream is read only public data, so any page may show it
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// serveWebSocket sends each event as a text message and pings every
+// heartbeat. A client that doesn't answer two pings in a row is gone.
+func (h *Hub) serveWebSocket(rw http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		// the upgrader has already replied
+		logger.Debugf("Websocket upgrade failed [%v]", err)
+		return
+	}
+	defer conn.Close()
+
+	c := h.subscribe()
+	defer h.unsubscribe(c)
+	ping := time.NewTicker(h.heartbeat)
+	defer ping.Stop()
+
+	// clients have nothing to say, but reading handles pongs and closes
+	alive := func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	}
+	_ = alive("")
+	conn.SetPongHandler(alive)
+	conn.SetReadLimit(512)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+		case m, ok := <-c.ch:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(writeTimeout))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err = conn.WriteMessage(websocket.TextMessage, m.data)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+		}
+		if err != nil {
+			logger.Debugf("Websocket closed [%v]", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/pointer2null/weather/env"
+	"github.com/pointer2null/weather/qc"
+	"github.com/pointer2null/weather/sensors"
+	logger "github.com/sirupsen/logrus"
+)
+
+// compareTemperatures checks each new atmosphere reading's MCP9808
+// temperature against the BME280's. While they differ by more than
+// -tempDelta on average the temperature is flagged suspect.
+func (w *weatherstation) compareTemperatures() {
+	drift := &qc.Redundant{Delta: *w.args.TempDelta, Window: env.TemperatureDriftWindow}
+	var last *sensors.AtmosphereReading
+	w.sampler.OnSnapshot(func(snap *sensors.Snapshot) {
+		atm := snap.Atmosphere
+		if atm == nil || atm == last {
+			return
+		}
+		last = atm
+		Prom_temperatureSecondary.Set(atm.Secondary.Float64())
+		for _, sensor := range []string{sensors.SourceMCP9808, sensors.SourceBME280} {
+			v := 0.0
+			if sensor == atm.TemperatureSource {
+				v = 1
+			}
+			Prom_temperatureSource.WithLabelValues(sensor).Set(v)
+		}
+		if atm.Primary == nil {
+			// nothing to compare while the BME280 stands in
+			return
+		}
+		diff := drift.Add(atm.Time, atm.Primary.Float64(), atm.Secondary.Float64())
+		Prom_temperatureDifference.Set(diff)
+		drifting := drift.Drifting()
+		if w.tempDrift.Swap(drifting) != drifting {
+			if drifting {
+				logger.Warnf("Temperature sensors differ by %.2fC", diff)
+			} else {
+				logger.Infof("Temperature sensors agree again, %.2fC apart", diff)
+			}
+		}
+	})
+}
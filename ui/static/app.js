@@ -0,0 +1,207 @@
+// Dashboard: current readings from /api/v1/current, then live from the
+// stream; today's extremes and the 24 hour sparklines are fetched again
+// every few minutes.
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+const compassPoints = ["N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"];
+
+// arrows reach the dial at this speed in mph
+const compassScale = 50;
+
+function fixed(v, digits) {
+  return v === null || v === undefined || Number.isNaN(v) ? "--" : Number(v).toFixed(digits);
+}
+
+function set(id, v, digits) {
+  $(id).textContent = fixed(v, digits);
+}
+
+function clock(iso) {
+  if (!iso) return "";
+  return new Date(iso).toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+}
+
+function compass(deg) {
+  return compassPoints[Math.round((((deg % 360) + 360) % 360) / 22.5) % 16];
+}
+
+let lastUpdate = null;
+
+function touched() {
+  lastUpdate = new Date();
+  $("updated").textContent = "updated " + lastUpdate.toLocaleTimeString();
+}
+
+// --- compass ---
+
+function drawTicks() {
+  const g = $("ticks");
+  for (let i = 0; i < 16; i++) {
+    const a = (i * 22.5 * Math.PI) / 180;
+    const inner = i % 4 === 0 ? 88 : 93;
+    const line = document.createElementNS("http://www.w3.org/2000/svg", "line");
+    line.setAttribute("class", "tick");
+    line.setAttribute("x1", 100 * Math.sin(a));
+    line.setAttribute("y1", -100 * Math.cos(a));
+    line.setAttribute("x2", inner * Math.sin(a));
+    line.setAttribute("y2", -inner * Math.cos(a));
+    g.appendChild(line);
+  }
+}
+
+// arrow sets the half length of an arrow from a speed, with the head at the
+// downwind end
+function arrow(lineId, headId, mph, headSize) {
+  const len = 32 + Math.min(mph / compassScale, 1) * 58;
+  const line = $(lineId);
+  line.setAttribute("y1", -len);
+  line.setAttribute("y2", len);
+  $(headId).setAttribute("points", `0,${len} ${-headSize / 2},${len - headSize * 1.6} ${headSize / 2},${len - headSize * 1.6}`);
+}
+
+function showWind(w) {
+  set("speed", w.speed, 0);
+  set("average", w.average, 1);
+  set("gust", w.gust, 1);
+  if (w.direction !== null && w.direction !== undefined) {
+    $("direction").textContent = `${compass(w.direction)} (${fixed(w.direction, 0)}°)`;
+    $("arrows").setAttribute("transform", `rotate(${w.direction})`);
+  }
+  arrow("wind-arrow", "wind-head", w.average || 0, 20);
+  arrow("gust-arrow", "gust-head", w.gust || 0, 16);
+}
+
+// --- current conditions ---
+
+function showAtmosphere(a) {
+  set("temperature", a.temperature, 1);
+  set("humidity", a.humidity, 0);
+  set("pressure", a.pressure, 1);
+  if (a.dewPoint !== undefined) {
+    set("dewpoint", a.dewPoint, 1);
+  } else if (a.temperature !== null && a.humidity !== null) {
+    // the same approximation the station uses
+    set("dewpoint", a.temperature - (100 - a.humidity) / 5, 1);
+  }
+}
+
+function showRain(r) {
+  set("rainrate", r.rate, 1);
+  if (r.day !== undefined) set("rainday", r.day, 1);
+}
+
+async function loadCurrent() {
+  const res = await fetch("/api/v1/current");
+  if (!res.ok) return;
+  const c = await res.json();
+  showAtmosphere({ temperature: c.hiResTemp_C, humidity: c.humidity_RH, pressure: c.mslp_hPa });
+  showRain({ rate: c.rain_rate_mm_hr });
+  showWind({ speed: c.wind_speed, average: c.wind_speed, gust: c.wind_gust, direction: c.wind_dir });
+  touched();
+}
+
+// --- live stream ---
+
+function connect() {
+  const status = $("status");
+  const es = new EventSource("/api/v1/stream");
+  es.onopen = () => {
+    status.textContent = "live";
+    status.className = "status live";
+  };
+  es.onerror = () => {
+    // EventSource retries by itself
+    status.textContent = "reconnecting";
+    status.className = "status down";
+  };
+  es.addEventListener("wind", (e) => {
+    const d = JSON.parse(e.data).data;
+    showWind({ speed: d.speed_mph, average: d.average_mph, gust: d.gust_mph, direction: d.direction });
+    touched();
+  });
+  es.addEventListener("atmosphere", (e) => {
+    const d = JSON.parse(e.data).data;
+    showAtmosphere({ temperature: d.temperature_C, humidity: d.humidity_RH, pressure: d.mslp_hPa, dewPoint: d.dew_point_C });
+    touched();
+  });
+  es.addEventListener("rain", (e) => {
+    const d = JSON.parse(e.data).data;
+    showRain({ rate: d.rate_mm_hr, day: d.day_mm });
+    touched();
+  });
+}
+
+// --- today ---
+
+function extreme(id, e, digits) {
+  set(id, e ? e.value : null, digits);
+  const at = $(id + "-at");
+  if (at) at.textContent = e ? clock(e.time) : "";
+}
+
+async function loadToday() {
+  const res = await fetch("/api/v1/extremes?period=day");
+  if (!res.ok) return;
+  const x = await res.json();
+  extreme("tmax", x.temperature_max_C, 1);
+  extreme("tmin", x.temperature_min_C, 1);
+  extreme("gmax", x.wind_gust_max_mph, 1);
+  extreme("pmin", x.mslp_min_hPa, 1);
+  extreme("pmax", x.mslp_max_hPa, 1);
+  extreme("rrmax", x.rain_rate_max_mm_hr, 1);
+  set("rtotal", x.rain_total_mm, 1);
+}
+
+// --- sparklines ---
+
+function sparkline(el, points) {
+  const label = el.dataset.label;
+  const unit = el.dataset.unit;
+  const values = points.filter((p) => p.v !== null);
+  let svg = "";
+  let range = "no data";
+  if (values.length > 1) {
+    const t0 = values[0].t;
+    const t1 = values[values.length - 1].t;
+    const lo = Math.min(...values.map((p) => p.v));
+    const hi = Math.max(...values.map((p) => p.v));
+    const span = hi - lo || 1;
+    const xy = values.map((p) => `${(((p.t - t0) / (t1 - t0 || 1)) * 100).toFixed(2)},${(38 - ((p.v - lo) / span) * 36).toFixed(2)}`);
+    svg = `<svg viewBox="0 0 100 40" preserveAspectRatio="none"><polyline points="${xy.join(" ")}"></polyline></svg>`;
+    range = `${fixed(lo, 1)} - ${fixed(hi, 1)} ${unit}`;
+  }
+  el.innerHTML = `<span>${label}</span>${svg || "<span></span>"}<span class="range">${range}</span>`;
+}
+
+async function loadTrends() {
+  const from = new Date(Date.now() - 24 * 3600 * 1000).toISOString();
+  const res = await fetch(`/api/v1/observations?resolution=minute&limit=10000&from=${encodeURIComponent(from)}`);
+  if (!res.ok) return;
+  const obs = (await res.json()).observations;
+  for (const el of document.querySelectorAll(".spark")) {
+    const field = el.dataset.field;
+    let total = 0;
+    const points = obs.map((o) => {
+      let v = o[field];
+      if (el.dataset.cumulative) {
+        total += v || 0;
+        v = total;
+      }
+      return { t: Date.parse(o.time), v: v === undefined ? null : v };
+    });
+    sparkline(el, points);
+  }
+}
+
+function every(f, ms) {
+  f().catch(() => {});
+  setInterval(() => f().catch(() => {}), ms);
+}
+
+drawTicks();
+loadCurrent().catch(() => {});
+connect();
+every(loadToday, 5 * 60 * 1000);
+every(loadTrends, 10 * 60 * 1000);
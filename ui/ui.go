@@ -0,0 +1,36 @@
+// Package ui is the station's dashboard: one page of current conditions,
+// today's extremes and 24 hour sparklines, kept up to date from the live
+// stream. It is embedded in the binary so the Pi serves it on its own.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Register serves the dashboard at / and its files under /ui/
+func Register(mux *http.ServeMux) {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		// static is compiled in, so this can't happen
+		panic(err)
+	}
+	index, err := fs.ReadFile(files, "index.html")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("/ui/", http.StripPrefix("/ui/", http.FileServer(http.FS(files))))
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		// / is the catch all, so anything unknown lands here
+		if r.URL.Path != "/" {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = rw.Write(index) // not much we can do if this fails
+	})
+}
@@ -0,0 +1,31 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	rec := get("/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, rec.Body.String(), `<script src="/ui/app.js">`)
+
+	for _, path := range []string{"/ui/app.js", "/ui/style.css"} {
+		require.Equal(t, http.StatusOK, get(path).Code, path)
+	}
+	require.Equal(t, http.StatusNotFound, get("/nothing").Code)
+	require.Equal(t, http.StatusNotFound, get("/ui/missing.js").Code)
+}
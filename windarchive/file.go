@@ -0,0 +1,174 @@
+package windarchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileStore writes one gzipped csv file per UTC day, named
+// wind-YYYY-MM-DD.csv.gz, with lines of
+//
+//	2026-10-18T10:00:00.250Z,3,225
+//
+// Each batch is appended as a complete gzip member, so a file is always
+// readable (by zcat or gzip.Reader) even while it is still being written.
+type FileStore struct {
+	dir string
+}
+
+const (
+	filePrefix = "wind-"
+	fileSuffix = ".csv.gz"
+	dayLayout  = "2006-01-02"
+	timeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) path(day time.Time) string {
+	return filepath.Join(f.dir, filePrefix+day.Format(dayLayout)+fileSuffix)
+}
+
+func (f *FileStore) Write(ctx context.Context, samples []Sample) error {
+	for len(samples) > 0 {
+		// split the batch at midnight
+		day := samples[0].Time.UTC().Truncate(24 * time.Hour)
+		n := 0
+		for n < len(samples) && samples[n].Time.UTC().Truncate(24*time.Hour).Equal(day) {
+			n++
+		}
+		if err := f.append(day, samples[:n]); err != nil {
+			return err
+		}
+		samples = samples[n:]
+	}
+	return nil
+}
+
+func (f *FileStore) append(day time.Time, samples []Sample) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	w := bufio.NewWriter(zw)
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s,%d,%g\n", s.Time.UTC().Format(timeLayout), s.Pulses, s.Direction)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path(day), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	// one write, so a crash can't leave half a member in the middle
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *FileStore) Export(ctx context.Context, from time.Time, to time.Time, fn func(Sample) error) error {
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := f.exportDay(day, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileStore) exportDay(day time.Time, from time.Time, to time.Time, fn func(Sample) error) error {
+	file, err := os.Open(f.path(day))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		s, err := parseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%v: %w", f.path(day), err)
+		}
+		if s.Time.Before(from) || !s.Time.Before(to) {
+			continue
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		// a member cut short by a power cut ends the file early
+		return err
+	}
+	return nil
+}
+
+func parseLine(line string) (Sample, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 3 {
+		return Sample{}, fmt.Errorf("bad line [%v]", line)
+	}
+	t, err := time.Parse(timeLayout, fields[0])
+	if err != nil {
+		return Sample{}, err
+	}
+	pulses, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return Sample{}, err
+	}
+	dir, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{Time: t, Pulses: uint32(pulses), Direction: dir}, nil
+}
+
+// Prune deletes the files for days that ended before before
+func (f *FileStore) Prune(ctx context.Context, before time.Time) error {
+	names, err := filepath.Glob(filepath.Join(f.dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		base := filepath.Base(name)
+		day, err := time.Parse(dayLayout, strings.TrimSuffix(strings.TrimPrefix(base, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		if day.Add(24 * time.Hour).After(before) {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
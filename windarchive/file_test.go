@@ -0,0 +1,54 @@
+package windarchive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFileStore(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	midnight := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	for i := -4; i < 4; i++ {
+		samples = append(samples, Sample{Time: midnight.Add(time.Duration(i) * 250 * time.Millisecond), Pulses: uint32(i + 4), Direction: 112.5})
+	}
+	// two batches into the same file
+	require.NoError(t, f.Write(ctx, samples[:6]))
+	require.NoError(t, f.Write(ctx, samples[6:]))
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "wind-2026-10-17.csv.gz"),
+		filepath.Join(dir, "wind-2026-10-18.csv.gz"),
+	}, names)
+
+	var got []Sample
+	collect := func(s Sample) error {
+		got = append(got, s)
+		return nil
+	}
+	require.NoError(t, f.Export(ctx, midnight.Add(-time.Hour), midnight.Add(time.Hour), collect))
+	require.Equal(t, samples, got)
+
+	got = nil
+	require.NoError(t, f.Export(ctx, midnight.Add(-250*time.Millisecond), midnight.Add(500*time.Millisecond), collect))
+	require.Len(t, got, 3)
+	require.Equal(t, uint32(3), got[0].Pulses)
+
+	// by midday on the 18th only the 17th has ended
+	require.NoError(t, f.Prune(ctx, midnight.Add(12*time.Hour)))
+	_, err = os.Stat(filepath.Join(dir, "wind-2026-10-17.csv.gz"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "wind-2026-10-18.csv.gz"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,93 @@
+package windarchive
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresStore writes samples to the wind_raw table with COPY
+type PostgresStore struct {
+	db      *sql.DB
+	station string
+}
+
+func NewPostgresStore(db *sql.DB, station string) *PostgresStore {
+	return &PostgresStore{db: db, station: station}
+}
+
+func (p *PostgresStore) Write(ctx context.Context, samples []Sample) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("wind_raw", "station_id", "observed_at", "pulses", "direction"))
+	if err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if _, err := stmt.ExecContext(ctx, p.station, s.Time, int16(s.Pulses), s.Direction); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+	// the empty exec sends the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) Export(ctx context.Context, from time.Time, to time.Time, f func(Sample) error) error {
+	rows, err := p.db.QueryContext(ctx, `SELECT observed_at, pulses, direction FROM wind_raw
+WHERE station_id = $1
+  AND observed_at >= $2
+  AND observed_at < $3
+ORDER BY observed_at`, p.station, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Sample
+		if err := rows.Scan(&s.Time, &s.Pulses, &s.Direction); err != nil {
+			return err
+		}
+		s.Time = s.Time.UTC()
+		if err := f(s); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Prune drops whole chunks when wind_raw is a timescale hypertable, which is
+// far cheaper than deleting rows from compressed chunks.
+func (p *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	var hypertable bool
+	err := p.db.QueryRowContext(ctx, `SELECT to_regclass('timescaledb_information.hypertables') IS NOT NULL`).Scan(&hypertable)
+	if err != nil {
+		return err
+	}
+	if hypertable {
+		err = p.db.QueryRowContext(ctx, `SELECT EXISTS (
+    SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'wind_raw')`).Scan(&hypertable)
+		if err != nil {
+			return err
+		}
+	}
+	if hypertable {
+		_, err = p.db.ExecContext(ctx, "SELECT drop_chunks('wind_raw', older_than => $1::timestamptz)", before)
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, "DELETE FROM wind_raw WHERE observed_at < $1", before)
+	return err
+}
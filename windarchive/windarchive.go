@@ -0,0 +1,94 @@
+// Package windarchive keeps every raw anemometer sample, the pulse count and
+// vane direction read 4 times a second, so gust structure can be analysed
+// and the gust calculation checked after the fact.
+package windarchive
+
+import (
+	"context"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	logger "github.com/sirupsen/logrus"
+)
+
+type Sample struct {
+	Time      time.Time
+	Pulses    uint32
+	Direction float64
+}
+
+// Store is where samples end up: compressed daily files or postgres
+type Store interface {
+	Write(ctx context.Context, samples []Sample) error
+	// Export calls f with each sample in [from, to), oldest first
+	Export(ctx context.Context, from time.Time, to time.Time, f func(Sample) error) error
+	// Prune removes samples older than before
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Archive collects samples from the sampling loop and writes them to the
+// store in batches.
+type Archive struct {
+	store   Store
+	keep    time.Duration
+	samples chan Sample
+}
+
+// New returns an archive that keeps samples for keep, or forever if keep is
+// zero.
+func New(store Store, keep time.Duration) *Archive {
+	return &Archive{
+		store:   store,
+		keep:    keep,
+		samples: make(chan Sample, env.WindSamplesPerSecond*int(env.WindArchiveFlush/time.Second)*2),
+	}
+}
+
+// Add queues a sample without blocking; if the writer has fallen behind the
+// sample is dropped.
+func (a *Archive) Add(t time.Time, pulses uint32, direction float64) {
+	select {
+	case a.samples <- Sample{Time: t.UTC(), Pulses: pulses, Direction: direction}:
+	default:
+	}
+}
+
+// Run writes the queued samples every env.WindArchiveFlush and prunes old
+// ones, forever. A batch that fails to write is retried with the next, up
+// to env.WindArchivePending samples, after which the oldest are dropped.
+func (a *Archive) Run() {
+	logger.Infof("Wind archive started, keeping %v", a.keep)
+	flush := time.NewTicker(env.WindArchiveFlush)
+	prune := time.NewTicker(env.WindArchivePrune)
+	var pending []Sample
+	for {
+		select {
+		case s := <-a.samples:
+			pending = append(pending, s)
+		case <-flush.C:
+			if len(pending) == 0 {
+				continue
+			}
+			if err := a.store.Write(context.Background(), pending); err != nil {
+				logger.Errorf("Failed to write %d wind samples [%v]", len(pending), err)
+				if over := len(pending) - env.WindArchivePending; over > 0 {
+					logger.Warnf("Dropping %d wind samples", over)
+					pending = pending[over:]
+				}
+				continue
+			}
+			pending = pending[:0]
+		case <-prune.C:
+			if a.keep <= 0 {
+				continue
+			}
+			if err := a.store.Prune(context.Background(), time.Now().Add(-a.keep)); err != nil {
+				logger.Errorf("Failed to prune wind archive [%v]", err)
+			}
+		}
+	}
+}
+
+func (a *Archive) Export(ctx context.Context, from time.Time, to time.Time, f func(Sample) error) error {
+	return a.store.Export(ctx, from, to, f)
+}
@@ -0,0 +1,181 @@
+// Package windrose counts how long the wind blew from each of the 16 compass
+// points the vane reports, split into speed bands, for wind roses and
+// prevailing wind statistics.
+package windrose
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+)
+
+// Sectors is the number of compass points the vane resolves
+const Sectors = 16
+
+var Points = [Sectors]string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// CalmMph is the mean speed below which the wind has no direction, the top
+// of Beaufort 0
+const CalmMph = 1.0
+
+// Bands are the lower limits, in mph, of the speed bands: Beaufort 1 to 5
+// and anything faster
+var Bands = []float64{CalmMph, 4, 8, 13, 19, 25}
+
+// Sector returns the compass point, 0 for north, nearest a direction in
+// degrees
+func Sector(direction float64) int {
+	s := int(math.Round(direction/(360.0/Sectors))) % Sectors
+	if s < 0 {
+		s += Sectors
+	}
+	return s
+}
+
+// Band returns the speed band of a speed in mph, or -1 if it is calm
+func Band(mph float64) int {
+	for b := len(Bands) - 1; b >= 0; b-- {
+		if mph >= Bands[b] {
+			return b
+		}
+	}
+	return -1
+}
+
+// BandName labels a band with its limits converted by conv, e.g. "4-8 mph"
+func BandName(b int, conv func(float64) float64, unit string) string {
+	if b == len(Bands)-1 {
+		return fmt.Sprintf("%.0f+ %s", conv(Bands[b]), unit)
+	}
+	return fmt.Sprintf("%.0f-%.0f %s", conv(Bands[b]), conv(Bands[b+1]), unit)
+}
+
+// Rose is the time, in minutes, the wind spent in each sector and band
+type Rose struct {
+	From    time.Time
+	To      time.Time
+	Minutes [Sectors][]float64
+	Calm    float64
+	Total   float64
+}
+
+func New(from, to time.Time) *Rose {
+	r := &Rose{From: from, To: to}
+	for s := range r.Minutes {
+		r.Minutes[s] = make([]float64, len(Bands))
+	}
+	return r
+}
+
+// Add counts minutes of wind at a mean speed from a direction
+func (r *Rose) Add(direction, mph, minutes float64) {
+	r.Total += minutes
+	b := Band(mph)
+	if b < 0 {
+		r.Calm += minutes
+		return
+	}
+	r.Minutes[Sector(direction)][b] += minutes
+}
+
+// Percent is the share of the whole time, calm included, spent in a sector
+// and band
+func (r *Rose) Percent(sector, band int) float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return r.Minutes[sector][band] / r.Total * 100
+}
+
+// SectorPercent is the share of the whole time the wind blew from a sector
+func (r *Rose) SectorPercent(sector int) float64 {
+	p := 0.0
+	for b := range r.Minutes[sector] {
+		p += r.Percent(sector, b)
+	}
+	return p
+}
+
+func (r *Rose) CalmPercent() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return r.Calm / r.Total * 100
+}
+
+// Prevailing is the sector the wind blew from most often, false if it was
+// calm throughout
+func (r *Rose) Prevailing() (int, bool) {
+	best, most := 0, 0.0
+	for s := range r.Minutes {
+		if p := r.SectorPercent(s); p > most {
+			best, most = s, p
+		}
+	}
+	return best, most > 0
+}
+
+// Reading is a mean over one minute of samples
+type Reading struct {
+	Time time.Time
+	// Speed is the mean speed in mph
+	Speed float64
+	// Direction is the compass point the vane pointed to while the cups
+	// turned most, the last known direction if they didn't turn at all
+	Direction float64
+	// Seconds is how much of the minute was sampled
+	Seconds float64
+}
+
+// Averager turns the raw samples, 4 a second, into one minute means. A
+// single sample is a quarter second and one pulse is 5.7 mph, far too coarse
+// to count on its own.
+type Averager struct {
+	emit    func(Reading)
+	minute  time.Time
+	samples int
+	pulses  uint32
+	sector  [Sectors]uint32
+	last    float64
+}
+
+// NewAverager calls emit with each minute as the next one starts
+func NewAverager(emit func(Reading)) *Averager {
+	return &Averager{emit: emit}
+}
+
+// Add takes one raw sample. Samples must come in time order.
+func (a *Averager) Add(t time.Time, pulses uint32, direction float64) {
+	if m := t.Truncate(time.Minute); !m.Equal(a.minute) {
+		a.Flush()
+		a.minute = m
+	}
+	a.samples++
+	a.pulses += pulses
+	a.sector[Sector(direction)] += pulses
+	a.last = direction
+}
+
+// Flush emits the minute so far, e.g. at the end of a range
+func (a *Averager) Flush() {
+	if a.samples == 0 {
+		return
+	}
+	seconds := float64(a.samples) / env.WindSamplesPerSecond
+	r := Reading{
+		Time:      a.minute,
+		Speed:     float64(a.pulses) / seconds * env.MphPerTick,
+		Direction: a.last,
+		Seconds:   seconds,
+	}
+	var most uint32
+	for s, p := range a.sector {
+		if p > most {
+			most, r.Direction = p, float64(s)*360/Sectors
+		}
+	}
+	a.emit(r)
+	a.samples, a.pulses, a.sector = 0, 0, [Sectors]uint32{}
+}
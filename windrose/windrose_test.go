@@ -0,0 +1,78 @@
+package windrose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pointer2null/weather/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectorAndBand(t *testing.T) {
+	require.Equal(t, 0, Sector(0))
+	require.Equal(t, 0, Sector(355))
+	require.Equal(t, 1, Sector(22.5))
+	require.Equal(t, 15, Sector(337.5))
+	require.Equal(t, 12, Sector(-90))
+
+	require.Equal(t, -1, Band(0.5))
+	require.Equal(t, 0, Band(1))
+	require.Equal(t, 2, Band(12.9))
+	require.Equal(t, 5, Band(60))
+	require.Equal(t, "4-8 mph", BandName(1, func(v float64) float64 { return v }, "mph"))
+	require.Equal(t, "40+ km/h", BandName(5, func(v float64) float64 { return v * 1.609344 }, "km/h"))
+}
+
+func TestRose(t *testing.T) {
+	r := New(time.Time{}, time.Time{})
+	_, ok := r.Prevailing()
+	require.False(t, ok)
+
+	r.Add(225, 10, 30)
+	r.Add(230, 20, 10)
+	r.Add(90, 3, 20)
+	r.Add(0, 0.2, 40)
+
+	require.Equal(t, 100.0, r.Total)
+	require.Equal(t, 40.0, r.CalmPercent())
+	require.Equal(t, 30.0, r.Percent(10, 2))
+	require.Equal(t, 40.0, r.SectorPercent(10))
+	p, ok := r.Prevailing()
+	require.True(t, ok)
+	require.Equal(t, "SW", Points[p])
+}
+
+func TestAverager(t *testing.T) {
+	var got []Reading
+	a := NewAverager(func(r Reading) { got = append(got, r) })
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 60*env.WindSamplesPerSecond; i++ {
+		// turning mostly in the west, with a gusty west south west every
+		// second, and resting in the north at the end
+		dir, pulses := 270.0, uint32(1)
+		switch {
+		case i%4 == 0:
+			dir = 247.5
+		case i >= 200:
+			dir, pulses = 0, 0
+		}
+		a.Add(start.Add(time.Duration(i)*time.Second/env.WindSamplesPerSecond), pulses, dir)
+	}
+	require.Empty(t, got)
+
+	// the next minute flushes this one
+	a.Add(start.Add(time.Minute), 0, 0)
+	require.Len(t, got, 1)
+	require.Equal(t, start, got[0].Time)
+	require.Equal(t, 60.0, got[0].Seconds)
+	// 200 pulses, and 10 more from the west south west after the cups stop
+	require.InDelta(t, 210.0/60*env.MphPerTick, got[0].Speed, 1e-9)
+	require.Equal(t, 270.0, got[0].Direction)
+
+	// a calm minute keeps the last direction
+	a.Flush()
+	require.Len(t, got, 2)
+	require.Zero(t, got[1].Speed)
+	require.Equal(t, 0.0, got[1].Direction)
+	require.Equal(t, 0.25, got[1].Seconds)
+}